@@ -0,0 +1,57 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+// DefaultBaseImageFieldPath is the field of the InfraBuild set to the base image by default.
+const DefaultBaseImageFieldPath = "spec.sourceImage"
+
+// BaseImageSource builds an image from the image exported by another Build.
+//
+// The Build controller waits for the base Build to be ready, then sets the artifact
+// of the base Build in the InfraBuild before the infrastructure provider creates the machine.
+type BaseImageSource struct {
+	// Name is the name of the base Build, in the namespace of the Build.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// FieldPath is the field of the InfraBuild set to the artifact of the base Build,
+	// defaults to spec.sourceImage.
+	// +optional
+	// +kubebuilder:default="spec.sourceImage"
+	FieldPath string `json:"fieldPath,omitempty"`
+
+	// Rebuild runs the Build again when the base Build exports a new image, see RebuildAnnotation.
+	// +optional
+	Rebuild bool `json:"rebuild,omitempty"`
+}
+
+// GetFieldPath returns the field of the InfraBuild set to the base image.
+func (s *BaseImageSource) GetFieldPath() string {
+	if s.FieldPath == "" {
+		return DefaultBaseImageFieldPath
+	}
+	return s.FieldPath
+}
+
+// LineageEntry is an image the image of a Build is built from.
+type LineageEntry struct {
+	// BuildName is the name of the Build which exported the image.
+	BuildName string `json:"buildName"`
+
+	// Artifact is the location of the image.
+	Artifact string `json:"artifact"`
+}
@@ -51,8 +51,44 @@ type BuildSpec struct {
 	// +optional
 	Provisioners []ProvisionerSpec `json:"provisioners,omitempty"`
 
+	// ProvisionerRuntime overrides the configuration of the Jobs running the built-in provisioners
+	// of the Build, including its cleanup stage.
+	// +optional
+	ProvisionerRuntime *ProvisionerRuntimeConfig `json:"provisionerRuntime,omitempty"`
+
+	// Validations is a list of assertions to run on the infrastructure machine
+	// once the provisioners are done, the image is not exported if one of them fails.
+	// +optional
+	Validations []ValidationSpec `json:"validations,omitempty"`
+
+	// Cleanup is the cleanup stage which generalizes the infrastructure machine before the image is exported.
+	// All the built-in generalization actions run when it is not set.
+	// +optional
+	Cleanup *CleanupSpec `json:"cleanup,omitempty"`
+
+	// BaseImageFrom builds the image from the image exported by another Build.
+	// +optional
+	BaseImageFrom *BaseImageSource `json:"baseImageFrom,omitempty"`
+
+	// Matrix fans the Build out into one Build per variant, the Build is ready once its
+	// variants are, see MatrixSpec.
+	// +optional
+	Matrix *MatrixSpec `json:"matrix,omitempty"`
+
+	// Notifications are the HTTP endpoints the lifecycle events of the Build are sent to.
+	// +optional
+	Notifications []NotificationSpec `json:"notifications,omitempty"`
+
+	// ArtifactRetention is the retention policy of the ImageArtifacts recording the images
+	// exported by the Build, see ImageArtifact.
+	// +optional
+	ArtifactRetention *RetentionPolicy `json:"artifactRetention,omitempty"`
+
 	// DeleteCascade is a flag to specify whether the built image(s)
 	// going to be cleaned up when the build is deleted.
+	// When set, the Build controller asks the infrastructure provider to delete the exported image
+	// and waits for it before the Build is removed, see DeleteImageAnnotation. Otherwise the image
+	// is preserved and remains recorded in its ImageArtifact.
 	// +optional
 	DeleteCascade bool `json:"deleteCascade,omitempty"`
 }
@@ -68,7 +104,29 @@ type ConnectorSpec struct {
 	// - username
 	// - password and/or privateKey
 	// - host
+	// - port, optional, defaults to 22
+	// - hostKey, optional, the public host key of the machine in the authorized_keys format
 	Credentials *corev1.LocalObjectReference `json:"credentials,omitempty"`
+
+	// Deadline is how long the connection to the machine may fail before the Build fails,
+	// counted from the first failed attempt. Defaults to 10 minutes.
+	// +optional
+	Deadline *metav1.Duration `json:"deadline,omitempty"`
+}
+
+// ConnectionStatus reports the attempts to connect to the infrastructure machine.
+type ConnectionStatus struct {
+	// Destination is the user, host and port the connection is attempted to, e.g. forge@10.0.0.1:22.
+	// +optional
+	Destination string `json:"destination,omitempty"`
+
+	// FailedAttempts is the number of failed attempts since the last successful connection.
+	// +optional
+	FailedAttempts int32 `json:"failedAttempts,omitempty"`
+
+	// FirstFailureTime is the time of the first of the failed attempts, see ConnectorSpec.Deadline.
+	// +optional
+	FirstFailureTime *metav1.Time `json:"firstFailureTime,omitempty"`
 }
 
 // ProvisionerSpec defines the provisioner to run on the infrastructure machine
@@ -80,7 +138,7 @@ type ProvisionerSpec struct {
 	// Type is the type of provisioner to run on the infrastructure machine
 	// e.g., type: "builtin" or type: "external"
 	// +kubebuilder:validation:Required
-	// +kubebuilder:validation:Enum=built-in/shell;external
+	// +kubebuilder:validation:Enum=built-in/shell;built-in/reboot;external
 	Type ProvisionerType `json:"type"`
 
 	// AllowFail is a flag to allow the provisioner to fail
@@ -98,13 +156,43 @@ type ProvisionerSpec struct {
 	// Ref is a reference to the provisioner object which contains the types of provisioners to run.
 	Ref *corev1.ObjectReference `json:"ref,omitempty"`
 
+	// ExpectDisconnect tells the provisioner that the script may drop the SSH connection,
+	// e.g. because it reboots the machine. A dropped connection is then not treated as a failure
+	// and the provisioner waits for the machine to come back before completing.
+	// It is always true for built-in/reboot provisioners.
+	// +optional
+	ExpectDisconnect bool `json:"expectDisconnect,omitempty"`
+
+	// VerifyBootID makes the provisioner check that the machine boot ID changed
+	// after a disconnect, to make sure the machine actually rebooted.
+	// +optional
+	VerifyBootID bool `json:"verifyBootID,omitempty"`
+
+	// ReconnectTimeout is how long to wait for the machine to go down and come back
+	// after a disconnect. Defaults to 10 minutes.
+	// +optional
+	ReconnectTimeout *metav1.Duration `json:"reconnectTimeout,omitempty"`
+
 	// Retries is the number of retries for the provisioner
-	// before marking it as failed
+	// before marking it as failed, the ExitCodePolicy tells which failures are retried.
 	// +optional
 	// +kube:validation:Minimum=0
 	// +kube:validation:default=1
 	Retries *int32 `json:"retries,omitempty"`
 
+	// ExitCodePolicy tells which exit codes of the script succeed, are retried or fail the provisioner.
+	// By default, all the failures are retried.
+	// +optional
+	ExitCodePolicy *ExitCodePolicy `json:"exitCodePolicy,omitempty"`
+
+	// RetryBackoff is how long to wait before running a failed provisioner again. Defaults to 10 seconds.
+	// +optional
+	RetryBackoff *metav1.Duration `json:"retryBackoff,omitempty"`
+
+	// Runtime overrides the configuration of the Job running the provisioner, on top of the one of the Build.
+	// +optional
+	Runtime *ProvisionerRuntimeConfig `json:"runtime,omitempty"`
+
 	// Status is the status of the provisioner
 	// +optional
 	// +kubebuilder:validation:Enum=Pending;Running;Completed;Failed;Unknown
@@ -118,12 +206,30 @@ type ProvisionerSpec struct {
 	// FailureMessage is the message of the provisioner failure
 	// +optional
 	FailureMessage *string `json:"failureMessage,omitempty"`
+
+	// ExitCode is the exit code of the script in the last run of the provisioner,
+	// it is unset if the script did not exit, e.g. because the machine could not be reached.
+	// +optional
+	ExitCode *int32 `json:"exitCode,omitempty"`
+
+	// Result is the result of the last run of the provisioner.
+	// +optional
+	Result *ProvisionerResult `json:"result,omitempty"`
+
+	// Retried is the number of times the provisioner ran again after a failure.
+	// +optional
+	Retried int32 `json:"retried,omitempty"`
+
+	// RetryTime is when the failed provisioner runs again.
+	// +optional
+	RetryTime *metav1.Time `json:"retryTime,omitempty"`
 }
 
 type ProvisionerType string
 
 const (
 	ProvisionerTypeShell    ProvisionerType = "built-in/shell"
+	ProvisionerTypeReboot   ProvisionerType = "built-in/reboot"
 	ProvisionerTypeExternal ProvisionerType = "external"
 )
 
@@ -177,11 +283,53 @@ type BuildStatus struct {
 	//+optional
 	Connected bool `json:"connected,omitempty"`
 
+	// Connection reports the attempts to connect to the underlying infrastructure machine.
+	//+optional
+	Connection *ConnectionStatus `json:"connection,omitempty"`
+
 	// ProvisionersReady describes the state of provisioners for the Build
 	// once all provisioners have finished successfully, this will be true
 	//+optional
 	ProvisionersReady bool `json:"provisionersReady,omitempty"`
 
+	// Validated describes if all the validations passed on the infrastructure machine.
+	//+optional
+	Validated bool `json:"validated,omitempty"`
+
+	// Validation is the report of the validations run on the infrastructure machine.
+	//+optional
+	Validation *ValidationReport `json:"validation,omitempty"`
+
+	// Outputs are the outputs of the completed provisioners, an output overrides the ones
+	// of the previous provisioners with the same key.
+	//+optional
+	Outputs map[string]string `json:"outputs,omitempty"`
+
+	// CleanedUp describes if the cleanup stage finished on the infrastructure machine.
+	//+optional
+	CleanedUp bool `json:"cleanedUp,omitempty"`
+
+	// Cleanup is the built-in shell provisioner running the cleanup stage.
+	//+optional
+	Cleanup *ProvisionerSpec `json:"cleanup,omitempty"`
+
+	// Artifact is the location of the image exported by the infrastructure provider.
+	//+optional
+	Artifact string `json:"artifact,omitempty"`
+
+	// ImageArtifact is the name of the ImageArtifact recording the exported image.
+	//+optional
+	ImageArtifact string `json:"imageArtifact,omitempty"`
+
+	// Lineage is the chain of the images the image is built from, from the root image
+	// to the base image, see BuildSpec.BaseImageFrom.
+	//+optional
+	Lineage []LineageEntry `json:"lineage,omitempty"`
+
+	// Variants is the status of the variants of a matrix Build.
+	//+optional
+	Variants []MatrixVariantStatus `json:"variants,omitempty"`
+
 	// Build Phase which is used to track the state of the build process
 	// E.g. Pending, Building, Terminating, Failed etc.
 	//+optional
@@ -198,6 +346,7 @@ type BuildStatus struct {
 //+kubebuilder:printcolumn:name="Infrastructure",type="string",JSONPath=".spec.infrastructureRef.kind",description="Kind of infrastructure"
 //+kubebuilder:printcolumn:name="Connection",type="string",JSONPath=".status.connected",description="Connection"
 //+kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase",description="Build Phase"
+//+kubebuilder:printcolumn:name="Artifact",type="string",JSONPath=".status.artifact",description="Exported image",priority=1
 
 // Build is the Schema for the builds API
 type Build struct {
@@ -0,0 +1,59 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+// CleanupAction is a generalization action run on the infrastructure machine before the image is exported.
+// +kubebuilder:validation:Enum=MachineID;SSHHostKeys;CloudInit;ShellHistory;Logs;TmpFiles;PackageCache
+type CleanupAction string
+
+const (
+	// CleanupActionMachineID empties /etc/machine-id so that every machine gets a new one on first boot.
+	CleanupActionMachineID CleanupAction = "MachineID"
+	// CleanupActionSSHHostKeys removes the SSH host keys so that every machine generates its own.
+	CleanupActionSSHHostKeys CleanupAction = "SSHHostKeys"
+	// CleanupActionCloudInit removes the cloud-init state so that it runs again on first boot.
+	CleanupActionCloudInit CleanupAction = "CloudInit"
+	// CleanupActionShellHistory removes the shell history of all users.
+	CleanupActionShellHistory CleanupAction = "ShellHistory"
+	// CleanupActionLogs removes rotated logs and truncates the others.
+	CleanupActionLogs CleanupAction = "Logs"
+	// CleanupActionTmpFiles removes the content of the temporary directories.
+	CleanupActionTmpFiles CleanupAction = "TmpFiles"
+	// CleanupActionPackageCache removes the package manager caches.
+	CleanupActionPackageCache CleanupAction = "PackageCache"
+)
+
+// CleanupSpec defines the cleanup stage which generalizes the infrastructure machine
+// right before the image is exported.
+type CleanupSpec struct {
+	// Disabled skips the cleanup stage.
+	// +optional
+	Disabled bool `json:"disabled,omitempty"`
+
+	// Actions is the list of generalization actions to run.
+	// Defaults to all the built-in actions.
+	// +optional
+	Actions []CleanupAction `json:"actions,omitempty"`
+
+	// Skip is a list of generalization actions not to run.
+	// +optional
+	Skip []CleanupAction `json:"skip,omitempty"`
+
+	// Run is an additional script to run after the generalization actions.
+	// +optional
+	Run *string `json:"run,omitempty"`
+}
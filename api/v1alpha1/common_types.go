@@ -39,6 +39,61 @@ const (
 	// on the reconciled object.
 	PausedAnnotation = "forge.build/paused"
 
+	// CancelAnnotation is an annotation that can be applied to a Build to stop it.
+	//
+	// The Build controller fails the Build, stops its running provisioners and does not run its phases anymore.
+	CancelAnnotation = "forge.build/cancel"
+
+	// RetryAnnotation is an annotation that can be applied to a failed or canceled Build to run it again.
+	//
+	// The Build controller clears the Build failure, resets the failed provisioners, validations and cleanup
+	// so that they run again, and removes the annotation.
+	RetryAnnotation = "forge.build/retry"
+
+	// RebuildAnnotation is an annotation that can be applied to a Build to run it again on a new machine.
+	//
+	// The Build controller keeps a copy of the InfraBuild in the annotation, deletes the InfraBuild and
+	// creates it again from the copy, resets the Build so that all its steps run again, and removes the annotation.
+	RebuildAnnotation = "forge.build/rebuild"
+
+	// ExportAnnotation is an annotation set by the Build controller on the InfraBuild once the machine
+	// has been provisioned, validated and cleaned up.
+	//
+	// Infrastructure providers must export the image of the machine when it is set, and then
+	// report it by setting status.ready on the InfraBuild.
+	ExportAnnotation = "forge.build/export"
+
+	// DeleteImageAnnotation is an annotation set by the Build controller on the InfraBuild when a Build
+	// with DeleteCascade is deleted.
+	//
+	// Infrastructure providers must delete the exported image when it is set, and then report it by
+	// setting status.imageDeleted on the InfraBuild. They must not delete the exported image when the
+	// InfraBuild is deleted without it.
+	DeleteImageAnnotation = "forge.build/delete-image"
+
+	// SourceCommitAnnotation is an annotation that can be applied to the ConfigMap referenced by
+	// runConfigMapRef or the object referenced by ref of a provisioner to record the commit SHA of
+	// the sources it was generated from.
+	//
+	// The Build controller records it in the ImageArtifact of the exported image.
+	SourceCommitAnnotation = "forge.build/source-commit"
+
+	// TraceParentAnnotation is an annotation set by the Build controller on Builds, and on the Pods of
+	// the provisioner Jobs, with the W3C trace context of the Build lifecycle when tracing is enabled.
+	//
+	// It is removed when the Build is retried or rebuilt so that each attempt is a separate trace.
+	TraceParentAnnotation = "forge.build/traceparent"
+
+	// ArchitectureLabel is the label set on ImageArtifacts with the architecture of the image.
+	ArchitectureLabel = "forge.build/architecture"
+
+	// RegionLabel is the label set on ImageArtifacts with the region of the image.
+	RegionLabel = "forge.build/region"
+
+	// DeprecatedLabel is the label set on ImageArtifacts to "true" when the image is deprecated
+	// and "false" otherwise, so that the images still in use can be selected.
+	DeprecatedLabel = "forge.build/deprecated"
+
 	// WatchLabel is a label othat can be applied to any Build API object.
 	//
 	// Controllers which allow for selective reconciliation may check this label and proceed
@@ -51,6 +106,10 @@ const (
 	// ProvisionerIDLabel is the label set on job linked to a Build and
 	// provisioners.
 	ProvisionerIDLabel = "forge.build/provisioner-uuid"
+
+	// ProvisionerStepLabel is the label set on the Jobs of the built-in provisioners with the index
+	// of the provisioner in the Build, or cleanup for the one running the cleanup stage.
+	ProvisionerStepLabel = "forge.build/provisioner-step"
 )
 
 const (
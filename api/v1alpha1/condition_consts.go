@@ -80,6 +80,28 @@ const (
 	// WaitingForConnectionReason (Severity=Info) documents a build waiting for the connection to the infrastructure.
 	WaitingForConnectionReason = "WaitingForConnection"
 
+	// MachineUnreachableReason (Severity=Warning) documents a build whose machine host cannot be resolved or routed to.
+	MachineUnreachableReason = "MachineUnreachable"
+
+	// ConnectionRefusedReason (Severity=Warning) documents a build whose machine refuses the connection on the SSH port.
+	ConnectionRefusedReason = "ConnectionRefused"
+
+	// ConnectionTimeoutReason (Severity=Warning) documents a build whose machine does not answer in time.
+	ConnectionTimeoutReason = "ConnectionTimeout"
+
+	// AuthenticationFailedReason (Severity=Warning) documents a build whose machine rejects the credentials.
+	AuthenticationFailedReason = "AuthenticationFailed"
+
+	// HostKeyMismatchReason (Severity=Warning) documents a build whose machine host key is not the one of the credentials.
+	HostKeyMismatchReason = "HostKeyMismatch"
+
+	// SSHProtocolErrorReason (Severity=Warning) documents a build whose machine does not complete the SSH handshake,
+	// e.g. another server listens on the SSH port.
+	SSHProtocolErrorReason = "SSHProtocolError"
+
+	// ConnectionFailedReason (Severity=Warning) documents a build which failed to connect to its machine for another reason.
+	ConnectionFailedReason = "ConnectionFailed"
+
 	// MachineReadyCondition reports the ready condition from the Machine object that is used as the builder machine.
 	MachineReadyCondition clusterv1.ConditionType = "MachineReady"
 
@@ -102,6 +124,52 @@ const (
 	ProvisionersReadyCondition clusterv1.ConditionType = "ProvisionersReady"
 )
 
+const (
+	// ValidatedCondition reports if the validations defined for the build passed on the machine.
+	ValidatedCondition clusterv1.ConditionType = "Validated"
+
+	// WaitingForValidationsReason (Severity=Info) documents a build waiting for the validations to run.
+	WaitingForValidationsReason = "WaitingForValidations"
+
+	// ValidationFailedReason (Severity=Error) documents a build with at least one failed validation.
+	ValidationFailedReason = "ValidationFailed"
+)
+
+const (
+	// CleanedUpCondition reports if the cleanup stage finished on the machine.
+	CleanedUpCondition clusterv1.ConditionType = "CleanedUp"
+
+	// WaitingForCleanupReason (Severity=Info) documents a build waiting for the cleanup stage to finish.
+	WaitingForCleanupReason = "WaitingForCleanup"
+
+	// CleanupFailedReason (Severity=Error) documents a build whose cleanup stage failed.
+	CleanupFailedReason = "CleanupFailed"
+)
+
+const (
+	// BaseImageReadyCondition reports if the image of the base Build is set in the InfraBuild, see BuildSpec.BaseImageFrom.
+	BaseImageReadyCondition clusterv1.ConditionType = "BaseImageReady"
+
+	// WaitingForBaseImageReason (Severity=Info) documents a Build waiting for its base Build to export its image.
+	WaitingForBaseImageReason = "WaitingForBaseImage"
+
+	// BaseImageFailedReason (Severity=Warning) documents a Build whose base Build failed, the Build
+	// waits for the base Build to be retried.
+	BaseImageFailedReason = "BaseImageFailed"
+)
+
+const (
+	// VariantsReadyCondition reports if enough variants of a matrix Build succeeded, see MatrixSpec.Quorum.
+	// The variants of each axis are reported by a condition of their own, see MatrixAxisReadyCondition.
+	VariantsReadyCondition clusterv1.ConditionType = "VariantsReady"
+
+	// WaitingForVariantsReason (Severity=Info) documents a matrix Build waiting for its variants to finish.
+	WaitingForVariantsReason = "WaitingForVariants"
+
+	// VariantsFailedReason (Severity=Error) documents a matrix Build with failed variants.
+	VariantsFailedReason = "VariantsFailed"
+)
+
 // Conditions and condition Reasons for the Machine object.
 
 const (
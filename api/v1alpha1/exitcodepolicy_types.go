@@ -0,0 +1,90 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"slices"
+	"time"
+)
+
+// DefaultProvisionerRetryBackoff is how long to wait before running a failed provisioner again.
+const DefaultProvisionerRetryBackoff = 10 * time.Second
+
+// ExitCodeAction is what happens to a provisioner when its script exits with an exit code.
+// +kubebuilder:validation:Enum=Succeed;Retry;Fail
+type ExitCodeAction string
+
+const (
+	// ExitCodeActionSucceed completes the provisioner.
+	ExitCodeActionSucceed ExitCodeAction = "Succeed"
+	// ExitCodeActionRetry runs the provisioner again, up to Retries times, before failing it.
+	ExitCodeActionRetry ExitCodeAction = "Retry"
+	// ExitCodeActionFail fails the provisioner without running it again.
+	ExitCodeActionFail ExitCodeAction = "Fail"
+)
+
+// ExitCodePolicy tells which exit codes of the script of a provisioner are transient failures,
+// e.g. an unreachable package mirror, to be retried, and which ones are script errors failing fast.
+// Runs which did not exit, e.g. because the machine could not be reached, are always retried.
+type ExitCodePolicy struct {
+	// Success are the exit codes meaning the script succeeded, besides 0.
+	// +optional
+	Success []int32 `json:"success,omitempty"`
+
+	// Retry are the exit codes of transient failures, the provisioner runs again.
+	// +optional
+	Retry []int32 `json:"retry,omitempty"`
+
+	// Fail are the exit codes of script errors, the provisioner fails without running again.
+	// +optional
+	Fail []int32 `json:"fail,omitempty"`
+
+	// Default is the action for the other non-zero exit codes. Defaults to Retry.
+	// +optional
+	Default ExitCodeAction `json:"default,omitempty"`
+}
+
+// Action returns the action for a run of the provisioner, exitCode is nil if the script did not exit.
+// A nil policy retries all the failed runs.
+func (p *ExitCodePolicy) Action(exitCode *int32) ExitCodeAction {
+	switch {
+	case exitCode == nil:
+		return ExitCodeActionRetry
+	case *exitCode == 0:
+		return ExitCodeActionSucceed
+	case p == nil:
+		return ExitCodeActionRetry
+	case slices.Contains(p.Success, *exitCode):
+		return ExitCodeActionSucceed
+	case slices.Contains(p.Retry, *exitCode):
+		return ExitCodeActionRetry
+	case slices.Contains(p.Fail, *exitCode):
+		return ExitCodeActionFail
+	case p.Default != "":
+		return p.Default
+	default:
+		return ExitCodeActionRetry
+	}
+}
+
+// GetRetryBackoff returns how long to wait before running the failed provisioner again.
+func (p *ProvisionerSpec) GetRetryBackoff() time.Duration {
+	if p.RetryBackoff == nil {
+		return DefaultProvisionerRetryBackoff
+	}
+	return p.RetryBackoff.Duration
+}
@@ -0,0 +1,148 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ImageArtifactSpec defines an image exported by a Build.
+type ImageArtifactSpec struct {
+	// BuildName is the name of the Build which exported the image.
+	// The ImageArtifact is not deleted with the Build.
+	// +kubebuilder:validation:MinLength=1
+	BuildName string `json:"buildName"`
+
+	// Provider is the name of the infrastructure provider which exported the image, e.g. static.
+	Provider string `json:"provider"`
+
+	// InfrastructureRef is the InfraBuild which exported the image.
+	InfrastructureRef corev1.ObjectReference `json:"infrastructureRef"`
+
+	// ImageID is the location of the image, as reported by the InfraBuild in status.artifact.
+	// +kubebuilder:validation:MinLength=1
+	ImageID string `json:"imageID"`
+
+	// Region is the region the image is available in.
+	// +optional
+	Region string `json:"region,omitempty"`
+
+	// Architecture is the CPU architecture of the image, e.g. amd64.
+	// +optional
+	Architecture string `json:"architecture,omitempty"`
+
+	// Checksum is the checksum of the image, e.g. sha256:<digest>.
+	// +optional
+	Checksum string `json:"checksum,omitempty"`
+
+	// BuildSpecHash is the hash of the spec of the Build, the images exported from the same spec have the same hash.
+	BuildSpecHash string `json:"buildSpecHash"`
+
+	// Provisioners are the provisioners which ran on the machine of the image.
+	// +optional
+	Provisioners []ProvisionerSource `json:"provisioners,omitempty"`
+
+	// Outputs are the outputs of the provisioners, e.g. the kernel version of the image, see BuildStatus.Outputs.
+	// +optional
+	Outputs map[string]string `json:"outputs,omitempty"`
+
+	// Lineage is the chain of the images the image is built from, see BuildSpec.BaseImageFrom.
+	// +optional
+	Lineage []LineageEntry `json:"lineage,omitempty"`
+
+	// Deprecated marks the image as not to be used anymore, e.g. because it has been replaced.
+	// +optional
+	Deprecated bool `json:"deprecated,omitempty"`
+
+	// Retention is the policy deleting the ImageArtifact.
+	// +optional
+	Retention *RetentionPolicy `json:"retention,omitempty"`
+}
+
+// ProvisionerSource records the sources of a provisioner which ran on the machine of an image.
+type ProvisionerSource struct {
+	// Type is the type of the provisioner.
+	Type ProvisionerType `json:"type"`
+
+	// Source is where the provisioner comes from: the ConfigMap of its script, the
+	// kind and name of its object, or inline.
+	// +optional
+	Source string `json:"source,omitempty"`
+
+	// Commit is the commit SHA of the sources of the provisioner, see SourceCommitAnnotation.
+	// +optional
+	Commit string `json:"commit,omitempty"`
+}
+
+// RetentionPolicy defines when ImageArtifacts are deleted.
+// Deleting an ImageArtifact only removes it from the catalog, the image itself is not deleted.
+type RetentionPolicy struct {
+	// KeepLast is the number of the most recent ImageArtifacts of the same Build to keep,
+	// the older ones are deleted.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	KeepLast *int32 `json:"keepLast,omitempty"`
+
+	// MaxAge is how long the ImageArtifact is kept after it has been created.
+	// +optional
+	MaxAge *metav1.Duration `json:"maxAge,omitempty"`
+}
+
+// ImageArtifactStatus defines the observed state of ImageArtifact.
+type ImageArtifactStatus struct {
+	// ExpirationTime is the time the ImageArtifact is deleted by its retention policy, see RetentionPolicy.MaxAge.
+	// +optional
+	ExpirationTime *metav1.Time `json:"expirationTime,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:path=imageartifacts,scope=Namespaced,categories=forge,singular=imageartifact
+//+kubebuilder:printcolumn:name="Build",type="string",JSONPath=".spec.buildName",description="Build which exported the image"
+//+kubebuilder:printcolumn:name="Provider",type="string",JSONPath=".spec.provider",description="Infrastructure provider"
+//+kubebuilder:printcolumn:name="Image",type="string",JSONPath=".spec.imageID",description="Exported image"
+//+kubebuilder:printcolumn:name="Architecture",type="string",JSONPath=".spec.architecture",description="Architecture of the image",priority=1
+//+kubebuilder:printcolumn:name="Region",type="string",JSONPath=".spec.region",description="Region of the image",priority=1
+//+kubebuilder:printcolumn:name="Deprecated",type="boolean",JSONPath=".spec.deprecated",description="Deprecated image"
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// ImageArtifact is the Schema for the imageartifacts API, it records an image exported by a Build.
+//
+// The latest image of a Build still in use can be queried with the labels set by the controllers:
+//
+//	kubectl get imageartifacts -l forge.build/build-name=ubuntu,forge.build/deprecated=false --sort-by=.metadata.creationTimestamp
+type ImageArtifact struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ImageArtifactSpec   `json:"spec,omitempty"`
+	Status ImageArtifactStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ImageArtifactList contains a list of ImageArtifact
+type ImageArtifactList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ImageArtifact `json:"items"`
+}
+
+func init() {
+	objectTypes = append(objectTypes, &ImageArtifact{}, &ImageArtifactList{})
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+	"strings"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+const (
+	// MatrixParentLabel is the label set on the Builds of the variants of a matrix Build,
+	// its value is the name of the matrix Build.
+	MatrixParentLabel = "forge.build/matrix-parent"
+
+	// MatrixVariantAnnotation is the annotation set on the Builds of the variants of a matrix Build,
+	// its value is the name of the variant, e.g. "arch=amd64,region=eu-west-1".
+	MatrixVariantAnnotation = "forge.build/matrix-variant"
+)
+
+// MatrixSpec fans a Build out into one Build per combination of the values of its axes.
+//
+// The Build of a variant is a copy of the matrix Build, with its own copy of the InfraBuild
+// referenced by spec.infrastructureRef, or its own InfraBuild created from it when its kind is a
+// template, e.g. FooBuildTemplate. The ${matrix.<axis>} placeholders found in the strings
+// of the Build spec and of the InfraBuild spec are replaced by the values of the variant.
+type MatrixSpec struct {
+	// Axes are the dimensions of the matrix, e.g. the architecture, the region or the base image.
+	// +kubebuilder:validation:MinItems=1
+	Axes []MatrixAxis `json:"axes"`
+
+	// Quorum is the number of variants which must succeed for the Build to be ready,
+	// it defaults to all of them.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	Quorum *int32 `json:"quorum,omitempty"`
+}
+
+// MatrixAxis is a dimension of a matrix Build.
+type MatrixAxis struct {
+	// Name is the name of the axis, used in the ${matrix.<name>} placeholders.
+	// +kubebuilder:validation:Pattern=`^[a-z][a-zA-Z0-9]*$`
+	Name string `json:"name"`
+
+	// Values are the values of the axis.
+	// +kubebuilder:validation:MinItems=1
+	Values []string `json:"values"`
+}
+
+// MatrixVariant is a combination of the values of the axes of a matrix Build, indexed by axis name.
+type MatrixVariant map[string]string
+
+// Variants returns the combinations of the values of the axes, the last axis varying the fastest.
+func (m *MatrixSpec) Variants() []MatrixVariant {
+	variants := []MatrixVariant{{}}
+	for _, axis := range m.Axes {
+		next := make([]MatrixVariant, 0, len(variants)*len(axis.Values))
+		for _, v := range variants {
+			for _, value := range axis.Values {
+				variant := make(MatrixVariant, len(v)+1)
+				for k, val := range v {
+					variant[k] = val
+				}
+				variant[axis.Name] = value
+				next = append(next, variant)
+			}
+		}
+		variants = next
+	}
+	return variants
+}
+
+// RequiredSucceeded returns the number of variants which must succeed for the Build to be ready.
+func (m *MatrixSpec) RequiredSucceeded() int {
+	total := 1
+	for _, axis := range m.Axes {
+		total *= len(axis.Values)
+	}
+	if m.Quorum != nil && int(*m.Quorum) < total {
+		return int(*m.Quorum)
+	}
+	return total
+}
+
+// Name returns the name of the variant, the values ordered by the axes of the matrix.
+func (v MatrixVariant) Name(m *MatrixSpec) string {
+	parts := make([]string, 0, len(m.Axes))
+	for _, axis := range m.Axes {
+		parts = append(parts, fmt.Sprintf("%s=%s", axis.Name, v[axis.Name]))
+	}
+	return strings.Join(parts, ",")
+}
+
+// MatrixAxisReadyCondition returns the condition reporting the variants of the given axis,
+// e.g. MatrixArchReady for the arch axis.
+func MatrixAxisReadyCondition(axis string) clusterv1.ConditionType {
+	return clusterv1.ConditionType("Matrix" + strings.ToUpper(axis[:1]) + axis[1:] + "Ready")
+}
+
+// MatrixVariantStatus is the observed state of a variant of a matrix Build.
+type MatrixVariantStatus struct {
+	// Name identifies the variant, e.g. "arch=amd64,region=eu-west-1".
+	Name string `json:"name"`
+
+	// Values are the values of the variant indexed by axis name.
+	Values MatrixVariant `json:"values"`
+
+	// BuildName is the name of the Build of the variant.
+	BuildName string `json:"buildName"`
+
+	// Phase is the phase of the Build of the variant.
+	// +optional
+	Phase string `json:"phase,omitempty"`
+
+	// FailureMessage is the failure message of the Build of the variant.
+	// +optional
+	FailureMessage *string `json:"failureMessage,omitempty"`
+}
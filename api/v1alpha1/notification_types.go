@@ -0,0 +1,100 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import corev1 "k8s.io/api/core/v1"
+
+// NotificationEvent is a lifecycle event of a Build sent to the notification endpoints.
+// +kubebuilder:validation:Enum=PhaseChanged;ProvisionerFailed;ArtifactExported
+type NotificationEvent string
+
+const (
+	// NotificationPhaseChanged is sent when the phase of the Build changes.
+	NotificationPhaseChanged NotificationEvent = "PhaseChanged"
+	// NotificationProvisionerFailed is sent when a provisioner of the Build fails.
+	NotificationProvisionerFailed NotificationEvent = "ProvisionerFailed"
+	// NotificationArtifactExported is sent when the image of the Build is exported.
+	NotificationArtifactExported NotificationEvent = "ArtifactExported"
+)
+
+// DefaultNotificationMaxRetries is the number of times a notification is sent again when the endpoint fails.
+const DefaultNotificationMaxRetries = 5
+
+// NotificationSpec is an HTTP endpoint the lifecycle events of the Build are sent to,
+// as CloudEvents in the structured JSON format.
+type NotificationSpec struct {
+	// Name is the name of the endpoint, unique in the Build.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// URL is the http or https URL the events are POSTed to. It must be a public host, not a
+	// private address or a name internal to the cluster.
+	// +kubebuilder:validation:MinLength=1
+	URL string `json:"url"`
+
+	// SigningSecretRef is the key of a Secret in the namespace of the Build holding the HMAC key the events
+	// are signed with, the hex encoded HMAC-SHA256 of the body is sent in the X-Forge-Signature-256 header.
+	// +optional
+	SigningSecretRef *corev1.SecretKeySelector `json:"signingSecretRef,omitempty"`
+
+	// Events are the events sent to the endpoint, all of them when empty.
+	// +optional
+	Events []NotificationEvent `json:"events,omitempty"`
+
+	// Phases restricts the PhaseChanged events to the transitions to these phases, e.g. Completed and Failed.
+	// +optional
+	Phases []BuildPhase `json:"phases,omitempty"`
+
+	// MaxRetries is the number of times an event is sent again, with an exponential backoff,
+	// when the endpoint cannot be reached or does not answer with a 2xx status. Defaults to 5.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	MaxRetries *int32 `json:"maxRetries,omitempty"`
+}
+
+// Accepts returns true if the event is sent to the endpoint, phase is the new phase of PhaseChanged events.
+func (n *NotificationSpec) Accepts(event NotificationEvent, phase BuildPhase) bool {
+	if len(n.Events) > 0 && !containsNotificationEvent(n.Events, event) {
+		return false
+	}
+	if event != NotificationPhaseChanged || len(n.Phases) == 0 {
+		return true
+	}
+	for _, p := range n.Phases {
+		if p == phase {
+			return true
+		}
+	}
+	return false
+}
+
+// GetMaxRetries returns the number of times an event is sent again when the endpoint fails.
+func (n *NotificationSpec) GetMaxRetries() int {
+	if n.MaxRetries == nil {
+		return DefaultNotificationMaxRetries
+	}
+	return int(*n.MaxRetries)
+}
+
+func containsNotificationEvent(events []NotificationEvent, event NotificationEvent) bool {
+	for _, e := range events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,44 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ProvisionerResult is the result of the last run of a provisioner, reported by the provisioner Job.
+type ProvisionerResult struct {
+	// Duration is how long the provisioner ran.
+	// +optional
+	Duration *metav1.Duration `json:"duration,omitempty"`
+
+	// Stdout is the end of the standard output of the script.
+	// +optional
+	Stdout string `json:"stdout,omitempty"`
+
+	// Stderr is the end of the standard error of the script.
+	// +optional
+	Stderr string `json:"stderr,omitempty"`
+
+	// Outputs are the key/value pairs emitted by the script, later provisioners can consume them.
+	// +optional
+	Outputs map[string]string `json:"outputs,omitempty"`
+
+	// Artifacts are the files written by the provisioner, e.g. the archive of an exported machine.
+	// +optional
+	Artifacts []string `json:"artifacts,omitempty"`
+}
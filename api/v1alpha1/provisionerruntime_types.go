@@ -0,0 +1,106 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ProvisionerRuntimeConfig configures the Pods of the Jobs running the built-in provisioners.
+// The controller manager flags provide the defaults, which the Build and then each provisioner
+// can override field by field, except the image and the security contexts when the Jobs run in the
+// shared namespace of the controller manager.
+type ProvisionerRuntimeConfig struct {
+	// Image is the image of the provisioner Jobs, e.g. registry.example.com/forge/forge-provisioner-shell:v0.1.0
+	// to run them in air-gapped clusters.
+	// +optional
+	Image string `json:"image,omitempty"`
+
+	// ImagePullPolicy is the pull policy of the image. Defaults to IfNotPresent.
+	// +optional
+	// +kubebuilder:validation:Enum=Always;Never;IfNotPresent
+	ImagePullPolicy corev1.PullPolicy `json:"imagePullPolicy,omitempty"`
+
+	// ImagePullSecrets are the secrets used to pull the image, they must exist in the namespace of the Jobs.
+	// +optional
+	ImagePullSecrets []corev1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+
+	// Resources are the compute resources of the provisioner container.
+	// +optional
+	Resources *corev1.ResourceRequirements `json:"resources,omitempty"`
+
+	// NodeSelector constrains the nodes the Jobs are scheduled on.
+	// +optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
+	// Tolerations allow the Jobs to be scheduled on tainted nodes.
+	// +optional
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+
+	// PriorityClassName is the priority class of the Pods.
+	// +optional
+	PriorityClassName string `json:"priorityClassName,omitempty"`
+
+	// PodSecurityContext is the security context of the Pods.
+	// +optional
+	PodSecurityContext *corev1.PodSecurityContext `json:"podSecurityContext,omitempty"`
+
+	// SecurityContext is the security context of the provisioner container.
+	// +optional
+	SecurityContext *corev1.SecurityContext `json:"securityContext,omitempty"`
+}
+
+// Merge returns the configuration overridden by the fields set in override.
+func (c *ProvisionerRuntimeConfig) Merge(override *ProvisionerRuntimeConfig) *ProvisionerRuntimeConfig {
+	merged := &ProvisionerRuntimeConfig{}
+	if c != nil {
+		merged = c.DeepCopy()
+	}
+	if override == nil {
+		return merged
+	}
+	override = override.DeepCopy()
+
+	if override.Image != "" {
+		merged.Image = override.Image
+	}
+	if override.ImagePullPolicy != "" {
+		merged.ImagePullPolicy = override.ImagePullPolicy
+	}
+	if override.ImagePullSecrets != nil {
+		merged.ImagePullSecrets = override.ImagePullSecrets
+	}
+	if override.Resources != nil {
+		merged.Resources = override.Resources
+	}
+	if override.NodeSelector != nil {
+		merged.NodeSelector = override.NodeSelector
+	}
+	if override.Tolerations != nil {
+		merged.Tolerations = override.Tolerations
+	}
+	if override.PriorityClassName != "" {
+		merged.PriorityClassName = override.PriorityClassName
+	}
+	if override.PodSecurityContext != nil {
+		merged.PodSecurityContext = override.PodSecurityContext
+	}
+	if override.SecurityContext != nil {
+		merged.SecurityContext = override.SecurityContext
+	}
+	return merged
+}
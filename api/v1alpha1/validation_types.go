@@ -0,0 +1,96 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+// ValidationType is the kind of assertion run by a validation.
+type ValidationType string
+
+const (
+	// ValidationTypePackageInstalled checks that the package named by Target is installed.
+	ValidationTypePackageInstalled ValidationType = "PackageInstalled"
+	// ValidationTypeServiceEnabled checks that the systemd service named by Target is enabled.
+	ValidationTypeServiceEnabled ValidationType = "ServiceEnabled"
+	// ValidationTypeServiceRunning checks that the systemd service named by Target is active.
+	ValidationTypeServiceRunning ValidationType = "ServiceRunning"
+	// ValidationTypePortListening checks that a process listens on the TCP port given by Target.
+	ValidationTypePortListening ValidationType = "PortListening"
+	// ValidationTypeFileExists checks that the path given by Target exists.
+	ValidationTypeFileExists ValidationType = "FileExists"
+	// ValidationTypeCommand runs the command given by Run, the validation passes if it exits with 0.
+	ValidationTypeCommand ValidationType = "Command"
+)
+
+// ValidationSpec defines an assertion run on the infrastructure machine
+// once the provisioners are done and before the image is exported.
+type ValidationSpec struct {
+	// Name identifies the validation in the report.
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+
+	// Type is the kind of assertion to run.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Enum=PackageInstalled;ServiceEnabled;ServiceRunning;PortListening;FileExists;Command
+	Type ValidationType `json:"type"`
+
+	// Target is the object of the assertion: a package name, a service name,
+	// a port number or a file path depending on the Type.
+	// +optional
+	Target string `json:"target,omitempty"`
+
+	// Run is the command to run for Command validations.
+	// +optional
+	Run *string `json:"run,omitempty"`
+
+	// AllowFail is a flag to report the validation failure without failing the Build.
+	// +optional
+	AllowFail bool `json:"allowFail,omitempty"`
+}
+
+// ValidationResult is the outcome of a single validation.
+type ValidationResult struct {
+	// Name is the name of the validation.
+	Name string `json:"name"`
+
+	// Type is the kind of assertion that was run.
+	Type ValidationType `json:"type"`
+
+	// Passed is true if the assertion holds on the machine.
+	Passed bool `json:"passed"`
+
+	// Message holds the tail of the command output, or the error, when the validation failed.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// ValidationReport is the structured report of the validations run on the machine.
+type ValidationReport struct {
+	// Passed is the number of validations that passed.
+	Passed int32 `json:"passed"`
+
+	// Failed is the number of validations that failed.
+	Failed int32 `json:"failed"`
+
+	// Results holds the result of every validation, in the order they were run.
+	// +optional
+	Results []ValidationResult `json:"results,omitempty"`
+
+	// CompletionTime is the time the validations finished.
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	buildv1 "github.com/forge-build/forge/api/v1alpha1"
+)
+
+var cancelCmd = &cobra.Command{
+	Use:   "cancel NAME",
+	Short: "Cancel a Build",
+	Long: `Cancel a Build: its running provisioners are stopped and the Build fails.
+
+A canceled Build can run again with forgectl retry.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return annotateBuild(cmd, args[0], buildv1.CancelAnnotation, "canceled")
+	},
+}
+
+var retryCmd = &cobra.Command{
+	Use:   "retry NAME",
+	Short: "Retry a failed or canceled Build",
+	Long: `Retry a failed or canceled Build: the failure is cleared and the failed
+provisioners, validations and cleanup run again.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return annotateBuild(cmd, args[0], buildv1.RetryAnnotation, "retried")
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(cancelCmd, retryCmd)
+}
+
+// annotateBuild sets an annotation handled by the Build controller on a Build.
+func annotateBuild(cmd *cobra.Command, name, annotation, action string) error {
+	c, err := newClient()
+	if err != nil {
+		return err
+	}
+	build, err := getBuild(cmd.Context(), c, name)
+	if err != nil {
+		return err
+	}
+
+	patch := client.MergeFrom(build.DeepCopy())
+	annotations := build.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[annotation] = time.Now().UTC().Format(time.RFC3339)
+	build.SetAnnotations(annotations)
+	if err := c.Patch(cmd.Context(), build, patch); err != nil {
+		return errors.Wrapf(err, "failed to annotate Build %s", name)
+	}
+	fmt.Printf("Build %s %s\n", name, action)
+	return nil
+}
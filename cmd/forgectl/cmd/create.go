@@ -0,0 +1,143 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/forge-build/forge/internal/webhooks"
+)
+
+type createOptions struct {
+	file      string
+	variables []string
+	dryRun    bool
+}
+
+var createOpts = &createOptions{}
+
+var createCmd = &cobra.Command{
+	Use:   "create -f FILE",
+	Short: "Create a Build from a manifest or a template",
+	Long: `Create a Build, and the objects next to it, from a YAML manifest or template.
+
+Template variables are written ${NAME} or ${NAME:-default} and are set with --set, the
+variables not set are kept as is, e.g. for the scripts. A literal ${ is written $${.`,
+	Example: `  forgectl create -f build.yaml
+  forgectl create -f build-template.yaml --set IMAGE_NAME=ubuntu-2204`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		objs, err := loadObjects(createOpts.file, createOpts.variables)
+		if err != nil {
+			return err
+		}
+		if err := validateObjects(objs); err != nil {
+			return err
+		}
+
+		if createOpts.dryRun {
+			return printObjects(objs)
+		}
+
+		c, err := newClient()
+		if err != nil {
+			return err
+		}
+		for _, obj := range objs {
+			if err := c.Create(cmd.Context(), obj); err != nil {
+				return errors.Wrapf(err, "failed to create %s %s", obj.GetKind(), obj.GetName())
+			}
+			fmt.Printf("%s/%s created\n", obj.GetKind(), obj.GetName())
+		}
+		return nil
+	},
+}
+
+func init() {
+	createCmd.Flags().StringVarP(&createOpts.file, "filename", "f", "", "The manifest or template to create, - reads from stdin")
+	createCmd.Flags().StringArrayVar(&createOpts.variables, "set", nil, "Set a template variable, e.g. --set KEY=VALUE")
+	createCmd.Flags().BoolVar(&createOpts.dryRun, "dry-run", false, "Print the rendered objects instead of creating them")
+	rootCmd.AddCommand(createCmd)
+}
+
+// loadObjects reads and renders a manifest, and defaults the namespace of its objects.
+func loadObjects(path string, variables []string) ([]*unstructured.Unstructured, error) {
+	objs, err := renderObjects(path, variables)
+	if err != nil {
+		return nil, err
+	}
+
+	ns, err := currentNamespace()
+	if err != nil {
+		return nil, err
+	}
+	for _, obj := range objs {
+		if obj.GetNamespace() == "" {
+			obj.SetNamespace(ns)
+		}
+	}
+	return objs, nil
+}
+
+// renderObjects reads and renders a manifest, without a cluster.
+func renderObjects(path string, variables []string) ([]*unstructured.Unstructured, error) {
+	data, err := readManifest(path)
+	if err != nil {
+		return nil, err
+	}
+	vars, err := parseVariables(variables)
+	if err != nil {
+		return nil, err
+	}
+	objs, err := decodeObjects(renderTemplate(data, vars))
+	if err != nil {
+		return nil, err
+	}
+	if len(objs) == 0 {
+		return nil, errors.Errorf("no objects found in %s", path)
+	}
+	return objs, nil
+}
+
+// validateObjects validates the Builds of a manifest like the Build webhook does.
+func validateObjects(objs []*unstructured.Unstructured) error {
+	var errs []error
+	for _, obj := range objs {
+		if !isBuild(obj) {
+			continue
+		}
+		build, err := toBuild(obj)
+		if err == nil {
+			err = webhooks.ValidateBuild(nil, build)
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		for _, err := range errs {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		return errors.Errorf("%d invalid Build(s)", len(errs))
+	}
+	return nil
+}
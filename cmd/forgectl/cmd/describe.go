@@ -0,0 +1,183 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+
+	buildv1 "github.com/forge-build/forge/api/v1alpha1"
+)
+
+var describeCmd = &cobra.Command{
+	Use:     "describe NAME",
+	Short:   "Describe a Build, its provisioners and its conditions as a tree",
+	Example: `  forgectl describe ubuntu-2204`,
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c, err := newClient()
+		if err != nil {
+			return err
+		}
+		build, err := getBuild(cmd.Context(), c, args[0])
+		if err != nil {
+			return err
+		}
+		return buildTree(build).render(os.Stdout)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(describeCmd)
+}
+
+// buildTree returns the tree view of a Build.
+func buildTree(build *buildv1.Build) *treeNode {
+	root := &treeNode{label: fmt.Sprintf("Build %s/%s  %s", build.Namespace, build.Name, build.Status.Phase)}
+	if build.Status.FailureReason != nil {
+		root.add("Failure  %s: %s", ptrString(build.Status.FailureReason), ptrString(build.Status.FailureMessage))
+	}
+
+	infra := "<none>"
+	if ref := build.Spec.InfrastructureRef; ref != nil {
+		infra = ref.Kind + "/" + ref.Name
+	}
+	root.add("Infrastructure  %s  ready=%t", infra, build.Status.InfrastructureReady)
+
+	if from := build.Spec.BaseImageFrom; from != nil {
+		base := root.add("BaseImage  Build/%s  %s", from.Name, from.GetFieldPath())
+		for _, entry := range build.Status.Lineage {
+			base.add("%s  %s", entry.BuildName, entry.Artifact)
+		}
+	}
+
+	if matrix := build.Spec.Matrix; matrix != nil {
+		variants := root.add("Matrix  %d variants  quorum=%d", len(matrix.Variants()), matrix.RequiredSucceeded())
+		for _, v := range build.Status.Variants {
+			phase := v.Phase
+			if phase == "" {
+				phase = string(buildv1.BuildPhasePending)
+			}
+			node := variants.add("%s  %s  %s", v.Name, v.BuildName, phase)
+			if v.FailureMessage != nil && buildv1.BuildPhase(v.Phase) == buildv1.BuildPhaseFailed {
+				node.add("%s", *v.FailureMessage)
+			}
+		}
+	}
+
+	credentials := "<none>"
+	if build.Spec.Connector.Credentials != nil {
+		credentials = build.Spec.Connector.Credentials.Name
+	}
+	root.add("Connection  %s credentials=%s  connected=%t", build.Spec.Connector.Type, credentials, build.Status.Connected)
+
+	provisioners := root.add("Provisioners  %s  ready=%t", provisionersSummary(build), build.Status.ProvisionersReady)
+	for i := range build.Spec.Provisioners {
+		addProvisioner(provisioners, fmt.Sprintf("[%d]", i), &build.Spec.Provisioners[i])
+	}
+
+	if report := build.Status.Validation; report != nil {
+		validations := root.add("Validations  %d passed, %d failed", report.Passed, report.Failed)
+		for _, result := range report.Results {
+			status := "Passed"
+			if !result.Passed {
+				status = "Failed"
+			}
+			node := validations.add("%s  %s  %s", result.Name, result.Type, status)
+			if result.Message != "" && !result.Passed {
+				node.add("%s", result.Message)
+			}
+		}
+	} else if len(build.Spec.Validations) > 0 {
+		root.add("Validations  %d pending", len(build.Spec.Validations))
+	}
+
+	if build.Status.Cleanup != nil {
+		addProvisioner(root, "Cleanup", build.Status.Cleanup)
+	} else if build.Spec.Cleanup != nil && build.Spec.Cleanup.Disabled {
+		root.add("Cleanup  disabled")
+	}
+
+	if build.Status.Artifact != "" {
+		artifact := root.add("Artifact  %s", build.Status.Artifact)
+		if build.Status.ImageArtifact != "" {
+			artifact.add("ImageArtifact/%s", build.Status.ImageArtifact)
+		}
+	}
+
+	conditions := root.add("Conditions")
+	for _, c := range build.Status.Conditions {
+		label := fmt.Sprintf("%s  %s", c.Type, c.Status)
+		if c.Status != corev1.ConditionTrue {
+			if c.Severity != "" {
+				label += "  " + string(c.Severity)
+			}
+			if c.Reason != "" {
+				label += "  " + c.Reason
+			}
+		}
+		node := conditions.add("%s", label)
+		if c.Message != "" {
+			node.add("%s", c.Message)
+		}
+	}
+	return root
+}
+
+func addProvisioner(parent *treeNode, name string, p *buildv1.ProvisionerSpec) {
+	status := ptrString(p.Status)
+	if status == "" {
+		status = string(buildv1.ProvisionerStatusPending)
+	}
+	node := parent.add("%s %s  %s", name, p.Type, status)
+	if p.UUID != nil {
+		node.add("uuid  %s", *p.UUID)
+	}
+	if p.AllowFail {
+		node.add("allowFail  true")
+	}
+	if p.FailureReason != nil || p.FailureMessage != nil {
+		node.add("failure  %s: %s", ptrString(p.FailureReason), ptrString(p.FailureMessage))
+	}
+	if p.ExitCode != nil {
+		node.add("exitCode  %d", *p.ExitCode)
+	}
+	if p.Retried > 0 {
+		node.add("retried  %d", p.Retried)
+	}
+	if p.Result == nil {
+		return
+	}
+	if p.Result.Duration != nil {
+		node.add("duration  %s", p.Result.Duration.Duration)
+	}
+	keys := make([]string, 0, len(p.Result.Outputs))
+	for key := range p.Result.Outputs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		node.add("output  %s=%s", key, p.Result.Outputs[key])
+	}
+	for _, artifact := range p.Result.Artifacts {
+		node.add("artifact  %s", artifact)
+	}
+}
@@ -0,0 +1,237 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+
+	buildv1 "github.com/forge-build/forge/api/v1alpha1"
+	builderror "github.com/forge-build/forge/pkg/errors"
+)
+
+func testBuild() *buildv1.Build {
+	return &buildv1.Build{
+		ObjectMeta: metav1.ObjectMeta{Name: "ubuntu", Namespace: "default"},
+		Spec: buildv1.BuildSpec{
+			Connector:         buildv1.ConnectorSpec{Type: "ssh", Credentials: &corev1.LocalObjectReference{Name: "ubuntu-ssh"}},
+			InfrastructureRef: &corev1.ObjectReference{Kind: "AWSBuild", Name: "ubuntu"},
+			Provisioners: []buildv1.ProvisionerSpec{
+				{
+					Type:   buildv1.ProvisionerTypeShell,
+					UUID:   ptr.To("1234"),
+					Status: ptr.To(buildv1.ProvisionerStatusCompleted),
+				},
+				{
+					Type:           buildv1.ProvisionerTypeShell,
+					UUID:           ptr.To("5678"),
+					Status:         ptr.To(buildv1.ProvisionerStatusFailed),
+					FailureReason:  ptr.To("ProvisionerFailed"),
+					FailureMessage: ptr.To("exit status 1"),
+				},
+				{Type: buildv1.ProvisionerTypeReboot},
+			},
+		},
+		Status: buildv1.BuildStatus{
+			Phase:               string(buildv1.BuildPhaseFailed),
+			FailureReason:       ptr.To(builderror.ProvisionerFailedError),
+			FailureMessage:      ptr.To("provisioner 5678 failed"),
+			InfrastructureReady: true,
+			Connected:           true,
+			Conditions: clusterv1.Conditions{
+				{Type: buildv1.ReadyCondition, Status: corev1.ConditionFalse, Severity: clusterv1.ConditionSeverityError, Reason: "ProvisionerFailed", Message: "exit status 1"},
+				{Type: buildv1.InfrastructureReadyCondition, Status: corev1.ConditionTrue},
+			},
+		},
+	}
+}
+
+func TestBuildTree(t *testing.T) {
+	g := NewWithT(t)
+
+	out := &bytes.Buffer{}
+	g.Expect(buildTree(testBuild()).render(out)).To(Succeed())
+	g.Expect(out.String()).To(Equal(`Build default/ubuntu  Failed
+├─Failure  ProvisionerFailed: provisioner 5678 failed
+├─Infrastructure  AWSBuild/ubuntu  ready=true
+├─Connection  ssh credentials=ubuntu-ssh  connected=true
+├─Provisioners  1/3  ready=false
+│ ├─[0] built-in/shell  Completed
+│ │ └─uuid  1234
+│ ├─[1] built-in/shell  Failed
+│ │ ├─uuid  5678
+│ │ └─failure  ProvisionerFailed: exit status 1
+│ └─[2] built-in/reboot  Pending
+└─Conditions
+  ├─Ready  False  Error  ProvisionerFailed
+  │ └─exit status 1
+  └─InfrastructureReady  True
+`))
+}
+
+func TestBuildTreeProvisionerResult(t *testing.T) {
+	g := NewWithT(t)
+
+	root := &treeNode{label: "Provisioners"}
+	addProvisioner(root, "[0]", &buildv1.ProvisionerSpec{
+		Type:     buildv1.ProvisionerTypeShell,
+		Status:   ptr.To(buildv1.ProvisionerStatusCompleted),
+		ExitCode: ptr.To[int32](0),
+		Retried:  1,
+		Result: &buildv1.ProvisionerResult{
+			Duration:  &metav1.Duration{Duration: 90 * time.Second},
+			Outputs:   map[string]string{"lockfile": "8c1f", "kernel": "6.8.0-40-generic"},
+			Artifacts: []string{"/exports/ubuntu.tar.gz"},
+		},
+	})
+
+	out := &bytes.Buffer{}
+	g.Expect(root.render(out)).To(Succeed())
+	g.Expect(out.String()).To(Equal(`Provisioners
+└─[0] built-in/shell  Completed
+  ├─exitCode  0
+  ├─retried  1
+  ├─duration  1m30s
+  ├─output  kernel=6.8.0-40-generic
+  ├─output  lockfile=8c1f
+  └─artifact  /exports/ubuntu.tar.gz
+`))
+}
+
+func TestBuildTreeMatrix(t *testing.T) {
+	g := NewWithT(t)
+
+	build := &buildv1.Build{
+		ObjectMeta: metav1.ObjectMeta{Name: "ubuntu", Namespace: "default"},
+		Spec: buildv1.BuildSpec{
+			Connector:         buildv1.ConnectorSpec{Type: "ssh"},
+			InfrastructureRef: &corev1.ObjectReference{Kind: "AWSBuild", Name: "ubuntu"},
+			Matrix: &buildv1.MatrixSpec{
+				Axes: []buildv1.MatrixAxis{{Name: "arch", Values: []string{"amd64", "arm64"}}},
+			},
+		},
+		Status: buildv1.BuildStatus{
+			Phase: string(buildv1.BuildPhaseBuilding),
+			Variants: []buildv1.MatrixVariantStatus{
+				{Name: "arch=amd64", BuildName: "ubuntu-1", Phase: string(buildv1.BuildPhaseCompleted)},
+				{Name: "arch=arm64", BuildName: "ubuntu-2", Phase: string(buildv1.BuildPhaseFailed), FailureMessage: ptr.To("exit status 1")},
+			},
+		},
+	}
+
+	out := &bytes.Buffer{}
+	g.Expect(buildTree(build).render(out)).To(Succeed())
+	g.Expect(out.String()).To(ContainSubstring(`├─Matrix  2 variants  quorum=2
+│ ├─arch=amd64  ubuntu-1  Completed
+│ └─arch=arm64  ubuntu-2  Failed
+│   └─exit status 1
+`))
+}
+
+func TestBuildTreeBaseImage(t *testing.T) {
+	g := NewWithT(t)
+
+	build := &buildv1.Build{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"},
+		Spec: buildv1.BuildSpec{
+			Connector:         buildv1.ConnectorSpec{Type: "ssh"},
+			InfrastructureRef: &corev1.ObjectReference{Kind: "AWSBuild", Name: "app"},
+			BaseImageFrom:     &buildv1.BaseImageSource{Name: "hardened"},
+		},
+		Status: buildv1.BuildStatus{
+			Phase:    string(buildv1.BuildPhaseCompleted),
+			Artifact: "ami-3",
+			Lineage: []buildv1.LineageEntry{
+				{BuildName: "base", Artifact: "ami-1"},
+				{BuildName: "hardened", Artifact: "ami-2"},
+			},
+		},
+	}
+
+	out := &bytes.Buffer{}
+	g.Expect(buildTree(build).render(out)).To(Succeed())
+	g.Expect(out.String()).To(ContainSubstring(`├─BaseImage  Build/hardened  spec.sourceImage
+│ ├─base  ami-1
+│ └─hardened  ami-2
+`))
+	g.Expect(out.String()).To(ContainSubstring("├─Artifact  ami-3\n"))
+
+	build.Status.ImageArtifact = "app-5d8f7c6b9a"
+	out.Reset()
+	g.Expect(buildTree(build).render(out)).To(Succeed())
+	g.Expect(out.String()).To(ContainSubstring("├─Artifact  ami-3\n│ └─ImageArtifact/app-5d8f7c6b9a\n"))
+}
+
+func TestSelectProvisioner(t *testing.T) {
+	g := NewWithT(t)
+	build := testBuild()
+
+	p, err := selectProvisioner(build, "")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(*p.UUID).To(Equal("5678"))
+
+	build.Spec.Provisioners[1].Status = ptr.To(buildv1.ProvisionerStatusRunning)
+	p, err = selectProvisioner(build, "")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(*p.UUID).To(Equal("5678"))
+
+	p, err = selectProvisioner(build, "0")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(*p.UUID).To(Equal("1234"))
+
+	p, err = selectProvisioner(build, "1234")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(*p.UUID).To(Equal("1234"))
+
+	_, err = selectProvisioner(build, "3")
+	g.Expect(err).To(HaveOccurred())
+	_, err = selectProvisioner(build, "cleanup")
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestWaitFunc(t *testing.T) {
+	g := NewWithT(t)
+	build := testBuild()
+
+	_, err := waitFunc("phase")
+	g.Expect(err).To(HaveOccurred())
+	_, err = waitFunc("status=Ready")
+	g.Expect(err).To(HaveOccurred())
+
+	done, err := waitFunc("condition=InfrastructureReady")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(done(build)).To(BeTrue())
+
+	done, err = waitFunc("phase=Failed")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(done(build)).To(BeTrue())
+
+	done, err = waitFunc("phase=Completed")
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = done(build)
+	g.Expect(err).To(HaveOccurred())
+
+	build.Status.Phase = string(buildv1.BuildPhaseBuilding)
+	g.Expect(done(build)).To(BeFalse())
+}
@@ -0,0 +1,160 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	buildv1 "github.com/forge-build/forge/api/v1alpha1"
+)
+
+type getOptions struct {
+	output string
+}
+
+var getOpts = &getOptions{}
+
+var getCmd = &cobra.Command{
+	Use:   "get [NAME]",
+	Short: "List the Builds or get one of them",
+	Example: `  forgectl get
+  forgectl get ubuntu-2204 -o yaml`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c, err := newClient()
+		if err != nil {
+			return err
+		}
+
+		var builds []buildv1.Build
+		if len(args) == 1 {
+			build, err := getBuild(cmd.Context(), c, args[0])
+			if err != nil {
+				return err
+			}
+			builds = append(builds, *build)
+		} else {
+			ns, err := currentNamespace()
+			if err != nil {
+				return err
+			}
+			list := &buildv1.BuildList{}
+			if err := c.List(cmd.Context(), list, client.InNamespace(ns)); err != nil {
+				return errors.Wrap(err, "failed to list Builds")
+			}
+			builds = list.Items
+		}
+
+		switch getOpts.output {
+		case "":
+			return printBuildTable(os.Stdout, builds, time.Now())
+		case "yaml":
+			for i := range builds {
+				if err := printYAML(os.Stdout, &builds[i], i > 0); err != nil {
+					return err
+				}
+			}
+			return nil
+		default:
+			return errors.Errorf("unsupported output format %q, use yaml", getOpts.output)
+		}
+	},
+}
+
+func init() {
+	getCmd.Flags().StringVarP(&getOpts.output, "output", "o", "", "Output format, yaml or empty for a table")
+	rootCmd.AddCommand(getCmd)
+}
+
+// printBuildTable prints the Builds as a table.
+func printBuildTable(w io.Writer, builds []buildv1.Build, now time.Time) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 3, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tPHASE\tINFRASTRUCTURE\tCONNECTED\tPROVISIONERS\tREADY\tAGE")
+	for i := range builds {
+		build := &builds[i]
+		infra := ""
+		if build.Spec.InfrastructureRef != nil {
+			infra = build.Spec.InfrastructureRef.Kind + "/" + build.Spec.InfrastructureRef.Name
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%t\t%s\t%t\t%s\n",
+			build.Name, build.Status.Phase, infra, build.Status.Connected,
+			provisionersSummary(build), build.Status.Ready, age(build.CreationTimestamp, now))
+	}
+	return tw.Flush()
+}
+
+// provisionersSummary returns the number of completed provisioners over the total.
+func provisionersSummary(build *buildv1.Build) string {
+	completed := 0
+	for _, p := range build.Spec.Provisioners {
+		if p.Status != nil && *p.Status == buildv1.ProvisionerStatusCompleted {
+			completed++
+		}
+	}
+	return fmt.Sprintf("%d/%d", completed, len(build.Spec.Provisioners))
+}
+
+func age(t metav1.Time, now time.Time) string {
+	if t.IsZero() {
+		return "<unknown>"
+	}
+	return duration.HumanDuration(now.Sub(t.Time))
+}
+
+// printYAML prints an object as YAML, separated from the previous one.
+func printYAML(w io.Writer, obj interface{}, separator bool) error {
+	data, err := yaml.Marshal(obj)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal the object")
+	}
+	if separator {
+		fmt.Fprintln(w, "---")
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// printObjects prints the objects of a manifest as YAML.
+func printObjects(objs []*unstructured.Unstructured) error {
+	for i, obj := range objs {
+		if err := printYAML(os.Stdout, obj.Object, i > 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ptrString returns the value of a string pointer, or an empty string.
+func ptrString[T ~string](s *T) string {
+	if s == nil {
+		return ""
+	}
+	return strings.TrimSpace(string(*s))
+}
@@ -0,0 +1,205 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/forge-build/forge/internal/packer"
+)
+
+type importOptions struct {
+	name                     string
+	infrastructureAPIVersion string
+	infrastructureKind       string
+	infrastructureName       string
+	output                   string
+	strict                   bool
+}
+
+var importOpts = &importOptions{}
+
+var importCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Import image recipes from other tools",
+}
+
+var importPackerCmd = &cobra.Command{
+	Use:   "packer TEMPLATE",
+	Short: "Convert a Packer template to a Build manifest",
+	Long: `Convert a Packer JSON template, HCL2 template file or directory of *.pkr.hcl files to a Build manifest.
+
+The shell, file and ansible provisioners are converted to shell provisioners, whose scripts are stored
+in ConfigMaps. The Packer variables become template variables, the manifest is created with
+forgectl create --set NAME=VALUE. The constructs which are not converted are reported.`,
+	Example: `  forgectl import packer ubuntu.pkr.hcl --infrastructure-kind AWSBuild > ubuntu.yaml
+  forgectl create -f ubuntu.yaml --set REGION=eu-west-1`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(_ *cobra.Command, args []string) error {
+		template, err := parsePackerTemplate(args[0])
+		if err != nil {
+			return err
+		}
+
+		name := importOpts.name
+		if name == "" {
+			name = buildName(template.Name)
+		}
+		infraName := importOpts.infrastructureName
+		if infraName == "" {
+			infraName = name
+		}
+		result, err := packer.Convert(template, packer.Options{
+			Name:      name,
+			Namespace: namespace,
+			InfrastructureRef: corev1.ObjectReference{
+				APIVersion: importOpts.infrastructureAPIVersion,
+				Kind:       importOpts.infrastructureKind,
+				Name:       infraName,
+			},
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, msg := range result.Unsupported {
+			fmt.Fprintf(os.Stderr, "WARNING: %s\n", msg)
+		}
+		if importOpts.strict && len(result.Unsupported) > 0 {
+			return errors.Errorf("%d constructs of %s are not converted", len(result.Unsupported), args[0])
+		}
+
+		out := io.Writer(os.Stdout)
+		if importOpts.output != "" {
+			f, err := os.Create(importOpts.output)
+			if err != nil {
+				return errors.Wrapf(err, "failed to create %s", importOpts.output)
+			}
+			defer f.Close()
+			out = f
+		}
+		return printResult(out, result)
+	},
+}
+
+func init() {
+	importPackerCmd.Flags().StringVar(&importOpts.name, "name", "", "The name of the Build, defaults to the name of the template")
+	importPackerCmd.Flags().StringVar(&importOpts.infrastructureAPIVersion, "infrastructure-api-version",
+		"infrastructure.forge.build/v1alpha1", "The API version of the InfraBuild")
+	importPackerCmd.Flags().StringVar(&importOpts.infrastructureKind, "infrastructure-kind", "${INFRASTRUCTURE_KIND}",
+		"The kind of the InfraBuild, a template variable by default")
+	importPackerCmd.Flags().StringVar(&importOpts.infrastructureName, "infrastructure-name", "",
+		"The name of the InfraBuild, defaults to the name of the Build")
+	importPackerCmd.Flags().StringVarP(&importOpts.output, "output", "o", "", "The file to write the manifest to, defaults to stdout")
+	importPackerCmd.Flags().BoolVar(&importOpts.strict, "strict", false, "Fail if some constructs of the template are not converted")
+	importCmd.AddCommand(importPackerCmd)
+	rootCmd.AddCommand(importCmd)
+}
+
+// parsePackerTemplate parses a JSON template, an HCL2 template or a directory of HCL2 templates.
+func parsePackerTemplate(path string) (*packer.Template, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read %s", path)
+	}
+
+	if info.IsDir() {
+		matches, err := filepath.Glob(filepath.Join(path, "*.pkr.hcl"))
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			return nil, errors.Errorf("no *.pkr.hcl file found in %s", path)
+		}
+		files := map[string][]byte{}
+		for _, match := range matches {
+			data, err := os.ReadFile(match)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to read %s", match)
+			}
+			files[match] = data
+		}
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return nil, err
+		}
+		return packer.ParseHCL(filepath.Base(abs), path, files)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read %s", path)
+	}
+	base := filepath.Base(path)
+	switch {
+	case strings.HasSuffix(base, ".pkr.json"):
+		return nil, errors.New("HCL2 templates in JSON syntax are not supported")
+	case strings.HasSuffix(base, ".json"):
+		return packer.ParseJSON(strings.TrimSuffix(base, ".json"), filepath.Dir(path), data)
+	case strings.HasSuffix(base, ".hcl"):
+		name := strings.TrimSuffix(strings.TrimSuffix(base, ".hcl"), ".pkr")
+		return packer.ParseHCL(name, filepath.Dir(path), map[string][]byte{path: data})
+	}
+	return nil, errors.Errorf("unknown template format %s, expected .json, .pkr.hcl or a directory", path)
+}
+
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// buildName returns a valid Build name from the name of a template.
+func buildName(name string) string {
+	name = strings.Trim(invalidNameChars.ReplaceAllString(strings.ToLower(name), "-"), "-")
+	if name == "" {
+		return "build"
+	}
+	return name
+}
+
+// printResult prints the objects of a converted template as a multi-document YAML manifest.
+func printResult(w io.Writer, result *packer.Result) error {
+	var objs []runtime.Object
+	if result.Secret != nil {
+		objs = append(objs, result.Secret)
+	}
+	for _, cm := range result.ConfigMaps {
+		objs = append(objs, cm)
+	}
+	objs = append(objs, result.Build)
+
+	for i, obj := range objs {
+		u, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+		if err != nil {
+			return errors.Wrap(err, "failed to convert the object")
+		}
+		unstructured.RemoveNestedField(u, "metadata", "creationTimestamp")
+		unstructured.RemoveNestedField(u, "status")
+		if err := printYAML(w, u, i > 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
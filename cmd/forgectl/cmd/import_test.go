@@ -0,0 +1,71 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/forge-build/forge/internal/packer"
+)
+
+func TestBuildName(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(buildName("Ubuntu_22.04")).To(Equal("ubuntu-22-04"))
+	g.Expect(buildName("__")).To(Equal("build"))
+}
+
+func TestImportPacker(t *testing.T) {
+	g := NewWithT(t)
+
+	dir := t.TempDir()
+	g.Expect(os.WriteFile(filepath.Join(dir, "variables.pkr.hcl"), []byte(`variable "name" {
+  default = "world"
+}`), 0o600)).To(Succeed())
+	g.Expect(os.WriteFile(filepath.Join(dir, "build.pkr.hcl"), []byte(`build {
+  provisioner "shell" {
+    inline = ["echo hello ${var.name}"]
+  }
+}`), 0o600)).To(Succeed())
+
+	template, err := parsePackerTemplate(dir)
+	g.Expect(err).ToNot(HaveOccurred())
+	result, err := packer.Convert(template, packer.Options{Name: buildName(template.Name)})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	out := &bytes.Buffer{}
+	g.Expect(printResult(out, result)).To(Succeed())
+
+	// The manifest is a valid forgectl template.
+	data := renderTemplate(out.Bytes(), map[string]string{"INFRASTRUCTURE_KIND": "StaticMachine"})
+	g.Expect(string(data)).ToNot(ContainSubstring("${INFRASTRUCTURE_KIND"))
+	g.Expect(string(data)).To(ContainSubstring("echo hello world"))
+	g.Expect(string(data)).ToNot(ContainSubstring("creationTimestamp"))
+	objs, err := decodeObjects(data)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(objs).To(HaveLen(2))
+
+	_, err = parsePackerTemplate(filepath.Join(dir, "missing.json"))
+	g.Expect(err).To(HaveOccurred())
+	_, err = parsePackerTemplate(t.TempDir())
+	g.Expect(err).To(HaveOccurred())
+}
@@ -0,0 +1,158 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"io"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	buildv1 "github.com/forge-build/forge/api/v1alpha1"
+)
+
+type logsOptions struct {
+	provisioner          string
+	provisionerNamespace string
+	follow               bool
+	tail                 int64
+}
+
+var logsOpts = &logsOptions{}
+
+var logsCmd = &cobra.Command{
+	Use:   "logs NAME",
+	Short: "Print the output of a Build provisioner",
+	Long: `Print the output of a Build provisioner, the running one by default.
+
+The output is read from the provisioner Job Pod, which is removed once the provisioner finished.`,
+	Example: `  forgectl logs ubuntu-2204 -f
+  forgectl logs ubuntu-2204 --provisioner 2
+  forgectl logs ubuntu-2204 --provisioner cleanup`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c, err := newClient()
+		if err != nil {
+			return err
+		}
+		build, err := getBuild(cmd.Context(), c, args[0])
+		if err != nil {
+			return err
+		}
+		p, err := selectProvisioner(build, logsOpts.provisioner)
+		if err != nil {
+			return err
+		}
+		if p.UUID == nil {
+			return errors.New("the provisioner has not started yet")
+		}
+
+		clientset, err := newClientset()
+		if err != nil {
+			return err
+		}
+		selector := labels.SelectorFromSet(labels.Set{
+			buildv1.BuildNameLabel:      build.Name,
+			buildv1.BuildNamespaceLabel: build.Namespace,
+			buildv1.ProvisionerIDLabel:  *p.UUID,
+		})
+		pods, err := clientset.CoreV1().Pods(logsOpts.provisionerNamespace).List(cmd.Context(), metav1.ListOptions{LabelSelector: selector.String()})
+		if err != nil {
+			return errors.Wrap(err, "failed to list the provisioner pods")
+		}
+		if len(pods.Items) == 0 {
+			return errors.Errorf("no pod found for provisioner %s, it is %s", *p.UUID, ptrString(p.Status))
+		}
+		// The latest pod is the last attempt of the provisioner.
+		sort.Slice(pods.Items, func(i, j int) bool {
+			return pods.Items[i].CreationTimestamp.Before(&pods.Items[j].CreationTimestamp)
+		})
+		pod := pods.Items[len(pods.Items)-1]
+
+		opts := &corev1.PodLogOptions{Follow: logsOpts.follow}
+		if logsOpts.tail >= 0 {
+			opts.TailLines = &logsOpts.tail
+		}
+		stream, err := clientset.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, opts).Stream(cmd.Context())
+		if err != nil {
+			return errors.Wrapf(err, "failed to get the logs of pod %s", pod.Name)
+		}
+		defer stream.Close()
+		_, err = io.Copy(os.Stdout, stream)
+		return err
+	},
+}
+
+func init() {
+	logsCmd.Flags().StringVarP(&logsOpts.provisioner, "provisioner", "p", "",
+		"The index or UUID of the provisioner, or cleanup, defaults to the running provisioner")
+	logsCmd.Flags().StringVar(&logsOpts.provisionerNamespace, "provisioner-namespace", "",
+		"The namespace where the provisioner Jobs run, all the namespaces are searched if unspecified")
+	logsCmd.Flags().BoolVarP(&logsOpts.follow, "follow", "f", false, "Stream the output")
+	logsCmd.Flags().Int64Var(&logsOpts.tail, "tail", -1, "Number of lines to print from the end, all of them by default")
+	rootCmd.AddCommand(logsCmd)
+}
+
+// selectProvisioner returns the provisioner of a Build by index, UUID or "cleanup".
+// It returns the running provisioner, or else the last started one, if selector is empty.
+func selectProvisioner(build *buildv1.Build, selector string) (*buildv1.ProvisionerSpec, error) {
+	switch selector {
+	case "":
+		var last *buildv1.ProvisionerSpec
+		for i := range build.Spec.Provisioners {
+			p := &build.Spec.Provisioners[i]
+			if p.Status != nil && *p.Status == buildv1.ProvisionerStatusRunning {
+				return p, nil
+			}
+			if p.UUID != nil {
+				last = p
+			}
+		}
+		if c := build.Status.Cleanup; c != nil && c.UUID != nil {
+			last = c
+		}
+		if last == nil {
+			return nil, errors.New("no provisioner has started yet")
+		}
+		return last, nil
+	case "cleanup":
+		if build.Status.Cleanup == nil {
+			return nil, errors.New("the cleanup stage has not started yet")
+		}
+		return build.Status.Cleanup, nil
+	}
+
+	for i := range build.Spec.Provisioners {
+		p := &build.Spec.Provisioners[i]
+		if p.UUID != nil && *p.UUID == selector {
+			return p, nil
+		}
+	}
+	if i, err := strconv.Atoi(selector); err == nil {
+		if i < 0 || i >= len(build.Spec.Provisioners) {
+			return nil, errors.Errorf("provisioner index %d out of range, the Build has %d provisioners", i, len(build.Spec.Provisioners))
+		}
+		return &build.Spec.Provisioners[i], nil
+	}
+	return nil, errors.Errorf("provisioner %q not found", selector)
+}
@@ -0,0 +1,116 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+
+	buildv1 "github.com/forge-build/forge/api/v1alpha1"
+)
+
+// variableRegexp matches the ${NAME} and ${NAME:-default} template variables, and the $${ escape sequences.
+var variableRegexp = regexp.MustCompile(`\$\$\{|\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// readManifest reads a manifest from a file, or from stdin if path is "-".
+func readManifest(path string) ([]byte, error) {
+	if path == "" {
+		return nil, errors.New("a manifest file is required, use -f")
+	}
+	if path == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read %s", path)
+	}
+	return data, nil
+}
+
+// parseVariables parses KEY=VALUE pairs.
+func parseVariables(pairs []string) (map[string]string, error) {
+	vars := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || key == "" {
+			return nil, errors.Errorf("invalid variable %q, expected KEY=VALUE", pair)
+		}
+		vars[key] = value
+	}
+	return vars, nil
+}
+
+// renderTemplate substitutes the ${NAME} and ${NAME:-default} variables of a template with the
+// given values, or with their default value. The other ${NAME} are kept, e.g. the variables of the
+// scripts of the provisioners, the environment of forgectl is not read. A literal ${ is written $${.
+func renderTemplate(data []byte, vars map[string]string) []byte {
+	return variableRegexp.ReplaceAllFunc(data, func(match []byte) []byte {
+		if string(match) == "$${" {
+			return match[1:]
+		}
+		groups := variableRegexp.FindSubmatch(match)
+		if v, ok := vars[string(groups[1])]; ok {
+			return []byte(v)
+		}
+		if len(groups[2]) > 0 {
+			return groups[3]
+		}
+		return match
+	})
+}
+
+// decodeObjects decodes the objects of a multi-document YAML or JSON manifest.
+func decodeObjects(data []byte) ([]*unstructured.Unstructured, error) {
+	decoder := utilyaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096)
+	var objs []*unstructured.Unstructured
+	for {
+		obj := &unstructured.Unstructured{}
+		if err := decoder.Decode(&obj.Object); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, errors.Wrap(err, "failed to decode the manifest")
+		}
+		if len(obj.Object) == 0 {
+			continue
+		}
+		objs = append(objs, obj)
+	}
+	return objs, nil
+}
+
+// isBuild returns true if the object is a Build.
+func isBuild(obj *unstructured.Unstructured) bool {
+	return obj.GroupVersionKind() == buildv1.GroupVersion.WithKind("Build")
+}
+
+// toBuild converts an unstructured object to a Build.
+func toBuild(obj *unstructured.Unstructured) (*buildv1.Build, error) {
+	build := &buildv1.Build{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructuredWithValidation(obj.Object, build, true); err != nil {
+		return nil, errors.Wrapf(err, "failed to decode Build %s", obj.GetName())
+	}
+	return build, nil
+}
@@ -0,0 +1,138 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestRenderTemplate(t *testing.T) {
+	t.Setenv("FORGECTL_TEST_REGION", "eu-west-1")
+	t.Setenv("HOME", "/home/operator")
+
+	tests := []struct {
+		name     string
+		template string
+		vars     map[string]string
+		want     string
+	}{
+		{
+			name:     "variable set",
+			template: "name: ${IMAGE_NAME}",
+			vars:     map[string]string{"IMAGE_NAME": "ubuntu"},
+			want:     "name: ubuntu",
+		},
+		{
+			name:     "variable of the environment is not read",
+			template: "region: ${FORGECTL_TEST_REGION}",
+			want:     "region: ${FORGECTL_TEST_REGION}",
+		},
+		{
+			name:     "variables of a script are kept",
+			template: "run: cp app.conf ${HOME}/.config && echo ${PATH}",
+			vars:     map[string]string{"IMAGE_NAME": "ubuntu"},
+			want:     "run: cp app.conf ${HOME}/.config && echo ${PATH}",
+		},
+		{
+			name:     "default value",
+			template: "size: ${DISK_SIZE:-20}",
+			want:     "size: 20",
+		},
+		{
+			name:     "set variable overrides the default value",
+			template: "size: ${DISK_SIZE:-20}",
+			vars:     map[string]string{"DISK_SIZE": "40"},
+			want:     "size: 40",
+		},
+		{
+			name:     "variable not set",
+			template: "name: ${IMAGE_NAME}",
+			want:     "name: ${IMAGE_NAME}",
+		},
+		{
+			name:     "escaped variables",
+			template: "run: echo $${HOME} $${arr[@]} ${NAME:-x}",
+			want:     "run: echo ${HOME} ${arr[@]} x",
+		},
+		{
+			name:     "shell variables without braces are kept",
+			template: "run: echo $HOME",
+			want:     "run: echo $HOME",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			g.Expect(string(renderTemplate([]byte(tt.template), tt.vars))).To(Equal(tt.want))
+		})
+	}
+}
+
+func TestParseVariables(t *testing.T) {
+	g := NewWithT(t)
+
+	vars, err := parseVariables([]string{"A=1", "B=x=y", "C="})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(vars).To(Equal(map[string]string{"A": "1", "B": "x=y", "C": ""}))
+
+	_, err = parseVariables([]string{"A"})
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestDecodeObjects(t *testing.T) {
+	g := NewWithT(t)
+
+	manifest := `---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: script
+data:
+  run.sh: echo hello
+---
+apiVersion: forge.build/v1alpha1
+kind: Build
+metadata:
+  name: ubuntu
+spec:
+  connector:
+    type: ssh
+  infrastructureRef:
+    apiVersion: infrastructure.forge.build/v1alpha1
+    kind: AWSBuild
+    name: ubuntu
+  provisioners:
+  - type: built-in/shell
+    run: echo hello
+`
+	objs, err := decodeObjects([]byte(manifest))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(objs).To(HaveLen(2))
+	g.Expect(isBuild(objs[0])).To(BeFalse())
+	g.Expect(isBuild(objs[1])).To(BeTrue())
+
+	build, err := toBuild(objs[1])
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(build.Spec.Provisioners).To(HaveLen(1))
+	g.Expect(validateObjects(objs)).To(Succeed())
+
+	objs[1].Object["spec"].(map[string]interface{})["connector"] = map[string]interface{}{"type": "winrm"}
+	g.Expect(validateObjects(objs)).ToNot(Succeed())
+}
@@ -0,0 +1,128 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd implements the forgectl commands.
+package cmd
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/kubernetes"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	buildv1 "github.com/forge-build/forge/api/v1alpha1"
+)
+
+var (
+	scheme = runtime.NewScheme()
+
+	kubeconfig  string
+	kubeContext string
+	namespace   string
+)
+
+func init() {
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(buildv1.AddToScheme(scheme))
+}
+
+var rootCmd = &cobra.Command{
+	Use:          "forgectl",
+	Short:        "forgectl manages Forge image Builds",
+	SilenceUsage: true,
+}
+
+// Execute runs the forgectl root command, cobra prints the error it returns.
+func Execute() error {
+	return rootCmd.Execute()
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&kubeconfig, "kubeconfig", "",
+		"Path to the kubeconfig file to use, defaults to the KUBECONFIG environment variable or ~/.kube/config")
+	rootCmd.PersistentFlags().StringVar(&kubeContext, "kubeconfig-context", "",
+		"Context to be used within the kubeconfig file, defaults to the current context")
+	rootCmd.PersistentFlags().StringVarP(&namespace, "namespace", "n", "",
+		"The namespace of the Builds, defaults to the namespace of the kubeconfig context")
+}
+
+func clientConfig() clientcmd.ClientConfig {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = kubeconfig
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, &clientcmd.ConfigOverrides{CurrentContext: kubeContext})
+}
+
+func restConfig() (*rest.Config, error) {
+	cfg, err := clientConfig().ClientConfig()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load the kubeconfig")
+	}
+	return cfg, nil
+}
+
+// newClient returns a client for the management cluster.
+func newClient() (client.Client, error) {
+	cfg, err := restConfig()
+	if err != nil {
+		return nil, err
+	}
+	c, err := client.New(cfg, client.Options{Scheme: scheme})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create the kubernetes client")
+	}
+	return c, nil
+}
+
+// newClientset returns a clientset for the management cluster, used to read Pod logs.
+func newClientset() (kubernetes.Interface, error) {
+	cfg, err := restConfig()
+	if err != nil {
+		return nil, err
+	}
+	return kubernetes.NewForConfig(cfg)
+}
+
+// currentNamespace returns the namespace set by flag, or the one of the kubeconfig context.
+func currentNamespace() (string, error) {
+	if namespace != "" {
+		return namespace, nil
+	}
+	ns, _, err := clientConfig().Namespace()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get the namespace from the kubeconfig")
+	}
+	return ns, nil
+}
+
+// getBuild returns the Build with the given name in the current namespace.
+func getBuild(ctx context.Context, c client.Client, name string) (*buildv1.Build, error) {
+	ns, err := currentNamespace()
+	if err != nil {
+		return nil, err
+	}
+	build := &buildv1.Build{}
+	if err := c.Get(ctx, client.ObjectKey{Namespace: ns, Name: name}, build); err != nil {
+		return nil, errors.Wrapf(err, "failed to get Build %s/%s", ns, name)
+	}
+	return build, nil
+}
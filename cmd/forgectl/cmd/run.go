@@ -0,0 +1,356 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/go-logr/logr/funcr"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/utils/ptr"
+
+	buildv1 "github.com/forge-build/forge/api/v1alpha1"
+	"github.com/forge-build/forge/internal/validation"
+	"github.com/forge-build/forge/pkg/ssh"
+	"github.com/forge-build/forge/provisioner/shell"
+	"github.com/forge-build/forge/provisioner/shell/cleanup"
+	"github.com/forge-build/forge/provisioner/shell/runner"
+)
+
+type runOptions struct {
+	file      string
+	variables []string
+	build     string
+	local     bool
+
+	host           string
+	port           int
+	user           string
+	password       string
+	privateKeyFile string
+	sshTimeout     time.Duration
+
+	skipValidations bool
+	cleanup         bool
+}
+
+var runOpts = &runOptions{}
+
+var runCmd = &cobra.Command{
+	Use:   "run --local -f FILE --host HOST",
+	Short: "Run the provisioners of a Build on an existing machine",
+	Long: `Run the provisioners of a Build manifest on an existing machine over SSH, without Kubernetes.
+
+The infrastructure of the Build is not created, the provisioners run in-process against the given host
+with the same semantics as in the cluster: retries, allowFail, reboots, outputs and scripts from the ConfigMaps
+of the manifest. The validations run once the provisioners are done. The cleanup stage only runs with
+--cleanup, as it generalizes the machine.`,
+	Example: `  forgectl run --local -f build.yaml --host 192.168.122.10 --user ubuntu --private-key ~/.ssh/id_ed25519
+  forgectl run --local -f build.yaml --host localhost --port 2222 --user root --password root`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		if !runOpts.local {
+			return errors.New("only local runs are supported, use --local, or forgectl create to run a Build in the cluster")
+		}
+
+		objs, err := renderObjects(runOpts.file, runOpts.variables)
+		if err != nil {
+			return err
+		}
+		if err := validateObjects(objs); err != nil {
+			return err
+		}
+		build, err := selectBuild(objs, runOpts.build)
+		if err != nil {
+			return err
+		}
+		if build.Spec.Matrix != nil {
+			return errors.Errorf("Build %s is a matrix Build, its variants only run in the cluster", build.Name)
+		}
+
+		sshClient, err := newLocalSSHClient(runOpts)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Connecting to %s@%s:%d\n", runOpts.user, runOpts.host, runOpts.port)
+		if err := sshClient.WaitForSSH(runOpts.sshTimeout); err != nil {
+			return errors.Wrapf(err, "failed to connect to %s", runOpts.host)
+		}
+		defer sshClient.Disconnect()
+
+		r := &localRun{
+			logger: funcr.New(func(_, args string) {
+				fmt.Fprintf(os.Stderr, "    %s\n", args)
+			}, funcr.Options{}),
+			client: sshClient,
+			objs:   objs,
+			stdout: os.Stdout,
+			stderr: os.Stderr,
+
+			skipValidations: runOpts.skipValidations,
+			cleanup:         runOpts.cleanup,
+		}
+		return r.run(cmd.Context(), build)
+	},
+}
+
+func init() {
+	runCmd.Flags().StringVarP(&runOpts.file, "filename", "f", "", "The Build manifest or template to run, - reads from stdin")
+	runCmd.Flags().StringArrayVar(&runOpts.variables, "set", nil, "Set a template variable, e.g. --set KEY=VALUE")
+	runCmd.Flags().StringVar(&runOpts.build, "build", "", "The name of the Build to run, if the manifest has several of them")
+	runCmd.Flags().BoolVar(&runOpts.local, "local", false, "Run the Build on an existing machine, without Kubernetes")
+	runCmd.Flags().StringVar(&runOpts.host, "host", "", "The host of the machine")
+	runCmd.Flags().IntVar(&runOpts.port, "port", 22, "The SSH port of the machine")
+	runCmd.Flags().StringVar(&runOpts.user, "user", "root", "The SSH user")
+	runCmd.Flags().StringVar(&runOpts.password, "password", "", "The SSH password")
+	runCmd.Flags().StringVar(&runOpts.privateKeyFile, "private-key", "", "The path of the SSH private key")
+	runCmd.Flags().DurationVar(&runOpts.sshTimeout, "ssh-timeout", 2*time.Minute, "How long to wait for the machine to accept SSH connections")
+	runCmd.Flags().BoolVar(&runOpts.skipValidations, "skip-validations", false, "Do not run the validations of the Build")
+	runCmd.Flags().BoolVar(&runOpts.cleanup, "cleanup", false, "Run the cleanup stage of the Build, which generalizes the machine")
+	rootCmd.AddCommand(runCmd)
+}
+
+// selectBuild returns the Build of a manifest with the given name, or the only Build if name is empty.
+func selectBuild(objs []*unstructured.Unstructured, name string) (*buildv1.Build, error) {
+	var builds []*unstructured.Unstructured
+	for _, obj := range objs {
+		if isBuild(obj) && (name == "" || obj.GetName() == name) {
+			builds = append(builds, obj)
+		}
+	}
+	switch {
+	case len(builds) == 0 && name != "":
+		return nil, errors.Errorf("Build %s not found in the manifest", name)
+	case len(builds) == 0:
+		return nil, errors.New("no Build found in the manifest")
+	case len(builds) > 1:
+		return nil, errors.New("the manifest has several Builds, select one with --build")
+	}
+	return toBuild(builds[0])
+}
+
+// lookupIP resolves the host of the machine.
+var lookupIP = net.LookupIP
+
+// newLocalSSHClient returns an SSH client for the machine given on the command line.
+func newLocalSSHClient(opts *runOptions) (*ssh.SSHClient, error) {
+	if opts.host == "" {
+		return nil, errors.New("the host of the machine is required, use --host")
+	}
+	ip := net.ParseIP(opts.host)
+	if ip == nil {
+		ips, err := lookupIP(opts.host)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to resolve %s", opts.host)
+		}
+		if len(ips) == 0 {
+			return nil, errors.Errorf("failed to resolve %s: no address", opts.host)
+		}
+		ip = ips[0]
+	}
+
+	creds := &ssh.Credentials{SSHUser: opts.user, SSHPassword: opts.password}
+	if opts.privateKeyFile != "" {
+		key, err := os.ReadFile(opts.privateKeyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read the private key")
+		}
+		creds.SSHPrivateKey = string(key)
+	}
+	if creds.SSHPassword == "" && creds.SSHPrivateKey == "" {
+		return nil, errors.New("a password or a private key is required, use --password or --private-key")
+	}
+
+	return &ssh.SSHClient{Creds: creds, IP: ip, Port: opts.port}, nil
+}
+
+// localRun runs a Build on a machine in-process.
+type localRun struct {
+	logger logr.Logger
+	client ssh.Client
+	// objs are the objects of the manifest, where the scripts of the provisioners are looked up.
+	objs   []*unstructured.Unstructured
+	stdout io.Writer
+	stderr io.Writer
+
+	skipValidations bool
+	cleanup         bool
+
+	// outputs are the outputs of the completed provisioners, exported to the next ones.
+	outputs map[string]string
+}
+
+func (r *localRun) run(ctx context.Context, build *buildv1.Build) error {
+	for i := range build.Spec.Provisioners {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		p := &build.Spec.Provisioners[i]
+		outputs, err := r.runProvisioner(ctx, fmt.Sprintf("Provisioner [%d] %s", i, p.Type), p)
+		if err != nil {
+			if !p.AllowFail {
+				return errors.Wrapf(err, "provisioner %d failed", i)
+			}
+			fmt.Fprintf(r.stdout, "==> Provisioner [%d] failed and is allowed to fail: %v\n", i, err)
+			continue
+		}
+		for key, value := range outputs {
+			if r.outputs == nil {
+				r.outputs = map[string]string{}
+			}
+			r.outputs[key] = value
+		}
+	}
+
+	if !r.skipValidations && len(build.Spec.Validations) > 0 {
+		if err := r.runValidations(build.Spec.Validations); err != nil {
+			return err
+		}
+	}
+
+	if r.cleanup && (build.Spec.Cleanup == nil || !build.Spec.Cleanup.Disabled) {
+		script, err := cleanup.Script(build.Spec.Cleanup)
+		if err != nil {
+			return err
+		}
+		p := &buildv1.ProvisionerSpec{Type: buildv1.ProvisionerTypeShell, Run: ptr.To(script)}
+		if _, err := r.runProvisioner(ctx, "Cleanup", p); err != nil {
+			return errors.Wrap(err, "cleanup failed")
+		}
+	}
+
+	fmt.Fprintf(r.stdout, "==> Build %s completed\n", build.Name)
+	return nil
+}
+
+// runProvisioner runs a provisioner, retrying it according to its exit code policy like the ShellJobController does,
+// and returns its outputs. The outputs of the previous provisioners are exported to its script like in the Jobs.
+func (r *localRun) runProvisioner(ctx context.Context, name string, p *buildv1.ProvisionerSpec) (map[string]string, error) {
+	if p.Type == buildv1.ProvisionerTypeExternal {
+		return nil, errors.New("external provisioners cannot run locally")
+	}
+	script, err := r.script(p)
+	if err != nil {
+		return nil, err
+	}
+	env := make(map[string]string, len(r.outputs))
+	for key, value := range r.outputs {
+		env[shell.OutputEnvName(key)] = value
+	}
+	opts := runner.Options{
+		Script:           script,
+		ExpectDisconnect: p.ExpectDisconnect,
+		Reboot:           p.Type == buildv1.ProvisionerTypeReboot,
+		VerifyBootID:     p.VerifyBootID,
+		Stdout:           r.stdout,
+		Stderr:           r.stderr,
+		Env:              env,
+		OutputsFile:      shell.OutputsFile,
+	}
+	if p.ReconnectTimeout != nil {
+		opts.ReconnectTimeout = p.ReconnectTimeout.Duration
+	}
+
+	// Retries is the number of times the failed provisioner runs again, it defaults to 1.
+	attempts := int(ptr.Deref(p.Retries, 1)) + 1
+	for attempt := 1; ; attempt++ {
+		fmt.Fprintf(r.stdout, "==> %s (attempt %d/%d)\n", name, attempt, attempts)
+		err = runner.Run(r.logger, r.client, opts)
+		if err == nil {
+			outputs, err := runner.ReadOutputs(r.client, opts.OutputsFile)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to read the outputs of %s", name)
+			}
+			return outputs, nil
+		}
+		var exitCode *int32
+		if code, ok := ssh.ExitStatus(err); ok {
+			exitCode = ptr.To(int32(code))
+		}
+		action := p.ExitCodePolicy.Action(exitCode)
+		if action == buildv1.ExitCodeActionSucceed {
+			fmt.Fprintf(r.stdout, "==> %s exited with %d, a success exit code\n", name, *exitCode)
+			return nil, nil
+		}
+		fmt.Fprintf(r.stdout, "==> %s failed: %v\n", name, err)
+		if action == buildv1.ExitCodeActionFail || attempt >= attempts {
+			return nil, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(p.GetRetryBackoff()):
+		}
+	}
+}
+
+// script returns the script of a provisioner, read from the ConfigMaps of the manifest if referenced.
+func (r *localRun) script(p *buildv1.ProvisionerSpec) (string, error) {
+	if p.Run != nil {
+		return *p.Run, nil
+	}
+	if p.RunConfigMapRef != nil {
+		for _, obj := range r.objs {
+			if obj.GetKind() != "ConfigMap" || obj.GetName() != p.RunConfigMapRef.Name {
+				continue
+			}
+			data, _, err := unstructured.NestedStringMap(obj.Object, "data")
+			if err != nil {
+				return "", errors.Wrapf(err, "failed to read ConfigMap %s", obj.GetName())
+			}
+			if len(data) != 1 {
+				return "", errors.Errorf("ConfigMap %s must have exactly one script, it has %d keys", obj.GetName(), len(data))
+			}
+			for _, script := range data {
+				return script, nil
+			}
+		}
+		return "", errors.Errorf("ConfigMap %s not found in the manifest", p.RunConfigMapRef.Name)
+	}
+	if p.Type == buildv1.ProvisionerTypeReboot {
+		return shell.DefaultRebootCommand, nil
+	}
+	return "", errors.New("the provisioner has no script to run")
+}
+
+func (r *localRun) runValidations(validations []buildv1.ValidationSpec) error {
+	fmt.Fprintln(r.stdout, "==> Validations")
+	report := validation.Run(r.client, validations)
+	for _, result := range report.Results {
+		status := "passed"
+		if !result.Passed {
+			status = "failed"
+		}
+		fmt.Fprintf(r.stdout, "    %s %s %s\n", result.Name, result.Type, status)
+		if !result.Passed && result.Message != "" {
+			fmt.Fprintf(r.stdout, "      %s\n", strings.ReplaceAll(result.Message, "\n", "\n      "))
+		}
+	}
+	if failed := validation.Failed(validations, report); len(failed) > 0 {
+		return errors.Errorf("validations failed: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,235 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/utils/ptr"
+
+	buildv1 "github.com/forge-build/forge/api/v1alpha1"
+	"github.com/forge-build/forge/pkg/ssh"
+	"github.com/forge-build/forge/provisioner/shell"
+)
+
+const runManifest = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: install
+data:
+  install.sh: apt-get install -y nginx
+---
+apiVersion: forge.build/v1alpha1
+kind: Build
+metadata:
+  name: ubuntu
+spec:
+  connector:
+    type: ssh
+  infrastructureRef:
+    apiVersion: infrastructure.forge.build/v1alpha1
+    kind: AWSBuild
+    name: ubuntu
+  provisioners:
+  - type: built-in/shell
+    runConfigMapRef:
+      name: install
+  - type: built-in/shell
+    run: flaky
+    retries: 2
+    retryBackoff: 0s
+  - type: built-in/shell
+    run: broken
+    retries: 0
+    allowFail: true
+  validations:
+  - name: nginx
+    type: PackageInstalled
+    target: nginx
+`
+
+func newLocalRun(t *testing.T, c ssh.Client) (*localRun, *buildv1.Build, *bytes.Buffer) {
+	t.Helper()
+	g := NewWithT(t)
+
+	objs, err := decodeObjects([]byte(runManifest))
+	g.Expect(err).ToNot(HaveOccurred())
+	build, err := selectBuild(objs, "")
+	g.Expect(err).ToNot(HaveOccurred())
+
+	out := &bytes.Buffer{}
+	return &localRun{
+		logger: logr.Discard(),
+		client: c,
+		objs:   objs,
+		stdout: out,
+		stderr: out,
+	}, build, out
+}
+
+// newMockClient returns a client running the scripts with run, without the exports of their environment
+// prepended by the runner. The scripts write no outputs.
+func newMockClient(run func(script string, stdout io.Writer) error) *ssh.MockSSHClient {
+	return &ssh.MockSSHClient{
+		MockRun: func(command string, stdout io.Writer, _ io.Writer) error {
+			if strings.HasPrefix(command, "rm -f ") {
+				return nil
+			}
+			lines := strings.Split(command, "\n")
+			return run(lines[len(lines)-1], stdout)
+		},
+		MockDownload: func(io.WriteCloser, string) error { return nil },
+	}
+}
+
+func TestLocalRun(t *testing.T) {
+	g := NewWithT(t)
+
+	var commands []string
+	flaky := 0
+	c := newMockClient(func(command string, stdout io.Writer) error {
+		commands = append(commands, command)
+		switch {
+		case command == "flaky":
+			flaky++
+			if flaky < 3 {
+				return errors.New("exit status 1")
+			}
+		case command == "broken":
+			return errors.New("exit status 2")
+		case strings.Contains(command, "nginx"):
+			_, _ = io.WriteString(stdout, "installed")
+		}
+		return nil
+	})
+
+	r, build, out := newLocalRun(t, c)
+	g.Expect(r.run(context.Background(), build)).To(Succeed())
+	// The flaky provisioner is retried until it succeeds, the broken one is allowed to fail.
+	g.Expect(commands[:5]).To(Equal([]string{"apt-get install -y nginx", "flaky", "flaky", "flaky", "broken"}))
+	g.Expect(commands).To(HaveLen(6))
+	g.Expect(out.String()).To(ContainSubstring("Provisioner [1] built-in/shell (attempt 3/3)"))
+	g.Expect(out.String()).To(ContainSubstring("Provisioner [2] failed and is allowed to fail"))
+	g.Expect(out.String()).To(ContainSubstring("Build ubuntu completed"))
+}
+
+func TestLocalRunFailure(t *testing.T) {
+	g := NewWithT(t)
+
+	c := newMockClient(func(command string, _ io.Writer) error {
+		if command == "flaky" {
+			return errors.New("exit status 1")
+		}
+		return nil
+	})
+
+	r, build, _ := newLocalRun(t, c)
+	err := r.run(context.Background(), build)
+	g.Expect(err).To(MatchError(ContainSubstring("provisioner 1 failed")))
+}
+
+func TestLocalRunOutputs(t *testing.T) {
+	g := NewWithT(t)
+
+	var scripts []string
+	outputs := ""
+	c := &ssh.MockSSHClient{
+		MockRun: func(command string, _ io.Writer, _ io.Writer) error {
+			if strings.HasPrefix(command, "rm -f ") {
+				outputs = ""
+				return nil
+			}
+			scripts = append(scripts, command)
+			if strings.HasSuffix(command, "uname -r") {
+				outputs = "kernel=6.8.0\n"
+			}
+			return nil
+		},
+		MockDownload: func(w io.WriteCloser, path string) error {
+			g.Expect(path).To(Equal(shell.OutputsFile))
+			_, err := io.WriteString(w, outputs)
+			return err
+		},
+	}
+
+	r, build, _ := newLocalRun(t, c)
+	build.Spec.Provisioners = []buildv1.ProvisionerSpec{
+		{Type: buildv1.ProvisionerTypeShell, Run: ptr.To(`echo "kernel=$(uname -r)" >> "$FORGE_OUTPUTS"; uname -r`)},
+		{Type: buildv1.ProvisionerTypeShell, Run: ptr.To(`echo "$FORGE_OUTPUT_KERNEL"`)},
+	}
+	build.Spec.Validations = nil
+	g.Expect(r.run(context.Background(), build)).To(Succeed())
+
+	// The outputs of the first provisioner are exported to the second one.
+	g.Expect(scripts).To(HaveLen(2))
+	g.Expect(scripts[0]).NotTo(ContainSubstring("FORGE_OUTPUT_KERNEL"))
+	g.Expect(scripts[0]).To(ContainSubstring("export FORGE_OUTPUTS='.forge-outputs'"))
+	g.Expect(scripts[1]).To(ContainSubstring("export FORGE_OUTPUT_KERNEL='6.8.0'\n"))
+	g.Expect(r.outputs).To(Equal(map[string]string{"kernel": "6.8.0"}))
+}
+
+func TestLocalRunScript(t *testing.T) {
+	g := NewWithT(t)
+	r, _, _ := newLocalRun(t, &ssh.MockSSHClient{})
+
+	script, err := r.script(&buildv1.ProvisionerSpec{Type: buildv1.ProvisionerTypeShell, Run: ptr.To("echo hello")})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(script).To(Equal("echo hello"))
+
+	script, err = r.script(&buildv1.ProvisionerSpec{Type: buildv1.ProvisionerTypeReboot})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(script).To(ContainSubstring("shutdown -r now"))
+
+	_, err = r.script(&buildv1.ProvisionerSpec{
+		Type:            buildv1.ProvisionerTypeShell,
+		RunConfigMapRef: &corev1.ObjectReference{Name: "missing"},
+	})
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestNewLocalSSHClientNoAddress(t *testing.T) {
+	g := NewWithT(t)
+	defer func(lookup func(string) ([]net.IP, error)) { lookupIP = lookup }(lookupIP)
+	lookupIP = func(string) ([]net.IP, error) { return nil, nil }
+
+	c, err := newLocalSSHClient(&runOptions{host: "builder.example.com", port: 22, user: "root", password: "root"})
+	g.Expect(err).To(MatchError("failed to resolve builder.example.com: no address"))
+	g.Expect(c).To(BeNil())
+}
+
+func TestSelectBuild(t *testing.T) {
+	g := NewWithT(t)
+
+	objs, err := decodeObjects([]byte(runManifest))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	_, err = selectBuild(objs, "ubuntu")
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = selectBuild(objs, "debian")
+	g.Expect(err).To(HaveOccurred())
+	_, err = selectBuild(objs[:1], "")
+	g.Expect(err).To(HaveOccurred())
+}
@@ -0,0 +1,57 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// treeNode is a node of the tree view printed by describe.
+type treeNode struct {
+	label    string
+	children []*treeNode
+}
+
+// add adds a child node to the node, and returns it.
+func (n *treeNode) add(format string, args ...interface{}) *treeNode {
+	child := &treeNode{label: fmt.Sprintf(format, args...)}
+	n.children = append(n.children, child)
+	return child
+}
+
+// render writes the tree to w.
+func (n *treeNode) render(w io.Writer) error {
+	var sb strings.Builder
+	sb.WriteString(n.label)
+	sb.WriteString("\n")
+	n.renderChildren(&sb, "")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func (n *treeNode) renderChildren(sb *strings.Builder, prefix string) {
+	for i, child := range n.children {
+		branch, indent := "├─", "│ "
+		if i == len(n.children)-1 {
+			branch, indent = "└─", "  "
+		}
+		sb.WriteString(prefix + branch + child.label + "\n")
+		child.renderChildren(sb, prefix+indent)
+	}
+}
@@ -0,0 +1,56 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+type validateOptions struct {
+	file      string
+	variables []string
+}
+
+var validateOpts = &validateOptions{}
+
+var validateCmd = &cobra.Command{
+	Use:   "validate -f FILE",
+	Short: "Validate a Build manifest offline",
+	Long: `Validate the Builds of a manifest or template without a cluster,
+with the same validation as the Build admission webhook.`,
+	Example: `  forgectl validate -f build.yaml`,
+	Args:    cobra.NoArgs,
+	RunE: func(_ *cobra.Command, _ []string) error {
+		objs, err := renderObjects(validateOpts.file, validateOpts.variables)
+		if err != nil {
+			return err
+		}
+		if err := validateObjects(objs); err != nil {
+			return err
+		}
+		fmt.Printf("%s is valid\n", validateOpts.file)
+		return nil
+	},
+}
+
+func init() {
+	validateCmd.Flags().StringVarP(&validateOpts.file, "filename", "f", "", "The manifest or template to validate, - reads from stdin")
+	validateCmd.Flags().StringArrayVar(&validateOpts.variables, "set", nil, "Set a template variable, e.g. --set KEY=VALUE")
+	rootCmd.AddCommand(validateCmd)
+}
@@ -0,0 +1,117 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/util/wait"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	buildv1 "github.com/forge-build/forge/api/v1alpha1"
+)
+
+type waitOptions struct {
+	forCondition string
+	timeout      time.Duration
+	interval     time.Duration
+}
+
+var waitOpts = &waitOptions{}
+
+var waitCmd = &cobra.Command{
+	Use:   "wait NAME --for phase=PHASE|condition=TYPE",
+	Short: "Wait for a Build to reach a phase or a condition",
+	Long: `Wait for a Build to reach a phase or to have a true condition.
+
+The command fails as soon as the Build fails, unless waiting for the Failed phase.`,
+	Example: `  forgectl wait ubuntu-2204 --for phase=Completed --timeout 1h
+  forgectl wait ubuntu-2204 --for condition=ProvisionersReady`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		done, err := waitFunc(waitOpts.forCondition)
+		if err != nil {
+			return err
+		}
+		c, err := newClient()
+		if err != nil {
+			return err
+		}
+
+		err = wait.PollUntilContextTimeout(cmd.Context(), waitOpts.interval, waitOpts.timeout, true, func(ctx context.Context) (bool, error) {
+			build, err := getBuild(ctx, c, args[0])
+			if err != nil {
+				return false, err
+			}
+			return done(build)
+		})
+		if err != nil {
+			if wait.Interrupted(err) {
+				return errors.Errorf("timed out waiting for Build %s to reach %s", args[0], waitOpts.forCondition)
+			}
+			return err
+		}
+		fmt.Printf("Build %s reached %s\n", args[0], waitOpts.forCondition)
+		return nil
+	},
+}
+
+func init() {
+	waitCmd.Flags().StringVar(&waitOpts.forCondition, "for", "phase=Completed", "The phase=PHASE or condition=TYPE to wait for")
+	waitCmd.Flags().DurationVar(&waitOpts.timeout, "timeout", time.Hour, "How long to wait")
+	waitCmd.Flags().DurationVar(&waitOpts.interval, "interval", 5*time.Second, "How often to check the Build")
+	rootCmd.AddCommand(waitCmd)
+}
+
+// waitFunc returns the function checking if a Build reached the phase=PHASE or condition=TYPE.
+func waitFunc(forCondition string) (func(*buildv1.Build) (bool, error), error) {
+	kind, value, ok := strings.Cut(forCondition, "=")
+	if !ok || value == "" {
+		return nil, errors.Errorf("invalid --for %q, expected phase=PHASE or condition=TYPE", forCondition)
+	}
+
+	var reached func(*buildv1.Build) bool
+	switch kind {
+	case "phase":
+		reached = func(build *buildv1.Build) bool {
+			return strings.EqualFold(build.Status.Phase, value)
+		}
+	case "condition":
+		reached = func(build *buildv1.Build) bool {
+			return conditions.IsTrue(build, clusterv1.ConditionType(value))
+		}
+	default:
+		return nil, errors.Errorf("invalid --for %q, expected phase=PHASE or condition=TYPE", forCondition)
+	}
+
+	return func(build *buildv1.Build) (bool, error) {
+		if reached(build) {
+			return true, nil
+		}
+		if build.Status.Phase == string(buildv1.BuildPhaseFailed) {
+			return false, errors.Errorf("Build %s failed: %s: %s", build.Name,
+				ptrString(build.Status.FailureReason), ptrString(build.Status.FailureMessage))
+		}
+		return false, nil
+	}, nil
+}
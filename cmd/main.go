@@ -22,6 +22,7 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"strings"
 
 	shellcontroller "github.com/forge-build/forge/provisioner/shell/controller"
 	"github.com/pkg/errors"
@@ -31,6 +32,8 @@ import (
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
@@ -40,9 +43,14 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/yaml"
 
 	buildv1 "github.com/forge-build/forge/api/v1alpha1"
+	staticv1 "github.com/forge-build/forge/infrastructure/static/api/v1alpha1"
+	staticctrl "github.com/forge-build/forge/infrastructure/static/controller"
 	buildctrl "github.com/forge-build/forge/internal/controller"
+	"github.com/forge-build/forge/internal/webhooks"
+	"github.com/forge-build/forge/pkg/tracing"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -53,16 +61,27 @@ var (
 
 func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(apiextensionsv1.AddToScheme(scheme))
 
 	utilruntime.Must(buildv1.AddToScheme(scheme))
+	utilruntime.Must(staticv1.AddToScheme(scheme))
 	//+kubebuilder:scaffold:scheme
 }
 
 var (
 	watchFilterValue string
 	buildConcurrency int
+	enableWebhooks   bool
 
-	ForgeCoreNameSpace = os.Getenv("POD_NAMESPACE")
+	enableStaticProvider bool
+	staticExportImage    string
+
+	otlpEndpoint string
+
+	provisionerImage            string
+	provisionerImagePullSecrets string
+	provisionerRuntimeConfig    string
+	provisionerNamespace        string
 )
 
 func main() {
@@ -87,6 +106,30 @@ func main() {
 	flag.IntVar(&buildConcurrency, "build-concurrency", 10,
 		"Number of builds to process simultaneously")
 
+	flag.BoolVar(&enableWebhooks, "enable-webhooks", false,
+		"If set, the admission webhooks will be served, this requires the webhook serving certificates to be mounted")
+
+	flag.BoolVar(&enableStaticProvider, "enable-static-provider", true,
+		"If set, the StaticMachine infrastructure provider is run, this requires its CRD to be installed")
+
+	flag.StringVar(&staticExportImage, "static-export-image", fmt.Sprintf("%s:%s", shellcontroller.ShellProvisionerRepo, shellcontroller.ShellProvisionerTag),
+		"The image of the Jobs exporting the StaticMachines, it runs the shell provisioner")
+
+	flag.StringVar(&provisionerImage, "provisioner-image", "",
+		fmt.Sprintf("The image of the provisioner Jobs, e.g. to use a mirror in air-gapped clusters. If unspecified, %s:%s is used", shellcontroller.ShellProvisionerRepo, shellcontroller.ShellProvisionerTag))
+
+	flag.StringVar(&provisionerImagePullSecrets, "provisioner-image-pull-secrets", "",
+		"Comma-separated names of the secrets used to pull the image of the provisioner Jobs")
+
+	flag.StringVar(&provisionerRuntimeConfig, "provisioner-runtime-config", "",
+		"Path to a YAML ProvisionerRuntimeConfig with the default image, resources, scheduling and security options of the provisioner Jobs. The provisioner image flags take precedence over it")
+
+	flag.StringVar(&provisionerNamespace, "provisioner-namespace", "",
+		fmt.Sprintf("The namespace where the provisioner Jobs of all the Builds run, e.g. %s, it requires the cluster-wide access of their service account to the Secrets. If unspecified, the Jobs run in the namespace of their Build with a service account created by the controller and the ssh credentials mounted", shellcontroller.ForgeCoreNamespace))
+
+	flag.StringVar(&otlpEndpoint, "otlp-endpoint", os.Getenv(tracing.EndpointEnv),
+		"The OTLP gRPC endpoint the traces of the Builds are exported to, e.g. http://localhost:4317 for a local collector. If unspecified, tracing is disabled")
+
 	opts := zap.Options{
 		Development: true,
 	}
@@ -146,6 +189,17 @@ func main() {
 	// Setup the context that's going to be used in controllers and for the manager.
 	ctx := ctrl.SetupSignalHandler()
 
+	shutdownTracing, err := tracing.Setup(ctx, "forge-controller-manager", otlpEndpoint)
+	if err != nil {
+		setupLog.Error(err, "unable to setup tracing")
+		os.Exit(1)
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			setupLog.Error(err, "unable to flush the traces")
+		}
+	}()
+
 	// setupChecks(mgr)
 	err = setupReconcilers(ctx, mgr)
 	if err != nil {
@@ -153,6 +207,13 @@ func main() {
 		os.Exit(1)
 	}
 
+	if enableWebhooks {
+		if err := (&webhooks.Build{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "Build")
+			os.Exit(1)
+		}
+	}
+
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -167,6 +228,7 @@ func main() {
 	setupLog.Info("starting manager")
 	if err := mgr.Start(ctx); err != nil {
 		setupLog.Error(err, "problem running manager")
+		_ = shutdownTracing(context.Background())
 		os.Exit(1)
 	}
 }
@@ -184,7 +246,22 @@ func main() {
 //}
 
 func setupReconcilers(ctx context.Context, mgr ctrl.Manager) error {
+	runtimeConfig, err := provisionerRuntime()
+	if err != nil {
+		return err
+	}
 	if err := (&buildctrl.BuildReconciler{
+		Client:               mgr.GetClient(),
+		Scheme:               mgr.GetScheme(),
+		ProvisionerRuntime:   runtimeConfig,
+		ProvisionerNamespace: provisionerNamespace,
+
+		WatchFilterValue: watchFilterValue,
+	}).SetupWithManager(ctx, mgr, concurrency(buildConcurrency)); err != nil {
+		return err
+	}
+
+	if err := (&buildctrl.ImageArtifactReconciler{
 		Client: mgr.GetClient(),
 		Scheme: mgr.GetScheme(),
 
@@ -193,6 +270,18 @@ func setupReconcilers(ctx context.Context, mgr ctrl.Manager) error {
 		return err
 	}
 
+	if enableStaticProvider {
+		if err := (&staticctrl.StaticMachineReconciler{
+			Client:      mgr.GetClient(),
+			Scheme:      mgr.GetScheme(),
+			ExportImage: staticExportImage,
+
+			WatchFilterValue: watchFilterValue,
+		}).SetupWithManager(ctx, mgr, concurrency(buildConcurrency)); err != nil {
+			return err
+		}
+	}
+
 	kubeConfig := ctrl.GetConfigOrDie()
 	// The only reason we're using kubernetes.Clientset is that we need it to read Pod logs,
 	// which is not supported by the client returned by the ctrl.Manager.
@@ -203,7 +292,7 @@ func setupReconcilers(ctx context.Context, mgr ctrl.Manager) error {
 	if err := (&shellcontroller.ShellJobController{
 		Client:    mgr.GetClient(),
 		Logger:    ctrl.Log.WithName("controllers").WithName("ShellJob"),
-		Namespace: "forge-core", // TODO change to ForgeCoreNameSpace
+		Namespace: provisionerNamespace,
 		Clientset: clientSet,
 	}).SetupWithManager(mgr); err != nil {
 		return err
@@ -211,6 +300,37 @@ func setupReconcilers(ctx context.Context, mgr ctrl.Manager) error {
 	return nil
 }
 
+// provisionerRuntime returns the default configuration of the provisioner Jobs, read from the
+// provisioner runtime config file and overridden by the provisioner image flags.
+func provisionerRuntime() (*buildv1.ProvisionerRuntimeConfig, error) {
+	config := &buildv1.ProvisionerRuntimeConfig{}
+	if provisionerRuntimeConfig != "" {
+		data, err := os.ReadFile(provisionerRuntimeConfig)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read the provisioner runtime config")
+		}
+		if err := yaml.UnmarshalStrict(data, config); err != nil {
+			return nil, errors.Wrapf(err, "failed to parse the provisioner runtime config %s", provisionerRuntimeConfig)
+		}
+	}
+
+	if provisionerImage != "" {
+		config.Image = provisionerImage
+	}
+	if config.Image == "" {
+		config.Image = fmt.Sprintf("%s:%s", shellcontroller.ShellProvisionerRepo, shellcontroller.ShellProvisionerTag)
+	}
+	if provisionerImagePullSecrets != "" {
+		config.ImagePullSecrets = nil
+		for _, name := range strings.Split(provisionerImagePullSecrets, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				config.ImagePullSecrets = append(config.ImagePullSecrets, corev1.LocalObjectReference{Name: name})
+			}
+		}
+	}
+	return config, nil
+}
+
 func concurrency(c int) controller.Options {
 	return controller.Options{MaxConcurrentReconciles: c}
 }
@@ -0,0 +1,44 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+
+const (
+	// CredentialsReadyCondition reports if the SSH credentials of the machine are provided to the Build.
+	CredentialsReadyCondition clusterv1.ConditionType = "CredentialsReady"
+
+	// WaitingForBuildReason (Severity=Info) documents a StaticMachine not owned by a Build yet.
+	WaitingForBuildReason = "WaitingForBuild"
+
+	// CredentialsNotFoundReason (Severity=Warning) documents a StaticMachine whose credentials Secret cannot be read.
+	CredentialsNotFoundReason = "CredentialsNotFound"
+)
+
+const (
+	// ExportedCondition reports if the image of the machine has been exported.
+	ExportedCondition clusterv1.ConditionType = "Exported"
+
+	// WaitingForExportRequestReason (Severity=Info) documents a StaticMachine waiting for the Build to request the export.
+	WaitingForExportRequestReason = "WaitingForExportRequest"
+
+	// ExportingReason (Severity=Info) documents a StaticMachine whose root filesystem is being archived.
+	ExportingReason = "Exporting"
+
+	// ExportFailedReason (Severity=Error) documents a StaticMachine whose root filesystem could not be archived.
+	ExportFailedReason = "ExportFailed"
+)
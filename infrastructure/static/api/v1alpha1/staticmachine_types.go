@@ -0,0 +1,129 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+
+	"github.com/forge-build/forge/pkg/contract/infra"
+)
+
+const (
+	// ProviderName is the name of the static infrastructure provider.
+	ProviderName = "static"
+)
+
+// StaticMachineSpec defines the desired state of StaticMachine
+type StaticMachineSpec struct {
+	// Host is the IP address or the DNS name of the existing machine.
+	// +kubebuilder:validation:MinLength=1
+	Host string `json:"host"`
+
+	// Port is the SSH port of the machine.
+	// +kubebuilder:default=22
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	Port int32 `json:"port,omitempty"`
+
+	// CredentialsRef is a reference to a Secret in the same namespace holding the SSH credentials
+	// of the machine, under the username and the password and/or privateKey keys.
+	CredentialsRef corev1.LocalObjectReference `json:"credentialsRef"`
+
+	// Export describes where the root filesystem of the machine is archived when the image is exported.
+	// Nothing is copied when it is not set, the machine itself being the image.
+	// +optional
+	Export *StaticMachineExport `json:"export,omitempty"`
+}
+
+// StaticMachineExport describes the archive of the root filesystem of the machine.
+type StaticMachineExport struct {
+	// PersistentVolumeClaim is the name of the PersistentVolumeClaim, in the same namespace,
+	// the archive is written to.
+	// +kubebuilder:validation:MinLength=1
+	PersistentVolumeClaim string `json:"persistentVolumeClaim"`
+
+	// Path is the path of the archive in the volume, defaults to <name>.tar.gz.
+	// +optional
+	Path string `json:"path,omitempty"`
+
+	// Excludes is a list of paths left out of the archive, in addition to the pseudo filesystems
+	// such as /proc, /sys and /dev.
+	// +optional
+	Excludes []string `json:"excludes,omitempty"`
+}
+
+// StaticMachineStatus defines the observed state of StaticMachine
+type StaticMachineStatus struct {
+	// Status holds the fields of the Forge contract, machineReady is true once the credentials
+	// of the machine are provided to the Build and ready once the image of the machine is exported
+	// to the artifact.
+	infra.Status `json:",inline"`
+
+	// Conditions defines current service state of the StaticMachine.
+	// +optional
+	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:metadata:labels="forge.build/v1alpha1=v1alpha1"
+//+kubebuilder:metadata:labels="forge.build/provider=static"
+//+kubebuilder:resource:path=staticmachines,scope=Namespaced,categories=forge,singular=staticmachine
+//+kubebuilder:printcolumn:name="Host",type="string",JSONPath=".spec.host",description="Host of the machine"
+//+kubebuilder:printcolumn:name="MachineReady",type="string",JSONPath=".status.machineReady",description="Machine ready"
+//+kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.ready",description="Image exported"
+//+kubebuilder:printcolumn:name="Artifact",type="string",JSONPath=".status.artifact",description="Exported image"
+
+// StaticMachine is the Schema for the staticmachines API, an InfraBuild pointing at an existing machine.
+type StaticMachine struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   StaticMachineSpec   `json:"spec,omitempty"`
+	Status StaticMachineStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// StaticMachineList contains a list of StaticMachine
+type StaticMachineList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []StaticMachine `json:"items"`
+}
+
+// GetConditions returns the set of conditions for this object.
+func (m *StaticMachine) GetConditions() clusterv1.Conditions {
+	return m.Status.Conditions
+}
+
+// SetConditions sets the conditions on this object.
+func (m *StaticMachine) SetConditions(conditions clusterv1.Conditions) {
+	m.Status.Conditions = conditions
+}
+
+// GetContractStatus returns the fields of the status read by the Build controller.
+func (m *StaticMachine) GetContractStatus() *infra.Status {
+	return &m.Status.Status
+}
+
+func init() {
+	objectTypes = append(objectTypes, &StaticMachine{}, &StaticMachineList{})
+}
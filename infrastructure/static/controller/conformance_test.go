@@ -0,0 +1,113 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+
+	buildv1 "github.com/forge-build/forge/api/v1alpha1"
+	infrav1 "github.com/forge-build/forge/infrastructure/static/api/v1alpha1"
+	"github.com/forge-build/forge/pkg/contract/infra/conformance"
+)
+
+func TestConformance(t *testing.T) {
+	if os.Getenv("KUBEBUILDER_ASSETS") == "" {
+		t.Skip("KUBEBUILDER_ASSETS is not set, run the envtest tests with make test")
+	}
+
+	testEnv := &envtest.Environment{
+		CRDDirectoryPaths:     []string{filepath.Join("..", "..", "..", "config", "crd", "bases")},
+		ErrorIfCRDPathMissing: true,
+	}
+	cfg, err := testEnv.Start()
+	if err != nil {
+		t.Fatalf("failed to start the test environment: %v", err)
+	}
+	defer func() {
+		if err := testEnv.Stop(); err != nil {
+			t.Errorf("failed to stop the test environment: %v", err)
+		}
+	}()
+
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = apiextensionsv1.AddToScheme(scheme)
+	_ = buildv1.AddToScheme(scheme)
+	_ = infrav1.AddToScheme(scheme)
+
+	mgr, err := ctrl.NewManager(cfg, ctrl.Options{
+		Scheme:  scheme,
+		Metrics: metricsserver.Options{BindAddress: "0"},
+	})
+	if err != nil {
+		t.Fatalf("failed to create the manager: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := (&StaticMachineReconciler{
+		Client:      mgr.GetClient(),
+		Scheme:      mgr.GetScheme(),
+		ExportImage: "forge-provisioner-shell:test",
+	}).SetupWithManager(ctx, mgr, controller.Options{}); err != nil {
+		t.Fatalf("failed to set up the StaticMachine controller: %v", err)
+	}
+	go func() {
+		if err := mgr.Start(ctx); err != nil {
+			t.Errorf("failed to start the manager: %v", err)
+		}
+	}()
+
+	k8sClient, err := client.New(cfg, client.Options{Scheme: scheme})
+	if err != nil {
+		t.Fatalf("failed to create the client: %v", err)
+	}
+
+	conformance.Run(t, conformance.Input{
+		Client:    k8sClient,
+		Namespace: metav1.NamespaceDefault,
+		Objects: []client.Object{
+			&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "conformance-credentials", Namespace: metav1.NamespaceDefault},
+				StringData: map[string]string{"username": "forge", "password": "forge"},
+			},
+		},
+		// The machine itself is the image when no export volume is set.
+		NewInfraBuild: func(name string) client.Object {
+			return &infrav1.StaticMachine{
+				ObjectMeta: metav1.ObjectMeta{Name: name},
+				Spec: infrav1.StaticMachineSpec{
+					Host:           "10.0.0.1",
+					CredentialsRef: corev1.LocalObjectReference{Name: "conformance-credentials"},
+				},
+			}
+		},
+	})
+}
@@ -0,0 +1,213 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+
+	buildv1 "github.com/forge-build/forge/api/v1alpha1"
+	infrav1 "github.com/forge-build/forge/infrastructure/static/api/v1alpha1"
+	"github.com/forge-build/forge/pkg/kube"
+	"github.com/forge-build/forge/provisioner/shell"
+	"github.com/forge-build/forge/provisioner/shell/job"
+)
+
+const (
+	// exportManagedBy is the value of the ManagedByLabel set on the export Jobs.
+	exportManagedBy = "forge-infrastructure-static"
+
+	// exportMountPath is where the export volume is mounted in the export Jobs.
+	exportMountPath = "/export"
+
+	credentialsVolume = "ssh-credentials"
+	exportVolume      = "export"
+)
+
+// pseudoFilesystems are the paths never archived, they are recreated when the machine boots.
+var pseudoFilesystems = []string{"/proc", "/sys", "/dev", "/run", "/tmp"}
+
+// ExportScript returns the script writing a gzipped tarball of the root filesystem of the machine to its stdout.
+func ExportScript(excludes []string) string {
+	args := []string{"-C", "/", "-czf", "-", "--one-file-system", "--numeric-owner"}
+	for _, e := range append(append([]string{}, pseudoFilesystems...), excludes...) {
+		args = append(args, "--exclude="+shellQuote("./"+strings.TrimLeft(path.Clean("/"+e), "/")))
+	}
+	args = append(args, ".")
+
+	return strings.Join([]string{
+		`if [ "$(id -u)" -eq 0 ]; then SUDO=; else SUDO="sudo -n"; fi`,
+		`$SUDO tar ` + strings.Join(args, " "),
+		// tar exits with 1 when files changed while being read, the archive is still usable.
+		`rc=$?`,
+		`[ $rc -le 1 ]`,
+	}, "\n")
+}
+
+// exportJob returns the Job archiving the root filesystem of the machine to the export volume.
+// It runs the shell provisioner with the ssh credentials Secret of the Build mounted.
+func exportJob(machine *infrav1.StaticMachine, build *buildv1.Build, image string) *batchv1.Job {
+	labels := map[string]string{
+		buildv1.ManagedByLabel:      exportManagedBy,
+		buildv1.BuildNameLabel:      build.Name,
+		buildv1.BuildNamespaceLabel: build.Namespace,
+	}
+
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ExportJobName(machine),
+			Namespace: machine.Namespace,
+			Labels:    labels,
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: ptr.To(int32(1)),
+			Completions:  ptr.To(int32(1)),
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: corev1.PodSpec{
+					Affinity:      job.LinuxNodeAffinity(),
+					RestartPolicy: corev1.RestartPolicyNever,
+					Containers: []corev1.Container{
+						{
+							Name:            "export",
+							Image:           image,
+							ImagePullPolicy: corev1.PullIfNotPresent,
+							Args: []string{
+								"--run-script", ExportScript(machine.Spec.Export.Excludes),
+								"--ssh-credentials-path", shell.CredentialsSecretPath,
+								"--output-file", path.Join(exportMountPath, exportPath(machine)),
+							},
+							TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
+							VolumeMounts: []corev1.VolumeMount{
+								{Name: credentialsVolume, MountPath: shell.CredentialsSecretPath, ReadOnly: true},
+								{Name: exportVolume, MountPath: exportMountPath},
+							},
+						},
+					},
+					Volumes: []corev1.Volume{
+						{
+							Name: credentialsVolume,
+							VolumeSource: corev1.VolumeSource{
+								Secret: &corev1.SecretVolumeSource{SecretName: build.Spec.Connector.Credentials.Name},
+							},
+						},
+						{
+							Name: exportVolume,
+							VolumeSource: corev1.VolumeSource{
+								PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+									ClaimName: machine.Spec.Export.PersistentVolumeClaim,
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// deleteImageJob returns the Job removing the archive of the root filesystem of the machine from the export volume.
+func deleteImageJob(machine *infrav1.StaticMachine, image string) *batchv1.Job {
+	labels := map[string]string{
+		buildv1.ManagedByLabel: exportManagedBy,
+	}
+	for _, l := range []string{buildv1.BuildNameLabel, buildv1.BuildNamespaceLabel} {
+		if v, ok := machine.Labels[l]; ok {
+			labels[l] = v
+		}
+	}
+
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      DeleteImageJobName(machine),
+			Namespace: machine.Namespace,
+			Labels:    labels,
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: ptr.To(int32(1)),
+			Completions:  ptr.To(int32(1)),
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: corev1.PodSpec{
+					Affinity:      job.LinuxNodeAffinity(),
+					RestartPolicy: corev1.RestartPolicyNever,
+					Containers: []corev1.Container{
+						{
+							Name:            "delete-image",
+							Image:           image,
+							ImagePullPolicy: corev1.PullIfNotPresent,
+							Args: []string{
+								"--remove-file", path.Join(exportMountPath, exportPath(machine)),
+							},
+							TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
+							VolumeMounts: []corev1.VolumeMount{
+								{Name: exportVolume, MountPath: exportMountPath},
+							},
+						},
+					},
+					Volumes: []corev1.Volume{
+						{
+							Name: exportVolume,
+							VolumeSource: corev1.VolumeSource{
+								PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+									ClaimName: machine.Spec.Export.PersistentVolumeClaim,
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// ExportJobName returns the name of the Job exporting the machine.
+func ExportJobName(machine *infrav1.StaticMachine) string {
+	return fmt.Sprintf("forge-export-%s", kube.ComputeHash(machine.Namespace+"/"+machine.Name))
+}
+
+// DeleteImageJobName returns the name of the Job deleting the image exported from the machine.
+func DeleteImageJobName(machine *infrav1.StaticMachine) string {
+	return fmt.Sprintf("forge-delete-export-%s", kube.ComputeHash(machine.Namespace+"/"+machine.Name))
+}
+
+// exportPath returns the path of the archive in the export volume.
+func exportPath(machine *infrav1.StaticMachine) string {
+	if machine.Spec.Export.Path != "" {
+		return strings.TrimLeft(path.Clean("/"+machine.Spec.Export.Path), "/")
+	}
+	return machine.Name + ".tar.gz"
+}
+
+// artifact returns the location of the exported image.
+func artifact(machine *infrav1.StaticMachine) string {
+	return fmt.Sprintf("pvc://%s/%s", machine.Spec.Export.PersistentVolumeClaim, exportPath(machine))
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,252 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package controller implements the controller of the static infrastructure provider,
+// the reference implementation of the Forge InfraBuild contract.
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+
+	buildv1 "github.com/forge-build/forge/api/v1alpha1"
+	infrav1 "github.com/forge-build/forge/infrastructure/static/api/v1alpha1"
+	"github.com/forge-build/forge/pkg/contract/infra"
+	forgeerrors "github.com/forge-build/forge/pkg/errors"
+	"github.com/forge-build/forge/util"
+	"github.com/forge-build/forge/util/predicates"
+)
+
+const (
+	// credentialsRequeueAfter is how long to wait before reading the credentials Secret again when it is missing.
+	credentialsRequeueAfter = 30 * time.Second
+)
+
+// StaticMachineReconciler reconciles a StaticMachine object
+type StaticMachineReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	// ExportImage is the image of the Jobs archiving the root filesystem of the machines,
+	// it runs the shell provisioner.
+	ExportImage string
+
+	// WatchFilterValue is the label value used to filter events prior to reconciliation.
+	WatchFilterValue string
+
+	recorder record.EventRecorder
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *StaticMachineReconciler) SetupWithManager(ctx context.Context, mgr ctrl.Manager, options controller.Options) error {
+	err := ctrl.NewControllerManagedBy(mgr).
+		For(&infrav1.StaticMachine{}).
+		Owns(&batchv1.Job{}).
+		WithOptions(options).
+		WithEventFilter(predicates.ResourceNotPausedAndHasFilterLabel(ctrl.LoggerFrom(ctx), r.WatchFilterValue)).
+		Watches(
+			&buildv1.Build{},
+			handler.EnqueueRequestsFromMapFunc(util.BuildToInfrastructureMapFunc(ctx, infrav1.GroupVersion.WithKind("StaticMachine"), mgr.GetClient(), &infrav1.StaticMachine{})),
+		).
+		Complete(r)
+	if err != nil {
+		return errors.Wrap(err, "failed setting up with a controller manager")
+	}
+
+	r.recorder = mgr.GetEventRecorderFor("staticmachine-controller")
+	return nil
+}
+
+//+kubebuilder:rbac:groups=infrastructure.forge.build,resources=staticmachines,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups=infrastructure.forge.build,resources=staticmachines/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=forge.build,resources=builds,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;update;patch
+//+kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;delete
+
+// Reconcile provides the Build with the credentials of the machine and exports its
+// root filesystem once requested.
+func (r *StaticMachineReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	return (&infra.Reconciler[*infrav1.StaticMachine]{
+		Client:       r.Client,
+		ProviderName: infrav1.ProviderName,
+		NewObject:    func() *infrav1.StaticMachine { return &infrav1.StaticMachine{} },
+		Provider:     r,
+		OwnedConditions: []clusterv1.ConditionType{
+			infrav1.CredentialsReadyCondition,
+			infrav1.ExportedCondition,
+		},
+	}).Reconcile(ctx, req)
+}
+
+// ReconcileMachine returns the SSH credentials of the machine, read from the Secret referenced by the StaticMachine.
+func (r *StaticMachineReconciler) ReconcileMachine(ctx context.Context, _ *buildv1.Build, machine *infrav1.StaticMachine) (*util.SSHCredentials, ctrl.Result, error) {
+	secret := &corev1.Secret{}
+	if err := r.Client.Get(ctx, client.ObjectKey{Namespace: machine.Namespace, Name: machine.Spec.CredentialsRef.Name}, secret); err != nil {
+		if apierrors.IsNotFound(err) {
+			conditions.MarkFalse(machine, infrav1.CredentialsReadyCondition, infrav1.CredentialsNotFoundReason, clusterv1.ConditionSeverityWarning,
+				"Secret %s not found", machine.Spec.CredentialsRef.Name)
+			return nil, ctrl.Result{RequeueAfter: credentialsRequeueAfter}, nil
+		}
+		return nil, ctrl.Result{}, errors.Wrap(err, "failed to get the credentials secret")
+	}
+
+	conditions.MarkTrue(machine, infrav1.CredentialsReadyCondition)
+	conditions.MarkFalse(machine, infrav1.ExportedCondition, infrav1.WaitingForExportRequestReason, clusterv1.ConditionSeverityInfo, "")
+	return &util.SSHCredentials{
+		Host:       machine.Spec.Host,
+		Port:       machinePort(machine),
+		Username:   string(secret.Data["username"]),
+		Password:   string(secret.Data["password"]),
+		PrivateKey: string(secret.Data["privateKey"]),
+		PublicKey:  string(secret.Data["publicKey"]),
+	}, ctrl.Result{}, nil
+}
+
+// ReconcileExport archives the root filesystem of the machine to the export volume.
+func (r *StaticMachineReconciler) ReconcileExport(ctx context.Context, build *buildv1.Build, machine *infrav1.StaticMachine) (bool, ctrl.Result, error) {
+	log := ctrl.LoggerFrom(ctx)
+
+	// The machine itself is the image.
+	if machine.Spec.Export == nil {
+		r.markExported(machine, fmt.Sprintf("ssh://%s", hostPort(machine)))
+		return true, ctrl.Result{}, nil
+	}
+
+	if build.Spec.Connector.Credentials == nil {
+		return false, ctrl.Result{}, errors.New("the Build has no credentials secret")
+	}
+
+	desired := exportJob(machine, build, r.ExportImage)
+	if err := controllerutil.SetControllerReference(machine, desired, r.Client.Scheme()); err != nil {
+		return false, ctrl.Result{}, err
+	}
+	job := &batchv1.Job{}
+	if err := r.Client.Get(ctx, client.ObjectKeyFromObject(desired), job); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return false, ctrl.Result{}, errors.Wrap(err, "failed to get the export job")
+		}
+		log.Info("Exporting the root filesystem of the machine", "job", desired.Name)
+		if err := r.Client.Create(ctx, desired); err != nil {
+			return false, ctrl.Result{}, errors.Wrap(err, "failed to create the export job")
+		}
+		r.recorder.Eventf(machine, corev1.EventTypeNormal, "Exporting", "Archiving the root filesystem to %s", artifact(machine))
+		conditions.MarkFalse(machine, infrav1.ExportedCondition, infrav1.ExportingReason, clusterv1.ConditionSeverityInfo, "")
+		return false, ctrl.Result{}, nil
+	}
+
+	for _, c := range job.Status.Conditions {
+		if c.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch c.Type {
+		case batchv1.JobComplete:
+			r.markExported(machine, artifact(machine))
+			return true, ctrl.Result{}, nil
+		case batchv1.JobFailed:
+			machine.Status.MarkFailed(forgeerrors.ExportFailedError, "export job %s failed: %s", job.Name, c.Message)
+			conditions.MarkFalse(machine, infrav1.ExportedCondition, infrav1.ExportFailedReason, clusterv1.ConditionSeverityError, c.Message)
+			r.recorder.Eventf(machine, corev1.EventTypeWarning, "ExportFailed", "Export job %s failed: %s", job.Name, c.Message)
+			return false, ctrl.Result{}, nil
+		}
+	}
+
+	conditions.MarkFalse(machine, infrav1.ExportedCondition, infrav1.ExportingReason, clusterv1.ConditionSeverityInfo, "")
+	return false, ctrl.Result{}, nil
+}
+
+// ReconcileDeleteImage removes the archive of the root filesystem from the export volume.
+// When the machine itself is the image there is nothing to delete, the machine is left untouched.
+func (r *StaticMachineReconciler) ReconcileDeleteImage(ctx context.Context, _ *buildv1.Build, machine *infrav1.StaticMachine) (bool, ctrl.Result, error) {
+	log := ctrl.LoggerFrom(ctx)
+
+	if machine.Spec.Export == nil {
+		r.recorder.Event(machine, corev1.EventTypeNormal, "ImageDeleted", "The machine is the image, it is not deleted")
+		return true, ctrl.Result{}, nil
+	}
+
+	desired := deleteImageJob(machine, r.ExportImage)
+	if err := controllerutil.SetControllerReference(machine, desired, r.Client.Scheme()); err != nil {
+		return false, ctrl.Result{}, err
+	}
+	job := &batchv1.Job{}
+	if err := r.Client.Get(ctx, client.ObjectKeyFromObject(desired), job); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return false, ctrl.Result{}, errors.Wrap(err, "failed to get the delete image job")
+		}
+		log.Info("Deleting the exported image", "job", desired.Name)
+		if err := r.Client.Create(ctx, desired); err != nil {
+			return false, ctrl.Result{}, errors.Wrap(err, "failed to create the delete image job")
+		}
+		return false, ctrl.Result{}, nil
+	}
+
+	for _, c := range job.Status.Conditions {
+		if c.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch c.Type {
+		case batchv1.JobComplete:
+			r.recorder.Eventf(machine, corev1.EventTypeNormal, "ImageDeleted", "Image %s deleted", machine.Status.Artifact)
+			return true, ctrl.Result{}, nil
+		case batchv1.JobFailed:
+			machine.Status.MarkFailed(forgeerrors.DeleteBuildError, "delete image job %s failed: %s", job.Name, c.Message)
+			r.recorder.Eventf(machine, corev1.EventTypeWarning, "ImageDeletionFailed", "Delete image job %s failed: %s", job.Name, c.Message)
+			return false, ctrl.Result{}, nil
+		}
+	}
+	return false, ctrl.Result{}, nil
+}
+
+// ReconcileDelete does nothing, the StaticMachines have no finalizer and the export Job
+// is garbage collected with them.
+func (r *StaticMachineReconciler) ReconcileDelete(_ context.Context, _ *buildv1.Build, _ *infrav1.StaticMachine) (bool, ctrl.Result, error) {
+	return true, ctrl.Result{}, nil
+}
+
+func (r *StaticMachineReconciler) markExported(machine *infrav1.StaticMachine, artifact string) {
+	machine.Status.Artifact = artifact
+	conditions.MarkTrue(machine, infrav1.ExportedCondition)
+	r.recorder.Eventf(machine, corev1.EventTypeNormal, "Exported", "Image exported to %s", artifact)
+}
+
+// machinePort returns the SSH port of the machine, 22 when it is not set.
+func machinePort(machine *infrav1.StaticMachine) int {
+	if machine.Spec.Port == 0 {
+		return 22
+	}
+	return int(machine.Spec.Port)
+}
+
+func hostPort(machine *infrav1.StaticMachine) string {
+	return net.JoinHostPort(machine.Spec.Host, strconv.Itoa(machinePort(machine)))
+}
@@ -0,0 +1,299 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	buildv1 "github.com/forge-build/forge/api/v1alpha1"
+	infrav1 "github.com/forge-build/forge/infrastructure/static/api/v1alpha1"
+	forgeerrors "github.com/forge-build/forge/pkg/errors"
+)
+
+func newTestObjects() (*buildv1.Build, *infrav1.StaticMachine, *corev1.Secret) {
+	build := &buildv1.Build{
+		TypeMeta:   metav1.TypeMeta{APIVersion: buildv1.GroupVersion.String(), Kind: "Build"},
+		ObjectMeta: metav1.ObjectMeta{Name: "ubuntu", Namespace: "default", UID: "build-uid"},
+		Spec: buildv1.BuildSpec{
+			InfrastructureRef: &corev1.ObjectReference{
+				APIVersion: infrav1.GroupVersion.String(),
+				Kind:       "StaticMachine",
+				Name:       "machine",
+			},
+		},
+	}
+	machine := &infrav1.StaticMachine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "machine",
+			Namespace: "default",
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: buildv1.GroupVersion.String(),
+				Kind:       "Build",
+				Name:       "ubuntu",
+				UID:        "build-uid",
+			}},
+		},
+		Spec: infrav1.StaticMachineSpec{
+			Host:           "10.0.0.1",
+			Port:           2222,
+			CredentialsRef: corev1.LocalObjectReference{Name: "machine-credentials"},
+		},
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "machine-credentials", Namespace: "default"},
+		Data: map[string][]byte{
+			"username": []byte("forge"),
+			"password": []byte("secret"),
+		},
+	}
+	return build, machine, secret
+}
+
+func newTestReconciler(objs ...client.Object) *StaticMachineReconciler {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = buildv1.AddToScheme(scheme)
+	_ = infrav1.AddToScheme(scheme)
+
+	return &StaticMachineReconciler{
+		Client: fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(objs...).
+			WithStatusSubresource(&buildv1.Build{}, &infrav1.StaticMachine{}).
+			Build(),
+		Scheme:      scheme,
+		ExportImage: "forge-provisioner-shell:test",
+		recorder:    record.NewFakeRecorder(10),
+	}
+}
+
+func reconcileMachine(g *WithT, r *StaticMachineReconciler) *infrav1.StaticMachine {
+	ctx := context.Background()
+	_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKey{Namespace: "default", Name: "machine"}})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	machine := &infrav1.StaticMachine{}
+	g.Expect(r.Client.Get(ctx, client.ObjectKey{Namespace: "default", Name: "machine"}, machine)).To(Succeed())
+	return machine
+}
+
+func TestReconcileWithoutOwner(t *testing.T) {
+	g := NewWithT(t)
+	_, machine, secret := newTestObjects()
+	machine.OwnerReferences = nil
+	r := newTestReconciler(machine, secret)
+
+	machine = reconcileMachine(g, r)
+	g.Expect(machine.Status.MachineReady).To(BeFalse())
+}
+
+func TestReconcilePaused(t *testing.T) {
+	g := NewWithT(t)
+	build, machine, secret := newTestObjects()
+	build.Spec.Paused = true
+	r := newTestReconciler(build, machine, secret)
+
+	machine = reconcileMachine(g, r)
+	g.Expect(machine.Status.MachineReady).To(BeFalse())
+}
+
+func TestReconcileCredentials(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	build, machine, secret := newTestObjects()
+	r := newTestReconciler(build, machine)
+
+	// The credentials are not there yet.
+	machine = reconcileMachine(g, r)
+	g.Expect(machine.Status.MachineReady).To(BeFalse())
+	g.Expect(conditions.GetReason(machine, infrav1.CredentialsReadyCondition)).To(Equal(infrav1.CredentialsNotFoundReason))
+
+	g.Expect(r.Client.Create(ctx, secret)).To(Succeed())
+	machine = reconcileMachine(g, r)
+	g.Expect(machine.Status.MachineReady).To(BeTrue())
+	g.Expect(machine.Status.Ready).To(BeFalse())
+	g.Expect(conditions.IsTrue(machine, infrav1.CredentialsReadyCondition)).To(BeTrue())
+	g.Expect(conditions.GetReason(machine, infrav1.ExportedCondition)).To(Equal(infrav1.WaitingForExportRequestReason))
+
+	g.Expect(r.Client.Get(ctx, client.ObjectKeyFromObject(build), build)).To(Succeed())
+	g.Expect(build.Spec.Connector.Credentials).NotTo(BeNil())
+	credentials := &corev1.Secret{}
+	g.Expect(r.Client.Get(ctx, client.ObjectKey{Namespace: "default", Name: build.Spec.Connector.Credentials.Name}, credentials)).To(Succeed())
+	g.Expect(credentials.StringData).To(HaveKeyWithValue("host", "10.0.0.1"))
+	g.Expect(credentials.StringData).To(HaveKeyWithValue("port", "2222"))
+	g.Expect(credentials.StringData).To(HaveKeyWithValue("username", "forge"))
+	g.Expect(credentials.StringData).To(HaveKeyWithValue("password", "secret"))
+}
+
+func TestReconcileExportWithoutVolume(t *testing.T) {
+	g := NewWithT(t)
+	build, machine, secret := newTestObjects()
+	machine.Annotations = map[string]string{buildv1.ExportAnnotation: ""}
+	r := newTestReconciler(build, machine, secret)
+
+	machine = reconcileMachine(g, r)
+	g.Expect(machine.Status.Ready).To(BeTrue())
+	g.Expect(machine.Status.Artifact).To(Equal("ssh://10.0.0.1:2222"))
+	g.Expect(conditions.IsTrue(machine, infrav1.ExportedCondition)).To(BeTrue())
+}
+
+func TestReconcileExport(t *testing.T) {
+	ctx := context.Background()
+
+	for _, tt := range []struct {
+		name         string
+		condition    batchv1.JobConditionType
+		wantReady    bool
+		wantArtifact string
+		wantFailure  bool
+	}{
+		{name: "export completed", condition: batchv1.JobComplete, wantReady: true, wantArtifact: "pvc://images/ubuntu/22.04.tar.gz"},
+		{name: "export failed", condition: batchv1.JobFailed, wantFailure: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			build, machine, secret := newTestObjects()
+			machine.Spec.Export = &infrav1.StaticMachineExport{
+				PersistentVolumeClaim: "images",
+				Path:                  "/ubuntu/22.04.tar.gz",
+				Excludes:              []string{"/var/cache/apt"},
+			}
+			r := newTestReconciler(build, machine, secret)
+
+			// The export is not requested yet.
+			machine = reconcileMachine(g, r)
+			jobs := &batchv1.JobList{}
+			g.Expect(r.Client.List(ctx, jobs)).To(Succeed())
+			g.Expect(jobs.Items).To(BeEmpty())
+
+			machine.Annotations = map[string]string{buildv1.ExportAnnotation: ""}
+			g.Expect(r.Client.Update(ctx, machine)).To(Succeed())
+			machine = reconcileMachine(g, r)
+			g.Expect(machine.Status.Ready).To(BeFalse())
+			g.Expect(conditions.GetReason(machine, infrav1.ExportedCondition)).To(Equal(infrav1.ExportingReason))
+
+			job := &batchv1.Job{}
+			g.Expect(r.Client.Get(ctx, client.ObjectKey{Namespace: "default", Name: ExportJobName(machine)}, job)).To(Succeed())
+			g.Expect(job.OwnerReferences).To(HaveLen(1))
+			g.Expect(job.OwnerReferences[0].Name).To(Equal("machine"))
+			g.Expect(job.Labels).To(HaveKeyWithValue(buildv1.BuildNameLabel, "ubuntu"))
+			g.Expect(job.Labels).NotTo(HaveKey(buildv1.ProvisionerIDLabel))
+			container := job.Spec.Template.Spec.Containers[0]
+			g.Expect(container.Image).To(Equal("forge-provisioner-shell:test"))
+			g.Expect(strings.Join(container.Args, " ")).To(ContainSubstring("--output-file /export/ubuntu/22.04.tar.gz"))
+			g.Expect(job.Spec.Template.Spec.Volumes[0].Secret.SecretName).To(Equal("ubuntu-ssh-credentials"))
+			g.Expect(job.Spec.Template.Spec.Volumes[1].PersistentVolumeClaim.ClaimName).To(Equal("images"))
+
+			job.Status.Conditions = []batchv1.JobCondition{{Type: tt.condition, Status: corev1.ConditionTrue, Message: "BackoffLimitExceeded"}}
+			g.Expect(r.Client.Status().Update(ctx, job)).To(Succeed())
+			machine = reconcileMachine(g, r)
+			g.Expect(machine.Status.Ready).To(Equal(tt.wantReady))
+			g.Expect(machine.Status.Artifact).To(Equal(tt.wantArtifact))
+			if tt.wantFailure {
+				g.Expect(machine.Status.FailureReason).To(HaveValue(Equal(forgeerrors.ExportFailedError)))
+				g.Expect(machine.Status.FailureMessage).To(HaveValue(ContainSubstring("BackoffLimitExceeded")))
+			} else {
+				g.Expect(machine.Status.FailureReason).To(BeNil())
+			}
+		})
+	}
+}
+
+func TestReconcileDeleteImage(t *testing.T) {
+	ctx := context.Background()
+
+	for _, tt := range []struct {
+		name        string
+		condition   batchv1.JobConditionType
+		wantDeleted bool
+		wantFailure bool
+	}{
+		{name: "image deleted", condition: batchv1.JobComplete, wantDeleted: true},
+		{name: "image deletion failed", condition: batchv1.JobFailed, wantFailure: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			build, machine, secret := newTestObjects()
+			machine.Spec.Export = &infrav1.StaticMachineExport{PersistentVolumeClaim: "images", Path: "/ubuntu/22.04.tar.gz"}
+			machine.Annotations = map[string]string{buildv1.ExportAnnotation: "", buildv1.DeleteImageAnnotation: ""}
+			machine.Status.MarkMachineReady()
+			machine.Status.MarkReady()
+			machine.Status.Artifact = "pvc://images/ubuntu/22.04.tar.gz"
+			r := newTestReconciler(build, machine, secret)
+
+			machine = reconcileMachine(g, r)
+			g.Expect(machine.Status.ImageDeleted).To(BeFalse())
+
+			job := &batchv1.Job{}
+			g.Expect(r.Client.Get(ctx, client.ObjectKey{Namespace: "default", Name: DeleteImageJobName(machine)}, job)).To(Succeed())
+			g.Expect(job.OwnerReferences).To(HaveLen(1))
+			container := job.Spec.Template.Spec.Containers[0]
+			g.Expect(container.Args).To(Equal([]string{"--remove-file", "/export/ubuntu/22.04.tar.gz"}))
+			g.Expect(job.Spec.Template.Spec.Volumes[0].PersistentVolumeClaim.ClaimName).To(Equal("images"))
+
+			job.Status.Conditions = []batchv1.JobCondition{{Type: tt.condition, Status: corev1.ConditionTrue, Message: "BackoffLimitExceeded"}}
+			g.Expect(r.Client.Status().Update(ctx, job)).To(Succeed())
+			machine = reconcileMachine(g, r)
+			g.Expect(machine.Status.ImageDeleted).To(Equal(tt.wantDeleted))
+			if tt.wantFailure {
+				g.Expect(machine.Status.FailureReason).To(HaveValue(Equal(forgeerrors.DeleteBuildError)))
+			} else {
+				g.Expect(machine.Status.FailureReason).To(BeNil())
+			}
+		})
+	}
+}
+
+func TestReconcileDeleteImageWithoutVolume(t *testing.T) {
+	g := NewWithT(t)
+	build, machine, secret := newTestObjects()
+	machine.Annotations = map[string]string{buildv1.DeleteImageAnnotation: ""}
+	machine.Status.MarkMachineReady()
+	machine.Status.MarkReady()
+	r := newTestReconciler(build, machine, secret)
+
+	// The machine itself is the image, it is left untouched.
+	machine = reconcileMachine(g, r)
+	g.Expect(machine.Status.ImageDeleted).To(BeTrue())
+	jobs := &batchv1.JobList{}
+	g.Expect(r.Client.List(context.Background(), jobs)).To(Succeed())
+	g.Expect(jobs.Items).To(BeEmpty())
+}
+
+func TestExportScript(t *testing.T) {
+	g := NewWithT(t)
+
+	script := ExportScript([]string{"/var/cache/apt/", "home/it's"})
+	g.Expect(script).To(ContainSubstring(`$SUDO tar -C / -czf - --one-file-system --numeric-owner --exclude='./proc'`))
+	g.Expect(script).To(ContainSubstring(`--exclude='./var/cache/apt'`))
+	g.Expect(script).To(ContainSubstring(`--exclude='./home/it'\''s' .`))
+	g.Expect(script).To(HaveSuffix(`[ $rc -le 1 ]`))
+}
@@ -0,0 +1,257 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/cluster-api/util/patch"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	buildv1 "github.com/forge-build/forge/api/v1alpha1"
+	"github.com/forge-build/forge/internal/external"
+)
+
+// reconcileBaseImage waits for the base Build to export its image and sets it in the InfraBuild
+// before the infrastructure provider creates the machine.
+func (r *BuildReconciler) reconcileBaseImage(ctx context.Context, build *buildv1.Build) (ctrl.Result, error) {
+	log := ctrl.LoggerFrom(ctx)
+
+	source := build.Spec.BaseImageFrom
+	if source == nil {
+		return ctrl.Result{}, nil
+	}
+
+	base := &buildv1.Build{}
+	if err := r.Client.Get(ctx, client.ObjectKey{Namespace: build.Namespace, Name: source.Name}, base); err != nil {
+		if apierrors.IsNotFound(err) {
+			conditions.MarkFalse(build, buildv1.BaseImageReadyCondition, buildv1.WaitingForBaseImageReason, buildv1.ConditionSeverityInfo,
+				"Base Build %s not found", source.Name)
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, errors.Wrapf(err, "failed to get the base Build %s", source.Name)
+	}
+
+	if !base.Status.Ready || base.Status.Artifact == "" {
+		if base.Status.GetTypedPhase() == buildv1.BuildPhaseFailed {
+			conditions.MarkFalse(build, buildv1.BaseImageReadyCondition, buildv1.BaseImageFailedReason, buildv1.ConditionSeverityWarning,
+				"Base Build %s failed: %s", base.Name, ptr.Deref(base.Status.FailureMessage, "unknown"))
+		} else {
+			conditions.MarkFalse(build, buildv1.BaseImageReadyCondition, buildv1.WaitingForBaseImageReason, buildv1.ConditionSeverityInfo,
+				"Waiting for base Build %s to export its image", base.Name)
+		}
+		return ctrl.Result{}, nil
+	}
+
+	switch current := baseImage(build); {
+	case current == base.Status.Artifact:
+	case current == "":
+		ok, err := r.injectBaseImage(ctx, build, base.Status.Artifact)
+		if err != nil || !ok {
+			return ctrl.Result{RequeueAfter: 30 * time.Second}, err
+		}
+		build.Status.Lineage = lineage(base)
+		r.recorder.Eventf(build, corev1.EventTypeNormal, "BaseImage", "Building from the image %s of Build %s", base.Status.Artifact, base.Name)
+	case source.Rebuild:
+		log.Info("Base Build exported a new image, rebuilding", "base", base.Name, "artifact", base.Status.Artifact)
+		r.recorder.Eventf(build, corev1.EventTypeNormal, "Rebuild", "Base Build %s exported the new image %s", base.Name, base.Status.Artifact)
+		setAnnotation(build, buildv1.RebuildAnnotation, "")
+		return ctrl.Result{Requeue: true}, nil
+	default:
+		r.recorder.Eventf(build, corev1.EventTypeNormal, "BaseImageOutdated", "Base Build %s exported the new image %s, the Build is not rebuilt", base.Name, base.Status.Artifact)
+	}
+
+	conditions.MarkTrue(build, buildv1.BaseImageReadyCondition)
+	return ctrl.Result{}, nil
+}
+
+// injectBaseImage sets the base image in the InfraBuild, it returns false if the InfraBuild does not exist yet.
+func (r *BuildReconciler) injectBaseImage(ctx context.Context, build *buildv1.Build, artifact string) (bool, error) {
+	obj, err := external.Get(ctx, r.Client, build.Spec.InfrastructureRef, build.Namespace)
+	if err != nil {
+		if apierrors.IsNotFound(errors.Cause(err)) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	patchHelper, err := patch.NewHelper(obj, r.Client)
+	if err != nil {
+		return false, err
+	}
+	fieldPath := build.Spec.BaseImageFrom.GetFieldPath()
+	if err := unstructured.SetNestedField(obj.Object, artifact, strings.Split(fieldPath, ".")...); err != nil {
+		return false, errors.Wrapf(err, "failed to set %s on %v %q", fieldPath, obj.GroupVersionKind(), obj.GetName())
+	}
+	if err := patchHelper.Patch(ctx, obj); err != nil {
+		return false, errors.Wrapf(err, "failed to set the base image of %v %q", obj.GroupVersionKind(), obj.GetName())
+	}
+	return true, nil
+}
+
+// baseImage returns the base image the Build is built from, empty if it is not set yet.
+func baseImage(build *buildv1.Build) string {
+	if len(build.Status.Lineage) == 0 {
+		return ""
+	}
+	return build.Status.Lineage[len(build.Status.Lineage)-1].Artifact
+}
+
+// lineage returns the lineage of the images built from the image of the base Build.
+func lineage(base *buildv1.Build) []buildv1.LineageEntry {
+	entries := make([]buildv1.LineageEntry, 0, len(base.Status.Lineage)+1)
+	entries = append(entries, base.Status.Lineage...)
+	return append(entries, buildv1.LineageEntry{BuildName: base.Name, Artifact: base.Status.Artifact})
+}
+
+// baseImageToBuilds maps a Build to the Builds built from its image.
+func (r *BuildReconciler) baseImageToBuilds(ctx context.Context, o client.Object) []reconcile.Request {
+	builds := &buildv1.BuildList{}
+	if err := r.Client.List(ctx, builds, client.InNamespace(o.GetNamespace())); err != nil {
+		ctrl.LoggerFrom(ctx).Error(err, "Failed to list the Builds built from the image of a Build", "build", o.GetName())
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, b := range builds.Items {
+		if b.Spec.BaseImageFrom != nil && b.Spec.BaseImageFrom.Name == o.GetName() {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&b)})
+		}
+	}
+	return requests
+}
+
+// reconcileRebuild runs a Build again on a new machine: it keeps a copy of the InfraBuild in the
+// rebuild annotation, deletes the InfraBuild, creates it again from the copy and resets the Build.
+func (r *BuildReconciler) reconcileRebuild(ctx context.Context, build *buildv1.Build) (ctrl.Result, error) {
+	log := ctrl.LoggerFrom(ctx)
+
+	// The variants of matrix Builds are rebuilt instead.
+	if build.Spec.Matrix != nil {
+		if err := r.annotateVariants(ctx, build, buildv1.RebuildAnnotation); err != nil {
+			return ctrl.Result{}, err
+		}
+		removeAnnotation(build, buildv1.RebuildAnnotation)
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	stash := build.GetAnnotations()[buildv1.RebuildAnnotation]
+	obj, err := external.Get(ctx, r.Client, build.Spec.InfrastructureRef, build.Namespace)
+	switch {
+	case err != nil && !apierrors.IsNotFound(errors.Cause(err)):
+		return ctrl.Result{}, err
+	case err == nil && !obj.GetDeletionTimestamp().IsZero():
+		log.V(3).Info("Waiting for the InfraBuild to be deleted")
+		return ctrl.Result{RequeueAfter: deleteRequeueAfter}, nil
+	case err == nil && stash == "":
+		// Keep a copy of the InfraBuild before deleting it.
+		stash, err := stashInfraBuild(obj)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		setAnnotation(build, buildv1.RebuildAnnotation, stash)
+		return ctrl.Result{Requeue: true}, nil
+	case err == nil:
+		log.Info("Deleting the InfraBuild to rebuild the image")
+		if err := r.Client.Delete(ctx, obj); err != nil && !apierrors.IsNotFound(err) {
+			return ctrl.Result{}, errors.Wrapf(err, "failed to delete %v %q", obj.GroupVersionKind(), obj.GetName())
+		}
+		return ctrl.Result{RequeueAfter: deleteRequeueAfter}, nil
+	}
+
+	if stash != "" {
+		obj := &unstructured.Unstructured{}
+		if err := json.Unmarshal([]byte(stash), &obj.Object); err != nil {
+			return ctrl.Result{}, errors.Wrap(err, "failed to read the copy of the InfraBuild")
+		}
+		if err := r.Client.Create(ctx, obj); err != nil && !apierrors.IsAlreadyExists(err) {
+			return ctrl.Result{}, errors.Wrapf(err, "failed to create %v %q", obj.GroupVersionKind(), obj.GetName())
+		}
+	}
+
+	resetBuild(build)
+	removeAnnotation(build, buildv1.RebuildAnnotation)
+	r.recorder.Eventf(build, corev1.EventTypeNormal, "Rebuilding", "Build %s is rebuilt", build.Name)
+	return ctrl.Result{Requeue: true}, nil
+}
+
+// stashInfraBuild returns a copy of the InfraBuild to create it again, without its status and ownership.
+func stashInfraBuild(obj *unstructured.Unstructured) (string, error) {
+	stash := &unstructured.Unstructured{Object: map[string]interface{}{}}
+	stash.SetAPIVersion(obj.GetAPIVersion())
+	stash.SetKind(obj.GetKind())
+	stash.SetName(obj.GetName())
+	stash.SetNamespace(obj.GetNamespace())
+	stash.SetLabels(obj.GetLabels())
+	annotations := obj.GetAnnotations()
+	delete(annotations, buildv1.ExportAnnotation)
+	stash.SetAnnotations(annotations)
+	if spec, ok := obj.Object["spec"]; ok {
+		stash.Object["spec"] = spec
+	}
+
+	data, err := json.Marshal(stash.Object)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to copy %v %q", obj.GroupVersionKind(), obj.GetName())
+	}
+	return string(data), nil
+}
+
+// resetBuild resets the status of a Build and of its provisioners so that all its steps run again.
+func resetBuild(build *buildv1.Build) {
+	for i := range build.Spec.Provisioners {
+		p := &build.Spec.Provisioners[i]
+		p.UUID = nil
+		p.Status = nil
+		p.FailureReason = nil
+		p.FailureMessage = nil
+		p.ExitCode = nil
+		p.Result = nil
+		p.Retried = 0
+		p.RetryTime = nil
+	}
+	build.Status = buildv1.BuildStatus{}
+	removeAnnotation(build, buildv1.RetryAnnotation)
+	removeAnnotation(build, buildv1.CancelAnnotation)
+	removeAnnotation(build, buildv1.TraceParentAnnotation)
+}
+
+func setAnnotation(obj client.Object, key, value string) {
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[key] = value
+	obj.SetAnnotations(annotations)
+}
+
+func removeAnnotation(obj client.Object, key string) {
+	annotations := obj.GetAnnotations()
+	delete(annotations, key)
+	obj.SetAnnotations(annotations)
+}
@@ -0,0 +1,212 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	buildv1 "github.com/forge-build/forge/api/v1alpha1"
+	infrav1 "github.com/forge-build/forge/infrastructure/static/api/v1alpha1"
+	forgeerrors "github.com/forge-build/forge/pkg/errors"
+)
+
+func newBaseImageObjects() (*buildv1.Build, *infrav1.StaticMachine) {
+	build := &buildv1.Build{
+		ObjectMeta: metav1.ObjectMeta{Name: "hardened", Namespace: "default"},
+		Spec: buildv1.BuildSpec{
+			InfrastructureRef: &corev1.ObjectReference{
+				APIVersion: infrav1.GroupVersion.String(),
+				Kind:       "StaticMachine",
+				Name:       "hardened",
+			},
+			BaseImageFrom: &buildv1.BaseImageSource{Name: "base", FieldPath: "spec.host"},
+			Provisioners: []buildv1.ProvisionerSpec{{
+				Type:   buildv1.ProvisionerTypeShell,
+				UUID:   ptr.To("1234"),
+				Status: ptr.To(buildv1.ProvisionerStatusCompleted),
+			}},
+		},
+	}
+	machine := &infrav1.StaticMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "hardened", Namespace: "default"},
+		Spec:       infrav1.StaticMachineSpec{Host: "10.0.0.1"},
+	}
+	return build, machine
+}
+
+func newBaseBuild(phase buildv1.BuildPhase, artifact string) *buildv1.Build {
+	return &buildv1.Build{
+		ObjectMeta: metav1.ObjectMeta{Name: "base", Namespace: "default"},
+		Status: buildv1.BuildStatus{
+			Phase:          string(phase),
+			Ready:          phase == buildv1.BuildPhaseCompleted,
+			Artifact:       artifact,
+			FailureMessage: ptr.To("exit status 1"),
+			Lineage:        []buildv1.LineageEntry{{BuildName: "root", Artifact: "pvc://images/root.tar.gz"}},
+		},
+	}
+}
+
+func newTestReconciler(g *WithT, objs ...client.Object) *BuildReconciler {
+	scheme := runtime.NewScheme()
+	g.Expect(buildv1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(batchv1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(apiextensionsv1.AddToScheme(scheme)).To(Succeed())
+	return &BuildReconciler{
+		Client:   fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build(),
+		recorder: record.NewFakeRecorder(32),
+	}
+}
+
+func TestReconcileBaseImage(t *testing.T) {
+	tests := []struct {
+		name       string
+		base       *buildv1.Build
+		wantReady  bool
+		wantReason string
+	}{
+		{
+			name:       "base Build not found",
+			wantReason: buildv1.WaitingForBaseImageReason,
+		},
+		{
+			name:       "base Build building",
+			base:       newBaseBuild(buildv1.BuildPhaseBuilding, ""),
+			wantReason: buildv1.WaitingForBaseImageReason,
+		},
+		{
+			name:       "base Build failed",
+			base:       newBaseBuild(buildv1.BuildPhaseFailed, ""),
+			wantReason: buildv1.BaseImageFailedReason,
+		},
+		{
+			name:      "base Build ready",
+			base:      newBaseBuild(buildv1.BuildPhaseCompleted, "pvc://images/base.tar.gz"),
+			wantReady: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			ctx := context.Background()
+
+			build, machine := newBaseImageObjects()
+			objs := []client.Object{build, machine}
+			if tt.base != nil {
+				objs = append(objs, tt.base)
+			}
+			r := newTestReconciler(g, objs...)
+
+			_, err := r.reconcileBaseImage(ctx, build)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(conditions.IsTrue(build, buildv1.BaseImageReadyCondition)).To(Equal(tt.wantReady))
+			g.Expect(conditions.GetReason(build, buildv1.BaseImageReadyCondition)).To(Equal(tt.wantReason))
+
+			g.Expect(r.Client.Get(ctx, client.ObjectKeyFromObject(machine), machine)).To(Succeed())
+			if !tt.wantReady {
+				g.Expect(machine.Spec.Host).To(Equal("10.0.0.1"))
+				g.Expect(build.Status.Lineage).To(BeEmpty())
+				return
+			}
+			g.Expect(machine.Spec.Host).To(Equal("pvc://images/base.tar.gz"))
+			g.Expect(build.Status.Lineage).To(Equal([]buildv1.LineageEntry{
+				{BuildName: "root", Artifact: "pvc://images/root.tar.gz"},
+				{BuildName: "base", Artifact: "pvc://images/base.tar.gz"},
+			}))
+		})
+	}
+}
+
+func TestReconcileBaseImageNewArtifact(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	build, machine := newBaseImageObjects()
+	build.Status.Lineage = []buildv1.LineageEntry{{BuildName: "base", Artifact: "pvc://images/base-1.tar.gz"}}
+	r := newTestReconciler(g, build, machine, newBaseBuild(buildv1.BuildPhaseCompleted, "pvc://images/base-2.tar.gz"))
+
+	// The Build is only rebuilt on request.
+	_, err := r.reconcileBaseImage(ctx, build)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(build.Annotations).NotTo(HaveKey(buildv1.RebuildAnnotation))
+	g.Expect(baseImage(build)).To(Equal("pvc://images/base-1.tar.gz"))
+
+	build.Spec.BaseImageFrom.Rebuild = true
+	res, err := r.reconcileBaseImage(ctx, build)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(res.Requeue).To(BeTrue())
+	g.Expect(build.Annotations).To(HaveKey(buildv1.RebuildAnnotation))
+}
+
+func TestReconcileRebuild(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	build, machine := newBaseImageObjects()
+	build.Annotations = map[string]string{buildv1.RebuildAnnotation: ""}
+	build.Status = buildv1.BuildStatus{
+		Phase:          string(buildv1.BuildPhaseFailed),
+		Ready:          true,
+		Artifact:       "pvc://images/hardened.tar.gz",
+		FailureReason:  ptr.To(forgeerrors.ProvisionerFailedError),
+		FailureMessage: ptr.To("exit status 1"),
+	}
+	machine.Annotations = map[string]string{buildv1.ExportAnnotation: ""}
+	machine.Status.Ready = true
+	r := newTestReconciler(g, build, machine)
+
+	// A copy of the InfraBuild is kept first.
+	_, err := r.reconcileRebuild(ctx, build)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(build.Annotations[buildv1.RebuildAnnotation]).To(ContainSubstring(`"host":"10.0.0.1"`))
+
+	// Then the InfraBuild is deleted.
+	_, err = r.reconcileRebuild(ctx, build)
+	g.Expect(err).NotTo(HaveOccurred())
+	err = r.Client.Get(ctx, client.ObjectKeyFromObject(machine), &infrav1.StaticMachine{})
+	g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+
+	// And created again along with the reset of the Build.
+	_, err = r.reconcileRebuild(ctx, build)
+	g.Expect(err).NotTo(HaveOccurred())
+	recreated := &infrav1.StaticMachine{}
+	g.Expect(r.Client.Get(ctx, client.ObjectKeyFromObject(machine), recreated)).To(Succeed())
+	g.Expect(recreated.Spec.Host).To(Equal("10.0.0.1"))
+	g.Expect(recreated.Annotations).NotTo(HaveKey(buildv1.ExportAnnotation))
+	g.Expect(recreated.Status.Ready).To(BeFalse())
+
+	g.Expect(build.Annotations).NotTo(HaveKey(buildv1.RebuildAnnotation))
+	g.Expect(build.Status).To(Equal(buildv1.BuildStatus{}))
+	g.Expect(build.Spec.Provisioners[0].UUID).To(BeNil())
+	g.Expect(build.Spec.Provisioners[0].Status).To(BeNil())
+}
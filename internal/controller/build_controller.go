@@ -21,13 +21,18 @@ import (
 	"fmt"
 	"path"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/trace"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	kerrors "k8s.io/apimachinery/pkg/util/errors"
@@ -44,13 +49,21 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	buildv1 "github.com/forge-build/forge/api/v1alpha1"
 	"github.com/forge-build/forge/internal/external"
+	"github.com/forge-build/forge/internal/notification"
+	"github.com/forge-build/forge/internal/validation"
+	"github.com/forge-build/forge/pkg/contract/infra"
 	forgeerrors "github.com/forge-build/forge/pkg/errors"
 	ssh "github.com/forge-build/forge/pkg/ssh"
+	"github.com/forge-build/forge/pkg/tracing"
+	"github.com/forge-build/forge/provisioner/shell"
+	"github.com/forge-build/forge/provisioner/shell/cleanup"
 	shellcontroller "github.com/forge-build/forge/provisioner/shell/controller"
+	forgeutil "github.com/forge-build/forge/util"
 	"github.com/forge-build/forge/util/annotations"
 	utilconversion "github.com/forge-build/forge/util/conversion"
 	"github.com/forge-build/forge/util/predicates"
@@ -73,14 +86,31 @@ type BuildReconciler struct {
 	// WatchFilterValue is the label value used to filter events prior to reconciliation.
 	WatchFilterValue string
 
+	// ProvisionerRuntime is the default configuration of the provisioner Jobs, see ProvisionerRuntimeConfig.
+	ProvisionerRuntime *buildv1.ProvisionerRuntimeConfig
+	// ProvisionerNamespace is the namespace of the provisioner Jobs, they run in the namespace of their Build if empty.
+	ProvisionerNamespace string
+
 	recorder        record.EventRecorder
 	externalTracker external.ObjectTracker
+	notifier        *notification.Notifier
+	// connections are the SSH connections to the machines of the Builds, reused across reconciliations.
+	connections ssh.Pool
+	// infraBuildKinds are the GroupVersionKinds whose CRD implements the InfraBuild contract, see preflightExternal.
+	infraBuildKinds sync.Map
+}
+
+// provisionerOptions returns the options of the provisioner Jobs, from the flags of the controller manager.
+func (r *BuildReconciler) provisionerOptions() shellcontroller.Options {
+	return shellcontroller.Options{Runtime: r.ProvisionerRuntime, Namespace: r.ProvisionerNamespace}
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *BuildReconciler) SetupWithManager(ctx context.Context, mgr ctrl.Manager, options controller.Options) error {
 	c, err := ctrl.NewControllerManagedBy(mgr).
 		For(&buildv1.Build{}).
+		Owns(&buildv1.Build{}).
+		Watches(&buildv1.Build{}, handler.EnqueueRequestsFromMapFunc(r.baseImageToBuilds)).
 		WithOptions(options).
 		WithEventFilter(predicates.ResourceNotPausedAndHasFilterLabel(ctrl.LoggerFrom(ctx), r.WatchFilterValue)).
 		Build(r)
@@ -94,11 +124,27 @@ func (r *BuildReconciler) SetupWithManager(ctx context.Context, mgr ctrl.Manager
 		Controller: c,
 		Cache:      mgr.GetCache(),
 	}
+	r.notifier = notification.NewNotifier(mgr.GetClient())
+	if err := mgr.Add(r.notifier); err != nil {
+		return errors.Wrap(err, "failed to add the notifier to the controller manager")
+	}
+	// Close the SSH connections when the manager stops.
+	if err := mgr.Add(manager.RunnableFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		r.connections.Close()
+		return nil
+	})); err != nil {
+		return errors.Wrap(err, "failed to add the SSH connections to the controller manager")
+	}
 	return nil
 }
 
 //+kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
+//+kubebuilder:rbac:groups=apiextensions.k8s.io,resources=customresourcedefinitions,verbs=get;list;watch
+//+kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;delete
 //+kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;patch;update
+//+kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch
+//+kubebuilder:rbac:groups=forge.build,resources=imageartifacts,verbs=get;list;watch;create;delete
 //+kubebuilder:rbac:groups=infrastructure.forge.build;provisioner.forge.build,resources=*,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=forge.build,resources=builds,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=forge.build,resources=builds/status,verbs=get;update;patch
@@ -115,6 +161,7 @@ func (r *BuildReconciler) Reconcile(ctx context.Context, req ctrl.Request) (_ ct
 		if apierrors.IsNotFound(err) {
 			// Object not found, return.  Created objects are automatically garbage collected.
 			// For additional cleanup logic use finalizers.
+			r.connections.Evict(req.String())
 			return ctrl.Result{}, nil
 		}
 
@@ -133,6 +180,14 @@ func (r *BuildReconciler) Reconcile(ctx context.Context, req ctrl.Request) (_ ct
 	if err != nil {
 		return ctrl.Result{}, err
 	}
+	before := build.DeepCopy()
+
+	// Trace the reconciliation as part of the Build lifecycle.
+	if tracing.Endpoint() != "" {
+		ctx = buildTraceContext(ctx, build)
+	}
+	ctx, span := tracing.Tracer().Start(ctx, "Reconcile", trace.WithAttributes(buildAttributes(build)...))
+	defer func() { tracing.End(span, reterr) }()
 
 	defer func() {
 		// Always reconcile the Status.Phase field.
@@ -146,6 +201,24 @@ func (r *BuildReconciler) Reconcile(ctx context.Context, req ctrl.Request) (_ ct
 		}
 		if err := patchBuild(ctx, patchHelper, build, patchOpts...); err != nil {
 			reterr = kerrors.NewAggregate([]error{reterr, err})
+			return
+		}
+
+		// End the trace of the Build lifecycle once it completed or failed.
+		if phase := build.Status.GetTypedPhase(); phase != before.Status.GetTypedPhase() &&
+			(phase == buildv1.BuildPhaseCompleted || phase == buildv1.BuildPhaseFailed) {
+			endBuildTrace(ctx, build)
+		}
+
+		// The machine is not reached anymore once the Build completed, failed or is deleted.
+		switch build.Status.GetTypedPhase() {
+		case buildv1.BuildPhaseCompleted, buildv1.BuildPhaseFailed, buildv1.BuildPhaseTerminating:
+			r.connections.Evict(req.String())
+		}
+
+		// Send the lifecycle events once they are persisted.
+		if r.notifier != nil && len(build.Spec.Notifications) > 0 {
+			r.notifier.Notify(build, notification.Events(before, build, time.Now()))
 		}
 	}()
 
@@ -161,6 +234,31 @@ func (r *BuildReconciler) Reconcile(ctx context.Context, req ctrl.Request) (_ ct
 		return ctrl.Result{}, nil
 	}
 
+	// Handle retry requests before anything else, so that a canceled Build can be retried.
+	if _, ok := build.GetAnnotations()[buildv1.RetryAnnotation]; ok {
+		if build.Spec.Matrix != nil {
+			if err := r.annotateVariants(ctx, build, buildv1.RetryAnnotation); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+		r.reconcileRetry(ctx, build)
+	}
+
+	// Handle rebuild requests, the Build runs again once its InfraBuild has been created again.
+	if _, ok := build.GetAnnotations()[buildv1.RebuildAnnotation]; ok {
+		return r.reconcileRebuild(ctx, build)
+	}
+
+	// Handle cancellation.
+	if _, ok := build.GetAnnotations()[buildv1.CancelAnnotation]; ok {
+		return r.reconcileCancel(ctx, build)
+	}
+
+	// Matrix Builds only create the Builds of their variants.
+	if build.Spec.Matrix != nil {
+		return r.reconcileMatrix(ctx, build)
+	}
+
 	// Handle normal reconciliation loop.
 	return r.reconcile(ctx, build)
 }
@@ -170,7 +268,11 @@ func patchBuild(ctx context.Context, patchHelper *patch.Helper, build *buildv1.B
 	conditions.SetSummary(build,
 		conditions.WithConditions(
 			buildv1.ProvisionersReadyCondition,
+			buildv1.ValidatedCondition,
+			buildv1.CleanedUpCondition,
 			buildv1.InfrastructureReadyCondition,
+			buildv1.BaseImageReadyCondition,
+			buildv1.VariantsReadyCondition,
 		),
 	)
 
@@ -178,11 +280,14 @@ func patchBuild(ctx context.Context, patchHelper *patch.Helper, build *buildv1.B
 	// Also, if requested, we are adding additional options like e.g. Patch ObservedGeneration when issuing the
 	// patch at the end of the reconcile loop.
 	options = append(options,
-		patch.WithOwnedConditions{Conditions: []clusterv1.ConditionType{
+		patch.WithOwnedConditions{Conditions: append([]clusterv1.ConditionType{
 			buildv1.ReadyCondition,
 			buildv1.ProvisionersReadyCondition,
+			buildv1.ValidatedCondition,
+			buildv1.CleanedUpCondition,
 			buildv1.InfrastructureReadyCondition,
-		}},
+			buildv1.BaseImageReadyCondition,
+		}, matrixConditions(build)...)},
 	)
 	return patchHelper.Patch(ctx, build, options...)
 }
@@ -190,10 +295,14 @@ func patchBuild(ctx context.Context, patchHelper *patch.Helper, build *buildv1.B
 // reconcile handles cluster reconciliation.
 func (r *BuildReconciler) reconcile(ctx context.Context, build *buildv1.Build) (ctrl.Result, error) {
 	phases := []func(context.Context, *buildv1.Build) (ctrl.Result, error){
-		r.reconcileInfrastructure,
-		r.reconcileConnection,
-		r.reconcileProvisioners,
-		r.reconcileImageProvided,
+		tracePhase("BaseImage", r.reconcileBaseImage),
+		tracePhase("Infrastructure", r.reconcileInfrastructure),
+		tracePhase("Connection", r.reconcileConnection),
+		tracePhase("Provisioners", r.reconcileProvisioners),
+		tracePhase("Validations", r.reconcileValidations),
+		tracePhase("Cleanup", r.reconcileCleanup),
+		tracePhase("ImageProvided", r.reconcileImageProvided),
+		tracePhase("ImageArtifact", r.reconcileImageArtifact),
 	}
 
 	res := ctrl.Result{}
@@ -212,10 +321,129 @@ func (r *BuildReconciler) reconcile(ctx context.Context, build *buildv1.Build) (
 	return res, kerrors.NewAggregate(errs)
 }
 
+// reconcileCancel stops a Build which has the cancel annotation.
+func (r *BuildReconciler) reconcileCancel(ctx context.Context, build *buildv1.Build) (ctrl.Result, error) {
+	log := ctrl.LoggerFrom(ctx)
+
+	if build.Status.Ready || ptr.Deref(build.Status.FailureReason, "") == forgeerrors.CanceledBuildError {
+		log.V(4).Info("Skipping reconcileCancel because the Build is already finished")
+		return ctrl.Result{}, nil
+	}
+
+	log.Info("Canceling the Build")
+	if build.Spec.Matrix != nil {
+		if err := r.annotateVariants(ctx, build, buildv1.CancelAnnotation); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	if err := r.deleteJobs(ctx, client.MatchingLabels{
+		buildv1.BuildNameLabel:      build.Name,
+		buildv1.BuildNamespaceLabel: build.Namespace,
+	}); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	for _, p := range buildProvisioners(build) {
+		if ptr.Deref(p.Status, "") == buildv1.ProvisionerStatusRunning {
+			p.Status = ptr.To(buildv1.ProvisionerStatusFailed)
+			p.FailureReason = ptr.To(string(forgeerrors.CanceledBuildError))
+			p.FailureMessage = ptr.To("Build canceled")
+		}
+	}
+
+	build.Status.FailureReason = ptr.To(forgeerrors.CanceledBuildError)
+	build.Status.FailureMessage = ptr.To("Build canceled")
+	r.recorder.Eventf(build, corev1.EventTypeNormal, "Canceled", "Build %s has been canceled", build.Name)
+	return ctrl.Result{}, nil
+}
+
+// deleteJobs deletes the Jobs of a Build matching labels, from all the namespaces.
+func (r *BuildReconciler) deleteJobs(ctx context.Context, labels client.MatchingLabels) error {
+	jobs := &batchv1.JobList{}
+	if err := r.Client.List(ctx, jobs, labels); err != nil {
+		return errors.Wrap(err, "failed to list the provisioner jobs")
+	}
+	for i := range jobs.Items {
+		if err := r.Client.Delete(ctx, &jobs.Items[i], client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil && !apierrors.IsNotFound(err) {
+			return errors.Wrapf(err, "failed to delete the provisioner job %s", jobs.Items[i].Name)
+		}
+	}
+	return nil
+}
+
+// reconcileRetry resets a failed Build so that its failed steps run again.
+func (r *BuildReconciler) reconcileRetry(ctx context.Context, build *buildv1.Build) {
+	log := ctrl.LoggerFrom(ctx)
+	log.Info("Retrying the Build")
+
+	annotations := build.GetAnnotations()
+	delete(annotations, buildv1.RetryAnnotation)
+	delete(annotations, buildv1.CancelAnnotation)
+	delete(annotations, buildv1.TraceParentAnnotation)
+	build.SetAnnotations(annotations)
+
+	for _, p := range buildProvisioners(build) {
+		if ptr.Deref(p.Status, "") == buildv1.ProvisionerStatusFailed {
+			p.UUID = nil
+			p.Status = nil
+			p.FailureReason = nil
+			p.FailureMessage = nil
+			p.ExitCode = nil
+			p.Result = nil
+			p.Retried = 0
+			p.RetryTime = nil
+		}
+	}
+	if build.Status.Cleanup != nil && ptr.Deref(build.Status.Cleanup.Status, "") == buildv1.ProvisionerStatusFailed {
+		build.Status.Cleanup = nil
+		conditions.Delete(build, buildv1.CleanedUpCondition)
+	}
+	if conditions.GetReason(build, buildv1.ValidatedCondition) == buildv1.ValidationFailedReason {
+		build.Status.Validation = nil
+		conditions.Delete(build, buildv1.ValidatedCondition)
+	}
+
+	if ptr.Deref(build.Status.FailureReason, "") == forgeerrors.ConnectionFailedError {
+		build.Status.Connection = nil
+	}
+
+	build.Status.FailureReason = nil
+	build.Status.FailureMessage = nil
+	r.recorder.Eventf(build, corev1.EventTypeNormal, "Retry", "Build %s is retried", build.Name)
+}
+
+// buildProvisioners returns the provisioners of the Build, including the one running the cleanup stage.
+func buildProvisioners(build *buildv1.Build) []*buildv1.ProvisionerSpec {
+	provisioners := make([]*buildv1.ProvisionerSpec, 0, len(build.Spec.Provisioners)+1)
+	for i := range build.Spec.Provisioners {
+		provisioners = append(provisioners, &build.Spec.Provisioners[i])
+	}
+	if build.Status.Cleanup != nil {
+		provisioners = append(provisioners, build.Status.Cleanup)
+	}
+	return provisioners
+}
+
 // reconcileDelete handles cluster deletion.
 func (r *BuildReconciler) reconcileDelete(ctx context.Context, build *buildv1.Build) (reconcile.Result, error) {
 	log := ctrl.LoggerFrom(ctx)
 
+	r.connections.Evict(client.ObjectKeyFromObject(build).String())
+
+	// The provisioner Jobs run in another namespace, they cannot be owned by the Build.
+	if err := r.deleteJobs(ctx, client.MatchingLabels{
+		buildv1.ManagedByLabel:      shell.ForgeProvisionerShellName,
+		buildv1.BuildNameLabel:      build.Name,
+		buildv1.BuildNamespaceLabel: build.Namespace,
+	}); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// The InfraBuild is one of the descendants, the image is deleted before it is.
+	if deleted, err := r.reconcileDeleteCascade(ctx, build); err != nil || !deleted {
+		return ctrl.Result{}, err
+	}
+
 	descendants, err := r.listDescendants(ctx, build)
 	if err != nil {
 		log.Error(err, "Failed to list descendants")
@@ -266,7 +494,8 @@ func (r *BuildReconciler) reconcileDelete(ctx context.Context, build *buildv1.Bu
 		return ctrl.Result{RequeueAfter: deleteRequeueAfter}, nil
 	}
 
-	if build.Spec.InfrastructureRef != nil {
+	// The InfraBuild of a matrix Build is only copied by its variants, it is not owned by the Build.
+	if build.Spec.InfrastructureRef != nil && build.Spec.Matrix == nil {
 		obj, err := external.Get(ctx, r.Client, build.Spec.InfrastructureRef, build.Namespace)
 		switch {
 		case apierrors.IsNotFound(errors.Cause(err)):
@@ -310,6 +539,12 @@ func (r *BuildReconciler) reconcileInfrastructure(ctx context.Context, build *bu
 		return ctrl.Result{}, nil
 	}
 
+	// The InfraBuild is not handed over to the provider before the base image is set.
+	if build.Spec.BaseImageFrom != nil && !conditions.IsTrue(build, buildv1.BaseImageReadyCondition) {
+		log.V(3).Info("Waiting for the base image")
+		return ctrl.Result{}, nil
+	}
+
 	// Call generic external reconciler.
 	infraReconcileResult, err := r.reconcileExternal(ctx, build, build.Spec.InfrastructureRef)
 	if err != nil {
@@ -360,6 +595,10 @@ func (r *BuildReconciler) reconcileInfrastructure(ctx context.Context, build *bu
 		return ctrl.Result{}, err
 	}
 	build.Status.Ready = ready
+	build.Status.Artifact, err = external.ArtifactFrom(infraConfig)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
 	// Only record the event if the status has changed
 	if preReconcileReady != build.Status.Ready {
 		r.recorder.Eventf(build, corev1.EventTypeNormal, "Ready", "Build %s Ready is now %t", build.Name, build.Status.Ready)
@@ -387,10 +626,40 @@ func (r *BuildReconciler) reconcileInfrastructure(ctx context.Context, build *bu
 	return ctrl.Result{}, nil
 }
 
+// preflightExternal checks that the CRD of the referenced object implements the InfraBuild contract,
+// the Build fails right away otherwise instead of waiting on a status which is never going to be set.
+// The CRDs implementing the contract are only checked once per GroupVersionKind.
+func (r *BuildReconciler) preflightExternal(ctx context.Context, build *buildv1.Build, ref *corev1.ObjectReference) error {
+	gvk := ref.GroupVersionKind()
+	if _, ok := r.infraBuildKinds.Load(gvk); ok {
+		return nil
+	}
+	crd := &apiextensionsv1.CustomResourceDefinition{}
+	crdName := forgeutil.CalculateCRDName(gvk.Group, gvk.Kind)
+	if err := r.Client.Get(ctx, client.ObjectKey{Name: crdName}, crd); err != nil {
+		return errors.Wrapf(err, "failed to retrieve the CRD %s", crdName)
+	}
+
+	if err := infra.ValidateCRD(crd); err != nil {
+		msg := fmt.Sprintf("%s %q can not be used as infrastructure: %v", gvk.Kind, ref.Name, err)
+		conditions.MarkFalse(build, buildv1.InfrastructureReadyCondition, buildv1.IncorrectExternalRefReason, buildv1.ConditionSeverityError, msg)
+		build.Status.FailureReason = ptr.To(forgeerrors.InvalidConfigurationBuildError)
+		build.Status.FailureMessage = ptr.To(msg)
+		r.recorder.Event(build, corev1.EventTypeWarning, buildv1.IncorrectExternalRefReason, msg)
+		return reconcile.TerminalError(errors.Wrap(err, "incorrect infrastructure reference"))
+	}
+	r.infraBuildKinds.Store(gvk, struct{}{})
+	return nil
+}
+
 // reconcileExternal handles generic unstructured objects referenced by a Cluster.
 func (r *BuildReconciler) reconcileExternal(ctx context.Context, build *buildv1.Build, ref *corev1.ObjectReference) (external.ReconcileOutput, error) {
 	log := ctrl.LoggerFrom(ctx)
 
+	if err := r.preflightExternal(ctx, build, ref); err != nil {
+		return external.ReconcileOutput{}, err
+	}
+
 	if err := utilconversion.UpdateReferenceAPIContract(ctx, r.Client, ref); err != nil {
 		return external.ReconcileOutput{}, err
 	}
@@ -462,9 +731,9 @@ func (r *BuildReconciler) reconcileExternal(ctx context.Context, build *buildv1.
 func (r *BuildReconciler) reconcileImageProvided(ctx context.Context, build *buildv1.Build) (ctrl.Result, error) {
 	log := ctrl.LoggerFrom(ctx)
 
-	// Skip checking if the Provisioners not ready.
-	if !build.Status.ProvisionersReady {
-		log.V(4).Info("Skipping reconcileImageProvided because Provisioners not ready yet")
+	// Skip checking if the machine is not cleaned up.
+	if !build.Status.CleanedUp {
+		log.V(4).Info("Skipping reconcileImageProvided because the machine is not cleaned up yet")
 		return ctrl.Result{}, nil
 	}
 
@@ -474,12 +743,45 @@ func (r *BuildReconciler) reconcileImageProvided(ctx context.Context, build *bui
 	}
 
 	log.V(4).Info("Checking for image exportation")
-	// TODO, Mark the InfraBuild to export the image.
+	if build.Spec.InfrastructureRef != nil {
+		if err := r.requestExport(ctx, build); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
 
 	conditions.MarkTrue(build, buildv1.BuildInitializedCondition)
 	return ctrl.Result{}, nil
 }
 
+// requestExport marks the InfraBuild to export the image of the machine,
+// the provider reports the exported image by setting status.ready.
+func (r *BuildReconciler) requestExport(ctx context.Context, build *buildv1.Build) error {
+	obj, err := external.Get(ctx, r.Client, build.Spec.InfrastructureRef, build.Namespace)
+	if err != nil {
+		return errors.Wrap(err, "failed to get the InfraBuild")
+	}
+	if _, ok := obj.GetAnnotations()[buildv1.ExportAnnotation]; ok {
+		return nil
+	}
+
+	patchHelper, err := patch.NewHelper(obj, r.Client)
+	if err != nil {
+		return err
+	}
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[buildv1.ExportAnnotation] = ""
+	obj.SetAnnotations(annotations)
+	if err := patchHelper.Patch(ctx, obj); err != nil {
+		return errors.Wrapf(err, "failed to mark %v %q for export", obj.GroupVersionKind(), obj.GetName())
+	}
+
+	r.recorder.Eventf(build, corev1.EventTypeNormal, "Exporting", "Build %s requested the export of the image", build.Name)
+	return nil
+}
+
 // reconcileConnection reconciles the connection to the underlying infrastructure machine.
 func (r *BuildReconciler) reconcileConnection(ctx context.Context, build *buildv1.Build) (ctrl.Result, error) {
 	log := ctrl.LoggerFrom(ctx)
@@ -495,16 +797,35 @@ func (r *BuildReconciler) reconcileConnection(ctx context.Context, build *buildv
 		return ctrl.Result{}, nil
 	}
 
+	// The machine is handed over to the infrastructure provider to export the image once it is cleaned up,
+	// it may be stopped and must not fail the Build.
+	if build.Status.CleanedUp {
+		log.V(4).Info("Skipping reconcileConnection because the machine is cleaned up")
+		return ctrl.Result{}, nil
+	}
+
+	// Skip connecting again once the deadline of the connector passed, until the Build is retried.
+	if ptr.Deref(build.Status.FailureReason, "") == forgeerrors.ConnectionFailedError {
+		log.V(4).Info("Skipping reconcileConnection because the connection to the machine failed")
+		return ctrl.Result{}, nil
+	}
+
 	log.V(4).Info("Checking for connection to infrastructure machine")
 	conditions.MarkFalse(build, buildv1.MachineReadyCondition, buildv1.WaitingForConnectionReason, buildv1.ConditionSeverityInfo, "")
-	// TODO, Try to connect to the infrastructure machine with spec.connector.
+	if build.Status.Connection == nil {
+		build.Status.Connection = &buildv1.ConnectionStatus{}
+	}
 
-	err := r.tryToConnect(ctx, build)
+	secret, err := r.credentials(ctx, build)
 	if err != nil {
-		return ctrl.Result{
-			RequeueAfter: 2 * time.Second,
-		}, errors.Wrap(err, "failed to connect to the machine")
+		return r.connectionFailed(ctx, build, err)
+	}
+	build.Status.Connection.Destination = ssh.Destination(secret)
+	if _, err := r.connections.Get(ctx, client.ObjectKeyFromObject(build).String(), secret, connectTimeout(build)); err != nil {
+		return r.connectionFailed(ctx, build, err)
 	}
+	build.Status.Connection.FailedAttempts = 0
+	build.Status.Connection.FirstFailureTime = nil
 
 	conditions.MarkTrue(build, buildv1.MachineReadyCondition)
 
@@ -519,22 +840,20 @@ func (r *BuildReconciler) reconcileConnection(ctx context.Context, build *buildv
 	return ctrl.Result{}, nil
 }
 
-func (r *BuildReconciler) tryToConnect(ctx context.Context, build *buildv1.Build) error {
-	secret := &corev1.Secret{}
-	if err := r.Client.Get(ctx, client.ObjectKey{Namespace: build.Namespace, Name: build.Spec.Connector.Credentials.Name}, secret); err != nil {
-		return errors.Wrap(err, "failed to get secret")
+// connect returns an SSH client connected to the infrastructure machine of the Build. The connection
+// is cached until the credentials Secret changes or the Build is finished, it must not be closed.
+func (r *BuildReconciler) connect(ctx context.Context, build *buildv1.Build) (*ssh.SSHClient, error) {
+	secret, err := r.credentials(ctx, build)
+	if err != nil {
+		return nil, err
 	}
 
-	sshClient, err := ssh.NewSSHClient(secret)
+	sshClient, err := r.connections.Get(ctx, client.ObjectKeyFromObject(build).String(), secret, SSHTimeout)
 	if err != nil {
-		return errors.Wrap(err, "failed to create SSH client")
-	}
-	if err = sshClient.WaitForSSH(SSHTimeout); err != nil {
-		return errors.Wrap(err, "failed to connect to the machine via ssh")
+		return nil, errors.Wrap(err, "failed to connect to the machine via ssh")
 	}
-	defer sshClient.Disconnect()
 
-	return nil
+	return sshClient, nil
 }
 
 // reconcileProvisioners reconciles the provisioners for the Build.
@@ -554,6 +873,8 @@ func (r *BuildReconciler) reconcileProvisioners(ctx context.Context, build *buil
 
 	log.V(4).Info("Checking for provisioners")
 	conditions.MarkFalse(build, buildv1.ProvisionersReadyCondition, buildv1.WaitingForProvisionersReason, buildv1.ConditionSeverityInfo, "")
+	// The outputs of the completed provisioners are available to the next ones and recorded in the ImageArtifact.
+	build.Status.Outputs = forgeutil.GetProvisionerOutputs(build)
 
 	for i := range build.Spec.Provisioners {
 		// TODO, Run the external provisioner.
@@ -563,15 +884,20 @@ func (r *BuildReconciler) reconcileProvisioners(ctx context.Context, build *buil
 		//	// reconcileExternal similar to infrastructure.
 		//}
 
-		// Builtin Provisioner
-		if build.Spec.Provisioners[i].Type == buildv1.ProvisionerTypeShell {
-			res, err := shellcontroller.Reconcile(ctx, r.Client, build, &build.Spec.Provisioners[i])
+		// Builtin Provisioners, reboot provisioners are shell provisioners running the reboot command.
+		if build.Spec.Provisioners[i].Type == buildv1.ProvisionerTypeShell ||
+			build.Spec.Provisioners[i].Type == buildv1.ProvisionerTypeReboot {
+			res, err := shellcontroller.Reconcile(ctx, r.Client, build, &build.Spec.Provisioners[i], r.provisionerOptions())
 			if err != nil {
 				return ctrl.Result{}, err
 			}
 			if res.Requeue || res.RequeueAfter > 0 {
 				return res, nil
 			}
+			// The next provisioners do not run once a provisioner failed the Build.
+			if build.Status.FailureReason != nil {
+				return ctrl.Result{}, nil
+			}
 		}
 	}
 
@@ -594,18 +920,128 @@ func (r *BuildReconciler) reconcileProvisioners(ctx context.Context, build *buil
 	return ctrl.Result{}, nil
 }
 
+// reconcileValidations runs the validations of the Build on the infrastructure machine.
+func (r *BuildReconciler) reconcileValidations(ctx context.Context, build *buildv1.Build) (ctrl.Result, error) {
+	log := ctrl.LoggerFrom(ctx)
+
+	// Skip checking if the Provisioners not ready.
+	if !build.Status.ProvisionersReady {
+		log.V(4).Info("Skipping reconcileValidations because Provisioners not ready yet")
+		return ctrl.Result{}, nil
+	}
+
+	if build.Status.Validated {
+		log.V(4).Info("Skipping reconcileValidations because validations already passed")
+		return ctrl.Result{}, nil
+	}
+
+	if conditions.GetReason(build, buildv1.ValidatedCondition) == buildv1.ValidationFailedReason {
+		log.V(4).Info("Skipping reconcileValidations because validations failed")
+		return ctrl.Result{}, nil
+	}
+
+	if len(build.Spec.Validations) > 0 {
+		log.V(4).Info("Running validations on the infrastructure machine")
+		conditions.MarkFalse(build, buildv1.ValidatedCondition, buildv1.WaitingForValidationsReason, buildv1.ConditionSeverityInfo, "")
+
+		sshClient, err := r.connect(ctx, build)
+		if err != nil {
+			return ctrl.Result{
+				RequeueAfter: 2 * time.Second,
+			}, errors.Wrap(err, "failed to connect to the machine")
+		}
+
+		build.Status.Validation = validation.Run(sshClient, build.Spec.Validations)
+		if failed := validation.Failed(build.Spec.Validations, build.Status.Validation); len(failed) > 0 {
+			message := fmt.Sprintf("Validations failed: %s", strings.Join(failed, ", "))
+			conditions.MarkFalse(build, buildv1.ValidatedCondition, buildv1.ValidationFailedReason, buildv1.ConditionSeverityError, message)
+			build.Status.FailureReason = ptr.To(forgeerrors.ValidationFailedError)
+			build.Status.FailureMessage = ptr.To(message)
+			r.recorder.Event(build, corev1.EventTypeWarning, buildv1.ValidationFailedReason, message)
+			return ctrl.Result{}, nil
+		}
+		r.recorder.Eventf(build, corev1.EventTypeNormal, "Validated", "%d validations passed", build.Status.Validation.Passed)
+	}
+
+	conditions.MarkTrue(build, buildv1.ValidatedCondition)
+	build.Status.Validated = true
+
+	return ctrl.Result{}, nil
+}
+
+// reconcileCleanup runs the cleanup stage generalizing the infrastructure machine before the image is exported.
+func (r *BuildReconciler) reconcileCleanup(ctx context.Context, build *buildv1.Build) (ctrl.Result, error) {
+	log := ctrl.LoggerFrom(ctx)
+
+	// Skip checking if the Validations did not pass.
+	if !build.Status.Validated {
+		log.V(4).Info("Skipping reconcileCleanup because Validations did not pass yet")
+		return ctrl.Result{}, nil
+	}
+
+	if build.Status.CleanedUp {
+		log.V(4).Info("Skipping reconcileCleanup because the machine is already cleaned up")
+		return ctrl.Result{}, nil
+	}
+
+	if build.Spec.Cleanup != nil && build.Spec.Cleanup.Disabled {
+		log.V(4).Info("Skipping reconcileCleanup because the cleanup stage is disabled")
+		conditions.MarkTrue(build, buildv1.CleanedUpCondition)
+		build.Status.CleanedUp = true
+		return ctrl.Result{}, nil
+	}
+
+	if build.Status.Cleanup == nil {
+		script, err := cleanup.Script(build.Spec.Cleanup)
+		if err != nil {
+			return ctrl.Result{}, errors.Wrap(err, "failed to generate the cleanup script")
+		}
+		build.Status.Cleanup = &buildv1.ProvisionerSpec{
+			Type: buildv1.ProvisionerTypeShell,
+			Run:  ptr.To(script),
+		}
+	}
+
+	log.V(4).Info("Checking for cleanup")
+	conditions.MarkFalse(build, buildv1.CleanedUpCondition, buildv1.WaitingForCleanupReason, buildv1.ConditionSeverityInfo, "")
+
+	res, err := shellcontroller.Reconcile(ctx, r.Client, build, build.Status.Cleanup, r.provisionerOptions())
+	if err != nil || res.Requeue || res.RequeueAfter > 0 {
+		return res, err
+	}
+
+	switch ptr.Deref(build.Status.Cleanup.Status, buildv1.ProvisionerStatusUnknown) {
+	case buildv1.ProvisionerStatusCompleted:
+		conditions.MarkTrue(build, buildv1.CleanedUpCondition)
+		r.recorder.Event(build, corev1.EventTypeNormal, "CleanedUp", "Machine cleaned up")
+		build.Status.CleanedUp = true
+	case buildv1.ProvisionerStatusFailed:
+		// The image is not exported from a machine which is not cleaned up.
+		message := fmt.Sprintf("Cleanup failed with Reason %s and Message %s",
+			ptr.Deref(build.Status.Cleanup.FailureReason, "Unknown"), ptr.Deref(build.Status.Cleanup.FailureMessage, ""))
+		conditions.MarkFalse(build, buildv1.CleanedUpCondition, buildv1.CleanupFailedReason, buildv1.ConditionSeverityError, message)
+		build.Status.FailureReason = ptr.To(forgeerrors.CleanupFailedError)
+		build.Status.FailureMessage = ptr.To(message)
+		r.recorder.Event(build, corev1.EventTypeWarning, buildv1.CleanupFailedReason, message)
+	}
+
+	return ctrl.Result{}, nil
+}
+
 type buildDescendants struct {
+	builds       buildv1.BuildList
 	infraBuild   unstructured.UnstructuredList
 	provisioners unstructured.UnstructuredList
 }
 
 // length returns the number of descendants.
 func (c *buildDescendants) length() int {
-	return len(c.infraBuild.Items) +
+	return len(c.builds.Items) +
+		len(c.infraBuild.Items) +
 		len(c.provisioners.Items)
 }
 
-// listDescendants returns a list of all InfraBuilds, and Provisioners for the Build.
+// listDescendants returns a list of all variant Builds, InfraBuilds, and Provisioners for the Build.
 func (r *BuildReconciler) listDescendants(ctx context.Context, build *buildv1.Build) (buildDescendants, error) {
 	var descendants buildDescendants
 
@@ -614,6 +1050,15 @@ func (r *BuildReconciler) listDescendants(ctx context.Context, build *buildv1.Bu
 		client.MatchingLabels(map[string]string{buildv1.BuildNameLabel: build.Name}),
 	}
 
+	// retrieve the Builds of the variants
+	if build.Spec.Matrix != nil {
+		err := r.List(ctx, &descendants.builds, client.InNamespace(build.Namespace),
+			client.MatchingLabels{buildv1.MatrixParentLabel: build.Name})
+		if err != nil {
+			return descendants, errors.Wrap(err, "failed to list the Builds of the variants")
+		}
+	}
+
 	// retrieve InfraBuild
 	infraBuildGVK := build.Spec.InfrastructureRef.GroupVersionKind()
 	descendants.infraBuild.SetGroupVersionKind(infraBuildGVK)
@@ -659,6 +1104,7 @@ func (c buildDescendants) filterOwnedDescendants(build *buildv1.Build) ([]client
 	}
 
 	lists := []client.ObjectList{
+		&c.builds,
 		&c.provisioners,
 		&c.infraBuild,
 	}
@@ -674,6 +1120,13 @@ func (c buildDescendants) filterOwnedDescendants(build *buildv1.Build) ([]client
 
 func (c *buildDescendants) descendantNames() string {
 	descendants := make([]string, 0)
+	buildNames := make([]string, len(c.builds.Items))
+	for i, b := range c.builds.Items {
+		buildNames[i] = b.GetName()
+	}
+	if len(buildNames) > 0 {
+		descendants = append(descendants, "Builds: "+strings.Join(buildNames, ","))
+	}
 	infraBuildNames := make([]string, len(c.infraBuild.Items))
 	for i, b := range c.infraBuild.Items {
 		infraBuildNames[i] = b.GetName()
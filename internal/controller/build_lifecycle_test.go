@@ -0,0 +1,297 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	buildv1 "github.com/forge-build/forge/api/v1alpha1"
+	"github.com/forge-build/forge/pkg/contract/infra"
+	forgeerrors "github.com/forge-build/forge/pkg/errors"
+	"github.com/forge-build/forge/pkg/ssh/sshtest"
+	"github.com/forge-build/forge/provisioner/shell"
+)
+
+const (
+	lifecycleTimeout  = 30 * time.Second
+	lifecyclePolling  = 250 * time.Millisecond
+	credentialsSecret = "machine-ssh"
+)
+
+// The Builds are reconciled by the controllers of the manager started by the suite, with a fake
+// InfraBuild provider and an in-process SSH server. There is no Job controller in the test
+// environment, the tests finish the provisioner Jobs and the status of their Pod themselves.
+var _ = Describe("Build lifecycle", func() {
+	var (
+		ctx       context.Context
+		namespace string
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{GenerateName: "build-lifecycle-"}}
+		Expect(k8sClient.Create(ctx, ns)).To(Succeed())
+		namespace = ns.Name
+
+		server := sshtest.NewServer(GinkgoTB(), sshtest.WithPassword("forge", "forge"))
+		secret := server.Secret("forge")
+		secret.Name = credentialsSecret
+		secret.Namespace = namespace
+		Expect(k8sClient.Create(ctx, secret)).To(Succeed())
+	})
+
+	It("builds an image from the infrastructure, connection and provisioners", func() {
+		build := createLifecycleBuild(ctx, namespace, "ubuntu", nil,
+			buildv1.ProvisionerSpec{Type: buildv1.ProvisionerTypeShell, Run: ptr.To("uname -r")},
+			buildv1.ProvisionerSpec{Type: buildv1.ProvisionerTypeShell, Run: ptr.To("apt-get upgrade -y")},
+		)
+
+		By("waiting for the machine to be ready and connected")
+		Eventually(func(g Gomega) {
+			g.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(build), build)).To(Succeed())
+			g.Expect(build.Finalizers).To(ContainElement(buildv1.BuildFinalizer))
+			g.Expect(build.Status.InfrastructureReady).To(BeTrue())
+			g.Expect(build.Status.Connected).To(BeTrue())
+			g.Expect(build.Status.GetTypedPhase()).To(Equal(buildv1.BuildPhaseBuilding))
+		}, lifecycleTimeout, lifecyclePolling).Should(Succeed())
+
+		By("running the provisioners one after the other")
+		job := provisionerJob(ctx, build, "0")
+		finishProvisionerJob(ctx, job, batchv1.JobComplete, shell.Result{ExitCode: ptr.To[int32](0), Outputs: map[string]string{"kernel": "6.8.0"}})
+		job = provisionerJob(ctx, build, "1")
+		Expect(job.Spec.Template.Spec.Containers[0].Env).To(ContainElement(corev1.EnvVar{Name: "FORGE_OUTPUT_KERNEL", Value: "6.8.0"}))
+		finishProvisionerJob(ctx, job, batchv1.JobComplete, shell.Result{ExitCode: ptr.To[int32](0)})
+
+		By("exporting the image")
+		Eventually(func(g Gomega) {
+			g.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(build), build)).To(Succeed())
+			g.Expect(build.Status.ProvisionersReady).To(BeTrue())
+			g.Expect(build.Status.Ready).To(BeTrue())
+			g.Expect(build.Status.GetTypedPhase()).To(Equal(buildv1.BuildPhaseCompleted))
+			g.Expect(build.Status.Artifact).To(Equal("fake-image-ubuntu"))
+			g.Expect(build.Status.ImageArtifact).NotTo(BeEmpty())
+		}, lifecycleTimeout, lifecyclePolling).Should(Succeed())
+		infraBuild := newFakeInfraBuild(namespace, "ubuntu")
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(infraBuild), infraBuild)).To(Succeed())
+		Expect(infraBuild.GetAnnotations()).To(HaveKey(buildv1.ExportAnnotation))
+
+		artifact := &buildv1.ImageArtifact{}
+		Expect(k8sClient.Get(ctx, client.ObjectKey{Namespace: namespace, Name: build.Status.ImageArtifact}, artifact)).To(Succeed())
+		Expect(artifact.Spec.ImageID).To(Equal("fake-image-ubuntu"))
+		Expect(artifact.Spec.Provider).To(Equal("fake"))
+		Expect(artifact.Spec.Outputs).To(Equal(map[string]string{"kernel": "6.8.0"}))
+	})
+
+	It("deletes the descendants of the Build before removing its finalizer", func() {
+		build := createLifecycleBuild(ctx, namespace, "debian", nil,
+			buildv1.ProvisionerSpec{Type: buildv1.ProvisionerTypeShell, Run: ptr.To("apt-get upgrade -y")},
+		)
+		job := provisionerJob(ctx, build, "0")
+
+		By("holding the teardown of the machine")
+		infraBuild := newFakeInfraBuild(namespace, "debian")
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(infraBuild), infraBuild)).To(Succeed())
+		patch := client.MergeFrom(infraBuild.DeepCopy())
+		infraBuild.SetAnnotations(map[string]string{fakeHoldDeletionAnnotation: ""})
+		Expect(k8sClient.Patch(ctx, infraBuild, patch)).To(Succeed())
+
+		Expect(k8sClient.Delete(ctx, build)).To(Succeed())
+		Eventually(func(g Gomega) {
+			g.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(infraBuild), infraBuild)).To(Succeed())
+			g.Expect(infraBuild.GetDeletionTimestamp()).NotTo(BeNil())
+			g.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(job), &batchv1.Job{})).To(Satisfy(apierrors.IsNotFound))
+		}, lifecycleTimeout, lifecyclePolling).Should(Succeed())
+		Consistently(func(g Gomega) {
+			g.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(build), build)).To(Succeed())
+			g.Expect(build.Finalizers).To(ContainElement(buildv1.BuildFinalizer))
+			g.Expect(build.Status.GetTypedPhase()).To(Equal(buildv1.BuildPhaseTerminating))
+		}, 2*time.Second, lifecyclePolling).Should(Succeed())
+
+		By("tearing down the machine")
+		patch = client.MergeFrom(infraBuild.DeepCopy())
+		infraBuild.SetAnnotations(nil)
+		Expect(k8sClient.Patch(ctx, infraBuild, patch)).To(Succeed())
+		Eventually(func(g Gomega) {
+			g.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(infraBuild), infraBuild)).To(Satisfy(apierrors.IsNotFound))
+			g.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(build), build)).To(Satisfy(apierrors.IsNotFound))
+		}, lifecycleTimeout, lifecyclePolling).Should(Succeed())
+	})
+
+	It("does not reconcile a paused Build until it is unpaused", func() {
+		build := createLifecycleBuild(ctx, namespace, "fedora", map[string]string{buildv1.PausedAnnotation: ""})
+
+		Consistently(func(g Gomega) {
+			g.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(build), build)).To(Succeed())
+			g.Expect(build.Finalizers).To(BeEmpty())
+			g.Expect(build.Status.InfrastructureReady).To(BeFalse())
+		}, 2*time.Second, lifecyclePolling).Should(Succeed())
+
+		patch := client.MergeFrom(build.DeepCopy())
+		build.SetAnnotations(nil)
+		Expect(k8sClient.Patch(ctx, build, patch)).To(Succeed())
+		Eventually(func(g Gomega) {
+			g.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(build), build)).To(Succeed())
+			g.Expect(build.Finalizers).To(ContainElement(buildv1.BuildFinalizer))
+			g.Expect(build.Status.InfrastructureReady).To(BeTrue())
+		}, lifecycleTimeout, lifecyclePolling).Should(Succeed())
+	})
+
+	It("fails the Build with the failure of its InfraBuild", func() {
+		build := createLifecycleBuild(ctx, namespace, "rocky", nil)
+		Eventually(func(g Gomega) {
+			g.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(build), build)).To(Succeed())
+			g.Expect(build.Status.InfrastructureReady).To(BeTrue())
+		}, lifecycleTimeout, lifecyclePolling).Should(Succeed())
+
+		infraBuild := newFakeInfraBuild(namespace, "rocky")
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(infraBuild), infraBuild)).To(Succeed())
+		patch := client.MergeFrom(infraBuild.DeepCopy())
+		Expect(unstructured.SetNestedField(infraBuild.Object, "InsufficientQuota", infra.FailureReasonPath...)).To(Succeed())
+		Expect(unstructured.SetNestedField(infraBuild.Object, "quota exceeded", infra.FailureMessagePath...)).To(Succeed())
+		Expect(k8sClient.Status().Patch(ctx, infraBuild, patch)).To(Succeed())
+
+		Eventually(func(g Gomega) {
+			g.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(build), build)).To(Succeed())
+			g.Expect(build.Status.FailureReason).To(Equal(ptr.To(forgeerrors.BuildStatusError("InsufficientQuota"))))
+			g.Expect(build.Status.FailureMessage).To(HaveValue(ContainSubstring("quota exceeded")))
+			g.Expect(build.Status.GetTypedPhase()).To(Equal(buildv1.BuildPhaseFailed))
+		}, lifecycleTimeout, lifecyclePolling).Should(Succeed())
+	})
+
+	It("fails the Build when a provisioner fails", func() {
+		build := createLifecycleBuild(ctx, namespace, "alpine", nil,
+			buildv1.ProvisionerSpec{Type: buildv1.ProvisionerTypeShell, Run: ptr.To("apk upgrade"), Retries: ptr.To[int32](0)},
+		)
+		finishProvisionerJob(ctx, provisionerJob(ctx, build, "0"), batchv1.JobFailed,
+			shell.Result{ExitCode: ptr.To[int32](3), Message: "no space left on device"})
+
+		Eventually(func(g Gomega) {
+			g.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(build), build)).To(Succeed())
+			g.Expect(build.Spec.Provisioners[0].Status).To(Equal(ptr.To(buildv1.ProvisionerStatusFailed)))
+			g.Expect(build.Spec.Provisioners[0].ExitCode).To(Equal(ptr.To[int32](3)))
+			g.Expect(build.Status.FailureReason).To(Equal(ptr.To(forgeerrors.ProvisionerFailedError)))
+			g.Expect(build.Status.FailureMessage).To(HaveValue(ContainSubstring("no space left on device")))
+			g.Expect(build.Status.GetTypedPhase()).To(Equal(buildv1.BuildPhaseFailed))
+		}, lifecycleTimeout, lifecyclePolling).Should(Succeed())
+	})
+})
+
+// createLifecycleBuild creates a Build reconciled by the manager of the suite, with its fake InfraBuild.
+// The cleanup stage is disabled, it would run as one more provisioner.
+func createLifecycleBuild(ctx context.Context, namespace, name string, annotations map[string]string, provisioners ...buildv1.ProvisionerSpec) *buildv1.Build {
+	infraBuild := newFakeInfraBuild(namespace, name)
+	Expect(k8sClient.Create(ctx, infraBuild)).To(Succeed())
+
+	build := &buildv1.Build{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   namespace,
+			Labels:      map[string]string{buildv1.WatchLabel: integrationWatchFilter},
+			Annotations: annotations,
+		},
+		Spec: buildv1.BuildSpec{
+			Connector: buildv1.ConnectorSpec{
+				Type:        "ssh",
+				Credentials: &corev1.LocalObjectReference{Name: credentialsSecret},
+			},
+			InfrastructureRef: &corev1.ObjectReference{
+				APIVersion: fakeInfraBuildGVK.GroupVersion().String(),
+				Kind:       fakeInfraBuildGVK.Kind,
+				Name:       name,
+			},
+			Provisioners: provisioners,
+			Cleanup:      &buildv1.CleanupSpec{Disabled: true},
+		},
+	}
+	Expect(k8sClient.Create(ctx, build)).To(Succeed())
+	return build
+}
+
+// provisionerJob waits for the Job of the provisioner of the Build at step.
+func provisionerJob(ctx context.Context, build *buildv1.Build, step string) *batchv1.Job {
+	jobs := &batchv1.JobList{}
+	Eventually(func(g Gomega) {
+		g.Expect(k8sClient.List(ctx, jobs, client.InNamespace(build.Namespace), client.MatchingLabels{
+			buildv1.BuildNameLabel:       build.Name,
+			buildv1.ProvisionerStepLabel: step,
+		})).To(Succeed())
+		g.Expect(jobs.Items).To(HaveLen(1))
+	}, lifecycleTimeout, lifecyclePolling).Should(Succeed())
+	return &jobs.Items[0]
+}
+
+// finishProvisionerJob terminates the Pod of the Job with the result of the provisioner in its
+// termination message, and sets the condition of the Job, as the kubelet and the Job controller do.
+func finishProvisionerJob(ctx context.Context, job *batchv1.Job, condition batchv1.JobConditionType, result shell.Result) {
+	message, err := json.Marshal(result)
+	Expect(err).NotTo(HaveOccurred())
+
+	container := job.Spec.Template.Spec.Containers[0]
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: job.Name + "-",
+			Namespace:    job.Namespace,
+			Labels:       job.Spec.Selector.MatchLabels,
+		},
+		Spec: corev1.PodSpec{
+			RestartPolicy: corev1.RestartPolicyNever,
+			Containers:    []corev1.Container{{Name: container.Name, Image: container.Image}},
+		},
+	}
+	Expect(controllerutil.SetControllerReference(job, pod, k8sClient.Scheme())).To(Succeed())
+	Expect(k8sClient.Create(ctx, pod)).To(Succeed())
+	pod.Status.ContainerStatuses = []corev1.ContainerStatus{{
+		Name:  container.Name,
+		Image: container.Image,
+		State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+			ExitCode: ptr.Deref(result.ExitCode, 1),
+			Reason:   "Completed",
+			Message:  string(message),
+		}},
+	}}
+	if condition == batchv1.JobFailed {
+		pod.Status.Phase = corev1.PodFailed
+		pod.Status.ContainerStatuses[0].State.Terminated.Reason = "Error"
+	} else {
+		pod.Status.Phase = corev1.PodSucceeded
+	}
+	Expect(k8sClient.Status().Update(ctx, pod)).To(Succeed())
+
+	now := metav1.Now()
+	job.Status.StartTime = &now
+	job.Status.Conditions = []batchv1.JobCondition{{Type: condition, Status: corev1.ConditionTrue, LastTransitionTime: now}}
+	if condition == batchv1.JobFailed {
+		job.Status.Failed = 1
+	} else {
+		job.Status.Succeeded = 1
+		job.Status.CompletionTime = &now
+	}
+	Expect(k8sClient.Status().Update(ctx, job)).To(Succeed())
+}
@@ -0,0 +1,61 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	buildv1 "github.com/forge-build/forge/api/v1alpha1"
+	forgeerrors "github.com/forge-build/forge/pkg/errors"
+)
+
+func TestReconcileCleanupFailed(t *testing.T) {
+	g := NewWithT(t)
+
+	build := &buildv1.Build{
+		ObjectMeta: metav1.ObjectMeta{Name: "ubuntu", Namespace: "default"},
+		Status: buildv1.BuildStatus{
+			Phase:     string(buildv1.BuildPhaseBuilding),
+			Validated: true,
+			Cleanup: &buildv1.ProvisionerSpec{
+				Type:           buildv1.ProvisionerTypeShell,
+				UUID:           ptr.To("1234"),
+				Status:         ptr.To(buildv1.ProvisionerStatusFailed),
+				FailureReason:  ptr.To("BackoffLimitExceeded"),
+				FailureMessage: ptr.To("exit status 1"),
+			},
+		},
+	}
+	r := newTestReconciler(g, build)
+
+	_, err := r.reconcileCleanup(context.Background(), build)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(build.Status.CleanedUp).To(BeFalse())
+	g.Expect(conditions.GetReason(build, buildv1.CleanedUpCondition)).To(Equal(buildv1.CleanupFailedReason))
+	g.Expect(build.Status.FailureReason).To(HaveValue(Equal(forgeerrors.CleanupFailedError)))
+	g.Expect(build.Status.FailureMessage).To(HaveValue(ContainSubstring("exit status 1")))
+
+	// The Build fails instead of waiting for the export.
+	r.reconcilePhase(context.Background(), build)
+	g.Expect(build.Status.GetTypedPhase()).To(Equal(buildv1.BuildPhaseFailed))
+}
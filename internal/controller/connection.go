@@ -0,0 +1,138 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	buildv1 "github.com/forge-build/forge/api/v1alpha1"
+	forgeerrors "github.com/forge-build/forge/pkg/errors"
+	ssh "github.com/forge-build/forge/pkg/ssh"
+)
+
+// defaultConnectionDeadline is how long the connection to a machine may fail by default, see ConnectorSpec.Deadline.
+const defaultConnectionDeadline = 10 * time.Minute
+
+// connectionFailure is how a failed connection is reported in the MachineReady condition.
+type connectionFailure struct {
+	reason string
+	hint   string
+}
+
+var connectionFailures = map[ssh.FailureReason]connectionFailure{
+	ssh.UnreachableFailure: {
+		reason: buildv1.MachineUnreachableReason,
+		hint:   "Check that the host of the credentials Secret resolves and is routable from the controller.",
+	},
+	ssh.ConnectionRefusedFailure: {
+		reason: buildv1.ConnectionRefusedReason,
+		hint:   "Check that sshd runs on the machine and listens on the port of the credentials Secret.",
+	},
+	ssh.TimeoutFailure: {
+		reason: buildv1.ConnectionTimeoutReason,
+		hint:   "Check that the machine is running and that its firewall rules allow SSH from the controller.",
+	},
+	ssh.AuthenticationFailure: {
+		reason: buildv1.AuthenticationFailedReason,
+		hint:   "Check the username and the password or privateKey of the credentials Secret.",
+	},
+	ssh.HostKeyMismatchFailure: {
+		reason: buildv1.HostKeyMismatchReason,
+		hint:   "Check the hostKey of the credentials Secret, the machine may have been replaced.",
+	},
+	ssh.ProtocolFailure: {
+		reason: buildv1.SSHProtocolErrorReason,
+		hint:   "Check that the port of the credentials Secret is the one sshd listens on.",
+	},
+}
+
+// credentials returns the Secret of the credentials to connect to the machine of the Build.
+func (r *BuildReconciler) credentials(ctx context.Context, build *buildv1.Build) (*corev1.Secret, error) {
+	secret := &corev1.Secret{}
+	if err := r.Client.Get(ctx, client.ObjectKey{Namespace: build.Namespace, Name: build.Spec.Connector.Credentials.Name}, secret); err != nil {
+		return nil, errors.Wrap(err, "failed to get secret")
+	}
+	return secret, nil
+}
+
+// connectionDeadline returns how long the connection to the machine of the Build may fail.
+func connectionDeadline(build *buildv1.Build) time.Duration {
+	if build.Spec.Connector.Deadline != nil {
+		return build.Spec.Connector.Deadline.Duration
+	}
+	return defaultConnectionDeadline
+}
+
+// connectTimeout returns how long to wait for the machine of the Build, without going past the deadline.
+func connectTimeout(build *buildv1.Build) time.Duration {
+	remaining := connectionDeadline(build)
+	if build.Status.Connection != nil && build.Status.Connection.FirstFailureTime != nil {
+		remaining -= time.Since(build.Status.Connection.FirstFailureTime.Time)
+	}
+	return min(SSHTimeout, remaining)
+}
+
+// connectionFailed reports a failed attempt to connect to the machine of the Build in the MachineReady
+// condition, the Build fails once the connection has been failing for longer than the deadline of its connector.
+func (r *BuildReconciler) connectionFailed(ctx context.Context, build *buildv1.Build, err error) (ctrl.Result, error) {
+	status := build.Status.Connection
+	status.FailedAttempts++
+	if status.FirstFailureTime == nil {
+		status.FirstFailureTime = ptr.To(metav1.Now())
+	}
+
+	destination := status.Destination
+	if destination == "" {
+		destination = "the machine"
+	}
+	failure, ok := connectionFailures[ssh.Diagnose(err)]
+	if !ok {
+		failure.reason = buildv1.ConnectionFailedReason
+	}
+	message := fmt.Sprintf("Cannot connect to %s: %v.", destination, err)
+	if failure.hint != "" {
+		message = fmt.Sprintf("%s %s", message, failure.hint)
+	}
+
+	deadline := connectionDeadline(build)
+	if time.Since(status.FirstFailureTime.Time) < deadline {
+		// The condition holds the error, an error returned would drop RequeueAfter for the backoff of the controller.
+		ctrl.LoggerFrom(ctx).Info("Failed to connect to the machine, retrying", "destination", destination, "attempts", status.FailedAttempts, "reason", failure.reason, "error", err.Error())
+		conditions.MarkFalse(build, buildv1.MachineReadyCondition, failure.reason, buildv1.ConditionSeverityWarning, message)
+		return ctrl.Result{
+			RequeueAfter: 2 * time.Second,
+		}, nil
+	}
+
+	ctrl.LoggerFrom(ctx).Info("Giving up connecting to the machine", "destination", destination, "attempts", status.FailedAttempts, "reason", failure.reason)
+	message = fmt.Sprintf("%s Gave up after %d attempts in %s.", message, status.FailedAttempts, deadline)
+	conditions.MarkFalse(build, buildv1.MachineReadyCondition, failure.reason, buildv1.ConditionSeverityError, message)
+	build.Status.FailureReason = ptr.To(forgeerrors.ConnectionFailedError)
+	build.Status.FailureMessage = ptr.To(message)
+	r.recorder.Event(build, corev1.EventTypeWarning, failure.reason, message)
+	return ctrl.Result{}, nil
+}
@@ -0,0 +1,194 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	buildv1 "github.com/forge-build/forge/api/v1alpha1"
+	forgeerrors "github.com/forge-build/forge/pkg/errors"
+	ssh "github.com/forge-build/forge/pkg/ssh"
+	"github.com/forge-build/forge/pkg/ssh/sshtest"
+)
+
+// newConnectionObjects returns a Build whose infrastructure is ready and the credentials Secret of server.
+func newConnectionObjects(server *sshtest.Server) (*buildv1.Build, *corev1.Secret) {
+	secret := server.Secret("forge")
+	secret.Name = "ubuntu-credentials"
+	secret.Namespace = "default"
+	build := &buildv1.Build{
+		ObjectMeta: metav1.ObjectMeta{Name: "ubuntu", Namespace: "default"},
+		Spec: buildv1.BuildSpec{
+			Connector: buildv1.ConnectorSpec{
+				Type:        "ssh",
+				Credentials: &corev1.LocalObjectReference{Name: secret.Name},
+			},
+		},
+		Status: buildv1.BuildStatus{InfrastructureReady: true},
+	}
+	return build, secret
+}
+
+func TestReconcileConnectionReusesConnection(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	server := sshtest.NewServer(t, sshtest.WithPassword("forge", "forge"))
+	build, secret := newConnectionObjects(server)
+	r := newTestReconciler(g, build, secret)
+	defer r.connections.Close()
+
+	for i := 0; i < 3; i++ {
+		_, err := r.reconcileConnection(ctx, build)
+		g.Expect(err).NotTo(HaveOccurred())
+	}
+	g.Expect(build.Status.Connected).To(BeTrue())
+	g.Expect(server.Connections()).To(Equal(1))
+	g.Expect(r.connections.Len()).To(Equal(1))
+
+	// A rotated Secret is connected with again.
+	secret.StringData = map[string]string{"rotated": "true"}
+	g.Expect(r.Client.Update(ctx, secret)).To(Succeed())
+	_, err := r.reconcileConnection(ctx, build)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(server.Connections()).To(Equal(2))
+	g.Expect(r.connections.Len()).To(Equal(1))
+
+	// The connection is released once the Build is deleted.
+	g.Expect(r.Client.Delete(ctx, build)).To(Succeed())
+	_, err = r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(build)})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(r.connections.Len()).To(BeZero())
+	g.Eventually(server.OpenConnections).Should(BeZero())
+}
+
+func TestReconcileConnectionFailures(t *testing.T) {
+	server := sshtest.NewServer(t, sshtest.WithPassword("forge", "forge"))
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	NewWithT(t).Expect(err).NotTo(HaveOccurred())
+	closedPort := strconv.Itoa(closed.Addr().(*net.TCPAddr).Port)
+	closed.Close()
+
+	tests := []struct {
+		name       string
+		secret     func(*corev1.Secret)
+		wantReason string
+		wantHint   string
+	}{
+		{
+			name:       "wrong password",
+			secret:     func(s *corev1.Secret) { s.Data["password"] = []byte("wrong") },
+			wantReason: buildv1.AuthenticationFailedReason,
+			wantHint:   "password or privateKey",
+		},
+		{
+			name:       "host key mismatch",
+			secret:     func(s *corev1.Secret) { s.Data["hostKey"] = sshtest.NewServer(t).Secret("forge").Data["hostKey"] },
+			wantReason: buildv1.HostKeyMismatchReason,
+			wantHint:   "hostKey",
+		},
+		{
+			name:       "connection refused",
+			secret:     func(s *corev1.Secret) { s.Data["port"] = []byte(closedPort) },
+			wantReason: buildv1.ConnectionRefusedReason,
+			wantHint:   "sshd runs on the machine",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			build, secret := newConnectionObjects(server)
+			tt.secret(secret)
+			// Give up after the first attempt.
+			build.Spec.Connector.Deadline = &metav1.Duration{}
+			r := newTestReconciler(g, build, secret)
+			defer r.connections.Close()
+
+			_, err := r.reconcileConnection(context.Background(), build)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(build.Status.Connected).To(BeFalse())
+			g.Expect(build.Status.Connection.Destination).To(Equal(ssh.Destination(secret)))
+			g.Expect(build.Status.Connection.FailedAttempts).To(BeEquivalentTo(1))
+			g.Expect(build.Status.FailureReason).To(HaveValue(Equal(forgeerrors.ConnectionFailedError)))
+			condition := conditions.Get(build, buildv1.MachineReadyCondition)
+			g.Expect(condition.Reason).To(Equal(tt.wantReason))
+			g.Expect(condition.Severity).To(Equal(buildv1.ConditionSeverityError))
+			g.Expect(condition.Message).To(ContainSubstring(ssh.Destination(secret)))
+			g.Expect(condition.Message).To(ContainSubstring(tt.wantHint))
+			g.Expect(*build.Status.FailureMessage).To(Equal(condition.Message))
+		})
+	}
+}
+
+func TestReconcileConnectionDeadline(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	server := sshtest.NewServer(t, sshtest.WithPassword("forge", "forge"))
+	build, secret := newConnectionObjects(server)
+	r := newTestReconciler(g, build)
+	defer r.connections.Close()
+
+	// The failed attempts are counted until the deadline.
+	for attempt := 1; attempt <= 2; attempt++ {
+		// The failure is reported in the condition, the connection is attempted again after RequeueAfter.
+		res, err := r.reconcileConnection(ctx, build)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(res.RequeueAfter).To(Equal(2 * time.Second))
+		g.Expect(build.Status.Connection.FailedAttempts).To(BeEquivalentTo(attempt))
+	}
+	g.Expect(build.Status.Connection.FirstFailureTime).NotTo(BeNil())
+	g.Expect(build.Status.FailureReason).To(BeNil())
+	g.Expect(conditions.GetReason(build, buildv1.MachineReadyCondition)).To(Equal(buildv1.ConnectionFailedReason))
+	g.Expect(conditions.GetSeverity(build, buildv1.MachineReadyCondition)).To(HaveValue(Equal(buildv1.ConditionSeverityWarning)))
+	g.Expect(conditions.GetMessage(build, buildv1.MachineReadyCondition)).To(ContainSubstring("not found"))
+
+	// A successful connection resets the failed attempts.
+	g.Expect(r.Client.Create(ctx, secret)).To(Succeed())
+	_, err := r.reconcileConnection(ctx, build)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(conditions.IsTrue(build, buildv1.MachineReadyCondition)).To(BeTrue())
+	g.Expect(build.Status.Connection).To(Equal(&buildv1.ConnectionStatus{Destination: ssh.Destination(secret)}))
+
+	// The Build fails once the connection failed for longer than the deadline.
+	g.Expect(r.Client.Delete(ctx, secret)).To(Succeed())
+	build.Status.Connection.FirstFailureTime = ptr.To(metav1.NewTime(time.Now().Add(-defaultConnectionDeadline)))
+	_, err = r.reconcileConnection(ctx, build)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(build.Status.FailureReason).To(HaveValue(Equal(forgeerrors.ConnectionFailedError)))
+	g.Expect(build.Status.FailureMessage).To(HaveValue(ContainSubstring("Gave up after 1 attempts in 10m0s")))
+
+	// No connection is attempted anymore, until the Build is retried.
+	_, err = r.reconcileConnection(ctx, build)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(build.Status.Connection.FailedAttempts).To(BeEquivalentTo(1))
+	r.reconcileRetry(ctx, build)
+	g.Expect(build.Status.FailureReason).To(BeNil())
+	g.Expect(build.Status.Connection).To(BeNil())
+}
@@ -0,0 +1,142 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"path"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/utils/ptr"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/cluster-api/util/patch"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	buildv1 "github.com/forge-build/forge/api/v1alpha1"
+	"github.com/forge-build/forge/internal/external"
+	"github.com/forge-build/forge/pkg/contract/infra"
+	forgeerrors "github.com/forge-build/forge/pkg/errors"
+)
+
+// reconcileDeleteCascade deletes the image exported by a Build deleted with DeleteCascade, before its
+// InfraBuild is deleted with the other descendants. The image is preserved otherwise. It returns true
+// once the InfraBuild can be deleted.
+func (r *BuildReconciler) reconcileDeleteCascade(ctx context.Context, build *buildv1.Build) (bool, error) {
+	// The InfraBuild of a matrix Build is only copied by its variants, they delete their own images.
+	if build.Spec.InfrastructureRef == nil || build.Spec.Matrix != nil {
+		return true, nil
+	}
+
+	obj, err := external.Get(ctx, r.Client, build.Spec.InfrastructureRef, build.Namespace)
+	switch {
+	case apierrors.IsNotFound(errors.Cause(err)):
+		return true, nil
+	case err != nil:
+		return false, errors.Wrapf(err, "failed to get %s %q for Build %s/%s",
+			path.Join(build.Spec.InfrastructureRef.APIVersion, build.Spec.InfrastructureRef.Kind),
+			build.Spec.InfrastructureRef.Name, build.Namespace, build.Name)
+	}
+	if !obj.GetDeletionTimestamp().IsZero() {
+		return true, nil
+	}
+
+	if !build.Spec.DeleteCascade {
+		if build.Status.ImageArtifact != "" {
+			r.recorder.Eventf(build, corev1.EventTypeNormal, "ImagePreserved", "The image %s is preserved in ImageArtifact %s", build.Status.Artifact, build.Status.ImageArtifact)
+		}
+		return true, nil
+	}
+	return r.reconcileDeleteImage(ctx, build, obj)
+}
+
+// reconcileDeleteImage asks the infrastructure provider to delete the image exported by a Build deleted
+// with DeleteCascade, it returns true once the image is deleted and the InfraBuild can be deleted.
+func (r *BuildReconciler) reconcileDeleteImage(ctx context.Context, build *buildv1.Build, obj *unstructured.Unstructured) (bool, error) {
+	log := ctrl.LoggerFrom(ctx)
+
+	exported, err := external.IsReady(obj)
+	if err != nil {
+		return false, err
+	}
+	if !exported {
+		log.V(3).Info("No image exported, nothing to delete")
+		return true, nil
+	}
+
+	deleted, err := external.IsImageDeleted(obj)
+	if err != nil {
+		return false, err
+	}
+	if deleted {
+		return true, r.deleteImageArtifact(ctx, build)
+	}
+
+	if !infra.ImageDeletionRequested(obj) {
+		patchHelper, err := patch.NewHelper(obj, r.Client)
+		if err != nil {
+			return false, err
+		}
+		annotations := obj.GetAnnotations()
+		if annotations == nil {
+			annotations = make(map[string]string)
+		}
+		annotations[buildv1.DeleteImageAnnotation] = ""
+		obj.SetAnnotations(annotations)
+		if err := patchHelper.Patch(ctx, obj); err != nil {
+			return false, errors.Wrapf(err, "failed to request the deletion of the image of %v %q", obj.GroupVersionKind(), obj.GetName())
+		}
+		r.recorder.Eventf(build, corev1.EventTypeNormal, "DeletingImage", "Build %s requested the deletion of the image %s", build.Name, build.Status.Artifact)
+	}
+
+	failureReason, failureMessage, err := external.FailuresFrom(obj)
+	if err != nil {
+		return false, err
+	}
+	if failureReason != "" || failureMessage != "" {
+		msg := fmt.Sprintf("Failed to delete the image %s of %v %q: %s", build.Status.Artifact, obj.GroupVersionKind().Kind, obj.GetName(), failureMessage)
+		conditions.MarkFalse(build, buildv1.InfrastructureReadyCondition, buildv1.DeletionFailedReason, buildv1.ConditionSeverityWarning, msg)
+		if ptr.Deref(build.Status.FailureReason, "") != forgeerrors.DeleteBuildError {
+			r.recorder.Event(build, corev1.EventTypeWarning, buildv1.DeletionFailedReason, msg)
+		}
+		build.Status.FailureReason = ptr.To(forgeerrors.DeleteBuildError)
+		build.Status.FailureMessage = ptr.To(msg)
+		return false, nil
+	}
+
+	log.Info("Waiting for the image to be deleted", "artifact", build.Status.Artifact)
+	conditions.MarkFalse(build, buildv1.InfrastructureReadyCondition, clusterv1.DeletingReason, buildv1.ConditionSeverityInfo,
+		"Waiting for %s %s to delete the image", obj.GroupVersionKind().Kind, obj.GetName())
+	return false, nil
+}
+
+// deleteImageArtifact deletes the ImageArtifact recording the deleted image of the Build.
+func (r *BuildReconciler) deleteImageArtifact(ctx context.Context, build *buildv1.Build) error {
+	if build.Status.ImageArtifact == "" {
+		return nil
+	}
+	artifact := &buildv1.ImageArtifact{ObjectMeta: metav1.ObjectMeta{Namespace: build.Namespace, Name: build.Status.ImageArtifact}}
+	if err := r.Client.Delete(ctx, artifact); err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrapf(err, "failed to delete the ImageArtifact %s", artifact.Name)
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	buildv1 "github.com/forge-build/forge/api/v1alpha1"
+	infrav1 "github.com/forge-build/forge/infrastructure/static/api/v1alpha1"
+	"github.com/forge-build/forge/internal/external"
+	forgeerrors "github.com/forge-build/forge/pkg/errors"
+)
+
+func TestReconcileDeleteImage(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	build, machine := newBaseImageObjects()
+	build.Spec.DeleteCascade = true
+	build.Status.Artifact = "pvc://images/hardened.tar.gz"
+	build.Status.ImageArtifact = "hardened-1234"
+	artifact := &buildv1.ImageArtifact{ObjectMeta: metav1.ObjectMeta{Name: "hardened-1234", Namespace: "default"}}
+	r := newTestReconciler(g, build, machine, artifact)
+
+	deleteImage := func() bool {
+		obj, err := external.Get(ctx, r.Client, build.Spec.InfrastructureRef, build.Namespace)
+		g.Expect(err).NotTo(HaveOccurred())
+		deleted, err := r.reconcileDeleteImage(ctx, build, obj)
+		g.Expect(err).NotTo(HaveOccurred())
+		return deleted
+	}
+	updateMachine := func(mutate func(*infrav1.StaticMachine)) {
+		g.Expect(r.Client.Get(ctx, client.ObjectKeyFromObject(machine), machine)).To(Succeed())
+		mutate(machine)
+		g.Expect(r.Client.Update(ctx, machine)).To(Succeed())
+	}
+
+	// There is nothing to delete before the image is exported.
+	g.Expect(deleteImage()).To(BeTrue())
+
+	// The deletion of the exported image is requested.
+	updateMachine(func(m *infrav1.StaticMachine) { m.Status.MarkReady() })
+	g.Expect(deleteImage()).To(BeFalse())
+	g.Expect(r.Client.Get(ctx, client.ObjectKeyFromObject(machine), machine)).To(Succeed())
+	g.Expect(machine.Annotations).To(HaveKey(buildv1.DeleteImageAnnotation))
+
+	// The provider failed to delete the image.
+	updateMachine(func(m *infrav1.StaticMachine) { m.Status.MarkFailed(forgeerrors.DeleteBuildError, "volume not found") })
+	g.Expect(deleteImage()).To(BeFalse())
+	g.Expect(build.Status.FailureReason).To(HaveValue(Equal(forgeerrors.DeleteBuildError)))
+	g.Expect(build.Status.FailureMessage).To(HaveValue(ContainSubstring("volume not found")))
+	g.Expect(conditions.GetReason(build, buildv1.InfrastructureReadyCondition)).To(Equal(buildv1.DeletionFailedReason))
+
+	// The image is deleted, so is its ImageArtifact.
+	updateMachine(func(m *infrav1.StaticMachine) {
+		m.Status.FailureReason = nil
+		m.Status.FailureMessage = nil
+		m.Status.MarkImageDeleted()
+	})
+	g.Expect(deleteImage()).To(BeTrue())
+	err := r.Client.Get(ctx, client.ObjectKeyFromObject(artifact), artifact)
+	g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+}
+
+func TestReconcileDeleteCascade(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	build, machine := newBaseImageObjects()
+	build.Spec.DeleteCascade = true
+	build.Status.Artifact = "pvc://images/hardened.tar.gz"
+	// The Build is read from the cache with its GroupVersionKind, the owner is matched with it.
+	build.SetGroupVersionKind(buildv1.GroupVersion.WithKind("Build"))
+	build.Finalizers = []string{buildv1.BuildFinalizer}
+	build.DeletionTimestamp = ptr.To(metav1.Now())
+	// The InfraBuild is a descendant owned by the Build.
+	machine.Labels = map[string]string{buildv1.BuildNameLabel: build.Name}
+	machine.OwnerReferences = []metav1.OwnerReference{{
+		APIVersion: buildv1.GroupVersion.String(),
+		Kind:       "Build",
+		Name:       build.Name,
+		Controller: ptr.To(true),
+	}}
+	machine.Status.MarkReady()
+	r := newTestReconciler(g, build, machine)
+
+	// The InfraBuild is kept until the provider deleted the image.
+	_, err := r.reconcileDelete(ctx, build)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(r.Client.Get(ctx, client.ObjectKeyFromObject(machine), machine)).To(Succeed())
+	g.Expect(machine.Annotations).To(HaveKey(buildv1.DeleteImageAnnotation))
+	g.Expect(build.Finalizers).To(ContainElement(buildv1.BuildFinalizer))
+
+	_, err = r.reconcileDelete(ctx, build)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(r.Client.Get(ctx, client.ObjectKeyFromObject(machine), machine)).To(Succeed())
+
+	// The InfraBuild is deleted once the image is.
+	machine.Status.MarkImageDeleted()
+	g.Expect(r.Client.Update(ctx, machine)).To(Succeed())
+	_, err = r.reconcileDelete(ctx, build)
+	g.Expect(err).NotTo(HaveOccurred())
+	err = r.Client.Get(ctx, client.ObjectKeyFromObject(machine), machine)
+	g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+
+	_, err = r.reconcileDelete(ctx, build)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(build.Finalizers).NotTo(ContainElement(buildv1.BuildFinalizer))
+}
@@ -0,0 +1,151 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	buildv1 "github.com/forge-build/forge/api/v1alpha1"
+	"github.com/forge-build/forge/pkg/contract/infra"
+)
+
+const (
+	// fakeInfraBuildFinalizer is the finalizer of the fake InfraBuilds, removed when their machine is torn down.
+	fakeInfraBuildFinalizer = "fakeinfrabuild.infrastructure.forge.build"
+
+	// fakeHoldDeletionAnnotation keeps the finalizer of a deleted fake InfraBuild, as a slow teardown does.
+	fakeHoldDeletionAnnotation = "infrastructure.forge.build/hold-deletion"
+)
+
+// fakeInfraBuildGVK is the kind of the fake InfraBuilds, which implement the InfraBuild contract in the tests.
+var fakeInfraBuildGVK = schema.GroupVersionKind{Group: "infrastructure.forge.build", Version: "v1alpha1", Kind: "FakeInfraBuild"}
+
+// fakeInfraBuildCRD returns the CRD of the fake InfraBuilds.
+func fakeInfraBuildCRD() *apiextensionsv1.CustomResourceDefinition {
+	return &apiextensionsv1.CustomResourceDefinition{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "fakeinfrabuilds.infrastructure.forge.build",
+			Labels: map[string]string{
+				infra.ContractLabel:       fakeInfraBuildGVK.Version,
+				buildv1.ProviderNameLabel: "fake",
+			},
+		},
+		Spec: apiextensionsv1.CustomResourceDefinitionSpec{
+			Group: fakeInfraBuildGVK.Group,
+			Names: apiextensionsv1.CustomResourceDefinitionNames{
+				Kind:     fakeInfraBuildGVK.Kind,
+				ListKind: fakeInfraBuildGVK.Kind + "List",
+				Plural:   "fakeinfrabuilds",
+				Singular: "fakeinfrabuild",
+			},
+			Scope: apiextensionsv1.NamespaceScoped,
+			Versions: []apiextensionsv1.CustomResourceDefinitionVersion{{
+				Name:         fakeInfraBuildGVK.Version,
+				Served:       true,
+				Storage:      true,
+				Subresources: &apiextensionsv1.CustomResourceSubresources{Status: &apiextensionsv1.CustomResourceSubresourceStatus{}},
+				Schema: &apiextensionsv1.CustomResourceValidation{
+					OpenAPIV3Schema: &apiextensionsv1.JSONSchemaProps{
+						Type: "object",
+						Properties: map[string]apiextensionsv1.JSONSchemaProps{
+							"spec": {Type: "object", XPreserveUnknownFields: ptr.To(true)},
+							"status": {
+								Type: "object",
+								Properties: map[string]apiextensionsv1.JSONSchemaProps{
+									"machineReady":   {Type: "boolean"},
+									"ready":          {Type: "boolean"},
+									"failureReason":  {Type: "string"},
+									"failureMessage": {Type: "string"},
+									"artifact":       {Type: "string"},
+								},
+								XPreserveUnknownFields: ptr.To(true),
+							},
+						},
+					},
+				},
+			}},
+		},
+	}
+}
+
+// newFakeInfraBuild returns a fake InfraBuild.
+func newFakeInfraBuild(namespace, name string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(fakeInfraBuildGVK)
+	obj.SetNamespace(namespace)
+	obj.SetName(name)
+	return obj
+}
+
+// fakeInfraBuildReconciler is the provider of the fake InfraBuilds. Their machine is ready once they
+// are handed over to their Build, and their image is exported once the Build requests it.
+type fakeInfraBuildReconciler struct {
+	client.Client
+}
+
+func (r *fakeInfraBuildReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("fakeinfrabuild").
+		For(newFakeInfraBuild("", "")).
+		Complete(r)
+}
+
+func (r *fakeInfraBuildReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	obj := newFakeInfraBuild(req.Namespace, req.Name)
+	if err := r.Get(ctx, req.NamespacedName, obj); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	if !obj.GetDeletionTimestamp().IsZero() {
+		if _, ok := obj.GetAnnotations()[fakeHoldDeletionAnnotation]; ok {
+			return ctrl.Result{}, nil
+		}
+		if controllerutil.RemoveFinalizer(obj, fakeInfraBuildFinalizer) {
+			return ctrl.Result{}, r.Update(ctx, obj)
+		}
+		return ctrl.Result{}, nil
+	}
+	if controllerutil.AddFinalizer(obj, fakeInfraBuildFinalizer) {
+		return ctrl.Result{}, r.Update(ctx, obj)
+	}
+
+	if metav1.GetControllerOf(obj) == nil {
+		return ctrl.Result{}, nil
+	}
+	patch := client.MergeFrom(obj.DeepCopy())
+	if err := unstructured.SetNestedField(obj.Object, true, infra.MachineReadyPath...); err != nil {
+		return ctrl.Result{}, err
+	}
+	if _, ok := obj.GetAnnotations()[buildv1.ExportAnnotation]; ok {
+		if err := unstructured.SetNestedField(obj.Object, true, infra.ReadyPath...); err != nil {
+			return ctrl.Result{}, err
+		}
+		if err := unstructured.SetNestedField(obj.Object, "fake-image-"+obj.GetName(), infra.ArtifactPath...); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	return ctrl.Result{}, r.Status().Patch(ctx, obj, patch)
+}
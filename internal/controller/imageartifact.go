@@ -0,0 +1,179 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	buildv1 "github.com/forge-build/forge/api/v1alpha1"
+	"github.com/forge-build/forge/internal/external"
+	"github.com/forge-build/forge/pkg/contract/infra"
+	"github.com/forge-build/forge/pkg/kube"
+	forgeutil "github.com/forge-build/forge/util"
+)
+
+// reconcileImageArtifact records the exported image in an ImageArtifact, which is not deleted with the Build.
+func (r *BuildReconciler) reconcileImageArtifact(ctx context.Context, build *buildv1.Build) (ctrl.Result, error) {
+	log := ctrl.LoggerFrom(ctx)
+
+	if !build.Status.Ready || build.Status.Artifact == "" || build.Spec.InfrastructureRef == nil {
+		return ctrl.Result{}, nil
+	}
+	name := imageArtifactName(build)
+	if build.Status.ImageArtifact == name {
+		return ctrl.Result{}, nil
+	}
+
+	obj, err := external.Get(ctx, r.Client, build.Spec.InfrastructureRef, build.Namespace)
+	if err != nil {
+		return ctrl.Result{}, errors.Wrap(err, "failed to get the InfraBuild")
+	}
+	status, err := infra.StatusFrom(obj)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	provider, err := r.providerName(ctx, build.Spec.InfrastructureRef)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	provisioners, err := r.provisionerSources(ctx, build)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	artifact := &buildv1.ImageArtifact{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: build.Namespace,
+		},
+		Spec: buildv1.ImageArtifactSpec{
+			BuildName:         build.Name,
+			Provider:          provider,
+			InfrastructureRef: *external.GetObjectReference(obj),
+			ImageID:           build.Status.Artifact,
+			Region:            status.Region,
+			Architecture:      status.Architecture,
+			Checksum:          status.Checksum,
+			BuildSpecHash:     buildSpecHash(build),
+			Provisioners:      provisioners,
+			Outputs:           build.Status.Outputs,
+			Lineage:           build.Status.Lineage,
+			Retention:         build.Spec.ArtifactRetention,
+		},
+	}
+	artifact.Labels = imageArtifactLabels(artifact)
+	if err := r.Client.Create(ctx, artifact); err != nil {
+		if !apierrors.IsAlreadyExists(err) {
+			return ctrl.Result{}, errors.Wrapf(err, "failed to create the ImageArtifact %s", name)
+		}
+		log.V(3).Info("ImageArtifact already exists", "imageArtifact", name)
+	} else {
+		r.recorder.Eventf(build, corev1.EventTypeNormal, "ImageArtifactCreated", "Recorded the image %s in ImageArtifact %s", build.Status.Artifact, name)
+	}
+
+	build.Status.ImageArtifact = name
+	return ctrl.Result{}, nil
+}
+
+// imageArtifactName returns the name of the ImageArtifact recording the image exported by the Build.
+func imageArtifactName(build *buildv1.Build) string {
+	return fmt.Sprintf("%s-%s", build.Name, kube.ComputeHash(build.Status.Artifact))
+}
+
+// buildSpecHash hashes the spec of the Build without the fields set by the controllers.
+func buildSpecHash(build *buildv1.Build) string {
+	spec := build.Spec.DeepCopy()
+	spec.Paused = false
+	spec.Connector.Credentials = nil
+	spec.ProvisionerRuntime = nil
+	for i := range spec.Provisioners {
+		p := &spec.Provisioners[i]
+		p.Runtime = nil
+		p.UUID = nil
+		p.Status = nil
+		p.FailureReason = nil
+		p.FailureMessage = nil
+		p.ExitCode = nil
+		p.Result = nil
+		p.Retried = 0
+		p.RetryTime = nil
+	}
+	return kube.ComputeHash(spec)
+}
+
+// providerName returns the name of the infrastructure provider of the InfraBuild, read from
+// the forge.build/provider label of its CRD, or the API group of the InfraBuild if it is not set.
+func (r *BuildReconciler) providerName(ctx context.Context, ref *corev1.ObjectReference) (string, error) {
+	gvk := ref.GroupVersionKind()
+	crd := &apiextensionsv1.CustomResourceDefinition{}
+	crdName := forgeutil.CalculateCRDName(gvk.Group, gvk.Kind)
+	if err := r.Client.Get(ctx, client.ObjectKey{Name: crdName}, crd); err != nil {
+		return "", errors.Wrapf(err, "failed to retrieve the CRD %s", crdName)
+	}
+	if name := crd.Labels[buildv1.ProviderNameLabel]; name != "" {
+		return name, nil
+	}
+	return gvk.Group, nil
+}
+
+// provisionerSources returns the sources of the provisioners of the Build, with the commit SHA
+// recorded by the SourceCommitAnnotation of their ConfigMap or object.
+func (r *BuildReconciler) provisionerSources(ctx context.Context, build *buildv1.Build) ([]buildv1.ProvisionerSource, error) {
+	sources := make([]buildv1.ProvisionerSource, 0, len(build.Spec.Provisioners))
+	for _, p := range build.Spec.Provisioners {
+		source := buildv1.ProvisionerSource{Type: p.Type}
+		var obj client.Object
+		switch {
+		case p.Ref != nil:
+			source.Source = p.Ref.Kind + "/" + p.Ref.Name
+			u, err := external.Get(ctx, r.Client, p.Ref, build.Namespace)
+			if err != nil && !apierrors.IsNotFound(errors.Cause(err)) {
+				return nil, err
+			}
+			if err == nil {
+				obj = u
+			}
+		case p.RunConfigMapRef != nil:
+			source.Source = "ConfigMap/" + p.RunConfigMapRef.Name
+			cm := &metav1.PartialObjectMetadata{}
+			cm.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("ConfigMap"))
+			if err := r.Client.Get(ctx, client.ObjectKey{Namespace: build.Namespace, Name: p.RunConfigMapRef.Name}, cm); err != nil {
+				if !apierrors.IsNotFound(err) {
+					return nil, errors.Wrapf(err, "failed to get the ConfigMap %s", p.RunConfigMapRef.Name)
+				}
+			} else {
+				obj = cm
+			}
+		case p.Run != nil:
+			source.Source = "inline"
+		}
+		if obj != nil {
+			source.Commit = obj.GetAnnotations()[buildv1.SourceCommitAnnotation]
+		}
+		sources = append(sources, source)
+	}
+	return sources, nil
+}
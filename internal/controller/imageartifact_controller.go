@@ -0,0 +1,229 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/cluster-api/util/patch"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	buildv1 "github.com/forge-build/forge/api/v1alpha1"
+	"github.com/forge-build/forge/util/predicates"
+)
+
+// ImageArtifactReconciler reconciles an ImageArtifact object
+type ImageArtifactReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	// WatchFilterValue is the label value used to filter events prior to reconciliation.
+	WatchFilterValue string
+
+	recorder record.EventRecorder
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ImageArtifactReconciler) SetupWithManager(ctx context.Context, mgr ctrl.Manager, options controller.Options) error {
+	err := ctrl.NewControllerManagedBy(mgr).
+		For(&buildv1.ImageArtifact{}).
+		Watches(&buildv1.ImageArtifact{}, handler.EnqueueRequestsFromMapFunc(r.imageArtifactToSiblings)).
+		WithOptions(options).
+		WithEventFilter(predicates.ResourceNotPausedAndHasFilterLabel(ctrl.LoggerFrom(ctx), r.WatchFilterValue)).
+		Complete(r)
+	if err != nil {
+		return errors.Wrap(err, "failed setting up with a controller manager")
+	}
+
+	r.recorder = mgr.GetEventRecorderFor("imageartifact-controller")
+	return nil
+}
+
+//+kubebuilder:rbac:groups=forge.build,resources=imageartifacts,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=forge.build,resources=imageartifacts/status,verbs=get;update;patch
+
+// Reconcile keeps the labels of an ImageArtifact in sync with its spec and deletes it
+// once its retention policy expires.
+func (r *ImageArtifactReconciler) Reconcile(ctx context.Context, req ctrl.Request) (_ ctrl.Result, reterr error) {
+	log := ctrl.LoggerFrom(ctx)
+
+	artifact := &buildv1.ImageArtifact{}
+	if err := r.Client.Get(ctx, req.NamespacedName, artifact); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	if !artifact.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	patchHelper, err := patch.NewHelper(artifact, r.Client)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	deleted := false
+	defer func() {
+		if deleted {
+			return
+		}
+		if err := patchHelper.Patch(ctx, artifact); err != nil {
+			reterr = kerrors.NewAggregate([]error{reterr, errors.Wrap(err, "failed to patch the ImageArtifact")})
+		}
+	}()
+
+	labels := artifact.GetLabels()
+	if labels == nil {
+		labels = make(map[string]string)
+	}
+	for k, v := range imageArtifactLabels(artifact) {
+		labels[k] = v
+	}
+	artifact.SetLabels(labels)
+
+	retention := artifact.Spec.Retention
+	if retention == nil {
+		artifact.Status.ExpirationTime = nil
+		return ctrl.Result{}, nil
+	}
+
+	if retention.KeepLast != nil {
+		superseded, err := r.superseded(ctx, artifact, int(*retention.KeepLast))
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if superseded {
+			log.Info("Deleting ImageArtifact superseded by newer images", "keepLast", *retention.KeepLast)
+			deleted = true
+			return ctrl.Result{}, r.delete(ctx, artifact, "Superseded", "More than %d newer images of Build %s", *retention.KeepLast, artifact.Spec.BuildName)
+		}
+	}
+
+	artifact.Status.ExpirationTime = nil
+	if retention.MaxAge != nil {
+		expiration := metav1.NewTime(artifact.CreationTimestamp.Add(retention.MaxAge.Duration))
+		artifact.Status.ExpirationTime = &expiration
+		if left := time.Until(expiration.Time); left > 0 {
+			return ctrl.Result{RequeueAfter: left}, nil
+		}
+		log.Info("Deleting expired ImageArtifact", "maxAge", retention.MaxAge.Duration)
+		deleted = true
+		return ctrl.Result{}, r.delete(ctx, artifact, "Expired", "Older than %s", retention.MaxAge.Duration)
+	}
+	return ctrl.Result{}, nil
+}
+
+// superseded returns true if at least keepLast ImageArtifacts of the same Build are more recent.
+func (r *ImageArtifactReconciler) superseded(ctx context.Context, artifact *buildv1.ImageArtifact, keepLast int) (bool, error) {
+	artifacts, err := r.siblings(ctx, artifact)
+	if err != nil {
+		return false, err
+	}
+	sort.Slice(artifacts, func(i, j int) bool {
+		a, b := artifacts[i].CreationTimestamp, artifacts[j].CreationTimestamp
+		if a.Equal(&b) {
+			return artifacts[i].Name > artifacts[j].Name
+		}
+		return b.Before(&a)
+	})
+	for i := range artifacts {
+		if artifacts[i].Name == artifact.Name {
+			return i >= keepLast, nil
+		}
+	}
+	return false, nil
+}
+
+// delete deletes the ImageArtifact, the image itself is not deleted.
+func (r *ImageArtifactReconciler) delete(ctx context.Context, artifact *buildv1.ImageArtifact, reason, format string, args ...interface{}) error {
+	if err := r.Client.Delete(ctx, artifact); err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrapf(err, "failed to delete the ImageArtifact %s", artifact.Name)
+	}
+	r.recorder.Eventf(artifact, corev1.EventTypeNormal, reason, format, args...)
+	return nil
+}
+
+// siblings lists the ImageArtifacts of the Build which exported the image, including the ImageArtifact itself.
+func (r *ImageArtifactReconciler) siblings(ctx context.Context, artifact *buildv1.ImageArtifact) ([]buildv1.ImageArtifact, error) {
+	list := &buildv1.ImageArtifactList{}
+	if err := r.Client.List(ctx, list, client.InNamespace(artifact.Namespace)); err != nil {
+		return nil, errors.Wrap(err, "failed to list the ImageArtifacts")
+	}
+	var artifacts []buildv1.ImageArtifact
+	for _, a := range list.Items {
+		if a.Spec.BuildName == artifact.Spec.BuildName && a.DeletionTimestamp.IsZero() {
+			artifacts = append(artifacts, a)
+		}
+	}
+	return artifacts, nil
+}
+
+// imageArtifactToSiblings maps an ImageArtifact to the other ImageArtifacts of its Build,
+// so that the older ones are deleted when a new image is exported.
+func (r *ImageArtifactReconciler) imageArtifactToSiblings(ctx context.Context, o client.Object) []reconcile.Request {
+	artifact, ok := o.(*buildv1.ImageArtifact)
+	if !ok {
+		return nil
+	}
+	artifacts, err := r.siblings(ctx, artifact)
+	if err != nil {
+		ctrl.LoggerFrom(ctx).Error(err, "Failed to list the ImageArtifacts of a Build", "build", artifact.Spec.BuildName)
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, a := range artifacts {
+		if a.Name != artifact.Name && a.Spec.Retention != nil && a.Spec.Retention.KeepLast != nil {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&a)})
+		}
+	}
+	return requests
+}
+
+// imageArtifactLabels returns the labels selecting an ImageArtifact by Build, provider,
+// architecture, region and deprecation, values which are not valid label values are skipped.
+func imageArtifactLabels(artifact *buildv1.ImageArtifact) map[string]string {
+	labels := map[string]string{
+		buildv1.DeprecatedLabel: strconv.FormatBool(artifact.Spec.Deprecated),
+	}
+	for k, v := range map[string]string{
+		buildv1.BuildNameLabel:    artifact.Spec.BuildName,
+		buildv1.ProviderNameLabel: artifact.Spec.Provider,
+		buildv1.ArchitectureLabel: artifact.Spec.Architecture,
+		buildv1.RegionLabel:       artifact.Spec.Region,
+	} {
+		if v != "" && len(validation.IsValidLabelValue(v)) == 0 {
+			labels[k] = v
+		}
+	}
+	return labels
+}
@@ -0,0 +1,211 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	buildv1 "github.com/forge-build/forge/api/v1alpha1"
+)
+
+func TestReconcileImageArtifact(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	build, machine := newBaseImageObjects()
+	build.Spec.BaseImageFrom = nil
+	build.Spec.Provisioners = append(build.Spec.Provisioners, buildv1.ProvisionerSpec{
+		Type:            buildv1.ProvisionerTypeShell,
+		RunConfigMapRef: &corev1.ObjectReference{Name: "hardening"},
+	})
+	build.Spec.Provisioners[0].Run = ptr.To("apt-get upgrade -y")
+	build.Spec.ArtifactRetention = &buildv1.RetentionPolicy{KeepLast: ptr.To[int32](3)}
+	build.Status.Lineage = []buildv1.LineageEntry{{BuildName: "base", Artifact: "pvc://images/base.tar.gz"}}
+	build.Status.Outputs = map[string]string{"kernel": "6.8.0-40-generic"}
+	machine.Status.Ready = true
+	machine.Status.Artifact = "pvc://images/hardened.tar.gz"
+	machine.Status.Architecture = "amd64"
+	machine.Status.Checksum = "sha256:1234"
+	crd := &apiextensionsv1.CustomResourceDefinition{ObjectMeta: metav1.ObjectMeta{
+		Name:   "staticmachines.infrastructure.forge.build",
+		Labels: map[string]string{buildv1.ProviderNameLabel: "static"},
+	}}
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+		Name:        "hardening",
+		Namespace:   "default",
+		Annotations: map[string]string{buildv1.SourceCommitAnnotation: "0123abc"},
+	}}
+	r := newTestReconciler(g, build, machine, crd, cm)
+
+	// Nothing is recorded before the image is exported.
+	_, err := r.reconcileImageArtifact(ctx, build)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(build.Status.ImageArtifact).To(BeEmpty())
+
+	build.Status.Ready = true
+	build.Status.Artifact = machine.Status.Artifact
+	_, err = r.reconcileImageArtifact(ctx, build)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(build.Status.ImageArtifact).To(HavePrefix("hardened-"))
+
+	artifact := &buildv1.ImageArtifact{}
+	g.Expect(r.Client.Get(ctx, client.ObjectKey{Namespace: "default", Name: build.Status.ImageArtifact}, artifact)).To(Succeed())
+	g.Expect(artifact.OwnerReferences).To(BeEmpty())
+	g.Expect(artifact.Spec.BuildName).To(Equal("hardened"))
+	g.Expect(artifact.Spec.Provider).To(Equal("static"))
+	g.Expect(artifact.Spec.InfrastructureRef.Kind).To(Equal("StaticMachine"))
+	g.Expect(artifact.Spec.ImageID).To(Equal("pvc://images/hardened.tar.gz"))
+	g.Expect(artifact.Spec.Architecture).To(Equal("amd64"))
+	g.Expect(artifact.Spec.Checksum).To(Equal("sha256:1234"))
+	g.Expect(artifact.Spec.BuildSpecHash).To(Equal(buildSpecHash(build)))
+	g.Expect(artifact.Spec.Lineage).To(Equal(build.Status.Lineage))
+	g.Expect(artifact.Spec.Outputs).To(Equal(map[string]string{"kernel": "6.8.0-40-generic"}))
+	g.Expect(artifact.Spec.Retention).To(Equal(build.Spec.ArtifactRetention))
+	g.Expect(artifact.Spec.Provisioners).To(Equal([]buildv1.ProvisionerSource{
+		{Type: buildv1.ProvisionerTypeShell, Source: "inline"},
+		{Type: buildv1.ProvisionerTypeShell, Source: "ConfigMap/hardening", Commit: "0123abc"},
+	}))
+	g.Expect(artifact.Labels).To(Equal(map[string]string{
+		buildv1.BuildNameLabel:    "hardened",
+		buildv1.ProviderNameLabel: "static",
+		buildv1.ArchitectureLabel: "amd64",
+		buildv1.DeprecatedLabel:   "false",
+	}))
+
+	// A rebuild exporting a new image is recorded in a new ImageArtifact.
+	first := build.Status.ImageArtifact
+	build.Status.Artifact = "pvc://images/hardened-2.tar.gz"
+	_, err = r.reconcileImageArtifact(ctx, build)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(build.Status.ImageArtifact).NotTo(Equal(first))
+}
+
+func TestBuildSpecHash(t *testing.T) {
+	g := NewWithT(t)
+
+	build, _ := newBaseImageObjects()
+	hash := buildSpecHash(build)
+
+	// The state set by the controllers does not change the hash.
+	build.Spec.Provisioners[0].UUID = ptr.To("5678")
+	build.Spec.Provisioners[0].Status = ptr.To(buildv1.ProvisionerStatusFailed)
+	build.Spec.Connector.Credentials = &corev1.LocalObjectReference{Name: "hardened-ssh"}
+	g.Expect(buildSpecHash(build)).To(Equal(hash))
+
+	build.Spec.Provisioners[0].Run = ptr.To("apt-get upgrade -y")
+	g.Expect(buildSpecHash(build)).NotTo(Equal(hash))
+}
+
+func newImageArtifact(name string, age time.Duration, retention *buildv1.RetentionPolicy) *buildv1.ImageArtifact {
+	return &buildv1.ImageArtifact{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         "default",
+			CreationTimestamp: metav1.NewTime(time.Now().Add(-age)),
+		},
+		Spec: buildv1.ImageArtifactSpec{
+			BuildName: "ubuntu",
+			Provider:  "static",
+			ImageID:   "pvc://images/" + name + ".tar.gz",
+			Retention: retention,
+		},
+	}
+}
+
+func newImageArtifactReconciler(g *WithT, objs ...client.Object) *ImageArtifactReconciler {
+	scheme := runtime.NewScheme()
+	g.Expect(buildv1.AddToScheme(scheme)).To(Succeed())
+	return &ImageArtifactReconciler{
+		Client:   fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).WithStatusSubresource(&buildv1.ImageArtifact{}).Build(),
+		recorder: record.NewFakeRecorder(32),
+	}
+}
+
+func reconcileImageArtifact(g *WithT, r *ImageArtifactReconciler, name string) (ctrl.Result, *buildv1.ImageArtifact) {
+	ctx := context.Background()
+	res, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKey{Namespace: "default", Name: name}})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	artifact := &buildv1.ImageArtifact{}
+	if err := r.Client.Get(ctx, client.ObjectKey{Namespace: "default", Name: name}, artifact); err != nil {
+		g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+		return res, nil
+	}
+	return res, artifact
+}
+
+func TestImageArtifactKeepLast(t *testing.T) {
+	g := NewWithT(t)
+
+	keepLast := &buildv1.RetentionPolicy{KeepLast: ptr.To[int32](2)}
+	other := newImageArtifact("debian", 4*time.Hour, keepLast)
+	other.Spec.BuildName = "debian"
+	r := newImageArtifactReconciler(g,
+		newImageArtifact("ubuntu-1", 3*time.Hour, keepLast),
+		newImageArtifact("ubuntu-2", 2*time.Hour, keepLast),
+		newImageArtifact("ubuntu-3", time.Hour, keepLast),
+		other,
+	)
+
+	_, artifact := reconcileImageArtifact(g, r, "ubuntu-3")
+	g.Expect(artifact).NotTo(BeNil())
+	g.Expect(artifact.Labels).To(HaveKeyWithValue(buildv1.BuildNameLabel, "ubuntu"))
+	g.Expect(artifact.Labels).To(HaveKeyWithValue(buildv1.DeprecatedLabel, "false"))
+	g.Expect(artifact.Status.ExpirationTime).To(BeNil())
+
+	_, artifact = reconcileImageArtifact(g, r, "ubuntu-2")
+	g.Expect(artifact).NotTo(BeNil())
+	_, artifact = reconcileImageArtifact(g, r, "ubuntu-1")
+	g.Expect(artifact).To(BeNil())
+	_, artifact = reconcileImageArtifact(g, r, "debian")
+	g.Expect(artifact).NotTo(BeNil())
+
+	// The new image of a Build triggers the retention of the older ones.
+	g.Expect(r.imageArtifactToSiblings(context.Background(), newImageArtifact("ubuntu-4", 0, nil))).To(HaveLen(2))
+}
+
+func TestImageArtifactMaxAge(t *testing.T) {
+	g := NewWithT(t)
+
+	maxAge := &buildv1.RetentionPolicy{MaxAge: &metav1.Duration{Duration: 2 * time.Hour}}
+	deprecated := newImageArtifact("ubuntu-2", time.Hour, maxAge)
+	deprecated.Spec.Deprecated = true
+	r := newImageArtifactReconciler(g, newImageArtifact("ubuntu-1", 3*time.Hour, maxAge), deprecated)
+
+	_, artifact := reconcileImageArtifact(g, r, "ubuntu-1")
+	g.Expect(artifact).To(BeNil())
+
+	res, artifact := reconcileImageArtifact(g, r, "ubuntu-2")
+	g.Expect(artifact).NotTo(BeNil())
+	g.Expect(artifact.Labels).To(HaveKeyWithValue(buildv1.DeprecatedLabel, "true"))
+	g.Expect(artifact.Status.ExpirationTime).NotTo(BeNil())
+	g.Expect(res.RequeueAfter).To(BeNumerically("~", time.Hour, time.Minute))
+}
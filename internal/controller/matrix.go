@@ -0,0 +1,364 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/utils/ptr"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	buildv1 "github.com/forge-build/forge/api/v1alpha1"
+	"github.com/forge-build/forge/internal/external"
+	forgeerrors "github.com/forge-build/forge/pkg/errors"
+	"github.com/forge-build/forge/pkg/kube"
+)
+
+// reconcileMatrix reconciles a matrix Build: it creates the Build of every variant
+// and aggregates their status.
+func (r *BuildReconciler) reconcileMatrix(ctx context.Context, build *buildv1.Build) (ctrl.Result, error) {
+	if err := r.preflightExternal(ctx, build, variantInfrastructureRef(build, build.Spec.InfrastructureRef.Name)); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	var errs []error
+	var variants []buildv1.MatrixVariantStatus
+	for _, variant := range build.Spec.Matrix.Variants() {
+		child, err := r.reconcileVariant(ctx, build, variant)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		variants = append(variants, buildv1.MatrixVariantStatus{
+			Name:           variant.Name(build.Spec.Matrix),
+			Values:         variant,
+			BuildName:      child.Name,
+			Phase:          child.Status.Phase,
+			FailureMessage: child.Status.FailureMessage,
+		})
+	}
+	if len(errs) > 0 {
+		return ctrl.Result{}, kerrors.NewAggregate(errs)
+	}
+
+	build.Status.Variants = variants
+	setMatrixStatus(build)
+	return ctrl.Result{}, nil
+}
+
+// reconcileVariant creates the Build of a variant and its InfraBuild, copied from the ones of the matrix Build.
+func (r *BuildReconciler) reconcileVariant(ctx context.Context, build *buildv1.Build, variant buildv1.MatrixVariant) (*buildv1.Build, error) {
+	log := ctrl.LoggerFrom(ctx)
+
+	child := &buildv1.Build{}
+	key := client.ObjectKey{Namespace: build.Namespace, Name: variantBuildName(build, variant)}
+	if err := r.Client.Get(ctx, key, child); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return nil, errors.Wrapf(err, "failed to get the Build %s", key.Name)
+		}
+		child, err = newVariantBuild(build, variant, r.Client.Scheme())
+		if err != nil {
+			return nil, err
+		}
+		log.Info("Creating the Build of a variant", "variant", variant.Name(build.Spec.Matrix), "build", child.Name)
+		if err := r.Client.Create(ctx, child); err != nil {
+			if apierrors.IsAlreadyExists(err) {
+				return child, nil
+			}
+			return nil, errors.Wrapf(err, "failed to create the Build %s", child.Name)
+		}
+		r.recorder.Eventf(build, corev1.EventTypeNormal, "VariantCreated", "Created Build %s for variant %s", child.Name, variant.Name(build.Spec.Matrix))
+	}
+
+	// The InfraBuild is created after the Build owning it, it is found once the Build reconciled it.
+	if conditions.Has(child, buildv1.InfrastructureReadyCondition) {
+		return child, nil
+	}
+	if err := r.ensureVariantInfraBuild(ctx, build, child, variant); err != nil {
+		return nil, err
+	}
+	return child, nil
+}
+
+// ensureVariantInfraBuild creates the InfraBuild of a variant from the one referenced by the matrix Build.
+func (r *BuildReconciler) ensureVariantInfraBuild(ctx context.Context, build, child *buildv1.Build, variant buildv1.MatrixVariant) error {
+	ref := child.Spec.InfrastructureRef
+	if _, err := external.Get(ctx, r.Client, ref, child.Namespace); err == nil {
+		return nil
+	} else if !apierrors.IsNotFound(errors.Cause(err)) {
+		return err
+	}
+
+	template, err := external.Get(ctx, r.Client, build.Spec.InfrastructureRef, build.Namespace)
+	if err != nil {
+		return err
+	}
+
+	var obj *unstructured.Unstructured
+	if strings.HasSuffix(template.GetKind(), buildv1.TemplateSuffix) {
+		obj, err = external.GenerateTemplate(&external.GenerateTemplateInput{
+			Template:    template,
+			TemplateRef: build.Spec.InfrastructureRef,
+			Namespace:   child.Namespace,
+			Name:        ref.Name,
+			ClusterName: child.Name,
+		})
+		if err != nil {
+			return err
+		}
+	} else {
+		obj = &unstructured.Unstructured{Object: map[string]interface{}{}}
+		obj.SetAPIVersion(template.GetAPIVersion())
+		obj.SetKind(template.GetKind())
+		obj.SetName(ref.Name)
+		obj.SetNamespace(child.Namespace)
+		labels := template.GetLabels()
+		if labels == nil {
+			labels = make(map[string]string)
+		}
+		labels[buildv1.BuildNameLabel] = child.Name
+		obj.SetLabels(labels)
+		if spec, ok := template.Object["spec"]; ok {
+			obj.Object["spec"] = runtime.DeepCopyJSONValue(spec)
+		}
+	}
+	if spec, ok := obj.Object["spec"].(map[string]interface{}); ok {
+		obj.Object["spec"] = substituteMatrix(spec, variant)
+	}
+	if err := controllerutil.SetControllerReference(child, obj, r.Client.Scheme()); err != nil {
+		return err
+	}
+
+	if err := r.Client.Create(ctx, obj); err != nil && !apierrors.IsAlreadyExists(err) {
+		return errors.Wrapf(err, "failed to create %v %q", obj.GroupVersionKind(), obj.GetName())
+	}
+	return nil
+}
+
+// setMatrixStatus sets the conditions, the readiness and the failure of a matrix Build from the status of its variants.
+func setMatrixStatus(build *buildv1.Build) {
+	matrix := build.Spec.Matrix
+	total := len(build.Status.Variants)
+	required := matrix.RequiredSucceeded()
+
+	var succeeded int
+	var failed []string
+	for _, v := range build.Status.Variants {
+		switch buildv1.BuildPhase(v.Phase) {
+		case buildv1.BuildPhaseCompleted:
+			succeeded++
+		case buildv1.BuildPhaseFailed:
+			failed = append(failed, fmt.Sprintf("%s: %s", v.Name, ptr.Deref(v.FailureMessage, "unknown")))
+		}
+	}
+
+	quorumLost := len(failed) > total-required
+	build.Status.Ready = !quorumLost && succeeded+len(failed) == total
+	switch {
+	case quorumLost:
+		conditions.MarkFalse(build, buildv1.VariantsReadyCondition, buildv1.VariantsFailedReason, buildv1.ConditionSeverityError,
+			"%d of %d variants failed, %d must succeed", len(failed), total, required)
+		build.Status.FailureReason = ptr.To(forgeerrors.VariantsFailedError)
+		build.Status.FailureMessage = ptr.To(fmt.Sprintf("%d of %d variants failed: %s", len(failed), total, strings.Join(failed, "; ")))
+	case build.Status.Ready:
+		conditions.MarkTrue(build, buildv1.VariantsReadyCondition)
+	default:
+		conditions.MarkFalse(build, buildv1.VariantsReadyCondition, buildv1.WaitingForVariantsReason, buildv1.ConditionSeverityInfo,
+			"%d of %d variants succeeded, %d must succeed", succeeded, total, required)
+	}
+	// The variants may have been retried since the quorum was lost.
+	if !quorumLost && ptr.Deref(build.Status.FailureReason, "") == forgeerrors.VariantsFailedError {
+		build.Status.FailureReason = nil
+		build.Status.FailureMessage = nil
+	}
+
+	for _, axis := range matrix.Axes {
+		setMatrixAxisCondition(build, axis, quorumLost)
+	}
+}
+
+// setMatrixAxisCondition reports the variants of each value of an axis.
+func setMatrixAxisCondition(build *buildv1.Build, axis buildv1.MatrixAxis, quorumLost bool) {
+	var failed, waiting []string
+	for _, value := range axis.Values {
+		var total, succeeded, failures int
+		for _, v := range build.Status.Variants {
+			if v.Values[axis.Name] != value {
+				continue
+			}
+			total++
+			switch buildv1.BuildPhase(v.Phase) {
+			case buildv1.BuildPhaseCompleted:
+				succeeded++
+			case buildv1.BuildPhaseFailed:
+				failures++
+			}
+		}
+		if failures > 0 {
+			failed = append(failed, fmt.Sprintf("%s: %d of %d variants failed", value, failures, total))
+		} else if succeeded < total {
+			waiting = append(waiting, fmt.Sprintf("%s: %d of %d variants succeeded", value, succeeded, total))
+		}
+	}
+
+	condition := buildv1.MatrixAxisReadyCondition(axis.Name)
+	switch {
+	case len(failed) > 0:
+		// Failed variants only fail the Build once the quorum cannot be reached anymore.
+		severity := buildv1.ConditionSeverityWarning
+		if quorumLost {
+			severity = buildv1.ConditionSeverityError
+		}
+		conditions.MarkFalse(build, condition, buildv1.VariantsFailedReason, severity, "%s", strings.Join(failed, ", "))
+	case len(waiting) > 0:
+		conditions.MarkFalse(build, condition, buildv1.WaitingForVariantsReason, buildv1.ConditionSeverityInfo, "%s", strings.Join(waiting, ", "))
+	default:
+		conditions.MarkTrue(build, condition)
+	}
+}
+
+// annotateVariants sets an annotation on the Builds of the variants of a matrix Build,
+// e.g. to cancel or retry them along with the matrix Build.
+func (r *BuildReconciler) annotateVariants(ctx context.Context, build *buildv1.Build, annotation string) error {
+	children := &buildv1.BuildList{}
+	if err := r.Client.List(ctx, children, client.InNamespace(build.Namespace),
+		client.MatchingLabels{buildv1.MatrixParentLabel: build.Name}); err != nil {
+		return errors.Wrap(err, "failed to list the Builds of the variants")
+	}
+	for i := range children.Items {
+		child := &children.Items[i]
+		if _, ok := child.GetAnnotations()[annotation]; ok {
+			continue
+		}
+		patch := client.MergeFrom(child.DeepCopy())
+		annotations := child.GetAnnotations()
+		if annotations == nil {
+			annotations = make(map[string]string)
+		}
+		annotations[annotation] = ""
+		child.SetAnnotations(annotations)
+		if err := r.Client.Patch(ctx, child, patch); err != nil {
+			return errors.Wrapf(err, "failed to annotate the Build %s", child.Name)
+		}
+	}
+	return nil
+}
+
+// matrixConditions returns the conditions owned by the Build controller on a matrix Build.
+func matrixConditions(build *buildv1.Build) []clusterv1.ConditionType {
+	if build.Spec.Matrix == nil {
+		return nil
+	}
+	types := []clusterv1.ConditionType{buildv1.VariantsReadyCondition}
+	for _, axis := range build.Spec.Matrix.Axes {
+		types = append(types, buildv1.MatrixAxisReadyCondition(axis.Name))
+	}
+	return types
+}
+
+// variantBuildName returns the name of the Build of a variant.
+func variantBuildName(build *buildv1.Build, variant buildv1.MatrixVariant) string {
+	return fmt.Sprintf("%s-%s", build.Name, kube.ComputeHash(variant.Name(build.Spec.Matrix)))
+}
+
+// variantInfrastructureRef returns the reference to the InfraBuild of a variant, the InfraBuild referenced
+// by the matrix Build can be a template of the kind of the InfraBuilds of the variants, e.g. StaticMachineTemplate.
+func variantInfrastructureRef(build *buildv1.Build, name string) *corev1.ObjectReference {
+	return &corev1.ObjectReference{
+		APIVersion: build.Spec.InfrastructureRef.APIVersion,
+		Kind:       strings.TrimSuffix(build.Spec.InfrastructureRef.Kind, buildv1.TemplateSuffix),
+		Name:       name,
+	}
+}
+
+// newVariantBuild returns the Build of a variant, a copy of the matrix Build referencing its own InfraBuild.
+func newVariantBuild(build *buildv1.Build, variant buildv1.MatrixVariant, scheme *runtime.Scheme) (*buildv1.Build, error) {
+	name := variantBuildName(build, variant)
+
+	spec := build.Spec.DeepCopy()
+	spec.Matrix = nil
+	spec.InfrastructureRef = variantInfrastructureRef(build, name)
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(spec)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to convert the Build spec")
+	}
+	spec = &buildv1.BuildSpec{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(substituteMatrix(content, variant), spec); err != nil {
+		return nil, errors.Wrap(err, "failed to convert the Build spec")
+	}
+
+	labels := map[string]string{buildv1.MatrixParentLabel: build.Name}
+	if value, ok := build.GetLabels()[buildv1.WatchLabel]; ok {
+		labels[buildv1.WatchLabel] = value
+	}
+	child := &buildv1.Build{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   build.Namespace,
+			Labels:      labels,
+			Annotations: map[string]string{buildv1.MatrixVariantAnnotation: variant.Name(build.Spec.Matrix)},
+		},
+		Spec: *spec,
+	}
+	if err := controllerutil.SetControllerReference(build, child, scheme); err != nil {
+		return nil, err
+	}
+	return child, nil
+}
+
+// substituteMatrix replaces the ${matrix.<axis>} placeholders found in the strings of an unstructured content.
+func substituteMatrix(content map[string]interface{}, variant buildv1.MatrixVariant) map[string]interface{} {
+	oldnew := make([]string, 0, 2*len(variant))
+	for axis, value := range variant {
+		oldnew = append(oldnew, fmt.Sprintf("${matrix.%s}", axis), value)
+	}
+	return substitute(content, strings.NewReplacer(oldnew...)).(map[string]interface{})
+}
+
+func substitute(value interface{}, replacer *strings.Replacer) interface{} {
+	switch v := value.(type) {
+	case string:
+		return replacer.Replace(v)
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, val := range v {
+			out[key] = substitute(val, replacer)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, val := range v {
+			out[i] = substitute(val, replacer)
+		}
+		return out
+	default:
+		return v
+	}
+}
@@ -0,0 +1,194 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	buildv1 "github.com/forge-build/forge/api/v1alpha1"
+	forgeerrors "github.com/forge-build/forge/pkg/errors"
+)
+
+func newMatrixBuild() *buildv1.Build {
+	return &buildv1.Build{
+		ObjectMeta: metav1.ObjectMeta{Name: "ubuntu", Namespace: "default", UID: "1234"},
+		Spec: buildv1.BuildSpec{
+			Connector: buildv1.ConnectorSpec{Type: "ssh"},
+			InfrastructureRef: &corev1.ObjectReference{
+				APIVersion: "infrastructure.forge.build/v1alpha1",
+				Kind:       "StaticMachine",
+				Name:       "ubuntu",
+			},
+			Provisioners: []buildv1.ProvisionerSpec{
+				{Type: buildv1.ProvisionerTypeShell, Run: ptr.To("echo ${matrix.arch} ${matrix.region} ${matrix.os}")},
+			},
+			Matrix: &buildv1.MatrixSpec{
+				Axes: []buildv1.MatrixAxis{
+					{Name: "arch", Values: []string{"amd64", "arm64"}},
+					{Name: "region", Values: []string{"eu-west-1", "us-east-1"}},
+				},
+			},
+		},
+	}
+}
+
+func TestMatrixVariants(t *testing.T) {
+	g := NewWithT(t)
+
+	matrix := newMatrixBuild().Spec.Matrix
+	var names []string
+	for _, v := range matrix.Variants() {
+		names = append(names, v.Name(matrix))
+	}
+	g.Expect(names).To(Equal([]string{
+		"arch=amd64,region=eu-west-1",
+		"arch=amd64,region=us-east-1",
+		"arch=arm64,region=eu-west-1",
+		"arch=arm64,region=us-east-1",
+	}))
+	g.Expect(matrix.RequiredSucceeded()).To(Equal(4))
+
+	matrix.Quorum = ptr.To[int32](3)
+	g.Expect(matrix.RequiredSucceeded()).To(Equal(3))
+}
+
+func TestNewVariantBuild(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(buildv1.AddToScheme(scheme)).To(Succeed())
+
+	build := newMatrixBuild()
+	variant := buildv1.MatrixVariant{"arch": "arm64", "region": "eu-west-1"}
+	child, err := newVariantBuild(build, variant, scheme)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	g.Expect(child.Name).To(Equal(variantBuildName(build, variant)))
+	g.Expect(child.Labels).To(HaveKeyWithValue(buildv1.MatrixParentLabel, "ubuntu"))
+	g.Expect(child.Annotations).To(HaveKeyWithValue(buildv1.MatrixVariantAnnotation, "arch=arm64,region=eu-west-1"))
+	g.Expect(child.OwnerReferences).To(HaveLen(1))
+	g.Expect(child.OwnerReferences[0].Name).To(Equal("ubuntu"))
+	g.Expect(child.Spec.Matrix).To(BeNil())
+	g.Expect(child.Spec.InfrastructureRef.Kind).To(Equal("StaticMachine"))
+	g.Expect(child.Spec.InfrastructureRef.Name).To(Equal(child.Name))
+	// Placeholders of unknown axes are left as they are.
+	g.Expect(child.Spec.Provisioners[0].Run).To(HaveValue(Equal("echo arm64 eu-west-1 ${matrix.os}")))
+
+	build.Spec.InfrastructureRef.Kind = "StaticMachineTemplate"
+	child, err = newVariantBuild(build, variant, scheme)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(child.Spec.InfrastructureRef.Kind).To(Equal("StaticMachine"))
+}
+
+func TestSetMatrixStatus(t *testing.T) {
+	variants := func(phases ...buildv1.BuildPhase) []buildv1.MatrixVariantStatus {
+		build := newMatrixBuild()
+		var statuses []buildv1.MatrixVariantStatus
+		for i, v := range build.Spec.Matrix.Variants() {
+			statuses = append(statuses, buildv1.MatrixVariantStatus{
+				Name:           v.Name(build.Spec.Matrix),
+				Values:         v,
+				Phase:          string(phases[i]),
+				FailureMessage: ptr.To("boom"),
+			})
+		}
+		return statuses
+	}
+	completed, failed, building := buildv1.BuildPhaseCompleted, buildv1.BuildPhaseFailed, buildv1.BuildPhaseBuilding
+
+	tests := []struct {
+		name        string
+		quorum      *int32
+		phases      []buildv1.BuildPhase
+		wantReady   bool
+		wantFailed  bool
+		wantReason  string
+		wantArch    string
+		wantArchMsg string
+	}{
+		{
+			name:      "all variants succeeded",
+			phases:    []buildv1.BuildPhase{completed, completed, completed, completed},
+			wantReady: true,
+		},
+		{
+			name:        "variants running",
+			phases:      []buildv1.BuildPhase{completed, building, completed, completed},
+			wantReason:  buildv1.WaitingForVariantsReason,
+			wantArch:    buildv1.WaitingForVariantsReason,
+			wantArchMsg: "amd64: 1 of 2 variants succeeded",
+		},
+		{
+			name:        "a variant failed",
+			phases:      []buildv1.BuildPhase{completed, building, failed, completed},
+			wantFailed:  true,
+			wantReason:  buildv1.VariantsFailedReason,
+			wantArch:    buildv1.VariantsFailedReason,
+			wantArchMsg: "arm64: 1 of 2 variants failed",
+		},
+		{
+			name:        "a variant failed within the quorum",
+			quorum:      ptr.To[int32](3),
+			phases:      []buildv1.BuildPhase{completed, completed, failed, completed},
+			wantReady:   true,
+			wantArch:    buildv1.VariantsFailedReason,
+			wantArchMsg: "arm64: 1 of 2 variants failed",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			build := newMatrixBuild()
+			build.Spec.Matrix.Quorum = tt.quorum
+			build.Status.Variants = variants(tt.phases...)
+			setMatrixStatus(build)
+
+			g.Expect(build.Status.Ready).To(Equal(tt.wantReady))
+			g.Expect(conditions.IsTrue(build, buildv1.VariantsReadyCondition)).To(Equal(tt.wantReady))
+			g.Expect(conditions.GetReason(build, buildv1.VariantsReadyCondition)).To(Equal(tt.wantReason))
+			if tt.wantFailed {
+				g.Expect(build.Status.FailureReason).To(HaveValue(Equal(forgeerrors.VariantsFailedError)))
+				g.Expect(build.Status.FailureMessage).To(HaveValue(ContainSubstring("arch=arm64,region=eu-west-1: boom")))
+			} else {
+				g.Expect(build.Status.FailureReason).To(BeNil())
+			}
+
+			arch := buildv1.MatrixAxisReadyCondition("arch")
+			g.Expect(arch).To(BeEquivalentTo("MatrixArchReady"))
+			g.Expect(conditions.GetReason(build, arch)).To(Equal(tt.wantArch))
+			g.Expect(conditions.GetMessage(build, arch)).To(Equal(tt.wantArchMsg))
+		})
+	}
+
+	// The failure is cleared once the failed variants are retried.
+	g := NewWithT(t)
+	build := newMatrixBuild()
+	build.Status.Variants = variants(completed, completed, failed, completed)
+	setMatrixStatus(build)
+	g.Expect(build.Status.FailureReason).NotTo(BeNil())
+	build.Status.Variants = variants(completed, completed, building, completed)
+	setMatrixStatus(build)
+	g.Expect(build.Status.FailureReason).To(BeNil())
+}
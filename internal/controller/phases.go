@@ -25,6 +25,14 @@ func (r *BuildReconciler) reconcilePhase(_ context.Context, build *buildv1.Build
 		build.Status.SetTypedPhase(buildv1.BuildPhaseBuilding)
 	}
 
+	if build.Spec.Matrix != nil && len(build.Status.Variants) > 0 {
+		build.Status.SetTypedPhase(buildv1.BuildPhaseBuilding)
+	}
+
+	if build.Status.Ready {
+		build.Status.SetTypedPhase(buildv1.BuildPhaseCompleted)
+	}
+
 	if build.Status.FailureReason != nil || build.Status.FailureMessage != nil {
 		build.Status.SetTypedPhase(buildv1.BuildPhaseFailed)
 	}
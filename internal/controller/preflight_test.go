@@ -0,0 +1,79 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	buildv1 "github.com/forge-build/forge/api/v1alpha1"
+	forgeerrors "github.com/forge-build/forge/pkg/errors"
+)
+
+func TestPreflightExternal(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	invalid := fakeInfraBuildCRD()
+	invalid.Name = "otherinfrabuilds.infrastructure.forge.build"
+	invalid.Spec.Names.Kind = "OtherInfraBuild"
+	invalid.Labels = nil
+
+	scheme := runtime.NewScheme()
+	g.Expect(buildv1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(apiextensionsv1.AddToScheme(scheme)).To(Succeed())
+	crdReads := 0
+	r := &BuildReconciler{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(fakeInfraBuildCRD(), invalid).
+			WithInterceptorFuncs(interceptor.Funcs{
+				Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+					if _, ok := obj.(*apiextensionsv1.CustomResourceDefinition); ok {
+						crdReads++
+					}
+					return c.Get(ctx, key, obj, opts...)
+				},
+			}).Build(),
+		recorder: record.NewFakeRecorder(32),
+	}
+
+	// The CRD implementing the contract is read once.
+	ref := &corev1.ObjectReference{APIVersion: fakeInfraBuildGVK.GroupVersion().String(), Kind: fakeInfraBuildGVK.Kind, Name: "ubuntu"}
+	for i := 0; i < 3; i++ {
+		build := &buildv1.Build{ObjectMeta: metav1.ObjectMeta{Name: "ubuntu", Namespace: "default"}}
+		g.Expect(r.preflightExternal(ctx, build, ref)).To(Succeed())
+	}
+	g.Expect(crdReads).To(Equal(1))
+
+	// The CRDs not implementing it are read again, they may be fixed.
+	ref = &corev1.ObjectReference{APIVersion: fakeInfraBuildGVK.GroupVersion().String(), Kind: "OtherInfraBuild", Name: "ubuntu"}
+	for i := 0; i < 2; i++ {
+		build := &buildv1.Build{ObjectMeta: metav1.ObjectMeta{Name: "ubuntu", Namespace: "default"}}
+		g.Expect(r.preflightExternal(ctx, build, ref)).NotTo(Succeed())
+		g.Expect(build.Status.FailureReason).To(HaveValue(Equal(forgeerrors.InvalidConfigurationBuildError)))
+	}
+	g.Expect(crdReads).To(Equal(3))
+}
@@ -0,0 +1,85 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	buildv1 "github.com/forge-build/forge/api/v1alpha1"
+	"github.com/forge-build/forge/pkg/tracing"
+)
+
+// buildTraceContext returns a context continuing the trace of the Build lifecycle,
+// the trace is started on the first reconciliation of the Build.
+func buildTraceContext(ctx context.Context, build *buildv1.Build) context.Context {
+	traceParent, ok := build.GetAnnotations()[buildv1.TraceParentAnnotation]
+	if !ok {
+		traceParent = tracing.NewTraceParent()
+		setAnnotation(build, buildv1.TraceParentAnnotation, traceParent)
+	}
+	return tracing.ContextWithTraceParent(ctx, traceParent)
+}
+
+// endBuildTrace records the span of the Build lifecycle, from the creation of the Build until it
+// completed or failed. The spans of its reconciliations already reference it as their parent.
+func endBuildTrace(ctx context.Context, build *buildv1.Build) {
+	traceParent, ok := build.GetAnnotations()[buildv1.TraceParentAnnotation]
+	if !ok {
+		return
+	}
+	sc := trace.SpanContextFromContext(tracing.ContextWithTraceParent(context.Background(), traceParent))
+	if !sc.IsValid() {
+		return
+	}
+
+	_, span := tracing.Tracer().Start(tracing.WithIDs(ctx, sc), "Build",
+		trace.WithNewRoot(),
+		trace.WithTimestamp(build.CreationTimestamp.Time),
+		trace.WithAttributes(buildAttributes(build)...),
+		trace.WithAttributes(attribute.String("forge.build.phase", build.Status.Phase)),
+	)
+	if build.Status.Artifact != "" {
+		span.SetAttributes(attribute.String("forge.build.artifact", build.Status.Artifact))
+	}
+	if build.Status.GetTypedPhase() == buildv1.BuildPhaseFailed {
+		span.SetStatus(codes.Error, ptr.Deref(build.Status.FailureMessage, ""))
+	}
+	span.End()
+}
+
+// tracePhase returns a reconciliation phase running in its own span.
+func tracePhase(name string, phase func(context.Context, *buildv1.Build) (ctrl.Result, error)) func(context.Context, *buildv1.Build) (ctrl.Result, error) {
+	return func(ctx context.Context, build *buildv1.Build) (ctrl.Result, error) {
+		ctx, span := tracing.Tracer().Start(ctx, name)
+		res, err := phase(ctx, build)
+		tracing.End(span, err)
+		return res, err
+	}
+}
+
+func buildAttributes(build *buildv1.Build) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("forge.build.namespace", build.Namespace),
+		attribute.String("forge.build.name", build.Name),
+	}
+}
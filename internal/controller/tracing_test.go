@@ -0,0 +1,82 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	buildv1 "github.com/forge-build/forge/api/v1alpha1"
+	"github.com/forge-build/forge/pkg/tracing"
+)
+
+func TestBuildTrace(t *testing.T) {
+	g := NewWithT(t)
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder), sdktrace.WithIDGenerator(tracing.IDGenerator{})))
+	defer otel.SetTracerProvider(provider)
+
+	created := time.Now().Add(-time.Hour).Truncate(time.Second)
+	build := &buildv1.Build{
+		ObjectMeta: metav1.ObjectMeta{Name: "ubuntu", Namespace: "default", CreationTimestamp: metav1.NewTime(created)},
+	}
+
+	// The trace of the Build lifecycle is kept across reconciliations.
+	ctx := buildTraceContext(context.Background(), build)
+	g.Expect(build.Annotations).To(HaveKey(buildv1.TraceParentAnnotation))
+	g.Expect(tracing.TraceParent(buildTraceContext(context.Background(), build))).To(Equal(tracing.TraceParent(ctx)))
+
+	phase := tracePhase("Provisioners", func(ctx context.Context, _ *buildv1.Build) (ctrl.Result, error) {
+		g.Expect(tracing.TraceParent(ctx)).NotTo(BeEmpty())
+		return ctrl.Result{}, nil
+	})
+	_, err := phase(ctx, build)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	build.Status.SetTypedPhase(buildv1.BuildPhaseFailed)
+	build.Status.FailureMessage = ptr.To("exit status 1")
+	endBuildTrace(ctx, build)
+
+	spans := recorder.Ended()
+	g.Expect(spans).To(HaveLen(2))
+	lifecycle := trace.SpanContextFromContext(ctx)
+	g.Expect(spans[0].Name()).To(Equal("Provisioners"))
+	g.Expect(spans[0].Parent().SpanID()).To(Equal(lifecycle.SpanID()))
+	g.Expect(spans[1].Name()).To(Equal("Build"))
+	g.Expect(spans[1].SpanContext().TraceID()).To(Equal(lifecycle.TraceID()))
+	g.Expect(spans[1].SpanContext().SpanID()).To(Equal(lifecycle.SpanID()))
+	g.Expect(spans[1].StartTime()).To(Equal(created))
+	g.Expect(spans[1].Status()).To(Equal(sdktrace.Status{Code: codes.Error, Description: "exit status 1"}))
+
+	// A retried Build starts a new trace.
+	r := newTestReconciler(g, build)
+	r.reconcileRetry(context.Background(), build)
+	g.Expect(build.Annotations).NotTo(HaveKey(buildv1.TraceParentAnnotation))
+}
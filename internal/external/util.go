@@ -28,6 +28,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	buildv1 "github.com/forge-build/forge/api/v1alpha1"
+	"github.com/forge-build/forge/pkg/contract/infra"
 )
 
 // Get uses the client and reference to get an external, unstructured object.
@@ -224,12 +225,12 @@ func GetObjectReference(obj *unstructured.Unstructured) *corev1.ObjectReference
 
 // FailuresFrom returns the FailureReason and FailureMessage fields from the external object status.
 func FailuresFrom(obj *unstructured.Unstructured) (string, string, error) {
-	failureReason, _, err := unstructured.NestedString(obj.Object, "status", "failureReason")
+	failureReason, _, err := unstructured.NestedString(obj.Object, infra.FailureReasonPath...)
 	if err != nil {
 		return "", "", errors.Wrapf(err, "failed to determine failureReason on %v %q",
 			obj.GroupVersionKind(), obj.GetName())
 	}
-	failureMessage, _, err := unstructured.NestedString(obj.Object, "status", "failureMessage")
+	failureMessage, _, err := unstructured.NestedString(obj.Object, infra.FailureMessagePath...)
 	if err != nil {
 		return "", "", errors.Wrapf(err, "failed to determine failureMessage on %v %q",
 			obj.GroupVersionKind(), obj.GetName())
@@ -237,9 +238,19 @@ func FailuresFrom(obj *unstructured.Unstructured) (string, string, error) {
 	return failureReason, failureMessage, nil
 }
 
+// ArtifactFrom returns the Status.Artifact field from the external object status.
+func ArtifactFrom(obj *unstructured.Unstructured) (string, error) {
+	artifact, _, err := unstructured.NestedString(obj.Object, infra.ArtifactPath...)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to determine artifact on %v %q",
+			obj.GroupVersionKind(), obj.GetName())
+	}
+	return artifact, nil
+}
+
 // IsReady returns true if the Status.Ready field on an external object is true.
 func IsReady(obj *unstructured.Unstructured) (bool, error) {
-	ready, found, err := unstructured.NestedBool(obj.Object, "status", "ready")
+	ready, found, err := unstructured.NestedBool(obj.Object, infra.ReadyPath...)
 	if err != nil {
 		return false, errors.Wrapf(err, "failed to determine %v %q readiness",
 			obj.GroupVersionKind(), obj.GetName())
@@ -247,9 +258,19 @@ func IsReady(obj *unstructured.Unstructured) (bool, error) {
 	return ready && found, nil
 }
 
+// IsImageDeleted returns true if the Status.ImageDeleted field on an external object is true.
+func IsImageDeleted(obj *unstructured.Unstructured) (bool, error) {
+	deleted, found, err := unstructured.NestedBool(obj.Object, infra.ImageDeletedPath...)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to determine if %v %q deleted its image",
+			obj.GroupVersionKind(), obj.GetName())
+	}
+	return deleted && found, nil
+}
+
 // IsMachineReady returns true if the Status.MachineReady field on an external object is true.
 func IsMachineReady(obj *unstructured.Unstructured) (bool, error) {
-	ready, found, err := unstructured.NestedBool(obj.Object, "status", "machineReady")
+	ready, found, err := unstructured.NestedBool(obj.Object, infra.MachineReadyPath...)
 	if err != nil {
 		return false, errors.Wrapf(err, "failed to determine %v %q readiness",
 			obj.GroupVersionKind(), obj.GetName())
@@ -0,0 +1,89 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notification
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+// internalDomains are the suffixes of the names resolved inside the cluster or the local network.
+var internalDomains = []string{".local", ".localhost", ".internal", ".svc"}
+
+// ValidateURL checks that the URL of an endpoint is an http or https URL of a public host, so the
+// Builds cannot use the manager to reach the services of the cluster or of its network.
+func ValidateURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("must be an http or https URL")
+	}
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if !isPublic(addr) {
+			return errors.Errorf("must not target the private address %s", addr)
+		}
+		return nil
+	}
+	if !strings.Contains(host, ".") || host == "localhost" {
+		return errors.Errorf("must not target the internal host %s", host)
+	}
+	for _, suffix := range internalDomains {
+		if strings.HasSuffix(host, suffix) {
+			return errors.Errorf("must not target the internal host %s", host)
+		}
+	}
+	return nil
+}
+
+// isPublic returns whether the address is routable on the internet.
+func isPublic(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsValid() && !addr.IsLoopback() && !addr.IsPrivate() && !addr.IsUnspecified() &&
+		!addr.IsLinkLocalUnicast() && !addr.IsLinkLocalMulticast() && !addr.IsInterfaceLocalMulticast() &&
+		!addr.IsMulticast() && !sharedAddressSpace.Contains(addr)
+}
+
+// sharedAddressSpace is the carrier-grade NAT range of RFC 6598, not covered by netip.Addr.IsPrivate.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// dialPublic refuses the connections to the addresses that are not public. It runs once the names are
+// resolved, so it also covers the public names resolving to private addresses and the redirects.
+func dialPublic(_, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return errors.Wrapf(err, "failed to parse the address %s", address)
+	}
+	if !isPublic(addrPort.Addr()) {
+		return errors.Errorf("refusing to connect to the private address %s", addrPort.Addr())
+	}
+	return nil
+}
+
+// newHTTPClient returns the client sending the events to the public endpoints only.
+func newHTTPClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// The addresses of the endpoints are checked when dialing, they would not be behind a proxy.
+	transport.Proxy = nil
+	transport.DialContext = (&net.Dialer{Timeout: sendTimeout, Control: dialPublic}).DialContext
+	return &http.Client{Timeout: sendTimeout, Transport: transport}
+}
@@ -0,0 +1,58 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notification
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestValidateURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		wantErr bool
+	}{
+		{name: "public host", url: "https://ci.example.com/hooks/forge"},
+		{name: "public address", url: "http://203.0.113.10:8080/hooks"},
+		{name: "no scheme", url: "ci.example.com/hooks", wantErr: true},
+		{name: "unsupported scheme", url: "ftp://ci.example.com/hooks", wantErr: true},
+		{name: "localhost", url: "http://localhost:8080", wantErr: true},
+		{name: "loopback address", url: "http://127.0.0.1:8080", wantErr: true},
+		{name: "IPv6 loopback address", url: "http://[::1]:8080", wantErr: true},
+		{name: "IPv4-mapped loopback address", url: "http://[::ffff:127.0.0.1]:8080", wantErr: true},
+		{name: "private address", url: "https://10.0.0.12/hooks", wantErr: true},
+		{name: "metadata service", url: "http://169.254.169.254/latest/meta-data", wantErr: true},
+		{name: "unspecified address", url: "http://0.0.0.0", wantErr: true},
+		{name: "single label host", url: "http://forge-webhook/hooks", wantErr: true},
+		{name: "Service of the cluster", url: "http://kubernetes.default.svc/api", wantErr: true},
+		{name: "fully qualified Service", url: "http://kubernetes.default.svc.cluster.local./api", wantErr: true},
+		{name: "internal domain", url: "https://ci.corp.internal/hooks", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			err := ValidateURL(tt.url)
+			if tt.wantErr {
+				g.Expect(err).To(HaveOccurred())
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package notification sends the lifecycle events of the Builds to the HTTP endpoints
+// listed in their spec, as CloudEvents in the structured JSON format.
+package notification
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"k8s.io/utils/ptr"
+
+	buildv1 "github.com/forge-build/forge/api/v1alpha1"
+)
+
+// CloudEvents attributes of the events.
+const (
+	SpecVersion = "1.0"
+	ContentType = "application/cloudevents+json"
+
+	PhaseChangedType      = "build.forge.build.phase.changed"
+	ProvisionerFailedType = "build.forge.build.provisioner.failed"
+	ArtifactExportedType  = "build.forge.build.artifact.exported"
+)
+
+// eventTypes are the CloudEvents types of the lifecycle events.
+var eventTypes = map[buildv1.NotificationEvent]string{
+	buildv1.NotificationPhaseChanged:      PhaseChangedType,
+	buildv1.NotificationProvisionerFailed: ProvisionerFailedType,
+	buildv1.NotificationArtifactExported:  ArtifactExportedType,
+}
+
+// CloudEvent is a lifecycle event of a Build in the CloudEvents structured JSON format.
+type CloudEvent struct {
+	SpecVersion     string    `json:"specversion"`
+	ID              string    `json:"id"`
+	Source          string    `json:"source"`
+	Type            string    `json:"type"`
+	Subject         string    `json:"subject"`
+	Time            time.Time `json:"time"`
+	DataContentType string    `json:"datacontenttype"`
+	Data            EventData `json:"data"`
+
+	// event is the lifecycle event, used to filter the endpoints.
+	event buildv1.NotificationEvent
+}
+
+// EventData is the payload of the events.
+type EventData struct {
+	Namespace      string           `json:"namespace"`
+	Name           string           `json:"name"`
+	Phase          string           `json:"phase"`
+	PreviousPhase  string           `json:"previousPhase,omitempty"`
+	FailureReason  string           `json:"failureReason,omitempty"`
+	FailureMessage string           `json:"failureMessage,omitempty"`
+	Artifact       string           `json:"artifact,omitempty"`
+	Provisioner    *ProvisionerData `json:"provisioner,omitempty"`
+}
+
+// ProvisionerData describes the failed provisioner of ProvisionerFailed events.
+type ProvisionerData struct {
+	Index          int    `json:"index"`
+	UUID           string `json:"uuid,omitempty"`
+	Type           string `json:"type"`
+	FailureReason  string `json:"failureReason,omitempty"`
+	FailureMessage string `json:"failureMessage,omitempty"`
+}
+
+// Events returns the lifecycle events of a Build between two of its states.
+func Events(before, after *buildv1.Build, now time.Time) []CloudEvent {
+	var events []CloudEvent
+	newEvent := func(event buildv1.NotificationEvent) CloudEvent {
+		return CloudEvent{
+			SpecVersion:     SpecVersion,
+			ID:              uuid.NewString(),
+			Source:          fmt.Sprintf("/apis/%s/namespaces/%s/builds/%s", buildv1.GroupVersion.String(), after.Namespace, after.Name),
+			Type:            eventTypes[event],
+			Subject:         after.Name,
+			Time:            now.UTC(),
+			DataContentType: "application/json",
+			Data: EventData{
+				Namespace:      after.Namespace,
+				Name:           after.Name,
+				Phase:          after.Status.Phase,
+				FailureReason:  string(ptr.Deref(after.Status.FailureReason, "")),
+				FailureMessage: ptr.Deref(after.Status.FailureMessage, ""),
+				Artifact:       after.Status.Artifact,
+			},
+			event: event,
+		}
+	}
+
+	for i, p := range after.Spec.Provisioners {
+		if ptr.Deref(p.Status, "") != buildv1.ProvisionerStatusFailed {
+			continue
+		}
+		if i < len(before.Spec.Provisioners) && ptr.Deref(before.Spec.Provisioners[i].Status, "") == buildv1.ProvisionerStatusFailed {
+			continue
+		}
+		e := newEvent(buildv1.NotificationProvisionerFailed)
+		e.Data.Provisioner = &ProvisionerData{
+			Index:          i,
+			UUID:           ptr.Deref(p.UUID, ""),
+			Type:           string(p.Type),
+			FailureReason:  ptr.Deref(p.FailureReason, ""),
+			FailureMessage: ptr.Deref(p.FailureMessage, ""),
+		}
+		events = append(events, e)
+	}
+
+	if after.Status.Artifact != "" && after.Status.Artifact != before.Status.Artifact {
+		events = append(events, newEvent(buildv1.NotificationArtifactExported))
+	}
+
+	if after.Status.Phase != before.Status.Phase {
+		e := newEvent(buildv1.NotificationPhaseChanged)
+		e.Data.PreviousPhase = before.Status.Phase
+		events = append(events, e)
+	}
+	return events
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notification
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+
+	buildv1 "github.com/forge-build/forge/api/v1alpha1"
+	forgeerrors "github.com/forge-build/forge/pkg/errors"
+)
+
+func testBuild() *buildv1.Build {
+	return &buildv1.Build{
+		ObjectMeta: metav1.ObjectMeta{Name: "ubuntu", Namespace: "default"},
+		Spec: buildv1.BuildSpec{
+			Provisioners: []buildv1.ProvisionerSpec{
+				{Type: buildv1.ProvisionerTypeShell, UUID: ptr.To("1234"), Status: ptr.To(buildv1.ProvisionerStatusRunning)},
+			},
+		},
+		Status: buildv1.BuildStatus{Phase: string(buildv1.BuildPhaseBuilding)},
+	}
+}
+
+func TestEvents(t *testing.T) {
+	g := NewWithT(t)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	before := testBuild()
+	g.Expect(Events(before, before.DeepCopy(), now)).To(BeEmpty())
+
+	// The provisioner failed and so did the Build.
+	after := before.DeepCopy()
+	after.Spec.Provisioners[0].Status = ptr.To(buildv1.ProvisionerStatusFailed)
+	after.Spec.Provisioners[0].FailureMessage = ptr.To("exit status 1")
+	after.Status.Phase = string(buildv1.BuildPhaseFailed)
+	after.Status.FailureReason = ptr.To(forgeerrors.ProvisionerFailedError)
+
+	events := Events(before, after, now)
+	g.Expect(events).To(HaveLen(2))
+	g.Expect(events[0].Type).To(Equal(ProvisionerFailedType))
+	g.Expect(events[0].Data.Provisioner).To(Equal(&ProvisionerData{Index: 0, UUID: "1234", Type: "built-in/shell", FailureMessage: "exit status 1"}))
+	g.Expect(events[1].Type).To(Equal(PhaseChangedType))
+	g.Expect(events[1].SpecVersion).To(Equal("1.0"))
+	g.Expect(events[1].Source).To(Equal("/apis/forge.build/v1alpha1/namespaces/default/builds/ubuntu"))
+	g.Expect(events[1].Subject).To(Equal("ubuntu"))
+	g.Expect(events[1].Time).To(Equal(now))
+	g.Expect(events[1].ID).NotTo(Equal(events[0].ID))
+	g.Expect(events[1].Data.Phase).To(Equal("Failed"))
+	g.Expect(events[1].Data.PreviousPhase).To(Equal("Building"))
+	g.Expect(events[1].Data.FailureReason).To(Equal(string(forgeerrors.ProvisionerFailedError)))
+
+	// The failure of the provisioner is only sent once.
+	g.Expect(Events(after, after.DeepCopy(), now)).To(BeEmpty())
+
+	// The image is exported.
+	before = testBuild()
+	after = before.DeepCopy()
+	after.Status.Artifact = "pvc://images/ubuntu.tar.gz"
+	after.Status.Phase = string(buildv1.BuildPhaseCompleted)
+	events = Events(before, after, now)
+	g.Expect(events).To(HaveLen(2))
+	g.Expect(events[0].Type).To(Equal(ArtifactExportedType))
+	g.Expect(events[0].Data.Artifact).To(Equal("pvc://images/ubuntu.tar.gz"))
+	g.Expect(events[1].Type).To(Equal(PhaseChangedType))
+}
@@ -0,0 +1,191 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notification
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog/v2"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	buildv1 "github.com/forge-build/forge/api/v1alpha1"
+)
+
+const (
+	// SignatureHeader is the header holding the hex encoded HMAC-SHA256 of the body of the signed events,
+	// prefixed with sha256=.
+	SignatureHeader = "X-Forge-Signature-256"
+
+	// sendTimeout is how long to wait for an endpoint to answer.
+	sendTimeout = 10 * time.Second
+
+	// defaultWorkers is how many events are sent at the same time by default.
+	defaultWorkers = 4
+)
+
+// Notifier sends the events to the notification endpoints of the Builds, and sends them again with an
+// exponential backoff when an endpoint fails. The events not sent yet are lost when the manager restarts.
+type Notifier struct {
+	// Client reads the Secrets holding the signing keys.
+	Client client.Reader
+
+	// HTTPClient sends the events.
+	HTTPClient *http.Client
+
+	// Workers is how many events are sent at the same time, so a slow endpoint does not delay the others.
+	Workers int
+
+	queue workqueue.RateLimitingInterface
+}
+
+// delivery is an event to send to an endpoint.
+type delivery struct {
+	namespace string
+	endpoint  buildv1.NotificationSpec
+	event     CloudEvent
+}
+
+// NewNotifier returns a Notifier reading the signing keys with the client.
+func NewNotifier(c client.Reader) *Notifier {
+	return newNotifier(c, workqueue.NewItemExponentialFailureRateLimiter(time.Second, 5*time.Minute))
+}
+
+func newNotifier(c client.Reader, rateLimiter workqueue.RateLimiter) *Notifier {
+	return &Notifier{
+		Client:     c,
+		HTTPClient: newHTTPClient(),
+		Workers:    defaultWorkers,
+		queue:      workqueue.NewRateLimitingQueueWithConfig(rateLimiter, workqueue.RateLimitingQueueConfig{Name: "notifications"}),
+	}
+}
+
+// Notify queues the events for the endpoints of the Build accepting them.
+func (n *Notifier) Notify(build *buildv1.Build, events []CloudEvent) {
+	for _, e := range events {
+		for i := range build.Spec.Notifications {
+			endpoint := &build.Spec.Notifications[i]
+			if !endpoint.Accepts(e.event, buildv1.BuildPhase(e.Data.Phase)) {
+				continue
+			}
+			n.queue.Add(&delivery{namespace: build.Namespace, endpoint: *endpoint.DeepCopy(), event: e})
+		}
+	}
+}
+
+// Start sends the queued events until the context is done, it implements manager.Runnable.
+func (n *Notifier) Start(ctx context.Context) error {
+	go func() {
+		<-ctx.Done()
+		n.queue.ShutDown()
+	}()
+	var wg sync.WaitGroup
+	for range max(n.Workers, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n.processNext(ctx) {
+			}
+		}()
+	}
+	wg.Wait()
+	return nil
+}
+
+func (n *Notifier) processNext(ctx context.Context) bool {
+	item, shutdown := n.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer n.queue.Done(item)
+
+	d := item.(*delivery)
+	log := ctrl.LoggerFrom(ctx).WithValues("build", klog.KRef(d.namespace, d.event.Subject), "endpoint", d.endpoint.Name, "type", d.event.Type)
+	if err := n.send(ctx, d); err != nil {
+		if n.queue.NumRequeues(item) < d.endpoint.GetMaxRetries() {
+			log.Error(err, "Failed to send the notification, retrying")
+			n.queue.AddRateLimited(item)
+			return true
+		}
+		log.Error(err, "Failed to send the notification, giving up")
+	}
+	n.queue.Forget(item)
+	return true
+}
+
+// send POSTs the event to the endpoint.
+func (n *Notifier) send(ctx context.Context, d *delivery) error {
+	body, err := json.Marshal(d.event)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal the event")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "failed to create the request")
+	}
+	req.Header.Set("Content-Type", ContentType)
+	if ref := d.endpoint.SigningSecretRef; ref != nil {
+		key, err := n.signingKey(ctx, d.namespace, ref)
+		if err != nil {
+			return err
+		}
+		req.Header.Set(SignatureHeader, "sha256="+Sign(key, body))
+	}
+
+	resp, err := n.HTTPClient.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "failed to send the event to %s", d.endpoint.Name)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.Errorf("endpoint %s answered %s", d.endpoint.Name, resp.Status)
+	}
+	return nil
+}
+
+// signingKey reads the HMAC key of an endpoint.
+func (n *Notifier) signingKey(ctx context.Context, namespace string, ref *corev1.SecretKeySelector) ([]byte, error) {
+	secret := &corev1.Secret{}
+	if err := n.Client.Get(ctx, client.ObjectKey{Namespace: namespace, Name: ref.Name}, secret); err != nil {
+		return nil, errors.Wrapf(err, "failed to get the signing Secret %s", ref.Name)
+	}
+	key, ok := secret.Data[ref.Key]
+	if !ok {
+		return nil, errors.Errorf("signing Secret %s has no key %s", ref.Name, ref.Key)
+	}
+	return key, nil
+}
+
+// Sign returns the hex encoded HMAC-SHA256 of the body.
+func Sign(key, body []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
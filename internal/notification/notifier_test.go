@@ -0,0 +1,178 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	buildv1 "github.com/forge-build/forge/api/v1alpha1"
+)
+
+// endpoint records the requests it receives, it fails the first ones.
+type endpoint struct {
+	mu       sync.Mutex
+	failures int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (e *endpoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	body, _ := io.ReadAll(r.Body)
+	e.requests = append(e.requests, r)
+	e.bodies = append(e.bodies, body)
+	if e.failures > 0 {
+		e.failures--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (e *endpoint) received() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return len(e.requests)
+}
+
+func startNotifier(t *testing.T) *Notifier {
+	scheme := runtime.NewScheme()
+	_ = corev1.AddToScheme(scheme)
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "hook", Namespace: "default"},
+		Data:       map[string][]byte{"key": []byte("secret")},
+	}
+	n := newNotifier(fake.NewClientBuilder().WithScheme(scheme).WithObjects(secret).Build(),
+		workqueue.NewItemExponentialFailureRateLimiter(time.Millisecond, 10*time.Millisecond))
+	// The test servers listen on the loopback address, refused by the default client.
+	n.HTTPClient = &http.Client{Timeout: sendTimeout}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go func() { _ = n.Start(ctx) }()
+	return n
+}
+
+func TestNotify(t *testing.T) {
+	g := NewWithT(t)
+
+	ep := &endpoint{failures: 1}
+	server := httptest.NewServer(ep)
+	defer server.Close()
+	n := startNotifier(t)
+
+	build := testBuild()
+	build.Spec.Notifications = []buildv1.NotificationSpec{
+		{
+			Name:             "ci",
+			URL:              server.URL,
+			SigningSecretRef: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "hook"}, Key: "key"},
+			Phases:           []buildv1.BuildPhase{buildv1.BuildPhaseCompleted},
+		},
+	}
+	after := build.DeepCopy()
+	after.Status.Phase = string(buildv1.BuildPhaseCompleted)
+	n.Notify(after, Events(build, after, time.Now()))
+
+	// The event is sent again after the endpoint failed.
+	g.Eventually(ep.received).Should(Equal(2))
+	g.Consistently(ep.received, 50*time.Millisecond).Should(Equal(2))
+
+	req, body := ep.requests[1], ep.bodies[1]
+	g.Expect(req.Method).To(Equal(http.MethodPost))
+	g.Expect(req.Header.Get("Content-Type")).To(Equal(ContentType))
+	g.Expect(req.Header.Get(SignatureHeader)).To(Equal("sha256=" + Sign([]byte("secret"), body)))
+	event := &CloudEvent{}
+	g.Expect(json.Unmarshal(body, event)).To(Succeed())
+	g.Expect(event.Type).To(Equal(PhaseChangedType))
+	g.Expect(event.Data.Phase).To(Equal("Completed"))
+
+	// The transitions to the other phases are filtered out.
+	before := after.DeepCopy()
+	after.Status.Phase = string(buildv1.BuildPhaseTerminating)
+	n.Notify(after, Events(before, after, time.Now()))
+	g.Consistently(ep.received, 50*time.Millisecond).Should(Equal(2))
+}
+
+func TestNotifyGivesUp(t *testing.T) {
+	g := NewWithT(t)
+
+	ep := &endpoint{failures: 10}
+	server := httptest.NewServer(ep)
+	defer server.Close()
+	n := startNotifier(t)
+
+	build := testBuild()
+	build.Spec.Notifications = []buildv1.NotificationSpec{{Name: "ci", URL: server.URL, MaxRetries: ptr.To[int32](2)}}
+	after := build.DeepCopy()
+	after.Status.Phase = string(buildv1.BuildPhaseFailed)
+	n.Notify(after, Events(build, after, time.Now()))
+
+	g.Eventually(ep.received).Should(Equal(3))
+	g.Consistently(ep.received, 50*time.Millisecond).Should(Equal(3))
+}
+
+func TestNotifySlowEndpoint(t *testing.T) {
+	g := NewWithT(t)
+
+	unblock := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { <-unblock }))
+	defer slow.Close()
+	defer close(unblock)
+	ep := &endpoint{}
+	server := httptest.NewServer(ep)
+	defer server.Close()
+	n := startNotifier(t)
+
+	build := testBuild()
+	build.Spec.Notifications = []buildv1.NotificationSpec{{Name: "slow", URL: slow.URL}, {Name: "ci", URL: server.URL}}
+	after := build.DeepCopy()
+	after.Status.Phase = string(buildv1.BuildPhaseCompleted)
+	n.Notify(after, Events(build, after, time.Now()))
+
+	// The event is sent to the other endpoint while the slow one has not answered yet.
+	g.Eventually(ep.received, time.Second).Should(Equal(1))
+}
+
+func TestNotifyPrivateAddress(t *testing.T) {
+	g := NewWithT(t)
+
+	ep := &endpoint{}
+	server := httptest.NewServer(ep)
+	defer server.Close()
+	n := newNotifier(nil, workqueue.DefaultControllerRateLimiter())
+
+	err := n.send(context.Background(), &delivery{endpoint: buildv1.NotificationSpec{Name: "ci", URL: server.URL}})
+	g.Expect(err).To(MatchError(ContainSubstring("refusing to connect to the private address 127.0.0.1")))
+	g.Expect(ep.received()).To(Equal(0))
+}
@@ -0,0 +1,454 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package packer
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+
+	buildv1 "github.com/forge-build/forge/api/v1alpha1"
+	"github.com/forge-build/forge/internal/validation"
+	"github.com/forge-build/forge/internal/webhooks"
+)
+
+const (
+	// ScriptKey is the key of the scripts in the provisioner ConfigMaps.
+	ScriptKey = "script.sh"
+
+	// maxFileSize is the maximum size of the files uploaded by the file provisioners,
+	// which are embedded in a ConfigMap.
+	maxFileSize = 512 * 1024
+
+	defaultInlineShebang = "/bin/sh -e"
+)
+
+// Options configures the conversion of a template.
+type Options struct {
+	// Name and Namespace are the name and namespace of the Build.
+	Name      string
+	Namespace string
+	// InfrastructureRef is the reference to the InfraBuild of the Build.
+	InfrastructureRef corev1.ObjectReference
+	// ReadFile reads the files referenced by the template, it defaults to reading them
+	// relative to the template directory.
+	ReadFile func(name string) ([]byte, error)
+}
+
+// Result is a template converted to a Build.
+type Result struct {
+	Build      *buildv1.Build
+	ConfigMaps []*corev1.ConfigMap
+	// Secret holds the SSH credentials of the machine, it is only set if the template has a ssh_host.
+	Secret *corev1.Secret
+	// Unsupported lists the constructs of the template which are not converted.
+	Unsupported []string
+}
+
+type converter struct {
+	*Template
+	opts   Options
+	result *Result
+}
+
+// Convert converts a template to a Build, the scripts of the provisioners are stored in ConfigMaps.
+func Convert(t *Template, opts Options) (*Result, error) {
+	if opts.Name == "" {
+		return nil, errors.New("the name of the Build is required")
+	}
+	if opts.ReadFile == nil {
+		opts.ReadFile = func(name string) ([]byte, error) {
+			if !filepath.IsAbs(name) {
+				name = filepath.Join(t.Dir, name)
+			}
+			return os.ReadFile(name)
+		}
+	}
+
+	c := &converter{
+		Template: t,
+		opts:     opts,
+		result: &Result{
+			Build: &buildv1.Build{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: buildv1.GroupVersion.String(),
+					Kind:       "Build",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      opts.Name,
+					Namespace: opts.Namespace,
+				},
+				Spec: buildv1.BuildSpec{
+					Connector:         buildv1.ConnectorSpec{Type: webhooks.ConnectorTypeSSH},
+					InfrastructureRef: opts.InfrastructureRef.DeepCopy(),
+				},
+			},
+		},
+	}
+
+	c.convertCommunicator()
+	for i, p := range t.Provisioners {
+		what := fmt.Sprintf("provisioner %d (%s)", i, p.Type)
+		switch p.Type {
+		case "shell":
+			c.convertShell(what, p)
+		case "file":
+			c.convertFile(what, p)
+		case "ansible", "ansible-local":
+			c.convertAnsible(what, p)
+		default:
+			c.unsupportedf("%s: %s provisioners are not converted", what, p.Type)
+		}
+	}
+
+	c.result.Unsupported = c.Unsupported
+	return c.result, nil
+}
+
+func (c *converter) convertCommunicator() {
+	comm := c.Communicator
+	if typ, ok := getString(comm, "communicator"); ok && typ != webhooks.ConnectorTypeSSH {
+		c.unsupportedf("communicator %s is not converted, only ssh is supported", typ)
+		return
+	}
+
+	host, hasHost := getString(comm, "ssh_host")
+	for _, key := range sortedKeys(comm) {
+		switch key {
+		case "communicator", "ssh_host":
+		case "ssh_username", "ssh_password":
+			if !hasHost {
+				c.unsupportedf("%s is not converted, set it on the InfraBuild which creates the credentials Secret", key)
+			}
+		case "ssh_private_key_file":
+			secret := fmt.Sprintf("%s-ssh-credentials", c.opts.Name)
+			if !hasHost {
+				secret = "the credentials Secret of the InfraBuild"
+			}
+			c.unsupportedf("ssh_private_key_file is not converted, set the private key under privateKey in %s", secret)
+		case "ssh_port":
+			if port, _ := getString(comm, key); port != "22" {
+				c.unsupportedf("ssh_port %s is not converted, the machine is reached on port 22", port)
+			}
+		default:
+			c.unsupportedf("%s is not converted", key)
+		}
+	}
+	if !hasHost {
+		return
+	}
+
+	name := fmt.Sprintf("%s-ssh-credentials", c.opts.Name)
+	secret := &corev1.Secret{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: c.opts.Namespace,
+			Labels:    map[string]string{buildv1.BuildNameLabel: c.opts.Name},
+		},
+		Type:       buildv1.BuildSecretType,
+		StringData: map[string]string{"host": host},
+	}
+	if username, ok := getString(comm, "ssh_username"); ok {
+		secret.StringData["username"] = username
+	}
+	if password, ok := getString(comm, "ssh_password"); ok {
+		secret.StringData["password"] = password
+	}
+	c.result.Secret = secret
+	c.result.Build.Spec.Connector.Credentials = &corev1.LocalObjectReference{Name: name}
+}
+
+func (c *converter) convertShell(what string, p Provisioner) {
+	c.checkKeys(what, p, "inline", "script", "scripts", "environment_vars", "env", "expect_disconnect",
+		"max_retries", "inline_shebang", "valid_exit_codes")
+	if codes, ok := getStrings(p.Config, "valid_exit_codes"); ok && (len(codes) != 1 || codes[0] != "0") {
+		c.unsupportedf("%s: valid_exit_codes is not converted, only 0 is a success", what)
+	}
+
+	env := c.environment(what, p, "environment_vars")
+	var scripts []string
+	if inline, ok := getStrings(p.Config, "inline"); ok {
+		shebang, ok := getString(p.Config, "inline_shebang")
+		if !ok {
+			shebang = defaultInlineShebang
+		}
+		scripts = append(scripts, wrapScript(env, fmt.Sprintf("#!%s\n%s\n", shebang, strings.Join(inline, "\n"))))
+	}
+	var files []string
+	if script, ok := getString(p.Config, "script"); ok {
+		files = append(files, script)
+	}
+	if s, ok := getStrings(p.Config, "scripts"); ok {
+		files = append(files, s...)
+	}
+	for _, file := range files {
+		content, err := c.opts.ReadFile(file)
+		if err != nil {
+			c.unsupportedf("%s: script %s cannot be read: %v", what, file, err)
+			continue
+		}
+		scripts = append(scripts, wrapScript(env, EscapePlaceholders(string(content))))
+	}
+	if len(scripts) == 0 {
+		c.unsupportedf("%s: no script to convert", what)
+	}
+
+	// Packer runs every script of a provisioner separately, each of them is a Forge provisioner.
+	for _, script := range scripts {
+		c.addProvisioner(what, p, script)
+	}
+}
+
+func (c *converter) convertFile(what string, p Provisioner) {
+	c.checkKeys(what, p, "source", "sources", "destination", "content", "direction", "generated")
+	if direction, _ := getString(p.Config, "direction"); direction == "download" {
+		c.unsupportedf("%s: downloads are not converted", what)
+		return
+	}
+	if generated, _ := getBool(p.Config, "generated"); generated {
+		c.unsupportedf("%s: generated is not converted, the sources must exist when converting", what)
+	}
+	destination, ok := getString(p.Config, "destination")
+	if !ok {
+		c.unsupportedf("%s: destination is required", what)
+		return
+	}
+
+	type upload struct {
+		destination string
+		content     []byte
+	}
+	var uploads []upload
+	if content, ok := getString(p.Config, "content"); ok {
+		uploads = append(uploads, upload{destination: destination, content: []byte(content)})
+	}
+	var sources []string
+	if source, ok := getString(p.Config, "source"); ok {
+		sources = append(sources, source)
+	}
+	if s, ok := getStrings(p.Config, "sources"); ok {
+		sources = append(sources, s...)
+	}
+	for _, source := range sources {
+		content, err := c.opts.ReadFile(source)
+		if err != nil {
+			c.unsupportedf("%s: source %s cannot be read, only files are converted: %v", what, source, err)
+			continue
+		}
+		dst := destination
+		if strings.HasSuffix(dst, "/") || len(sources) > 1 {
+			dst = path.Join(dst, filepath.Base(source))
+		}
+		uploads = append(uploads, upload{destination: dst, content: content})
+	}
+
+	var sb strings.Builder
+	size := 0
+	for _, u := range uploads {
+		size += len(u.content)
+		dst := validation.Quote(u.destination)
+		fmt.Fprintf(&sb, "mkdir -p \"$(dirname %s)\"\n", dst)
+		fmt.Fprintf(&sb, "base64 -d > %s <<'FORGE_EOF'\n%sFORGE_EOF\n", dst, wrapBase64(u.content))
+	}
+	if size > maxFileSize {
+		c.unsupportedf("%s: the files are larger than %d bytes, they do not fit in a ConfigMap", what, maxFileSize)
+		return
+	}
+	if len(uploads) == 0 {
+		c.unsupportedf("%s: no file to convert", what)
+		return
+	}
+	c.addProvisioner(what, p, "set -e\n"+sb.String())
+}
+
+func (c *converter) convertAnsible(what string, p Provisioner) {
+	c.checkKeys(what, p, "playbook_file", "extra_arguments", "ansible_env_vars", "command")
+	if p.Type == "ansible" {
+		c.unsupportedf("%s: the playbook runs on the machine with a local connection, ansible must be installed on it", what)
+	}
+
+	playbook, ok := getString(p.Config, "playbook_file")
+	if !ok {
+		c.unsupportedf("%s: playbook_file is required", what)
+		return
+	}
+	content, err := c.opts.ReadFile(playbook)
+	if err != nil {
+		c.unsupportedf("%s: playbook %s cannot be read: %v", what, playbook, err)
+		return
+	}
+	command, ok := getString(p.Config, "command")
+	if !ok {
+		command = "ansible-playbook"
+	}
+	args := []string{command, "-i", validation.Quote("localhost,"), "-c", "local"}
+	extra, _ := getStrings(p.Config, "extra_arguments")
+	for _, arg := range extra {
+		args = append(args, validation.Quote(arg))
+	}
+	args = append(args, `"$forge_dir/playbook.yml"`)
+
+	var sb strings.Builder
+	sb.WriteString(c.environment(what, p, "ansible_env_vars"))
+	sb.WriteString("forge_dir=$(mktemp -d)\n")
+	fmt.Fprintf(&sb, "cat > \"$forge_dir/playbook.yml\" <<'%s'\n", heredocDelimiter(string(content)))
+	sb.WriteString(withTrailingNewline(EscapePlaceholders(string(content))))
+	fmt.Fprintf(&sb, "%s\n", heredocDelimiter(string(content)))
+	fmt.Fprintf(&sb, "%s\n", strings.Join(args, " "))
+	sb.WriteString("forge_rc=$?\nrm -rf \"$forge_dir\"\nexit $forge_rc\n")
+	c.addProvisioner(what, p, sb.String())
+}
+
+// addProvisioner adds a shell provisioner running a script stored in a ConfigMap.
+func (c *converter) addProvisioner(what string, p Provisioner, script string) {
+	build := c.result.Build
+	name := fmt.Sprintf("%s-provisioner-%d", build.Name, len(build.Spec.Provisioners))
+	c.result.ConfigMaps = append(c.result.ConfigMaps, &corev1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: c.opts.Namespace,
+			Labels:    map[string]string{buildv1.BuildNameLabel: build.Name},
+		},
+		Data: map[string]string{ScriptKey: script},
+	})
+
+	spec := buildv1.ProvisionerSpec{
+		Type:            buildv1.ProvisionerTypeShell,
+		RunConfigMapRef: &corev1.ObjectReference{Name: name},
+		// Packer does not retry the provisioners unless max_retries is set.
+		Retries: ptr.To[int32](0),
+	}
+	if _, ok := p.Config["max_retries"]; ok {
+		if retries, ok := getInt(p.Config, "max_retries"); ok {
+			spec.Retries = ptr.To(int32(retries))
+		} else {
+			c.unsupportedf("%s: max_retries must be a number", what)
+		}
+	}
+	if expect, _ := getBool(p.Config, "expect_disconnect"); expect {
+		spec.ExpectDisconnect = true
+	}
+	build.Spec.Provisioners = append(build.Spec.Provisioners, spec)
+}
+
+// checkKeys reports the settings of a provisioner which are not converted.
+func (c *converter) checkKeys(what string, p Provisioner, supported ...string) {
+	for _, key := range sortedKeys(p.Config) {
+		found := false
+		for _, s := range supported {
+			if key == s {
+				found = true
+				break
+			}
+		}
+		if !found {
+			c.unsupportedf("%s: %s is not converted", what, key)
+		}
+	}
+}
+
+// environment returns the export statements of the environment variables of a provisioner,
+// set as KEY=VALUE strings, or as an env map for shell provisioners.
+func (c *converter) environment(what string, p Provisioner, key string) string {
+	env := map[string]string{}
+	vars, _ := getStrings(p.Config, key)
+	for _, v := range vars {
+		name, value, ok := strings.Cut(v, "=")
+		if !ok {
+			c.unsupportedf("%s: %s %q is not a KEY=VALUE pair", what, key, v)
+			continue
+		}
+		env[name] = value
+	}
+	if m, ok := p.Config["env"].(map[string]interface{}); ok && key == "environment_vars" {
+		for name := range m {
+			if value, ok := getString(m, name); ok {
+				env[name] = value
+			}
+		}
+	}
+
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var sb strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&sb, "export %s=%s\n", name, validation.Quote(env[name]))
+	}
+	return sb.String()
+}
+
+// wrapScript returns a script uploading a script to the machine and running it like Packer does,
+// so that its shebang is honored.
+func wrapScript(env, script string) string {
+	delimiter := heredocDelimiter(script)
+	var sb strings.Builder
+	sb.WriteString(env)
+	sb.WriteString("forge_script=$(mktemp)\n")
+	fmt.Fprintf(&sb, "cat > \"$forge_script\" <<'%s'\n", delimiter)
+	sb.WriteString(withTrailingNewline(script))
+	fmt.Fprintf(&sb, "%s\n", delimiter)
+	sb.WriteString("chmod +x \"$forge_script\"\n\"$forge_script\"\nforge_rc=$?\nrm -f \"$forge_script\"\nexit $forge_rc\n")
+	return sb.String()
+}
+
+// heredocDelimiter returns a here-document delimiter which is not a line of the content.
+func heredocDelimiter(content string) string {
+	delimiter := "FORGE_EOF"
+	lines := map[string]bool{}
+	for _, line := range strings.Split(content, "\n") {
+		lines[line] = true
+	}
+	for i := 1; lines[delimiter]; i++ {
+		delimiter = fmt.Sprintf("FORGE_EOF_%d", i)
+	}
+	return delimiter
+}
+
+func withTrailingNewline(s string) string {
+	if strings.HasSuffix(s, "\n") {
+		return s
+	}
+	return s + "\n"
+}
+
+// wrapBase64 encodes content in base64 with lines of 76 characters.
+func wrapBase64(content []byte) string {
+	encoded := base64.StdEncoding.EncodeToString(content)
+	var sb strings.Builder
+	for len(encoded) > 76 {
+		sb.WriteString(encoded[:76] + "\n")
+		encoded = encoded[76:]
+	}
+	if encoded != "" {
+		sb.WriteString(encoded + "\n")
+	}
+	return sb.String()
+}
@@ -0,0 +1,274 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package packer
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/pkg/errors"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// ParseHCL parses HCL2 Packer templates, the files of a template directory are parsed together.
+func ParseHCL(name, dir string, files map[string][]byte) (*Template, error) {
+	t := &Template{Name: name, Dir: dir, Communicator: map[string]interface{}{}}
+
+	filenames := make([]string, 0, len(files))
+	for filename := range files {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+
+	var blocks hclsyntax.Blocks
+	for _, filename := range filenames {
+		file, diags := hclsyntax.ParseConfig(files[filename], filename, hcl.InitialPos)
+		if diags.HasErrors() {
+			return nil, errors.Wrapf(diags, "failed to parse %s", filename)
+		}
+		body := file.Body.(*hclsyntax.Body)
+		for _, attr := range sortedAttributes(body.Attributes) {
+			t.unsupportedf("%s: top-level attribute %s is not converted", filename, attr.Name)
+		}
+		blocks = append(blocks, body.Blocks...)
+	}
+
+	ctx := t.evalContext(blocks)
+
+	sources := 0
+	for _, block := range blocks {
+		switch block.Type {
+		case "variable", "variables", "locals", "local", "packer":
+		case "source":
+			sources++
+			t.unsupportedf("source %s is not converted, create its InfraBuild", strings.Join(block.Labels, "."))
+			if sources > 1 {
+				t.unsupportedf("the communicator of source %s is not converted, only the one of the first source is", strings.Join(block.Labels, "."))
+				continue
+			}
+			for _, attr := range sortedAttributes(block.Body.Attributes) {
+				if attr.Name == "communicator" || strings.HasPrefix(attr.Name, "ssh_") {
+					if v, ok := t.evaluate(ctx, "source "+strings.Join(block.Labels, "."), attr); ok {
+						t.Communicator[attr.Name] = v
+					}
+				}
+			}
+		case "build":
+			t.parseBuild(ctx, block)
+		default:
+			t.unsupportedf("%s blocks are not converted", block.Type)
+		}
+	}
+
+	return t, nil
+}
+
+// evalContext returns the context evaluating the expressions of the template,
+// where the variables and locals are forgectl template placeholders.
+func (t *Template) evalContext(blocks hclsyntax.Blocks) *hcl.EvalContext {
+	vars := map[string]cty.Value{}
+	for _, block := range blocks {
+		var attrs []*hclsyntax.Attribute
+		switch {
+		case block.Type == "variable" && len(block.Labels) == 1:
+			v := Variable{Name: block.Labels[0]}
+			if attr, ok := block.Body.Attributes["default"]; ok {
+				if value, ok := t.evaluate(nil, "variable "+v.Name, attr); ok && value != nil {
+					if s, ok := getString(map[string]interface{}{"v": value}, "v"); ok {
+						v.Default = &s
+					} else {
+						t.unsupportedf("variable %s: only string, number and bool defaults are converted", v.Name)
+					}
+				}
+			}
+			t.Variables = append(t.Variables, v)
+			vars[v.Name] = cty.StringVal(placeholderSentinel + placeholder(v.Name, v.Default)[1:])
+		case block.Type == "variables":
+			// Legacy variables block, where the attributes are the variables defaults.
+			attrs = sortedAttributes(block.Body.Attributes)
+		}
+		for _, attr := range attrs {
+			v := Variable{Name: attr.Name}
+			if value, ok := t.evaluate(nil, "variable "+v.Name, attr); ok && value != nil {
+				if s, ok := getString(map[string]interface{}{"v": value}, "v"); ok {
+					v.Default = &s
+				}
+			}
+			t.Variables = append(t.Variables, v)
+			vars[v.Name] = cty.StringVal(placeholderSentinel + placeholder(v.Name, v.Default)[1:])
+		}
+	}
+
+	ctx := &hcl.EvalContext{Variables: map[string]cty.Value{
+		"var":   cty.ObjectVal(vars),
+		"local": cty.EmptyObjectVal,
+	}}
+
+	// Locals may reference each other, resolve them until no more of them can be.
+	pending := map[string]hcl.Expression{}
+	for _, block := range blocks {
+		switch block.Type {
+		case "locals":
+			for name, attr := range block.Body.Attributes {
+				pending[name] = attr.Expr
+			}
+		case "local":
+			if attr, ok := block.Body.Attributes["expression"]; ok && len(block.Labels) == 1 {
+				pending[block.Labels[0]] = attr.Expr
+			}
+		}
+	}
+	locals := map[string]cty.Value{}
+	for len(pending) > 0 {
+		resolved := false
+		for name, expr := range pending {
+			value, diags := expr.Value(ctx)
+			if diags.HasErrors() {
+				continue
+			}
+			locals[name] = value
+			delete(pending, name)
+			resolved = true
+		}
+		ctx.Variables["local"] = cty.ObjectVal(locals)
+		if !resolved {
+			break
+		}
+	}
+	for _, name := range sortedNames(pending) {
+		t.unsupportedf("local %s cannot be converted", name)
+	}
+
+	return ctx
+}
+
+func (t *Template) parseBuild(ctx *hcl.EvalContext, block *hclsyntax.Block) {
+	for _, attr := range sortedAttributes(block.Body.Attributes) {
+		switch attr.Name {
+		case "name", "description", "sources":
+		default:
+			t.unsupportedf("build attribute %s is not converted", attr.Name)
+		}
+	}
+
+	for _, b := range block.Body.Blocks {
+		if b.Type != "provisioner" || len(b.Labels) != 1 {
+			if b.Type != "source" {
+				t.unsupportedf("build %s blocks are not converted", b.Type)
+			}
+			continue
+		}
+
+		p := Provisioner{Type: b.Labels[0], Config: map[string]interface{}{}}
+		what := "provisioner " + p.Type
+		for _, attr := range sortedAttributes(b.Body.Attributes) {
+			if v, ok := t.evaluate(ctx, what, attr); ok {
+				p.Config[attr.Name] = v
+			}
+		}
+		for _, nested := range b.Body.Blocks {
+			t.unsupportedf("%s: %s blocks are not converted", what, nested.Type)
+		}
+		t.Provisioners = append(t.Provisioners, p)
+	}
+}
+
+// evaluate evaluates an attribute to a Go value, it reports the attributes which cannot be evaluated.
+func (t *Template) evaluate(ctx *hcl.EvalContext, what string, attr *hclsyntax.Attribute) (interface{}, bool) {
+	value, diags := attr.Expr.Value(ctx)
+	if diags.HasErrors() {
+		t.unsupportedf("%s: %s cannot be converted: %s", what, attr.Name, diags.Errs()[0])
+		return nil, false
+	}
+	v, err := ctyToGo(value)
+	if err != nil {
+		t.unsupportedf("%s: %s cannot be converted: %s", what, attr.Name, err)
+		return nil, false
+	}
+	return restorePlaceholders(v), true
+}
+
+// ctyToGo converts a cty value to the Go values of a decoded JSON document.
+func ctyToGo(value cty.Value) (interface{}, error) {
+	if value.IsNull() {
+		return nil, nil
+	}
+	if !value.IsWhollyKnown() {
+		return nil, errors.New("value is unknown")
+	}
+
+	typ := value.Type()
+	switch {
+	case typ == cty.String:
+		return value.AsString(), nil
+	case typ == cty.Bool:
+		return value.True(), nil
+	case typ == cty.Number:
+		f := value.AsBigFloat()
+		if f.IsInt() {
+			i, _ := f.Int64()
+			return i, nil
+		}
+		v, _ := f.Float64()
+		return v, nil
+	case typ.IsListType() || typ.IsTupleType() || typ.IsSetType():
+		out := []interface{}{}
+		for it := value.ElementIterator(); it.Next(); {
+			_, elem := it.Element()
+			v, err := ctyToGo(elem)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, v)
+		}
+		return out, nil
+	case typ.IsMapType() || typ.IsObjectType():
+		out := map[string]interface{}{}
+		for it := value.ElementIterator(); it.Next(); {
+			key, elem := it.Element()
+			v, err := ctyToGo(elem)
+			if err != nil {
+				return nil, err
+			}
+			out[key.AsString()] = v
+		}
+		return out, nil
+	}
+	return nil, errors.Errorf("unsupported type %s", typ.FriendlyName())
+}
+
+func sortedAttributes(attrs hclsyntax.Attributes) []*hclsyntax.Attribute {
+	out := make([]*hclsyntax.Attribute, 0, len(attrs))
+	for _, attr := range attrs {
+		out = append(out, attr)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
+
+func sortedNames(m map[string]hcl.Expression) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
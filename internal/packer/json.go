@@ -0,0 +1,141 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package packer
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// jsonFunctionRegexp matches the template functions of JSON templates, e.g. {{user `name`}}.
+var jsonFunctionRegexp = regexp.MustCompile("\\{\\{\\s*([a-zA-Z_]+)\\s*(?:`([^`]*)`)?\\s*\\}\\}")
+
+// ParseJSON parses a legacy JSON Packer template.
+func ParseJSON(name, dir string, data []byte) (*Template, error) {
+	raw := map[string]interface{}{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse %s", name)
+	}
+
+	t := &Template{Name: name, Dir: dir, Communicator: map[string]interface{}{}}
+	keys := make([]string, 0, len(raw))
+	for key := range raw {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		switch key {
+		case "variables", "builders", "provisioners", "description", "min_packer_version", "sensitive-variables":
+		default:
+			t.unsupportedf("%s are not converted", key)
+		}
+	}
+
+	variables, _ := raw["variables"].(map[string]interface{})
+	defaults := map[string]*string{}
+	for _, name := range sortedKeys(variables) {
+		v := Variable{Name: name}
+		if value := variables[name]; value != nil {
+			def := fmt.Sprint(value)
+			v.Default = &def
+		}
+		defaults[name] = v.Default
+		t.Variables = append(t.Variables, v)
+	}
+	interpolate := func(s string) string {
+		return jsonFunctionRegexp.ReplaceAllStringFunc(EscapePlaceholders(s), func(match string) string {
+			groups := jsonFunctionRegexp.FindStringSubmatch(match)
+			switch groups[1] {
+			case "user":
+				if _, ok := defaults[groups[2]]; !ok {
+					t.unsupportedf("variable %s is used but not declared", groups[2])
+				}
+				return placeholder(groups[2], defaults[groups[2]])
+			case "env":
+				return fmt.Sprintf("${%s:-}", groups[2])
+			}
+			t.unsupportedf("template function %s is not converted", match)
+			return match
+		})
+	}
+
+	builders, _ := raw["builders"].([]interface{})
+	for i, b := range builders {
+		builder, _ := b.(map[string]interface{})
+		t.unsupportedf("builder %v is not converted, create its InfraBuild", builder["type"])
+		if i > 0 {
+			t.unsupportedf("the communicator of builder %v is not converted, only the one of the first builder is", builder["type"])
+			continue
+		}
+		for _, key := range sortedKeys(builder) {
+			if key == "communicator" || strings.HasPrefix(key, "ssh_") {
+				t.Communicator[key] = interpolateValue(builder[key], interpolate)
+			}
+		}
+	}
+
+	provisioners, _ := raw["provisioners"].([]interface{})
+	for i, p := range provisioners {
+		config, ok := p.(map[string]interface{})
+		if !ok {
+			return nil, errors.Errorf("provisioner %d is not an object", i)
+		}
+		typ, _ := config["type"].(string)
+		delete(config, "type")
+		t.Provisioners = append(t.Provisioners, Provisioner{
+			Type:   typ,
+			Config: interpolateValue(config, interpolate).(map[string]interface{}),
+		})
+	}
+
+	return t, nil
+}
+
+// interpolateValue interpolates the strings of a JSON value.
+func interpolateValue(value interface{}, interpolate func(string) string) interface{} {
+	switch v := value.(type) {
+	case string:
+		return interpolate(v)
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i := range v {
+			out[i] = interpolateValue(v[i], interpolate)
+		}
+		return out
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k := range v {
+			out[k] = interpolateValue(v[k], interpolate)
+		}
+		return out
+	}
+	return value
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
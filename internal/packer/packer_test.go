@@ -0,0 +1,284 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package packer
+
+import (
+	"os"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/utils/ptr"
+
+	buildv1 "github.com/forge-build/forge/api/v1alpha1"
+)
+
+const hclTemplate = `
+packer {
+  required_plugins {
+    amazon = { version = ">= 1.0.0", source = "github.com/hashicorp/amazon" }
+  }
+}
+
+variable "region" {
+  type    = string
+  default = "eu-west-1"
+}
+
+variable "user" {
+  type = string
+}
+
+locals {
+  greeting = "hello ${local.name}"
+  name     = var.user
+}
+
+source "amazon-ebs" "ubuntu" {
+  region       = var.region
+  ssh_username = "ubuntu"
+}
+
+build {
+  sources = ["source.amazon-ebs.ubuntu"]
+
+  provisioner "shell" {
+    environment_vars  = ["GREETING=${local.greeting}"]
+    inline            = ["echo $GREETING in ${var.region}", "echo $${HOME}"]
+    expect_disconnect = true
+    max_retries       = 2
+  }
+
+  provisioner "shell" {
+    scripts      = ["a.sh", "b.sh"]
+    pause_before = "10s"
+  }
+
+  provisioner "file" {
+    content     = "hello"
+    destination = "/etc/motd"
+  }
+
+  provisioner "ansible" {
+    playbook_file   = "site.yml"
+    extra_arguments = ["-e", "foo=bar"]
+  }
+
+  provisioner "powershell" {
+    inline = ["Write-Host hi"]
+  }
+
+  post-processor "manifest" {}
+}
+`
+
+const jsonTemplate = `{
+  "variables": {"region": "us-east-1", "password": null},
+  "builders": [{
+    "type": "null",
+    "ssh_host": "10.0.0.5",
+    "ssh_username": "root",
+    "ssh_password": "{{user ` + "`password`" + `}}"
+  }],
+  "provisioners": [
+    {"type": "shell", "inline": ["echo {{user ` + "`region`" + `}} {{timestamp}} ${PATH}"]},
+    {"type": "file", "source": "motd", "destination": "/etc/", "direction": "download"}
+  ],
+  "post-processors": [{"type": "manifest"}]
+}`
+
+func readFile(files map[string]string) func(string) ([]byte, error) {
+	return func(name string) ([]byte, error) {
+		content, ok := files[name]
+		if !ok {
+			return nil, os.ErrNotExist
+		}
+		return []byte(content), nil
+	}
+}
+
+func TestParseHCL(t *testing.T) {
+	g := NewWithT(t)
+
+	template, err := ParseHCL("ubuntu", ".", map[string][]byte{"ubuntu.pkr.hcl": []byte(hclTemplate)})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(template.Variables).To(Equal([]Variable{
+		{Name: "region", Default: ptr.To("eu-west-1")},
+		{Name: "user"},
+	}))
+	g.Expect(template.Communicator).To(Equal(map[string]interface{}{"ssh_username": "ubuntu"}))
+	g.Expect(template.Provisioners).To(HaveLen(5))
+	g.Expect(template.Provisioners[0].Config).To(Equal(map[string]interface{}{
+		"environment_vars":  []interface{}{"GREETING=hello ${USER}"},
+		"inline":            []interface{}{"echo $GREETING in ${REGION:-eu-west-1}", "echo $${HOME}"},
+		"expect_disconnect": true,
+		"max_retries":       int64(2),
+	}))
+	g.Expect(template.Unsupported).To(ConsistOf(
+		"source amazon-ebs.ubuntu is not converted, create its InfraBuild",
+		"build post-processor blocks are not converted",
+	))
+}
+
+func TestParseHCLErrors(t *testing.T) {
+	g := NewWithT(t)
+
+	_, err := ParseHCL("broken", ".", map[string][]byte{"broken.pkr.hcl": []byte(`build {`)})
+	g.Expect(err).To(HaveOccurred())
+
+	template, err := ParseHCL("funcs", ".", map[string][]byte{"funcs.pkr.hcl": []byte(`
+locals {
+  stamp = timestamp()
+}
+data "amazon-ami" "ubuntu" {}
+build {
+  provisioner "shell" {
+    inline = ["echo ${local.stamp}"]
+  }
+}
+`)})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(template.Provisioners).To(HaveLen(1))
+	g.Expect(template.Provisioners[0].Config).To(BeEmpty())
+	g.Expect(template.Unsupported).To(ContainElements(
+		"local stamp cannot be converted",
+		"data blocks are not converted",
+	))
+}
+
+func TestParseJSON(t *testing.T) {
+	g := NewWithT(t)
+
+	template, err := ParseJSON("legacy", ".", []byte(jsonTemplate))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(template.Variables).To(Equal([]Variable{
+		{Name: "password"},
+		{Name: "region", Default: ptr.To("us-east-1")},
+	}))
+	g.Expect(template.Communicator).To(Equal(map[string]interface{}{
+		"ssh_host":     "10.0.0.5",
+		"ssh_username": "root",
+		"ssh_password": "${PASSWORD}",
+	}))
+	g.Expect(template.Provisioners[0].Config["inline"]).To(Equal([]interface{}{"echo ${REGION:-us-east-1} {{timestamp}} $${PATH}"}))
+	g.Expect(template.Unsupported).To(ConsistOf(
+		"post-processors are not converted",
+		"builder null is not converted, create its InfraBuild",
+		"template function {{timestamp}} is not converted",
+	))
+
+	_, err = ParseJSON("broken", ".", []byte(`{`))
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestConvertHCL(t *testing.T) {
+	g := NewWithT(t)
+
+	template, err := ParseHCL("ubuntu", ".", map[string][]byte{"ubuntu.pkr.hcl": []byte(hclTemplate)})
+	g.Expect(err).ToNot(HaveOccurred())
+	result, err := Convert(template, Options{
+		Name:              "ubuntu",
+		Namespace:         "images",
+		InfrastructureRef: corev1.ObjectReference{Kind: "AWSBuild", Name: "ubuntu"},
+		ReadFile: readFile(map[string]string{
+			"a.sh":     "#!/bin/bash\necho ${HOME}\n",
+			"b.sh":     "echo b",
+			"site.yml": "- hosts: all\n",
+		}),
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	build := result.Build
+	g.Expect(build.Name).To(Equal("ubuntu"))
+	g.Expect(build.Namespace).To(Equal("images"))
+	g.Expect(build.Spec.InfrastructureRef.Kind).To(Equal("AWSBuild"))
+	g.Expect(build.Spec.Connector.Credentials).To(BeNil())
+	g.Expect(result.Secret).To(BeNil())
+
+	// The shell provisioner with two scripts is converted to two provisioners.
+	g.Expect(build.Spec.Provisioners).To(HaveLen(5))
+	g.Expect(result.ConfigMaps).To(HaveLen(5))
+	for i, p := range build.Spec.Provisioners {
+		g.Expect(p.Type).To(Equal(buildv1.ProvisionerTypeShell))
+		g.Expect(p.RunConfigMapRef.Name).To(Equal(result.ConfigMaps[i].Name))
+	}
+	g.Expect(build.Spec.Provisioners[0].ExpectDisconnect).To(BeTrue())
+	g.Expect(build.Spec.Provisioners[0].Retries).To(Equal(ptr.To[int32](2)))
+	g.Expect(build.Spec.Provisioners[1].Retries).To(Equal(ptr.To[int32](0)))
+
+	g.Expect(result.ConfigMaps[0].Data[ScriptKey]).To(Equal(`export GREETING='hello ${USER}'
+forge_script=$(mktemp)
+cat > "$forge_script" <<'FORGE_EOF'
+#!/bin/sh -e
+echo $GREETING in ${REGION:-eu-west-1}
+echo $${HOME}
+FORGE_EOF
+chmod +x "$forge_script"
+"$forge_script"
+forge_rc=$?
+rm -f "$forge_script"
+exit $forge_rc
+`))
+	g.Expect(result.ConfigMaps[1].Data[ScriptKey]).To(ContainSubstring("#!/bin/bash\necho $${HOME}\nFORGE_EOF\n"))
+	g.Expect(result.ConfigMaps[3].Data[ScriptKey]).To(ContainSubstring("base64 -d > '/etc/motd' <<'FORGE_EOF'\naGVsbG8=\nFORGE_EOF\n"))
+	g.Expect(result.ConfigMaps[4].Data[ScriptKey]).To(ContainSubstring(`ansible-playbook -i 'localhost,' -c local '-e' 'foo=bar' "$forge_dir/playbook.yml"`))
+
+	g.Expect(result.Unsupported).To(ContainElements(
+		"ssh_username is not converted, set it on the InfraBuild which creates the credentials Secret",
+		"provisioner 1 (shell): pause_before is not converted",
+		"provisioner 3 (ansible): the playbook runs on the machine with a local connection, ansible must be installed on it",
+		"provisioner 4 (powershell): powershell provisioners are not converted",
+	))
+}
+
+func TestConvertJSON(t *testing.T) {
+	g := NewWithT(t)
+
+	template, err := ParseJSON("legacy", ".", []byte(jsonTemplate))
+	g.Expect(err).ToNot(HaveOccurred())
+	result, err := Convert(template, Options{Name: "legacy", ReadFile: readFile(nil)})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	g.Expect(result.Secret).ToNot(BeNil())
+	g.Expect(result.Secret.Name).To(Equal("legacy-ssh-credentials"))
+	g.Expect(result.Secret.StringData).To(Equal(map[string]string{
+		"host":     "10.0.0.5",
+		"username": "root",
+		"password": "${PASSWORD}",
+	}))
+	g.Expect(result.Build.Spec.Connector.Credentials.Name).To(Equal("legacy-ssh-credentials"))
+	g.Expect(result.Build.Spec.Provisioners).To(HaveLen(1))
+	g.Expect(result.Unsupported).To(ContainElement("provisioner 1 (file): downloads are not converted"))
+
+	_, err = Convert(template, Options{})
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestHeredocDelimiter(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(heredocDelimiter("echo hello")).To(Equal("FORGE_EOF"))
+	g.Expect(heredocDelimiter("cat <<FORGE_EOF\nhello\nFORGE_EOF\nFORGE_EOF_1")).To(Equal("FORGE_EOF_2"))
+}
+
+func TestVariableName(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(VariableName("aws_region")).To(Equal("AWS_REGION"))
+	g.Expect(VariableName("image-name")).To(Equal("IMAGE_NAME"))
+	g.Expect(VariableName("1st")).To(Equal("_1ST"))
+}
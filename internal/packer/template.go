@@ -0,0 +1,168 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package packer converts Packer templates to Forge Build manifests.
+package packer
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Template is a Packer template, reduced to what is converted to a Build.
+type Template struct {
+	// Name is the name of the template, e.g. its file name.
+	Name string
+	// Dir is the directory the relative paths of the template are relative to.
+	Dir string
+	// Variables are the user variables of the template.
+	Variables []Variable
+	// Communicator are the ssh_* settings of the builder.
+	Communicator map[string]interface{}
+	// Provisioners are the provisioners of the template, in order.
+	Provisioners []Provisioner
+	// Unsupported lists the constructs of the template which are not converted.
+	Unsupported []string
+}
+
+// Variable is a Packer user variable.
+type Variable struct {
+	Name string
+	// Default is the default value of the variable, nil if the variable is required.
+	Default *string
+}
+
+// Provisioner is a Packer provisioner.
+type Provisioner struct {
+	Type string
+	// Config is the configuration of the provisioner, with the variables replaced by placeholders.
+	Config map[string]interface{}
+}
+
+// unsupportedf records a construct of the template which is not converted.
+func (t *Template) unsupportedf(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	for _, u := range t.Unsupported {
+		if u == msg {
+			return
+		}
+	}
+	t.Unsupported = append(t.Unsupported, msg)
+}
+
+var invalidVariableChars = regexp.MustCompile(`[^A-Z0-9_]`)
+
+// VariableName returns the forgectl template variable name of a Packer variable.
+func VariableName(name string) string {
+	name = invalidVariableChars.ReplaceAllString(strings.ToUpper(name), "_")
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "_" + name
+	}
+	return name
+}
+
+// placeholder returns the forgectl template placeholder of a variable.
+func placeholder(name string, def *string) string {
+	if def == nil {
+		return fmt.Sprintf("${%s}", VariableName(name))
+	}
+	return fmt.Sprintf("${%s:-%s}", VariableName(name), *def)
+}
+
+// placeholderSentinel replaces the $ of the placeholders while the expressions of a template
+// are evaluated, so that they are told apart from the ${ sequences of the template strings.
+const placeholderSentinel = "\ue000"
+
+// restorePlaceholders escapes the ${ sequences of the strings of a value and restores its placeholders.
+func restorePlaceholders(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return strings.ReplaceAll(EscapePlaceholders(v), placeholderSentinel+"{", "${")
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i := range v {
+			out[i] = restorePlaceholders(v[i])
+		}
+		return out
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k := range v {
+			out[k] = restorePlaceholders(v[k])
+		}
+		return out
+	}
+	return value
+}
+
+// EscapePlaceholders escapes the ${ sequences of a text, e.g. a shell script,
+// so that forgectl does not render them as template variables.
+func EscapePlaceholders(s string) string {
+	return strings.ReplaceAll(s, "${", "$${")
+}
+
+// getString returns a string setting of a provisioner configuration.
+func getString(config map[string]interface{}, key string) (string, bool) {
+	switch v := config[key].(type) {
+	case string:
+		return v, true
+	case int64:
+		return strconv.FormatInt(v, 10), true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case bool:
+		return strconv.FormatBool(v), true
+	}
+	return "", false
+}
+
+// getStrings returns a list of strings setting of a provisioner configuration.
+func getStrings(config map[string]interface{}, key string) ([]string, bool) {
+	values, ok := config[key].([]interface{})
+	if !ok {
+		return nil, false
+	}
+	out := make([]string, 0, len(values))
+	for _, v := range values {
+		s, ok := getString(map[string]interface{}{"v": v}, "v")
+		if !ok {
+			return nil, false
+		}
+		out = append(out, s)
+	}
+	return out, true
+}
+
+// getBool returns a boolean setting of a provisioner configuration.
+func getBool(config map[string]interface{}, key string) (bool, bool) {
+	s, ok := getString(config, key)
+	if !ok {
+		return false, false
+	}
+	b, err := strconv.ParseBool(s)
+	return b, err == nil
+}
+
+// getInt returns an integer setting of a provisioner configuration.
+func getInt(config map[string]interface{}, key string) (int64, bool) {
+	s, ok := getString(config, key)
+	if !ok {
+		return 0, false
+	}
+	i, err := strconv.ParseInt(s, 10, 64)
+	return i, err == nil
+}
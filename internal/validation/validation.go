@@ -0,0 +1,138 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package validation runs the Build validations on the infrastructure machine.
+package validation
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+
+	buildv1 "github.com/forge-build/forge/api/v1alpha1"
+	"github.com/forge-build/forge/pkg/ssh"
+)
+
+const (
+	// maxMessageLength is the maximum length of the output kept in a validation result.
+	maxMessageLength = 1024
+)
+
+// Command returns the shell command asserting the validation on the machine.
+func Command(v buildv1.ValidationSpec) (string, error) {
+	if v.Type != buildv1.ValidationTypeCommand && v.Target == "" {
+		return "", errors.Errorf("validation %q of type %s requires a target", v.Name, v.Type)
+	}
+	target := Quote(v.Target)
+
+	switch v.Type {
+	case buildv1.ValidationTypePackageInstalled:
+		return fmt.Sprintf("if command -v dpkg-query >/dev/null 2>&1; then dpkg-query -W -f='${Status}' %[1]s 2>&1 | grep -q 'install ok installed'; "+
+			"elif command -v rpm >/dev/null 2>&1; then rpm -q %[1]s; "+
+			"elif command -v apk >/dev/null 2>&1; then apk info -e %[1]s; "+
+			"else echo 'no supported package manager found' >&2; exit 1; fi", target), nil
+	case buildv1.ValidationTypeServiceEnabled:
+		return fmt.Sprintf("systemctl is-enabled %s", target), nil
+	case buildv1.ValidationTypeServiceRunning:
+		return fmt.Sprintf("systemctl is-active %s", target), nil
+	case buildv1.ValidationTypePortListening:
+		port, err := strconv.ParseUint(v.Target, 10, 16)
+		if err != nil {
+			return "", errors.Wrapf(err, "validation %q has an invalid port %q", v.Name, v.Target)
+		}
+		return fmt.Sprintf("if command -v ss >/dev/null 2>&1; then ss -H -ltn 'sport = :%[1]d' | grep -q .; "+
+			"else netstat -ltn | awk '{print $4}' | grep -Eq ':%[1]d$'; fi", port), nil
+	case buildv1.ValidationTypeFileExists:
+		return fmt.Sprintf("test -e %s", target), nil
+	case buildv1.ValidationTypeCommand:
+		if ptr.Deref(v.Run, "") == "" {
+			return "", errors.Errorf("validation %q of type %s requires a command to run", v.Name, v.Type)
+		}
+		return *v.Run, nil
+	default:
+		return "", errors.Errorf("validation %q has an unsupported type %q", v.Name, v.Type)
+	}
+}
+
+// Run runs the validations on the machine and returns the report.
+// A validation fails if its command can not be built, fails to run, or exits with a non-zero status.
+func Run(c ssh.Client, validations []buildv1.ValidationSpec) *buildv1.ValidationReport {
+	report := &buildv1.ValidationReport{}
+	for _, v := range validations {
+		result := buildv1.ValidationResult{
+			Name: v.Name,
+			Type: v.Type,
+		}
+
+		message, passed := run(c, v)
+		if passed {
+			result.Passed = true
+			report.Passed++
+		} else {
+			result.Message = truncate(message)
+			report.Failed++
+		}
+		report.Results = append(report.Results, result)
+	}
+	report.CompletionTime = ptr.To(metav1.Now())
+	return report
+}
+
+// run runs a single validation and returns the failure message, if any.
+func run(c ssh.Client, v buildv1.ValidationSpec) (string, bool) {
+	command, err := Command(v)
+	if err != nil {
+		return err.Error(), false
+	}
+
+	output := &bytes.Buffer{}
+	if err := c.Run(command, output, output); err != nil {
+		if out := strings.TrimSpace(output.String()); out != "" {
+			return fmt.Sprintf("%s: %s", err, out), false
+		}
+		return err.Error(), false
+	}
+	return "", true
+}
+
+// Failed returns the names of the failed validations which are not allowed to fail.
+// The results of the report are in the order of the validations, names are not required to be unique.
+func Failed(validations []buildv1.ValidationSpec, report *buildv1.ValidationReport) []string {
+	var failed []string
+	for i, r := range report.Results {
+		if !r.Passed && (i >= len(validations) || !validations[i].AllowFail) {
+			failed = append(failed, r.Name)
+		}
+	}
+	return failed
+}
+
+// Quote quotes s to be used as a single word in a POSIX shell command.
+func Quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func truncate(s string) string {
+	if len(s) <= maxMessageLength {
+		return s
+	}
+	return "..." + s[len(s)-maxMessageLength:]
+}
@@ -0,0 +1,162 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package validation
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"k8s.io/utils/ptr"
+
+	buildv1 "github.com/forge-build/forge/api/v1alpha1"
+	"github.com/forge-build/forge/pkg/ssh"
+)
+
+func TestCommand(t *testing.T) {
+	tests := []struct {
+		name     string
+		spec     buildv1.ValidationSpec
+		contains string
+		wantErr  bool
+	}{
+		{
+			name:     "package installed",
+			spec:     buildv1.ValidationSpec{Name: "nginx", Type: buildv1.ValidationTypePackageInstalled, Target: "nginx"},
+			contains: "dpkg-query -W -f='${Status}' 'nginx'",
+		},
+		{
+			name:     "service enabled",
+			spec:     buildv1.ValidationSpec{Name: "sshd", Type: buildv1.ValidationTypeServiceEnabled, Target: "ssh"},
+			contains: "systemctl is-enabled 'ssh'",
+		},
+		{
+			name:     "service running",
+			spec:     buildv1.ValidationSpec{Name: "sshd", Type: buildv1.ValidationTypeServiceRunning, Target: "ssh"},
+			contains: "systemctl is-active 'ssh'",
+		},
+		{
+			name:     "port listening",
+			spec:     buildv1.ValidationSpec{Name: "ssh port", Type: buildv1.ValidationTypePortListening, Target: "22"},
+			contains: "sport = :22",
+		},
+		{
+			name:    "invalid port",
+			spec:    buildv1.ValidationSpec{Name: "ssh port", Type: buildv1.ValidationTypePortListening, Target: "22; reboot"},
+			wantErr: true,
+		},
+		{
+			name:     "file exists with quote",
+			spec:     buildv1.ValidationSpec{Name: "file", Type: buildv1.ValidationTypeFileExists, Target: "/etc/it's"},
+			contains: `test -e '/etc/it'\''s'`,
+		},
+		{
+			name:     "command",
+			spec:     buildv1.ValidationSpec{Name: "kernel", Type: buildv1.ValidationTypeCommand, Run: ptr.To("uname -r | grep -q 6.8")},
+			contains: "uname -r | grep -q 6.8",
+		},
+		{
+			name:    "command without run",
+			spec:    buildv1.ValidationSpec{Name: "kernel", Type: buildv1.ValidationTypeCommand},
+			wantErr: true,
+		},
+		{
+			name:    "missing target",
+			spec:    buildv1.ValidationSpec{Name: "file", Type: buildv1.ValidationTypeFileExists},
+			wantErr: true,
+		},
+		{
+			name:    "unsupported type",
+			spec:    buildv1.ValidationSpec{Name: "foo", Type: "Foo", Target: "bar"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			command, err := Command(tt.spec)
+			if tt.wantErr {
+				g.Expect(err).To(HaveOccurred())
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(command).To(ContainSubstring(tt.contains))
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	g := NewWithT(t)
+
+	c := &ssh.MockSSHClient{
+		MockRun: func(command string, stdout io.Writer, _ io.Writer) error {
+			if strings.Contains(command, "nginx") {
+				_, _ = io.WriteString(stdout, "disabled\n")
+				return errors.New("Process exited with status 1")
+			}
+			return nil
+		},
+	}
+	validations := []buildv1.ValidationSpec{
+		{Name: "ssh", Type: buildv1.ValidationTypeServiceEnabled, Target: "ssh"},
+		{Name: "nginx", Type: buildv1.ValidationTypeServiceEnabled, Target: "nginx"},
+		{Name: "optional nginx", Type: buildv1.ValidationTypeServiceRunning, Target: "nginx", AllowFail: true},
+		{Name: "broken", Type: buildv1.ValidationTypeCommand},
+	}
+
+	report := Run(c, validations)
+	g.Expect(report.Passed).To(Equal(int32(1)))
+	g.Expect(report.Failed).To(Equal(int32(3)))
+	g.Expect(report.CompletionTime).NotTo(BeNil())
+	g.Expect(report.Results).To(HaveLen(4))
+	g.Expect(report.Results[0].Passed).To(BeTrue())
+	g.Expect(report.Results[1].Passed).To(BeFalse())
+	g.Expect(report.Results[1].Message).To(Equal("Process exited with status 1: disabled"))
+	g.Expect(report.Results[3].Message).To(ContainSubstring("requires a command"))
+
+	g.Expect(Failed(validations, report)).To(Equal([]string{"nginx", "broken"}))
+}
+
+func TestFailedDuplicateNames(t *testing.T) {
+	g := NewWithT(t)
+
+	validations := []buildv1.ValidationSpec{
+		{Name: "ssh", Type: buildv1.ValidationTypeServiceRunning, Target: "ssh"},
+		{Name: "ssh", Type: buildv1.ValidationTypePortListening, Target: "22", AllowFail: true},
+	}
+	report := &buildv1.ValidationReport{Results: []buildv1.ValidationResult{
+		{Name: "ssh", Type: buildv1.ValidationTypeServiceRunning},
+		{Name: "ssh", Type: buildv1.ValidationTypePortListening},
+	}}
+
+	// The validation allowed to fail does not hide the other one with the same name.
+	g.Expect(Failed(validations, report)).To(Equal([]string{"ssh"}))
+	report.Results[0].Passed = true
+	g.Expect(Failed(validations, report)).To(BeEmpty())
+}
+
+func TestTruncate(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(truncate("short")).To(Equal("short"))
+	long := strings.Repeat("a", maxMessageLength) + "tail"
+	g.Expect(truncate(long)).To(HaveLen(maxMessageLength + 3))
+	g.Expect(truncate(long)).To(HaveSuffix("tail"))
+}
@@ -0,0 +1,388 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package webhooks implements the admission webhooks of the Forge API types.
+package webhooks
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1validation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	buildv1 "github.com/forge-build/forge/api/v1alpha1"
+	"github.com/forge-build/forge/internal/notification"
+	"github.com/forge-build/forge/internal/validation"
+)
+
+const (
+	// ConnectorTypeSSH is the only connector type supported by the Build controller.
+	ConnectorTypeSSH = "ssh"
+
+	// MaxMatrixVariants is the maximum number of variants of a matrix Build.
+	MaxMatrixVariants = 256
+)
+
+// Build implements a validating webhook for Build.
+type Build struct{}
+
+// SetupWebhookWithManager sets up the Build webhook with the manager.
+func (webhook *Build) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&buildv1.Build{}).
+		WithValidator(webhook).
+		Complete()
+}
+
+//+kubebuilder:webhook:verbs=create;update,path=/validate-forge-build-v1alpha1-build,mutating=false,failurePolicy=fail,matchPolicy=Equivalent,groups=forge.build,resources=builds,versions=v1alpha1,name=validation.build.forge.build,sideEffects=None,admissionReviewVersions=v1
+
+var _ webhook.CustomValidator = &Build{}
+
+// ValidateCreate implements webhook.CustomValidator so a webhook will be registered for the type.
+func (webhook *Build) ValidateCreate(_ context.Context, obj runtime.Object) (admission.Warnings, error) {
+	build, ok := obj.(*buildv1.Build)
+	if !ok {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("expected a Build but got a %T", obj))
+	}
+	return nil, ValidateBuild(nil, build)
+}
+
+// ValidateUpdate implements webhook.CustomValidator so a webhook will be registered for the type.
+func (webhook *Build) ValidateUpdate(_ context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	oldBuild, ok := oldObj.(*buildv1.Build)
+	if !ok {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("expected a Build but got a %T", oldObj))
+	}
+	newBuild, ok := newObj.(*buildv1.Build)
+	if !ok {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("expected a Build but got a %T", newObj))
+	}
+	return nil, ValidateBuild(oldBuild, newBuild)
+}
+
+// ValidateDelete implements webhook.CustomValidator so a webhook will be registered for the type.
+func (webhook *Build) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// ValidateBuild validates a Build, oldBuild is nil on creation.
+// It is shared by the admission webhook and forgectl to validate manifests offline.
+func ValidateBuild(oldBuild, newBuild *buildv1.Build) error {
+	allErrs := validateBuildSpec(field.NewPath("spec"), &newBuild.Spec)
+	if from := newBuild.Spec.BaseImageFrom; from != nil && from.Name == newBuild.Name {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "baseImageFrom", "name"), from.Name, "cannot be the Build itself"))
+	}
+	if oldBuild != nil {
+		allErrs = append(allErrs, validateBuildUpdate(field.NewPath("spec"), &oldBuild.Spec, &newBuild.Spec)...)
+	}
+
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(buildv1.GroupVersion.WithKind("Build").GroupKind(), newBuild.Name, allErrs)
+}
+
+func validateBuildSpec(path *field.Path, spec *buildv1.BuildSpec) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if spec.InfrastructureRef == nil {
+		allErrs = append(allErrs, field.Required(path.Child("infrastructureRef"), "must be set"))
+	} else {
+		if spec.InfrastructureRef.APIVersion == "" {
+			allErrs = append(allErrs, field.Required(path.Child("infrastructureRef", "apiVersion"), "must be set"))
+		}
+		if spec.InfrastructureRef.Kind == "" {
+			allErrs = append(allErrs, field.Required(path.Child("infrastructureRef", "kind"), "must be set"))
+		}
+		if spec.InfrastructureRef.Name == "" {
+			allErrs = append(allErrs, field.Required(path.Child("infrastructureRef", "name"), "must be set"))
+		}
+	}
+
+	if spec.Connector.Type != ConnectorTypeSSH {
+		allErrs = append(allErrs, field.NotSupported(path.Child("connector", "type"), spec.Connector.Type, []string{ConnectorTypeSSH}))
+	}
+
+	for i := range spec.Provisioners {
+		allErrs = append(allErrs, validateProvisioner(path.Child("provisioners").Index(i), &spec.Provisioners[i])...)
+	}
+
+	if spec.ProvisionerRuntime != nil {
+		allErrs = append(allErrs, validateProvisionerRuntime(path.Child("provisionerRuntime"), spec.ProvisionerRuntime)...)
+	}
+
+	if spec.BaseImageFrom != nil {
+		allErrs = append(allErrs, validateBaseImageFrom(path.Child("baseImageFrom"), spec.BaseImageFrom)...)
+	}
+
+	if spec.Matrix != nil {
+		allErrs = append(allErrs, validateMatrix(path.Child("matrix"), spec.Matrix)...)
+	}
+
+	endpoints := map[string]bool{}
+	for i := range spec.Notifications {
+		nPath := path.Child("notifications").Index(i)
+		if endpoints[spec.Notifications[i].Name] {
+			allErrs = append(allErrs, field.Duplicate(nPath.Child("name"), spec.Notifications[i].Name))
+		}
+		endpoints[spec.Notifications[i].Name] = true
+		allErrs = append(allErrs, validateNotification(nPath, &spec.Notifications[i])...)
+	}
+
+	if spec.ArtifactRetention != nil {
+		allErrs = append(allErrs, validateRetention(path.Child("artifactRetention"), spec.ArtifactRetention)...)
+	}
+
+	names := map[string]bool{}
+	for i, v := range spec.Validations {
+		vPath := path.Child("validations").Index(i)
+		if names[v.Name] {
+			allErrs = append(allErrs, field.Duplicate(vPath.Child("name"), v.Name))
+		}
+		names[v.Name] = true
+		if _, err := validation.Command(v); err != nil {
+			allErrs = append(allErrs, field.Invalid(vPath, v.Name, err.Error()))
+		}
+	}
+
+	return allErrs
+}
+
+func validateProvisioner(path *field.Path, p *buildv1.ProvisionerSpec) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if p.Retries != nil && *p.Retries < 0 {
+		allErrs = append(allErrs, field.Invalid(path.Child("retries"), *p.Retries, "must be greater than or equal to 0"))
+	}
+	if p.RetryBackoff != nil && p.RetryBackoff.Duration < 0 {
+		allErrs = append(allErrs, field.Invalid(path.Child("retryBackoff"), p.RetryBackoff.Duration.String(), "must be greater than or equal to 0"))
+	}
+	if p.ExitCodePolicy != nil {
+		allErrs = append(allErrs, validateExitCodePolicy(path.Child("exitCodePolicy"), p.ExitCodePolicy)...)
+	}
+
+	switch p.Type {
+	case buildv1.ProvisionerTypeShell:
+		if p.Run == nil && p.RunConfigMapRef == nil {
+			allErrs = append(allErrs, field.Required(path.Child("run"), "one of run or runConfigMapRef must be set"))
+		}
+		if p.Run != nil && p.RunConfigMapRef != nil {
+			allErrs = append(allErrs, field.Forbidden(path.Child("runConfigMapRef"), "cannot be set together with run"))
+		}
+	case buildv1.ProvisionerTypeReboot:
+		if p.RunConfigMapRef != nil {
+			allErrs = append(allErrs, field.Forbidden(path.Child("runConfigMapRef"), "is not supported by built-in/reboot provisioners"))
+		}
+	case buildv1.ProvisionerTypeExternal:
+		if p.Ref == nil {
+			allErrs = append(allErrs, field.Required(path.Child("ref"), "must be set for external provisioners"))
+		}
+		if p.Runtime != nil {
+			allErrs = append(allErrs, field.Forbidden(path.Child("runtime"), "is not supported by external provisioners"))
+		}
+	default:
+		allErrs = append(allErrs, field.NotSupported(path.Child("type"), p.Type, []buildv1.ProvisionerType{
+			buildv1.ProvisionerTypeShell,
+			buildv1.ProvisionerTypeReboot,
+			buildv1.ProvisionerTypeExternal,
+		}))
+	}
+
+	if p.Runtime != nil {
+		allErrs = append(allErrs, validateProvisionerRuntime(path.Child("runtime"), p.Runtime)...)
+	}
+
+	return allErrs
+}
+
+func validateExitCodePolicy(path *field.Path, policy *buildv1.ExitCodePolicy) field.ErrorList {
+	var allErrs field.ErrorList
+
+	// An exit code has a single action, 0 is always a success.
+	actions := map[int32]string{}
+	for _, list := range []struct {
+		name  string
+		codes []int32
+	}{{"success", policy.Success}, {"retry", policy.Retry}, {"fail", policy.Fail}} {
+		for i, code := range list.codes {
+			codePath := path.Child(list.name).Index(i)
+			switch {
+			case code <= 0 || code > 255:
+				allErrs = append(allErrs, field.Invalid(codePath, code, "must be between 1 and 255"))
+			case actions[code] != "" && actions[code] != list.name:
+				allErrs = append(allErrs, field.Invalid(codePath, code, fmt.Sprintf("is already in %s", actions[code])))
+			default:
+				actions[code] = list.name
+			}
+		}
+	}
+
+	return allErrs
+}
+
+func validateProvisionerRuntime(path *field.Path, config *buildv1.ProvisionerRuntimeConfig) field.ErrorList {
+	var allErrs field.ErrorList
+
+	for i, secret := range config.ImagePullSecrets {
+		if secret.Name == "" {
+			allErrs = append(allErrs, field.Required(path.Child("imagePullSecrets").Index(i).Child("name"), "must be set"))
+		}
+	}
+
+	allErrs = append(allErrs, metav1validation.ValidateLabels(config.NodeSelector, path.Child("nodeSelector"))...)
+
+	if config.Resources != nil {
+		for name, request := range config.Resources.Requests {
+			if limit, ok := config.Resources.Limits[name]; ok && request.Cmp(limit) > 0 {
+				allErrs = append(allErrs, field.Invalid(path.Child("resources", "requests").Key(string(name)), request.String(),
+					fmt.Sprintf("must be less than or equal to the %s limit", name)))
+			}
+		}
+	}
+
+	return allErrs
+}
+
+func validateBaseImageFrom(path *field.Path, from *buildv1.BaseImageSource) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if from.Name == "" {
+		allErrs = append(allErrs, field.Required(path.Child("name"), "must be set"))
+	}
+	fieldPath := from.GetFieldPath()
+	if !strings.HasPrefix(fieldPath, "spec.") || strings.Contains(fieldPath, "..") || strings.HasSuffix(fieldPath, ".") {
+		allErrs = append(allErrs, field.Invalid(path.Child("fieldPath"), fieldPath, "must be a field of the InfraBuild spec, e.g. spec.sourceImage"))
+	}
+
+	return allErrs
+}
+
+func validateNotification(path *field.Path, n *buildv1.NotificationSpec) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if err := notification.ValidateURL(n.URL); err != nil {
+		allErrs = append(allErrs, field.Invalid(path.Child("url"), n.URL, err.Error()))
+	}
+	if ref := n.SigningSecretRef; ref != nil {
+		if ref.Name == "" {
+			allErrs = append(allErrs, field.Required(path.Child("signingSecretRef", "name"), "must be set"))
+		}
+		if ref.Key == "" {
+			allErrs = append(allErrs, field.Required(path.Child("signingSecretRef", "key"), "must be set"))
+		}
+	}
+	phases := []string{
+		string(buildv1.BuildPhasePending),
+		string(buildv1.BuildPhaseBuilding),
+		string(buildv1.BuildPhaseTerminating),
+		string(buildv1.BuildPhaseCompleted),
+		string(buildv1.BuildPhaseFailed),
+		string(buildv1.BuildPhaseUnknown),
+	}
+	for i, p := range n.Phases {
+		if !slices.Contains(phases, string(p)) {
+			allErrs = append(allErrs, field.NotSupported(path.Child("phases").Index(i), p, phases))
+		}
+	}
+	if n.MaxRetries != nil && *n.MaxRetries < 0 {
+		allErrs = append(allErrs, field.Invalid(path.Child("maxRetries"), *n.MaxRetries, "must be greater than or equal to 0"))
+	}
+
+	return allErrs
+}
+
+func validateRetention(path *field.Path, retention *buildv1.RetentionPolicy) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if retention.KeepLast != nil && *retention.KeepLast < 1 {
+		allErrs = append(allErrs, field.Invalid(path.Child("keepLast"), *retention.KeepLast, "must be greater than or equal to 1"))
+	}
+	if retention.MaxAge != nil && retention.MaxAge.Duration <= 0 {
+		allErrs = append(allErrs, field.Invalid(path.Child("maxAge"), retention.MaxAge.Duration.String(), "must be greater than 0"))
+	}
+
+	return allErrs
+}
+
+func validateMatrix(path *field.Path, matrix *buildv1.MatrixSpec) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if len(matrix.Axes) == 0 {
+		allErrs = append(allErrs, field.Required(path.Child("axes"), "must have at least one axis"))
+	}
+
+	total := 1
+	names := map[string]bool{}
+	for i, axis := range matrix.Axes {
+		axisPath := path.Child("axes").Index(i)
+		if axis.Name == "" {
+			allErrs = append(allErrs, field.Required(axisPath.Child("name"), "must be set"))
+		}
+		if names[axis.Name] {
+			allErrs = append(allErrs, field.Duplicate(axisPath.Child("name"), axis.Name))
+		}
+		names[axis.Name] = true
+
+		if len(axis.Values) == 0 {
+			allErrs = append(allErrs, field.Required(axisPath.Child("values"), "must have at least one value"))
+		}
+		values := map[string]bool{}
+		for j, value := range axis.Values {
+			if values[value] {
+				allErrs = append(allErrs, field.Duplicate(axisPath.Child("values").Index(j), value))
+			}
+			values[value] = true
+		}
+		total *= len(axis.Values)
+	}
+
+	if total > MaxMatrixVariants {
+		allErrs = append(allErrs, field.TooMany(path.Child("axes"), total, MaxMatrixVariants))
+	}
+	if matrix.Quorum != nil && (*matrix.Quorum < 1 || int(*matrix.Quorum) > total) {
+		allErrs = append(allErrs, field.Invalid(path.Child("quorum"), *matrix.Quorum,
+			fmt.Sprintf("must be between 1 and the number of variants, %d", total)))
+	}
+
+	return allErrs
+}
+
+func validateBuildUpdate(path *field.Path, oldSpec, newSpec *buildv1.BuildSpec) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if oldSpec.InfrastructureRef != nil && newSpec.InfrastructureRef != nil &&
+		oldSpec.InfrastructureRef.GroupVersionKind().GroupKind() != newSpec.InfrastructureRef.GroupVersionKind().GroupKind() {
+		allErrs = append(allErrs, field.Forbidden(path.Child("infrastructureRef"), "kind cannot be changed"))
+	}
+	if oldSpec.InfrastructureRef != nil && newSpec.InfrastructureRef != nil &&
+		oldSpec.InfrastructureRef.Name != newSpec.InfrastructureRef.Name {
+		allErrs = append(allErrs, field.Forbidden(path.Child("infrastructureRef", "name"), "cannot be changed"))
+	}
+	if !reflect.DeepEqual(oldSpec.Matrix, newSpec.Matrix) {
+		allErrs = append(allErrs, field.Forbidden(path.Child("matrix"), "cannot be changed"))
+	}
+
+	return allErrs
+}
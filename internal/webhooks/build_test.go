@@ -0,0 +1,346 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+
+	buildv1 "github.com/forge-build/forge/api/v1alpha1"
+)
+
+func newBuild() *buildv1.Build {
+	return &buildv1.Build{
+		Spec: buildv1.BuildSpec{
+			Connector: buildv1.ConnectorSpec{Type: ConnectorTypeSSH},
+			InfrastructureRef: &corev1.ObjectReference{
+				APIVersion: "infrastructure.forge.build/v1alpha1",
+				Kind:       "AWSBuild",
+				Name:       "ubuntu-2204",
+			},
+			Provisioners: []buildv1.ProvisionerSpec{
+				{Type: buildv1.ProvisionerTypeShell, Run: ptr.To("echo hello")},
+				{Type: buildv1.ProvisionerTypeReboot},
+			},
+		},
+	}
+}
+
+func TestValidateBuild(t *testing.T) {
+	tests := []struct {
+		name    string
+		mutate  func(*buildv1.Build)
+		wantErr bool
+	}{
+		{
+			name:   "valid build",
+			mutate: func(*buildv1.Build) {},
+		},
+		{
+			name:    "missing infrastructureRef",
+			mutate:  func(b *buildv1.Build) { b.Spec.InfrastructureRef = nil },
+			wantErr: true,
+		},
+		{
+			name:    "unsupported connector",
+			mutate:  func(b *buildv1.Build) { b.Spec.Connector.Type = "winrm" },
+			wantErr: true,
+		},
+		{
+			name:    "shell provisioner without script",
+			mutate:  func(b *buildv1.Build) { b.Spec.Provisioners[0].Run = nil },
+			wantErr: true,
+		},
+		{
+			name: "shell provisioner with run and runConfigMapRef",
+			mutate: func(b *buildv1.Build) {
+				b.Spec.Provisioners[0].RunConfigMapRef = &corev1.ObjectReference{Name: "script"}
+			},
+			wantErr: true,
+		},
+		{
+			name:    "negative retries",
+			mutate:  func(b *buildv1.Build) { b.Spec.Provisioners[0].Retries = ptr.To[int32](-1) },
+			wantErr: true,
+		},
+		{
+			name: "exit code policy",
+			mutate: func(b *buildv1.Build) {
+				b.Spec.Provisioners[0].ExitCodePolicy = &buildv1.ExitCodePolicy{Retry: []int32{100}, Fail: []int32{1, 2}, Default: buildv1.ExitCodeActionFail}
+				b.Spec.Provisioners[0].RetryBackoff = &metav1.Duration{Duration: time.Minute}
+			},
+		},
+		{
+			name: "exit code policy with an invalid exit code",
+			mutate: func(b *buildv1.Build) {
+				b.Spec.Provisioners[0].ExitCodePolicy = &buildv1.ExitCodePolicy{Success: []int32{256}}
+			},
+			wantErr: true,
+		},
+		{
+			name: "exit code policy with an exit code in several lists",
+			mutate: func(b *buildv1.Build) {
+				b.Spec.Provisioners[0].ExitCodePolicy = &buildv1.ExitCodePolicy{Retry: []int32{100}, Fail: []int32{100}}
+			},
+			wantErr: true,
+		},
+		{
+			name:    "negative retry backoff",
+			mutate:  func(b *buildv1.Build) { b.Spec.Provisioners[0].RetryBackoff = &metav1.Duration{Duration: -time.Second} },
+			wantErr: true,
+		},
+		{
+			name:    "external provisioner without ref",
+			mutate:  func(b *buildv1.Build) { b.Spec.Provisioners[1].Type = buildv1.ProvisionerTypeExternal },
+			wantErr: true,
+		},
+		{
+			name: "duplicate validation names",
+			mutate: func(b *buildv1.Build) {
+				b.Spec.Validations = []buildv1.ValidationSpec{
+					{Name: "nginx", Type: buildv1.ValidationTypePackageInstalled, Target: "nginx"},
+					{Name: "nginx", Type: buildv1.ValidationTypeServiceRunning, Target: "nginx"},
+				}
+			},
+			wantErr: true,
+		},
+		{
+			name: "base image",
+			mutate: func(b *buildv1.Build) {
+				b.Spec.BaseImageFrom = &buildv1.BaseImageSource{Name: "ubuntu-base", FieldPath: "spec.image.id"}
+			},
+		},
+		{
+			name: "base image from the Build itself",
+			mutate: func(b *buildv1.Build) {
+				b.Name = "ubuntu"
+				b.Spec.BaseImageFrom = &buildv1.BaseImageSource{Name: "ubuntu"}
+			},
+			wantErr: true,
+		},
+		{
+			name: "base image set outside of the InfraBuild spec",
+			mutate: func(b *buildv1.Build) {
+				b.Spec.BaseImageFrom = &buildv1.BaseImageSource{Name: "ubuntu-base", FieldPath: "status.image"}
+			},
+			wantErr: true,
+		},
+		{
+			name: "notifications",
+			mutate: func(b *buildv1.Build) {
+				b.Spec.Notifications = []buildv1.NotificationSpec{{
+					Name:             "ci",
+					URL:              "https://ci.example.com/hooks/forge",
+					SigningSecretRef: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "ci-hook"}, Key: "key"},
+					Events:           []buildv1.NotificationEvent{buildv1.NotificationPhaseChanged},
+					Phases:           []buildv1.BuildPhase{buildv1.BuildPhaseCompleted, buildv1.BuildPhaseFailed},
+				}}
+			},
+		},
+		{
+			name: "notification with an invalid URL",
+			mutate: func(b *buildv1.Build) {
+				b.Spec.Notifications = []buildv1.NotificationSpec{{Name: "ci", URL: "ci.example.com/hooks"}}
+			},
+			wantErr: true,
+		},
+		{
+			name: "notification to a private address",
+			mutate: func(b *buildv1.Build) {
+				b.Spec.Notifications = []buildv1.NotificationSpec{{Name: "ci", URL: "http://169.254.169.254/latest/meta-data"}}
+			},
+			wantErr: true,
+		},
+		{
+			name: "notification to a Service of the cluster",
+			mutate: func(b *buildv1.Build) {
+				b.Spec.Notifications = []buildv1.NotificationSpec{{Name: "ci", URL: "http://kubernetes.default.svc/api"}}
+			},
+			wantErr: true,
+		},
+		{
+			name: "notification with an unknown phase",
+			mutate: func(b *buildv1.Build) {
+				b.Spec.Notifications = []buildv1.NotificationSpec{{Name: "ci", URL: "https://ci.example.com", Phases: []buildv1.BuildPhase{"Done"}}}
+			},
+			wantErr: true,
+		},
+		{
+			name: "duplicate notification",
+			mutate: func(b *buildv1.Build) {
+				b.Spec.Notifications = []buildv1.NotificationSpec{
+					{Name: "ci", URL: "https://ci.example.com"},
+					{Name: "ci", URL: "https://chat.example.com"},
+				}
+			},
+			wantErr: true,
+		},
+		{
+			name: "artifact retention",
+			mutate: func(b *buildv1.Build) {
+				b.Spec.ArtifactRetention = &buildv1.RetentionPolicy{KeepLast: ptr.To[int32](3), MaxAge: &metav1.Duration{Duration: time.Hour}}
+			},
+		},
+		{
+			name: "artifact retention keeping no image",
+			mutate: func(b *buildv1.Build) {
+				b.Spec.ArtifactRetention = &buildv1.RetentionPolicy{KeepLast: ptr.To[int32](0)}
+			},
+			wantErr: true,
+		},
+		{
+			name: "artifact retention with a negative max age",
+			mutate: func(b *buildv1.Build) {
+				b.Spec.ArtifactRetention = &buildv1.RetentionPolicy{MaxAge: &metav1.Duration{Duration: -time.Hour}}
+			},
+			wantErr: true,
+		},
+		{
+			name: "matrix",
+			mutate: func(b *buildv1.Build) {
+				b.Spec.Matrix = &buildv1.MatrixSpec{
+					Axes: []buildv1.MatrixAxis{
+						{Name: "arch", Values: []string{"amd64", "arm64"}},
+						{Name: "region", Values: []string{"eu-west-1", "us-east-1"}},
+					},
+					Quorum: ptr.To[int32](3),
+				}
+			},
+		},
+		{
+			name: "matrix with duplicate axes",
+			mutate: func(b *buildv1.Build) {
+				b.Spec.Matrix = &buildv1.MatrixSpec{Axes: []buildv1.MatrixAxis{
+					{Name: "arch", Values: []string{"amd64"}},
+					{Name: "arch", Values: []string{"arm64"}},
+				}}
+			},
+			wantErr: true,
+		},
+		{
+			name: "matrix axis with duplicate values",
+			mutate: func(b *buildv1.Build) {
+				b.Spec.Matrix = &buildv1.MatrixSpec{Axes: []buildv1.MatrixAxis{
+					{Name: "arch", Values: []string{"amd64", "amd64"}},
+				}}
+			},
+			wantErr: true,
+		},
+		{
+			name: "provisioner runtime",
+			mutate: func(b *buildv1.Build) {
+				b.Spec.ProvisionerRuntime = &buildv1.ProvisionerRuntimeConfig{
+					Image:            "registry.example.com/forge/forge-provisioner-shell:v0.1.0",
+					ImagePullSecrets: []corev1.LocalObjectReference{{Name: "registry"}},
+					NodeSelector:     map[string]string{"kubernetes.io/arch": "amd64"},
+				}
+				b.Spec.Provisioners[0].Runtime = &buildv1.ProvisionerRuntimeConfig{
+					Resources: &corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("256Mi")},
+						Limits:   corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi")},
+					},
+				}
+			},
+		},
+		{
+			name: "provisioner runtime with an unnamed image pull secret",
+			mutate: func(b *buildv1.Build) {
+				b.Spec.ProvisionerRuntime = &buildv1.ProvisionerRuntimeConfig{ImagePullSecrets: []corev1.LocalObjectReference{{}}}
+			},
+			wantErr: true,
+		},
+		{
+			name: "provisioner runtime with an invalid node selector",
+			mutate: func(b *buildv1.Build) {
+				b.Spec.ProvisionerRuntime = &buildv1.ProvisionerRuntimeConfig{NodeSelector: map[string]string{"node role": "builder"}}
+			},
+			wantErr: true,
+		},
+		{
+			name: "provisioner runtime with requests greater than the limits",
+			mutate: func(b *buildv1.Build) {
+				b.Spec.Provisioners[0].Runtime = &buildv1.ProvisionerRuntimeConfig{
+					Resources: &corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2")},
+						Limits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
+					},
+				}
+			},
+			wantErr: true,
+		},
+		{
+			name: "external provisioner with a runtime",
+			mutate: func(b *buildv1.Build) {
+				b.Spec.Provisioners[1].Type = buildv1.ProvisionerTypeExternal
+				b.Spec.Provisioners[1].Ref = &corev1.ObjectReference{Kind: "AnsibleProvisioner", Name: "nginx"}
+				b.Spec.Provisioners[1].Runtime = &buildv1.ProvisionerRuntimeConfig{Image: "ansible"}
+			},
+			wantErr: true,
+		},
+		{
+			name: "matrix quorum greater than the variants",
+			mutate: func(b *buildv1.Build) {
+				b.Spec.Matrix = &buildv1.MatrixSpec{
+					Axes:   []buildv1.MatrixAxis{{Name: "arch", Values: []string{"amd64", "arm64"}}},
+					Quorum: ptr.To[int32](3),
+				}
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			build := newBuild()
+			tt.mutate(build)
+			err := ValidateBuild(nil, build)
+			if tt.wantErr {
+				g.Expect(err).To(HaveOccurred())
+			} else {
+				g.Expect(err).ToNot(HaveOccurred())
+			}
+		})
+	}
+}
+
+func TestValidateBuildUpdate(t *testing.T) {
+	g := NewWithT(t)
+
+	oldBuild := newBuild()
+	newBuild := oldBuild.DeepCopy()
+	newBuild.Spec.Provisioners[0].Run = ptr.To("echo world")
+	g.Expect(ValidateBuild(oldBuild, newBuild)).To(Succeed())
+
+	newBuild = oldBuild.DeepCopy()
+	newBuild.Spec.InfrastructureRef.Name = "ubuntu-2404"
+	g.Expect(ValidateBuild(oldBuild, newBuild)).ToNot(Succeed())
+
+	newBuild = oldBuild.DeepCopy()
+	newBuild.Spec.InfrastructureRef.Kind = "GCPBuild"
+	g.Expect(ValidateBuild(oldBuild, newBuild)).ToNot(Succeed())
+
+	newBuild = oldBuild.DeepCopy()
+	newBuild.Spec.Matrix = &buildv1.MatrixSpec{Axes: []buildv1.MatrixAxis{{Name: "arch", Values: []string{"amd64"}}}}
+	g.Expect(ValidateBuild(oldBuild, newBuild)).ToNot(Succeed())
+}
@@ -0,0 +1,316 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package conformance is a test suite proving an infrastructure provider satisfies the Forge
+// InfraBuild contract. Providers run it against a test environment, e.g. envtest, with the Build
+// CRD and their CRDs installed and their controller running:
+//
+//	func TestConformance(t *testing.T) {
+//		conformance.Run(t, conformance.Input{
+//			Client:        k8sClient,
+//			Namespace:     "default",
+//			NewInfraBuild: func(name string) client.Object { return &MyMachine{...} },
+//		})
+//	}
+package conformance
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	buildv1 "github.com/forge-build/forge/api/v1alpha1"
+	"github.com/forge-build/forge/pkg/contract/infra"
+	"github.com/forge-build/forge/util"
+)
+
+const (
+	// DefaultTimeout is how long to wait for an InfraBuild to reach a state.
+	DefaultTimeout = 30 * time.Second
+
+	// consistentlyDuration is how long an InfraBuild has to stay in a state.
+	consistentlyDuration = 2 * time.Second
+
+	pollingInterval = 250 * time.Millisecond
+)
+
+// Input is the input of the conformance suite.
+type Input struct {
+	// Client is a client of the test environment, its scheme must include the Forge, the provider
+	// and the apiextensions types.
+	Client client.Client
+
+	// Namespace is where the test objects are created, it must exist.
+	Namespace string
+
+	// NewInfraBuild returns a new InfraBuild named name. Its machine must become ready and, unless
+	// SkipExport is set, its image must be exported without help from outside the test environment.
+	NewInfraBuild func(name string) client.Object
+
+	// Objects are created before the InfraBuilds, e.g. the Secrets they reference.
+	// +optional
+	Objects []client.Object
+
+	// Timeout is how long to wait for an InfraBuild to reach a state, defaults to DefaultTimeout.
+	// +optional
+	Timeout time.Duration
+
+	// SkipExport skips the export checks, for providers whose export needs resources missing from the test environment.
+	// +optional
+	SkipExport bool
+}
+
+// Run runs the conformance suite as subtests of t.
+func Run(t *testing.T, in Input) {
+	ctx := context.Background()
+	if in.Timeout == 0 {
+		in.Timeout = DefaultTimeout
+	}
+
+	gvk, err := apiutil.GVKForObject(in.NewInfraBuild("gvk"), in.Client.Scheme())
+	if err != nil {
+		t.Fatalf("failed to get the GroupVersionKind of the InfraBuild: %v", err)
+	}
+	for _, obj := range in.Objects {
+		if err := in.Client.Create(ctx, obj); err != nil && !apierrors.IsAlreadyExists(err) {
+			t.Fatalf("failed to create %s: %v", obj.GetName(), err)
+		}
+	}
+
+	s := &suite{Input: in, ctx: ctx, gvk: gvk}
+	t.Run("CRD declares the contract version", s.testContractVersion)
+	t.Run("CRD exposes the contract status fields", s.testStatusFields)
+	t.Run("InfraBuild without owner Build is ignored", s.testNoOwner)
+	t.Run("InfraBuild of a paused Build is ignored", s.testPaused)
+	t.Run("InfraBuild provides the machine credentials to the Build", s.testMachineReady)
+	if !in.SkipExport {
+		t.Run("InfraBuild exports the image when requested", s.testExport)
+	}
+	t.Run("InfraBuild can be deleted", s.testDelete)
+}
+
+type suite struct {
+	Input
+	ctx context.Context
+	gvk schema.GroupVersionKind
+}
+
+func (s *suite) getCRD(g *WithT) *apiextensionsv1.CustomResourceDefinition {
+	crd := &apiextensionsv1.CustomResourceDefinition{}
+	name := util.CalculateCRDName(s.gvk.Group, s.gvk.Kind)
+	g.Expect(s.Client.Get(s.ctx, client.ObjectKey{Name: name}, crd)).To(Succeed(), "CRD %s not found", name)
+	return crd
+}
+
+func (s *suite) testContractVersion(t *testing.T) {
+	g := NewWithT(t)
+	crd := s.getCRD(g)
+
+	g.Expect(crd.Labels).To(HaveKey(infra.ContractLabel),
+		"CRD %s must have the %s label listing the versions implementing the contract", crd.Name, infra.ContractLabel)
+	g.Expect(infra.ContractVersions(crd)).To(ContainElement(s.gvk.Version),
+		"the %s label of CRD %s must list version %s", infra.ContractLabel, crd.Name, s.gvk.Version)
+	// The Build controller refuses the InfraBuilds of CRDs failing this check.
+	g.Expect(infra.ValidateCRD(crd)).To(Succeed())
+}
+
+func (s *suite) testStatusFields(t *testing.T) {
+	g := NewWithT(t)
+	crd := s.getCRD(g)
+
+	var version *apiextensionsv1.CustomResourceDefinitionVersion
+	for i := range crd.Spec.Versions {
+		if crd.Spec.Versions[i].Name == s.gvk.Version {
+			version = &crd.Spec.Versions[i]
+		}
+	}
+	g.Expect(version).NotTo(BeNil(), "CRD %s does not serve version %s", crd.Name, s.gvk.Version)
+	g.Expect(version.Subresources).NotTo(BeNil())
+	g.Expect(version.Subresources.Status).NotTo(BeNil(), "CRD %s must have the status subresource", crd.Name)
+	g.Expect(version.Schema).NotTo(BeNil())
+	g.Expect(version.Schema.OpenAPIV3Schema).NotTo(BeNil())
+
+	status, ok := version.Schema.OpenAPIV3Schema.Properties["status"]
+	g.Expect(ok).To(BeTrue(), "CRD %s has no status", crd.Name)
+	for field, typ := range map[string]string{
+		infra.MachineReadyPath[1]:   "boolean",
+		infra.ReadyPath[1]:          "boolean",
+		infra.FailureReasonPath[1]:  "string",
+		infra.FailureMessagePath[1]: "string",
+	} {
+		prop, ok := status.Properties[field]
+		g.Expect(ok).To(BeTrue(), "CRD %s has no status.%s field", crd.Name, field)
+		g.Expect(prop.Type).To(Equal(typ), "status.%s of CRD %s must be a %s", field, crd.Name, typ)
+	}
+}
+
+func (s *suite) testNoOwner(t *testing.T) {
+	g := NewWithT(t)
+
+	obj := s.NewInfraBuild("conformance-no-owner")
+	obj.SetNamespace(s.Namespace)
+	g.Expect(s.Client.Create(s.ctx, obj)).To(Succeed())
+	defer s.cleanup(t, obj)
+
+	g.Consistently(func(g Gomega) {
+		g.Expect(s.status(g, obj.GetName()).MachineReady).To(BeFalse())
+	}, consistentlyDuration, pollingInterval).Should(Succeed())
+}
+
+func (s *suite) testPaused(t *testing.T) {
+	g := NewWithT(t)
+
+	build, obj := s.createBuild(g, "conformance-paused", true)
+	defer s.cleanup(t, obj, build)
+
+	g.Consistently(func(g Gomega) {
+		g.Expect(s.status(g, obj.GetName()).MachineReady).To(BeFalse())
+	}, consistentlyDuration, pollingInterval).Should(Succeed())
+}
+
+func (s *suite) testMachineReady(t *testing.T) {
+	g := NewWithT(t)
+
+	build, obj := s.createBuild(g, "conformance-machine-ready", false)
+	defer s.cleanup(t, obj, build)
+
+	s.waitForMachineReady(g, build, obj)
+}
+
+func (s *suite) testExport(t *testing.T) {
+	g := NewWithT(t)
+
+	build, obj := s.createBuild(g, "conformance-export", false)
+	defer s.cleanup(t, obj, build)
+	s.waitForMachineReady(g, build, obj)
+
+	// The image must not be exported before the Build requests it.
+	g.Consistently(func(g Gomega) {
+		g.Expect(s.status(g, obj.GetName()).Ready).To(BeFalse())
+	}, consistentlyDuration, pollingInterval).Should(Succeed(), "status.ready must not be set before the export is requested")
+
+	u := s.get(g, obj.GetName())
+	annotations := u.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[buildv1.ExportAnnotation] = ""
+	u.SetAnnotations(annotations)
+	g.Expect(s.Client.Update(s.ctx, u)).To(Succeed())
+
+	g.Eventually(func(g Gomega) {
+		status := s.status(g, obj.GetName())
+		g.Expect(status.Failed()).To(BeFalse(), "export failed: %s", ptrString(status.FailureMessage))
+		g.Expect(status.Ready).To(BeTrue())
+	}, s.Timeout, pollingInterval).Should(Succeed(), "status.ready must be set once the image is exported")
+}
+
+func (s *suite) testDelete(t *testing.T) {
+	g := NewWithT(t)
+
+	build, obj := s.createBuild(g, "conformance-delete", false)
+	s.waitForMachineReady(g, build, obj)
+
+	s.cleanup(t, obj, build)
+	g.Eventually(func() bool {
+		return apierrors.IsNotFound(s.Client.Get(s.ctx, client.ObjectKeyFromObject(obj), s.empty()))
+	}, s.Timeout, pollingInterval).Should(BeTrue(), "the InfraBuild must be removed once deleted")
+}
+
+// createBuild creates a Build and its InfraBuild, owned by the Build as done by the Build controller.
+func (s *suite) createBuild(g *WithT, name string, paused bool) (*buildv1.Build, client.Object) {
+	build := &buildv1.Build{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: s.Namespace},
+		Spec: buildv1.BuildSpec{
+			Paused:    paused,
+			Connector: buildv1.ConnectorSpec{Type: "ssh"},
+			InfrastructureRef: &corev1.ObjectReference{
+				APIVersion: s.gvk.GroupVersion().String(),
+				Kind:       s.gvk.Kind,
+				Name:       name,
+			},
+		},
+	}
+	g.Expect(s.Client.Create(s.ctx, build)).To(Succeed())
+
+	obj := s.NewInfraBuild(name)
+	obj.SetNamespace(s.Namespace)
+	obj.SetLabels(map[string]string{buildv1.BuildNameLabel: build.Name})
+	g.Expect(controllerutil.SetControllerReference(build, obj, s.Client.Scheme())).To(Succeed())
+	g.Expect(s.Client.Create(s.ctx, obj)).To(Succeed())
+	return build, obj
+}
+
+func (s *suite) waitForMachineReady(g *WithT, build *buildv1.Build, obj client.Object) {
+	g.Eventually(func(g Gomega) {
+		status := s.status(g, obj.GetName())
+		g.Expect(status.Failed()).To(BeFalse(), "machine failed: %s", ptrString(status.FailureMessage))
+		g.Expect(status.MachineReady).To(BeTrue())
+	}, s.Timeout, pollingInterval).Should(Succeed(), "status.machineReady must be set once the machine is ready")
+
+	g.Expect(s.Client.Get(s.ctx, client.ObjectKeyFromObject(build), build)).To(Succeed())
+	g.Expect(build.Spec.Connector.Credentials).NotTo(BeNil(), "the Build must reference the credentials Secret once the machine is ready")
+	secret := &corev1.Secret{}
+	g.Expect(s.Client.Get(s.ctx, client.ObjectKey{Namespace: s.Namespace, Name: build.Spec.Connector.Credentials.Name}, secret)).To(Succeed())
+	g.Expect(secret.Data).To(HaveKey("host"), "the credentials Secret must have a host")
+	g.Expect(secret.Data).To(HaveKey("username"), "the credentials Secret must have a username")
+	g.Expect(secret.Data).To(Or(HaveKey("password"), HaveKey("privateKey")), "the credentials Secret must have a password or a privateKey")
+}
+
+func (s *suite) empty() *unstructured.Unstructured {
+	u := &unstructured.Unstructured{}
+	u.SetGroupVersionKind(s.gvk)
+	return u
+}
+
+func (s *suite) get(g Gomega, name string) *unstructured.Unstructured {
+	u := s.empty()
+	g.Expect(s.Client.Get(s.ctx, client.ObjectKey{Namespace: s.Namespace, Name: name}, u)).To(Succeed())
+	return u
+}
+
+// status reads the contract fields of the InfraBuild status the way the Build controller does.
+func (s *suite) status(g Gomega, name string) *infra.Status {
+	status, err := infra.StatusFrom(s.get(g, name))
+	g.Expect(err).NotTo(HaveOccurred())
+	return status
+}
+
+func (s *suite) cleanup(t *testing.T, objs ...client.Object) {
+	for _, obj := range objs {
+		if err := s.Client.Delete(s.ctx, obj); err != nil && !apierrors.IsNotFound(err) {
+			t.Logf("failed to delete %s: %v", obj.GetName(), err)
+		}
+	}
+}
+
+func ptrString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,113 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package infra
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+
+	buildv1 "github.com/forge-build/forge/api/v1alpha1"
+)
+
+// ContractLabel is the label of the InfraBuild CRDs listing the versions implementing the contract, separated by "_".
+var ContractLabel = buildv1.GroupVersion.String()
+
+// statusFields are the status fields of the contract with their schema type.
+var statusFields = []struct {
+	path []string
+	typ  string
+}{
+	{path: MachineReadyPath, typ: "boolean"},
+	{path: ReadyPath, typ: "boolean"},
+	{path: FailureReasonPath, typ: "string"},
+	{path: FailureMessagePath, typ: "string"},
+}
+
+// ContractVersions returns the versions of an InfraBuild CRD implementing the contract.
+func ContractVersions(crd *apiextensionsv1.CustomResourceDefinition) []string {
+	value := crd.GetLabels()[ContractLabel]
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, "_")
+}
+
+// ValidateCRD checks that an InfraBuild CRD implements the contract: it lists the versions implementing it
+// in the ContractLabel, and these versions are served, have the status subresource and the status fields
+// of the contract.
+func ValidateCRD(crd *apiextensionsv1.CustomResourceDefinition) error {
+	versions := ContractVersions(crd)
+	if len(versions) == 0 {
+		return errors.Errorf("CRD %s does not declare the %s contract, the %s label listing the versions implementing it is missing",
+			crd.Name, ContractLabel, ContractLabel)
+	}
+
+	var problems []string
+	for _, name := range versions {
+		var version *apiextensionsv1.CustomResourceDefinitionVersion
+		for i := range crd.Spec.Versions {
+			if crd.Spec.Versions[i].Name == name {
+				version = &crd.Spec.Versions[i]
+			}
+		}
+		if version == nil || !version.Served {
+			problems = append(problems, fmt.Sprintf("version %s is not served", name))
+			continue
+		}
+		for _, p := range validateVersion(version) {
+			problems = append(problems, fmt.Sprintf("version %s: %s", name, p))
+		}
+	}
+	if len(problems) > 0 {
+		return errors.Errorf("CRD %s does not implement the %s contract: %s", crd.Name, ContractLabel, strings.Join(problems, ", "))
+	}
+	return nil
+}
+
+func validateVersion(version *apiextensionsv1.CustomResourceDefinitionVersion) []string {
+	var problems []string
+	if version.Subresources == nil || version.Subresources.Status == nil {
+		problems = append(problems, "the status subresource is not enabled")
+	}
+	if version.Schema == nil || version.Schema.OpenAPIV3Schema == nil {
+		return append(problems, "the schema is missing")
+	}
+
+	status, ok := version.Schema.OpenAPIV3Schema.Properties["status"]
+	if !ok {
+		return append(problems, "the schema has no status")
+	}
+	// Any field is accepted.
+	if len(status.Properties) == 0 && status.XPreserveUnknownFields != nil && *status.XPreserveUnknownFields {
+		return problems
+	}
+	for _, f := range statusFields {
+		name := strings.Join(f.path, ".")
+		prop, ok := status.Properties[f.path[1]]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s is missing", name))
+			continue
+		}
+		if prop.Type != f.typ {
+			problems = append(problems, fmt.Sprintf("%s must be a %s, not a %s", name, f.typ, prop.Type))
+		}
+	}
+	return problems
+}
@@ -0,0 +1,128 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package infra
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+)
+
+func testCRD(status apiextensionsv1.JSONSchemaProps) *apiextensionsv1.CustomResourceDefinition {
+	return &apiextensionsv1.CustomResourceDefinition{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "fakebuilds.infrastructure.forge.build",
+			Labels: map[string]string{ContractLabel: "v1alpha1"},
+		},
+		Spec: apiextensionsv1.CustomResourceDefinitionSpec{
+			Versions: []apiextensionsv1.CustomResourceDefinitionVersion{{
+				Name:         "v1alpha1",
+				Served:       true,
+				Subresources: &apiextensionsv1.CustomResourceSubresources{Status: &apiextensionsv1.CustomResourceSubresourceStatus{}},
+				Schema: &apiextensionsv1.CustomResourceValidation{
+					OpenAPIV3Schema: &apiextensionsv1.JSONSchemaProps{
+						Type:       "object",
+						Properties: map[string]apiextensionsv1.JSONSchemaProps{"status": status},
+					},
+				},
+			}},
+		},
+	}
+}
+
+func contractStatus() apiextensionsv1.JSONSchemaProps {
+	return apiextensionsv1.JSONSchemaProps{
+		Type: "object",
+		Properties: map[string]apiextensionsv1.JSONSchemaProps{
+			"machineReady":   {Type: "boolean"},
+			"ready":          {Type: "boolean"},
+			"failureReason":  {Type: "string"},
+			"failureMessage": {Type: "string"},
+		},
+	}
+}
+
+func TestValidateCRD(t *testing.T) {
+	tests := []struct {
+		name    string
+		crd     func() *apiextensionsv1.CustomResourceDefinition
+		wantErr string
+	}{
+		{
+			name: "implements the contract",
+			crd:  func() *apiextensionsv1.CustomResourceDefinition { return testCRD(contractStatus()) },
+		},
+		{
+			name: "status preserving unknown fields",
+			crd: func() *apiextensionsv1.CustomResourceDefinition {
+				return testCRD(apiextensionsv1.JSONSchemaProps{Type: "object", XPreserveUnknownFields: ptr.To(true)})
+			},
+		},
+		{
+			name: "missing contract label",
+			crd: func() *apiextensionsv1.CustomResourceDefinition {
+				crd := testCRD(contractStatus())
+				crd.Labels = nil
+				return crd
+			},
+			wantErr: "label listing the versions implementing it is missing",
+		},
+		{
+			name: "contract version not served",
+			crd: func() *apiextensionsv1.CustomResourceDefinition {
+				crd := testCRD(contractStatus())
+				crd.Labels[ContractLabel] = "v1alpha1_v1beta1"
+				return crd
+			},
+			wantErr: "version v1beta1 is not served",
+		},
+		{
+			name: "no status subresource",
+			crd: func() *apiextensionsv1.CustomResourceDefinition {
+				crd := testCRD(contractStatus())
+				crd.Spec.Versions[0].Subresources = nil
+				return crd
+			},
+			wantErr: "the status subresource is not enabled",
+		},
+		{
+			name: "missing and mistyped fields",
+			crd: func() *apiextensionsv1.CustomResourceDefinition {
+				status := contractStatus()
+				delete(status.Properties, "machineReady")
+				status.Properties["ready"] = apiextensionsv1.JSONSchemaProps{Type: "string"}
+				return testCRD(status)
+			},
+			wantErr: "version v1alpha1: status.machineReady is missing, version v1alpha1: status.ready must be a boolean, not a string",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			err := ValidateCRD(tt.crd())
+			if tt.wantErr == "" {
+				g.Expect(err).NotTo(HaveOccurred())
+				return
+			}
+			g.Expect(err).To(MatchError(ContainSubstring(tt.wantErr)))
+		})
+	}
+}
@@ -0,0 +1,218 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package infra
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/patch"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	buildv1 "github.com/forge-build/forge/api/v1alpha1"
+	forgeerrors "github.com/forge-build/forge/pkg/errors"
+	"github.com/forge-build/forge/util"
+	"github.com/forge-build/forge/util/annotations"
+)
+
+// Provider implements the infrastructure specific steps of an InfraBuild, the Reconciler
+// takes care of the contract around them.
+type Provider[T InfraBuild] interface {
+	// ReconcileMachine brings up the machine, it returns its SSH credentials once it can be connected to.
+	// It is not called anymore once status.machineReady is set.
+	ReconcileMachine(ctx context.Context, build *buildv1.Build, obj T) (*util.SSHCredentials, ctrl.Result, error)
+
+	// ReconcileExport exports the image of the machine once the Build requested it, it returns true
+	// once the image is exported. It is not called anymore once status.ready is set.
+	ReconcileExport(ctx context.Context, build *buildv1.Build, obj T) (bool, ctrl.Result, error)
+
+	// ReconcileDelete releases the machine, it returns true once the finalizer can be removed.
+	// It is only called when the Reconciler has a Finalizer, build is nil if the Build is already gone.
+	ReconcileDelete(ctx context.Context, build *buildv1.Build, obj T) (bool, ctrl.Result, error)
+}
+
+// ImageDeleter is implemented by the Providers able to delete the exported images.
+type ImageDeleter[T InfraBuild] interface {
+	// ReconcileDeleteImage deletes the exported image once the Build requested it, it returns true
+	// once the image is deleted. It is not called anymore once status.imageDeleted is set.
+	ReconcileDeleteImage(ctx context.Context, build *buildv1.Build, obj T) (bool, ctrl.Result, error)
+}
+
+// Reconciler is a base reconciler for InfraBuilds. It looks up the owner Build, skips the paused and
+// externally managed objects, creates the credentials Secret of the Build once the machine is ready,
+// calls the Provider when the Build requests the export or the deletion of the image, and patches the object.
+type Reconciler[T InfraBuild] struct {
+	Client client.Client
+
+	// ProviderName is the name of the provider, set on the credentials Secrets.
+	ProviderName string
+
+	// Finalizer is added to the InfraBuilds when set, so that Provider.ReconcileDelete runs before they are removed.
+	// +optional
+	Finalizer string
+
+	// NewObject returns an empty InfraBuild to read the reconciled object into.
+	NewObject func() T
+
+	// Provider implements the infrastructure specific steps.
+	Provider Provider[T]
+
+	// OwnedConditions are the conditions of the InfraBuild set by the Provider.
+	// +optional
+	OwnedConditions []clusterv1.ConditionType
+}
+
+// Reconcile reconciles an InfraBuild according to the contract.
+func (r *Reconciler[T]) Reconcile(ctx context.Context, req ctrl.Request) (_ ctrl.Result, reterr error) {
+	log := ctrl.LoggerFrom(ctx)
+
+	obj := r.NewObject()
+	if err := r.Client.Get(ctx, req.NamespacedName, obj); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+
+	// Skip the objects managed by another controller.
+	if annotations.IsExternallyManaged(obj) {
+		log.V(4).Info("InfraBuild is externally managed, skipping")
+		return ctrl.Result{}, nil
+	}
+
+	build, err := util.GetOwnerBuild(ctx, r.Client, metav1.ObjectMeta{
+		Namespace:       obj.GetNamespace(),
+		OwnerReferences: obj.GetOwnerReferences(),
+	})
+	if err != nil {
+		// The Build may already be gone when the InfraBuild is being deleted.
+		if !apierrors.IsNotFound(errors.Cause(err)) || obj.GetDeletionTimestamp().IsZero() {
+			return ctrl.Result{}, err
+		}
+		build = nil
+	}
+	if build == nil && obj.GetDeletionTimestamp().IsZero() {
+		log.Info("Waiting for the Build controller to set the OwnerRef on the InfraBuild")
+		return ctrl.Result{}, nil
+	}
+	if build != nil {
+		log = log.WithValues("build", build.Name)
+		ctx = ctrl.LoggerInto(ctx, log)
+
+		if annotations.IsPaused(build, obj) {
+			log.Info("Reconciliation is paused for this object")
+			return ctrl.Result{}, nil
+		}
+	}
+
+	patchHelper, err := patch.NewHelper(obj, r.Client)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	defer func() {
+		if err := patchHelper.Patch(ctx, obj, patch.WithOwnedConditions{Conditions: r.OwnedConditions}); err != nil {
+			reterr = kerrors.NewAggregate([]error{reterr, err})
+		}
+	}()
+
+	if !obj.GetDeletionTimestamp().IsZero() {
+		return r.reconcileDelete(ctx, build, obj)
+	}
+
+	// Add finalizer first if not set to avoid the race condition between init and delete.
+	if r.Finalizer != "" && !controllerutil.ContainsFinalizer(obj, r.Finalizer) {
+		controllerutil.AddFinalizer(obj, r.Finalizer)
+		return ctrl.Result{}, nil
+	}
+
+	return r.reconcileNormal(ctx, build, obj)
+}
+
+func (r *Reconciler[T]) reconcileNormal(ctx context.Context, build *buildv1.Build, obj T) (ctrl.Result, error) {
+	status := obj.GetContractStatus()
+	if status.Failed() {
+		return ctrl.Result{}, nil
+	}
+
+	// The Build is being deleted with its image.
+	if ImageDeletionRequested(obj) {
+		return r.reconcileDeleteImage(ctx, build, obj)
+	}
+
+	if !status.MachineReady {
+		creds, res, err := r.Provider.ReconcileMachine(ctx, build, obj)
+		if err != nil || creds == nil {
+			return res, err
+		}
+		if err := util.EnsureCredentialsSecret(ctx, r.Client, build, *creds, r.ProviderName); err != nil {
+			return ctrl.Result{}, err
+		}
+		status.MarkMachineReady()
+	}
+
+	if status.Ready || !ExportRequested(obj) {
+		return ctrl.Result{}, nil
+	}
+	exported, res, err := r.Provider.ReconcileExport(ctx, build, obj)
+	if err != nil {
+		return res, err
+	}
+	if exported {
+		status.MarkReady()
+	}
+	return res, nil
+}
+
+func (r *Reconciler[T]) reconcileDeleteImage(ctx context.Context, build *buildv1.Build, obj T) (ctrl.Result, error) {
+	status := obj.GetContractStatus()
+	if status.ImageDeleted {
+		return ctrl.Result{}, nil
+	}
+
+	deleter, ok := r.Provider.(ImageDeleter[T])
+	if !ok {
+		status.MarkFailed(forgeerrors.DeleteBuildError, "provider %s does not support deleting images", r.ProviderName)
+		return ctrl.Result{}, nil
+	}
+	deleted, res, err := deleter.ReconcileDeleteImage(ctx, build, obj)
+	if err != nil {
+		return res, err
+	}
+	if deleted {
+		status.MarkImageDeleted()
+	}
+	return res, nil
+}
+
+func (r *Reconciler[T]) reconcileDelete(ctx context.Context, build *buildv1.Build, obj T) (ctrl.Result, error) {
+	if r.Finalizer == "" || !controllerutil.ContainsFinalizer(obj, r.Finalizer) {
+		return ctrl.Result{}, nil
+	}
+
+	deleted, res, err := r.Provider.ReconcileDelete(ctx, build, obj)
+	if err != nil || !deleted {
+		return res, err
+	}
+	controllerutil.RemoveFinalizer(obj, r.Finalizer)
+	return ctrl.Result{}, nil
+}
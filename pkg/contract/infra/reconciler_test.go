@@ -0,0 +1,287 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package infra_test
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	buildv1 "github.com/forge-build/forge/api/v1alpha1"
+	infrav1 "github.com/forge-build/forge/infrastructure/static/api/v1alpha1"
+	"github.com/forge-build/forge/pkg/contract/infra"
+	forgeerrors "github.com/forge-build/forge/pkg/errors"
+	"github.com/forge-build/forge/util"
+)
+
+const testFinalizer = "test.infrastructure.forge.build"
+
+// fakeProvider records the calls of the Reconciler.
+type fakeProvider struct {
+	creds    *util.SSHCredentials
+	exported bool
+	deleted  bool
+
+	machineCalls int
+	exportCalls  int
+	deleteCalls  int
+	deleteBuild  *buildv1.Build
+}
+
+// fakeImageDeleter is a fakeProvider able to delete the exported images.
+type fakeImageDeleter struct {
+	fakeProvider
+	imageDeleted     bool
+	deleteImageCalls int
+}
+
+func (p *fakeImageDeleter) ReconcileDeleteImage(_ context.Context, _ *buildv1.Build, _ *infrav1.StaticMachine) (bool, ctrl.Result, error) {
+	p.deleteImageCalls++
+	return p.imageDeleted, ctrl.Result{}, nil
+}
+
+func (p *fakeProvider) ReconcileMachine(_ context.Context, _ *buildv1.Build, _ *infrav1.StaticMachine) (*util.SSHCredentials, ctrl.Result, error) {
+	p.machineCalls++
+	return p.creds, ctrl.Result{}, nil
+}
+
+func (p *fakeProvider) ReconcileExport(_ context.Context, _ *buildv1.Build, _ *infrav1.StaticMachine) (bool, ctrl.Result, error) {
+	p.exportCalls++
+	return p.exported, ctrl.Result{}, nil
+}
+
+func (p *fakeProvider) ReconcileDelete(_ context.Context, build *buildv1.Build, _ *infrav1.StaticMachine) (bool, ctrl.Result, error) {
+	p.deleteCalls++
+	p.deleteBuild = build
+	return p.deleted, ctrl.Result{}, nil
+}
+
+func newTestObjects() (*buildv1.Build, *infrav1.StaticMachine) {
+	build := &buildv1.Build{
+		TypeMeta:   metav1.TypeMeta{APIVersion: buildv1.GroupVersion.String(), Kind: "Build"},
+		ObjectMeta: metav1.ObjectMeta{Name: "ubuntu", Namespace: "default", UID: "build-uid"},
+	}
+	machine := &infrav1.StaticMachine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "machine",
+			Namespace: "default",
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: buildv1.GroupVersion.String(),
+				Kind:       "Build",
+				Name:       "ubuntu",
+				UID:        "build-uid",
+			}},
+		},
+	}
+	return build, machine
+}
+
+func newTestReconciler(provider infra.Provider[*infrav1.StaticMachine], finalizer string, objs ...client.Object) *infra.Reconciler[*infrav1.StaticMachine] {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = buildv1.AddToScheme(scheme)
+	_ = infrav1.AddToScheme(scheme)
+
+	return &infra.Reconciler[*infrav1.StaticMachine]{
+		Client: fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(objs...).
+			WithStatusSubresource(&buildv1.Build{}, &infrav1.StaticMachine{}).
+			Build(),
+		ProviderName: "test",
+		Finalizer:    finalizer,
+		NewObject:    func() *infrav1.StaticMachine { return &infrav1.StaticMachine{} },
+		Provider:     provider,
+	}
+}
+
+func reconcileMachine(g *WithT, r *infra.Reconciler[*infrav1.StaticMachine]) *infrav1.StaticMachine {
+	ctx := context.Background()
+	_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKey{Namespace: "default", Name: "machine"}})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	machine := &infrav1.StaticMachine{}
+	if err := r.Client.Get(ctx, client.ObjectKey{Namespace: "default", Name: "machine"}, machine); err != nil {
+		return nil
+	}
+	return machine
+}
+
+func TestReconcilerSkips(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(build *buildv1.Build, machine *infrav1.StaticMachine)
+	}{
+		{
+			name: "no owner Build",
+			mutate: func(_ *buildv1.Build, machine *infrav1.StaticMachine) {
+				machine.OwnerReferences = nil
+			},
+		},
+		{
+			name: "paused Build",
+			mutate: func(build *buildv1.Build, _ *infrav1.StaticMachine) {
+				build.Spec.Paused = true
+			},
+		},
+		{
+			name: "paused InfraBuild",
+			mutate: func(_ *buildv1.Build, machine *infrav1.StaticMachine) {
+				machine.Annotations = map[string]string{buildv1.PausedAnnotation: ""}
+			},
+		},
+		{
+			name: "externally managed InfraBuild",
+			mutate: func(_ *buildv1.Build, machine *infrav1.StaticMachine) {
+				machine.Annotations = map[string]string{buildv1.ManagedByAnnotation: "someone"}
+			},
+		},
+		{
+			name: "failed InfraBuild",
+			mutate: func(_ *buildv1.Build, machine *infrav1.StaticMachine) {
+				machine.Status.MarkFailed(forgeerrors.CreateBuildError, "failed")
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			build, machine := newTestObjects()
+			tt.mutate(build, machine)
+			provider := &fakeProvider{creds: &util.SSHCredentials{Host: "10.0.0.1"}}
+			r := newTestReconciler(provider, "", build, machine)
+
+			machine = reconcileMachine(g, r)
+			g.Expect(provider.machineCalls).To(BeZero())
+			g.Expect(machine.Status.MachineReady).To(BeFalse())
+		})
+	}
+}
+
+func TestReconcilerMachineAndExport(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	build, machine := newTestObjects()
+	provider := &fakeProvider{}
+	r := newTestReconciler(provider, "", build, machine)
+
+	// The machine is not ready yet.
+	machine = reconcileMachine(g, r)
+	g.Expect(provider.machineCalls).To(Equal(1))
+	g.Expect(machine.Status.MachineReady).To(BeFalse())
+
+	// The machine is ready, the Build gets the credentials.
+	provider.creds = &util.SSHCredentials{Host: "10.0.0.1", Port: 2222, Username: "forge", Password: "secret"}
+	machine = reconcileMachine(g, r)
+	g.Expect(provider.machineCalls).To(Equal(2))
+	g.Expect(machine.Status.MachineReady).To(BeTrue())
+	g.Expect(provider.exportCalls).To(BeZero())
+
+	g.Expect(r.Client.Get(ctx, client.ObjectKeyFromObject(build), build)).To(Succeed())
+	g.Expect(build.Spec.Connector.Credentials).NotTo(BeNil())
+	secret := &corev1.Secret{}
+	g.Expect(r.Client.Get(ctx, client.ObjectKey{Namespace: "default", Name: build.Spec.Connector.Credentials.Name}, secret)).To(Succeed())
+	g.Expect(secret.Type).To(Equal(buildv1.BuildSecretType))
+	g.Expect(secret.Annotations).To(HaveKeyWithValue(buildv1.ProviderNameLabel, "test"))
+	g.Expect(secret.StringData).To(HaveKeyWithValue("host", "10.0.0.1"))
+
+	// The export is requested, the provider is only called until the image is exported.
+	machine.Annotations = map[string]string{buildv1.ExportAnnotation: ""}
+	g.Expect(r.Client.Update(ctx, machine)).To(Succeed())
+	machine = reconcileMachine(g, r)
+	g.Expect(provider.machineCalls).To(Equal(2))
+	g.Expect(provider.exportCalls).To(Equal(1))
+	g.Expect(machine.Status.Ready).To(BeFalse())
+
+	provider.exported = true
+	machine = reconcileMachine(g, r)
+	g.Expect(provider.exportCalls).To(Equal(2))
+	g.Expect(machine.Status.Ready).To(BeTrue())
+
+	reconcileMachine(g, r)
+	g.Expect(provider.exportCalls).To(Equal(2))
+}
+
+func TestReconcilerFinalizer(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	build, machine := newTestObjects()
+	provider := &fakeProvider{}
+	r := newTestReconciler(provider, testFinalizer, build, machine)
+
+	// The finalizer is added first.
+	machine = reconcileMachine(g, r)
+	g.Expect(machine.Finalizers).To(ConsistOf(testFinalizer))
+	g.Expect(provider.machineCalls).To(BeZero())
+
+	// The Build is already gone, the finalizer is only removed once the provider is done.
+	g.Expect(r.Client.Delete(ctx, build)).To(Succeed())
+	g.Expect(r.Client.Delete(ctx, machine)).To(Succeed())
+	machine = reconcileMachine(g, r)
+	g.Expect(provider.deleteCalls).To(Equal(1))
+	g.Expect(provider.deleteBuild).To(BeNil())
+	g.Expect(machine.Finalizers).To(ConsistOf(testFinalizer))
+
+	provider.deleted = true
+	g.Expect(reconcileMachine(g, r)).To(BeNil())
+	g.Expect(provider.deleteCalls).To(Equal(2))
+}
+
+func TestReconcilerDeleteImage(t *testing.T) {
+	g := NewWithT(t)
+	build, machine := newTestObjects()
+	machine.Annotations = map[string]string{buildv1.ExportAnnotation: "", buildv1.DeleteImageAnnotation: ""}
+	machine.Status.MarkMachineReady()
+	machine.Status.MarkReady()
+	provider := &fakeImageDeleter{}
+	r := newTestReconciler(provider, "", build, machine)
+
+	// The provider is only called until the image is deleted.
+	machine = reconcileMachine(g, r)
+	g.Expect(provider.deleteImageCalls).To(Equal(1))
+	g.Expect(machine.Status.ImageDeleted).To(BeFalse())
+
+	provider.imageDeleted = true
+	machine = reconcileMachine(g, r)
+	g.Expect(provider.deleteImageCalls).To(Equal(2))
+	g.Expect(machine.Status.ImageDeleted).To(BeTrue())
+
+	reconcileMachine(g, r)
+	g.Expect(provider.deleteImageCalls).To(Equal(2))
+	g.Expect(provider.exportCalls).To(BeZero())
+}
+
+func TestReconcilerDeleteImageNotSupported(t *testing.T) {
+	g := NewWithT(t)
+	build, machine := newTestObjects()
+	machine.Annotations = map[string]string{buildv1.DeleteImageAnnotation: ""}
+	machine.Status.MarkMachineReady()
+	machine.Status.MarkReady()
+	r := newTestReconciler(&fakeProvider{}, "", build, machine)
+
+	machine = reconcileMachine(g, r)
+	g.Expect(machine.Status.ImageDeleted).To(BeFalse())
+	g.Expect(machine.Status.FailureReason).To(HaveValue(Equal(forgeerrors.DeleteBuildError)))
+}
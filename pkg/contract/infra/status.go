@@ -0,0 +1,190 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package infra implements the Forge contract of the InfraBuild objects for infrastructure
+// provider authors: the status fields read by the Build controller, a base reconciler
+// and, in the conformance package, a test suite proving a provider satisfies the contract.
+//
+// The Build controller reads the following fields of the InfraBuild referenced by
+// spec.infrastructureRef:
+//
+//   - status.machineReady, true once the machine can be connected to, the provider must have
+//     provided the Build with the credentials of the machine, see util.EnsureCredentialsSecret.
+//   - status.ready, true once the image of the machine is exported, the Build requests the export
+//     by setting the forge.build/export annotation on the InfraBuild.
+//   - status.failureReason and status.failureMessage, set when the build cannot succeed.
+//   - status.failureDomains, optional.
+//   - status.artifact, optional, the location of the exported image, it is reported on the Build
+//     and used by the Builds building from its image, see BuildSpec.BaseImageFrom.
+//   - status.imageDeleted, true once the exported image is deleted, the Build requests the deletion
+//     by setting the forge.build/delete-image annotation on the InfraBuild when it is deleted with
+//     spec.deleteCascade. The exported image must be preserved otherwise.
+//   - status.region, status.architecture and status.checksum, optional, recorded with the artifact
+//     in the ImageArtifact of the image.
+//
+// The CRD of the InfraBuild must list the versions implementing the contract in the
+// forge.build/v1alpha1 label and expose these fields in its schema, the Build fails otherwise,
+// see ValidateCRD.
+package infra
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	buildv1 "github.com/forge-build/forge/api/v1alpha1"
+	forgeerrors "github.com/forge-build/forge/pkg/errors"
+)
+
+// Paths of the status fields of the contract.
+var (
+	MachineReadyPath   = []string{"status", "machineReady"}
+	ReadyPath          = []string{"status", "ready"}
+	FailureReasonPath  = []string{"status", "failureReason"}
+	FailureMessagePath = []string{"status", "failureMessage"}
+	FailureDomainsPath = []string{"status", "failureDomains"}
+	ArtifactPath       = []string{"status", "artifact"}
+	RegionPath         = []string{"status", "region"}
+	ArchitecturePath   = []string{"status", "architecture"}
+	ChecksumPath       = []string{"status", "checksum"}
+	ImageDeletedPath   = []string{"status", "imageDeleted"}
+)
+
+// Status holds the status fields of the contract, providers embed it inline in the status of their InfraBuild:
+//
+//	type MyMachineStatus struct {
+//		infra.Status `json:",inline"`
+//		...
+//	}
+//
+// +kubebuilder:object:generate=true
+type Status struct {
+	// MachineReady is true once the machine can be connected to.
+	// +optional
+	MachineReady bool `json:"machineReady,omitempty"`
+
+	// Ready is true once the image of the machine is exported.
+	// +optional
+	Ready bool `json:"ready,omitempty"`
+
+	// FailureReason indicates that there is a fatal problem reconciling the
+	// state, and will be set to a token value suitable for
+	// programmatic interpretation.
+	// +optional
+	FailureReason *forgeerrors.BuildStatusError `json:"failureReason,omitempty"`
+
+	// FailureMessage indicates that there is a fatal problem reconciling the
+	// state, and will be set to a descriptive error message.
+	// +optional
+	FailureMessage *string `json:"failureMessage,omitempty"`
+
+	// FailureDomains is a slice of failure domain objects the machine can be built in.
+	// +optional
+	FailureDomains buildv1.FailureDomains `json:"failureDomains,omitempty"`
+
+	// Artifact is the location of the exported image, e.g. pvc://<claim>/<path>.
+	// +optional
+	Artifact string `json:"artifact,omitempty"`
+
+	// Region is the region the exported image is available in.
+	// +optional
+	Region string `json:"region,omitempty"`
+
+	// Architecture is the CPU architecture of the exported image, e.g. amd64.
+	// +optional
+	Architecture string `json:"architecture,omitempty"`
+
+	// Checksum is the checksum of the exported image, e.g. sha256:<digest>.
+	// +optional
+	Checksum string `json:"checksum,omitempty"`
+
+	// ImageDeleted is true once the exported image is deleted.
+	// +optional
+	ImageDeleted bool `json:"imageDeleted,omitempty"`
+}
+
+// MarkMachineReady reports that the machine can be connected to.
+func (s *Status) MarkMachineReady() {
+	s.MachineReady = true
+}
+
+// MarkReady reports that the image of the machine is exported.
+func (s *Status) MarkReady() {
+	s.Ready = true
+}
+
+// MarkImageDeleted reports that the exported image is deleted.
+func (s *Status) MarkImageDeleted() {
+	s.ImageDeleted = true
+}
+
+// MarkFailed reports a fatal problem, the Build fails with the same reason.
+func (s *Status) MarkFailed(reason forgeerrors.BuildStatusError, format string, args ...interface{}) {
+	s.FailureReason = &reason
+	msg := fmt.Sprintf(format, args...)
+	s.FailureMessage = &msg
+}
+
+// Failed returns true if a fatal problem has been reported.
+func (s *Status) Failed() bool {
+	return s.FailureReason != nil || s.FailureMessage != nil
+}
+
+// InfraBuild is an InfraBuild object with a typed contract status.
+type InfraBuild interface {
+	client.Object
+
+	// GetConditions returns the conditions of the InfraBuild.
+	GetConditions() clusterv1.Conditions
+	// SetConditions sets the conditions of the InfraBuild.
+	SetConditions(clusterv1.Conditions)
+
+	// GetContractStatus returns the contract fields of the status.
+	GetContractStatus() *Status
+}
+
+// StatusFrom reads the contract fields of the status of an unstructured InfraBuild.
+func StatusFrom(obj *unstructured.Unstructured) (*Status, error) {
+	s := &Status{}
+	status, found, err := unstructured.NestedMap(obj.Object, "status")
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read the status of %v %q", obj.GroupVersionKind(), obj.GetName())
+	}
+	if !found {
+		return s, nil
+	}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(status, s); err != nil {
+		return nil, errors.Wrapf(err, "failed to read the status of %v %q", obj.GroupVersionKind(), obj.GetName())
+	}
+	return s, nil
+}
+
+// ExportRequested returns true if the Build requested the export of the image.
+func ExportRequested(obj metav1.Object) bool {
+	_, ok := obj.GetAnnotations()[buildv1.ExportAnnotation]
+	return ok
+}
+
+// ImageDeletionRequested returns true if the Build requested the deletion of the exported image.
+func ImageDeletionRequested(obj metav1.Object) bool {
+	_, ok := obj.GetAnnotations()[buildv1.DeleteImageAnnotation]
+	return ok
+}
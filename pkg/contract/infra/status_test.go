@@ -0,0 +1,93 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package infra
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	buildv1 "github.com/forge-build/forge/api/v1alpha1"
+	forgeerrors "github.com/forge-build/forge/pkg/errors"
+)
+
+func TestStatusFrom(t *testing.T) {
+	g := NewWithT(t)
+
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"status": map[string]interface{}{
+			"machineReady":   true,
+			"ready":          false,
+			"failureReason":  "ExportFailed",
+			"failureMessage": "disk full",
+			"failureDomains": map[string]interface{}{
+				"eu-west-1a": map[string]interface{}{"controlPlane": true},
+			},
+			"artifact":     "pvc://images/ubuntu.tar.gz",
+			"architecture": "amd64",
+			"checksum":     "sha256:1234",
+		},
+	}}
+	status, err := StatusFrom(obj)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(status.MachineReady).To(BeTrue())
+	g.Expect(status.Ready).To(BeFalse())
+	g.Expect(status.FailureReason).To(HaveValue(Equal(forgeerrors.ExportFailedError)))
+	g.Expect(status.FailureMessage).To(HaveValue(Equal("disk full")))
+	g.Expect(status.FailureDomains).To(HaveKey("eu-west-1a"))
+	g.Expect(status.Artifact).To(Equal("pvc://images/ubuntu.tar.gz"))
+	g.Expect(status.Architecture).To(Equal("amd64"))
+	g.Expect(status.Checksum).To(Equal("sha256:1234"))
+	g.Expect(status.Failed()).To(BeTrue())
+
+	status, err = StatusFrom(&unstructured.Unstructured{Object: map[string]interface{}{}})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(*status).To(Equal(Status{}))
+
+	_, err = StatusFrom(&unstructured.Unstructured{Object: map[string]interface{}{
+		"status": map[string]interface{}{"ready": "yes"},
+	}})
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestStatusMarkers(t *testing.T) {
+	g := NewWithT(t)
+
+	status := &Status{}
+	g.Expect(status.Failed()).To(BeFalse())
+
+	status.MarkMachineReady()
+	status.MarkReady()
+	g.Expect(status.MachineReady).To(BeTrue())
+	g.Expect(status.Ready).To(BeTrue())
+
+	status.MarkFailed(forgeerrors.CreateBuildError, "instance %s terminated", "i-123")
+	g.Expect(status.Failed()).To(BeTrue())
+	g.Expect(status.FailureReason).To(HaveValue(Equal(forgeerrors.CreateBuildError)))
+	g.Expect(status.FailureMessage).To(HaveValue(Equal("instance i-123 terminated")))
+}
+
+func TestExportRequested(t *testing.T) {
+	g := NewWithT(t)
+
+	obj := &metav1.ObjectMeta{}
+	g.Expect(ExportRequested(obj)).To(BeFalse())
+	obj.Annotations = map[string]string{buildv1.ExportAnnotation: ""}
+	g.Expect(ExportRequested(obj)).To(BeTrue())
+}
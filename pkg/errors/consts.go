@@ -27,4 +27,22 @@ const (
 
 	// ProvisionerFailedError indicates that the provisioner failed.
 	ProvisionerFailedError BuildStatusError = "ProvisionerFailed"
+
+	// CanceledBuildError indicates that the Build was canceled.
+	CanceledBuildError BuildStatusError = "Canceled"
+
+	// CleanupFailedError indicates that the cleanup stage failed on the machine.
+	CleanupFailedError BuildStatusError = "CleanupFailed"
+
+	// ValidationFailedError indicates that a validation failed on the machine.
+	ValidationFailedError BuildStatusError = "ValidationFailed"
+
+	// ExportFailedError indicates that the infrastructure provider failed to export the image.
+	ExportFailedError BuildStatusError = "ExportFailed"
+
+	// VariantsFailedError indicates that too many variants of a matrix Build failed to reach its quorum.
+	VariantsFailedError BuildStatusError = "VariantsFailed"
+
+	// ConnectionFailedError indicates that the machine could not be connected to before the deadline of the connector.
+	ConnectionFailedError BuildStatusError = "ConnectionFailed"
 )
@@ -0,0 +1,84 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ssh
+
+import (
+	"errors"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+)
+
+// FailureReason is the cause of a failed connection to a server, see Diagnose.
+type FailureReason string
+
+const (
+	// UnreachableFailure is returned when the host cannot be resolved or routed to.
+	UnreachableFailure FailureReason = "Unreachable"
+
+	// ConnectionRefusedFailure is returned when nothing listens on the port of the server.
+	ConnectionRefusedFailure FailureReason = "ConnectionRefused"
+
+	// TimeoutFailure is returned when the server does not answer in time.
+	TimeoutFailure FailureReason = "Timeout"
+
+	// AuthenticationFailure is returned when the credentials are invalid or rejected by the server.
+	AuthenticationFailure FailureReason = "AuthenticationFailed"
+
+	// HostKeyMismatchFailure is returned when the host key of the server is not the expected one.
+	HostKeyMismatchFailure FailureReason = "HostKeyMismatch"
+
+	// ProtocolFailure is returned when the server does not complete the SSH handshake,
+	// e.g. it does not send an SSH banner or has no algorithm in common with the client.
+	ProtocolFailure FailureReason = "ProtocolError"
+
+	// UnknownFailure is returned for the other errors.
+	UnknownFailure FailureReason = "Unknown"
+)
+
+// Diagnose returns the reason of an error returned while connecting to a server.
+func Diagnose(err error) FailureReason {
+	var dnsErr *net.DNSError
+	var netErr net.Error
+	var hsErr *handshakeError
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, ErrHostKeyMismatch):
+		return HostKeyMismatchFailure
+	case errors.Is(err, ErrInvalidUsername), errors.Is(err, ErrInvalidAuth), errors.Is(err, ErrInvalidPrivateKey):
+		return AuthenticationFailure
+	case errors.As(err, &hsErr) && strings.HasPrefix(hsErr.err.Error(), "ssh: handshake failed: ssh: unable to authenticate"):
+		// The SSH client does not return a typed error when the server rejects all the methods.
+		return AuthenticationFailure
+	case errors.As(err, &dnsErr), errors.Is(err, syscall.EHOSTUNREACH), errors.Is(err, syscall.ENETUNREACH):
+		return UnreachableFailure
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return ConnectionRefusedFailure
+	case errors.Is(err, os.ErrDeadlineExceeded), errors.Is(err, syscall.ETIMEDOUT),
+		errors.As(err, &netErr) && netErr.Timeout():
+		// Checked before the handshake, which times out when the server does not send its banner.
+		return TimeoutFailure
+	case errors.As(err, &hsErr):
+		// The connection is established, the server does not answer as an SSH server.
+		return ProtocolFailure
+	case errors.Is(err, ErrTimeout):
+		return TimeoutFailure
+	}
+	return UnknownFailure
+}
@@ -0,0 +1,193 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ssh
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	cssh "golang.org/x/crypto/ssh"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/forge-build/forge/pkg/ssh/sshtest"
+)
+
+func TestDiagnose(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want FailureReason
+	}{
+		{name: "no error", err: nil, want: ""},
+		{name: "DNS", err: fmt.Errorf("failed to resolve host: %w", &net.DNSError{Err: "no such host", Name: "builder.invalid"}), want: UnreachableFailure},
+		{name: "no route", err: &net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.EHOSTUNREACH)}, want: UnreachableFailure},
+		{name: "refused", err: &net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}, want: ConnectionRefusedFailure},
+		// The error of the last attempt is kept once the client gave up.
+		{name: "refused until timeout", err: fmt.Errorf("%w: %w", ErrTimeout, &net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}), want: ConnectionRefusedFailure},
+		{name: "dial timeout", err: &net.OpError{Op: "dial", Err: os.ErrDeadlineExceeded}, want: TimeoutFailure},
+		{name: "timeout without cause", err: ErrTimeout, want: TimeoutFailure},
+		{name: "rejected credentials", err: &handshakeError{err: errors.New("ssh: handshake failed: ssh: unable to authenticate, attempted methods [none password], no supported methods remain")}, want: AuthenticationFailure},
+		{name: "missing credentials", err: ErrInvalidAuth, want: AuthenticationFailure},
+		{name: "host key", err: fmt.Errorf("ssh: handshake failed: %w", ErrHostKeyMismatch), want: HostKeyMismatchFailure},
+		{name: "banner", err: &handshakeError{err: errors.New("ssh: handshake failed: EOF")}, want: ProtocolFailure},
+		{name: "no banner", err: &handshakeError{err: fmt.Errorf("ssh: handshake failed: %w", &net.OpError{Op: "read", Err: os.ErrDeadlineExceeded})}, want: TimeoutFailure},
+		{name: "handshake message outside of a handshake", err: errors.New("ssh: handshake failed: EOF"), want: UnknownFailure},
+		{name: "other", err: errors.New("boom"), want: UnknownFailure},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Diagnose(tt.err); got != tt.want {
+				t.Errorf("Diagnose(%v) = %s, want %s", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+// connectOnce connects once to the machine of secret and returns the reason of the failure.
+func connectOnce(t *testing.T, secret *corev1.Secret) FailureReason {
+	t.Helper()
+	pool := &Pool{}
+	defer pool.Close()
+	_, err := pool.Get(context.Background(), "default/ubuntu", secret, 0)
+	return Diagnose(err)
+}
+
+func TestDiagnoseConnections(t *testing.T) {
+	srv := sshtest.NewServer(t, sshtest.WithPassword("forge", password))
+
+	t.Run("connected", func(t *testing.T) {
+		if got := connectOnce(t, srv.Secret("forge")); got != "" {
+			t.Errorf("Diagnose() = %s, want no failure", got)
+		}
+	})
+
+	t.Run("refused", func(t *testing.T) {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		secret := srv.Secret("forge")
+		secret.Data["port"] = []byte(fmt.Sprint(l.Addr().(*net.TCPAddr).Port))
+		l.Close()
+		if got := connectOnce(t, secret); got != ConnectionRefusedFailure {
+			t.Errorf("Diagnose() = %s, want %s", got, ConnectionRefusedFailure)
+		}
+	})
+
+	t.Run("wrong password", func(t *testing.T) {
+		secret := srv.Secret("forge")
+		secret.Data["password"] = []byte("wrong")
+		if got := connectOnce(t, secret); got != AuthenticationFailure {
+			t.Errorf("Diagnose() = %s, want %s", got, AuthenticationFailure)
+		}
+	})
+
+	t.Run("invalid private key", func(t *testing.T) {
+		secret := srv.Secret("forge")
+		secret.Data["privateKey"] = []byte("not a key")
+		if got := connectOnce(t, secret); got != AuthenticationFailure {
+			t.Errorf("Diagnose() = %s, want %s", got, AuthenticationFailure)
+		}
+	})
+
+	t.Run("host key mismatch", func(t *testing.T) {
+		pub, _, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		key, err := cssh.NewPublicKey(pub)
+		if err != nil {
+			t.Fatal(err)
+		}
+		secret := srv.Secret("forge")
+		secret.Data["hostKey"] = cssh.MarshalAuthorizedKey(key)
+		if got := connectOnce(t, secret); got != HostKeyMismatchFailure {
+			t.Errorf("Diagnose() = %s, want %s", got, HostKeyMismatchFailure)
+		}
+	})
+
+	t.Run("not an SSH server", func(t *testing.T) {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer l.Close()
+		go func() {
+			for {
+				conn, err := l.Accept()
+				if err != nil {
+					return
+				}
+				_, _ = conn.Write([]byte("HTTP/1.1 400 Bad Request\r\n\r\n"))
+				conn.Close()
+			}
+		}()
+		secret := srv.Secret("forge")
+		secret.Data["port"] = []byte(fmt.Sprint(l.Addr().(*net.TCPAddr).Port))
+		if got := connectOnce(t, secret); got != ProtocolFailure {
+			t.Errorf("Diagnose() = %s, want %s", got, ProtocolFailure)
+		}
+	})
+
+	t.Run("no banner", func(t *testing.T) {
+		defer func(timeout time.Duration) { handshakeTimeout = timeout }(handshakeTimeout)
+		handshakeTimeout = 200 * time.Millisecond
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer l.Close()
+		// The server accepts the connections but never sends its banner, it closes them once the client did.
+		go func() {
+			for {
+				conn, err := l.Accept()
+				if err != nil {
+					return
+				}
+				go func() {
+					defer conn.Close()
+					_, _ = io.Copy(io.Discard, conn)
+				}()
+			}
+		}()
+		secret := srv.Secret("forge")
+		secret.Data["port"] = []byte(fmt.Sprint(l.Addr().(*net.TCPAddr).Port))
+		if got := connectOnce(t, secret); got != TimeoutFailure {
+			t.Errorf("Diagnose() = %s, want %s", got, TimeoutFailure)
+		}
+	})
+}
+
+func TestDestination(t *testing.T) {
+	secret := &corev1.Secret{Data: map[string][]byte{"username": []byte("forge"), "host": []byte("10.0.0.1")}}
+	if got, want := Destination(secret), "forge@10.0.0.1:22"; got != want {
+		t.Errorf("Destination() = %s, want %s", got, want)
+	}
+	secret.Data["port"] = []byte("2222")
+	if got, want := Destination(secret), "forge@10.0.0.1:2222"; got != want {
+		t.Errorf("Destination() = %s, want %s", got, want)
+	}
+}
@@ -1,8 +1,13 @@
 package ssh
 
 import (
+	"bytes"
+	"fmt"
 	"net"
+	"strconv"
+	"strings"
 
+	cssh "golang.org/x/crypto/ssh"
 	corev1 "k8s.io/api/core/v1"
 )
 
@@ -16,13 +21,56 @@ func NewSSHClient(secret *corev1.Secret) (*SSHClient, error) {
 	if privateKey, ok := secret.Data["privateKey"]; ok {
 		creds.SSHPrivateKey = string(privateKey)
 	}
-	ip := net.ParseIP(string(secret.Data["host"]))
+	host := string(secret.Data["host"])
+	ip := net.ParseIP(host)
+	if ip == nil && host != "" {
+		// The host is not an IP address, resolve it.
+		ips, err := net.LookupIP(host)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve host %q: %w", host, err)
+		}
+		ip = ips[0]
+	}
+
+	port := sshPort
+	if p, ok := secret.Data["port"]; ok && len(p) > 0 {
+		var err error
+		if port, err = strconv.Atoi(string(p)); err != nil {
+			return nil, fmt.Errorf("invalid port %q: %w", p, err)
+		}
+	}
 
 	sshClient := &SSHClient{
 		Creds: creds,
 		IP:    ip,
-		Port:  22,
+		Port:  port,
+	}
+	if hostKey, ok := secret.Data["hostKey"]; ok && len(hostKey) > 0 {
+		key, _, _, _, err := cssh.ParseAuthorizedKey(hostKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid host key: %w", err)
+		}
+		sshClient.HostKey = key
 	}
 
 	return sshClient, nil
 }
+
+// Destination returns the user, host and port the credentials secret connects to, e.g. forge@10.0.0.1:22.
+func Destination(secret *corev1.Secret) string {
+	port := string(secret.Data["port"])
+	if port == "" {
+		port = strconv.Itoa(sshPort)
+	}
+	return fmt.Sprintf("%s@%s", secret.Data["username"], net.JoinHostPort(string(secret.Data["host"]), port))
+}
+
+// GetBootID returns the boot ID of the remote machine, which changes on every boot.
+func GetBootID(c Client) (string, error) {
+	output := &bytes.Buffer{}
+	errOutput := &bytes.Buffer{}
+	if err := c.Run("cat /proc/sys/kernel/random/boot_id", output, errOutput); err != nil {
+		return "", fmt.Errorf("failed to read boot ID: %w: %s", err, errOutput.String())
+	}
+	return strings.TrimSpace(output.String()), nil
+}
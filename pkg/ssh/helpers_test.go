@@ -0,0 +1,71 @@
+package ssh
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestNewSSHClient(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     map[string]string
+		wantIP   string
+		wantPort int
+		wantErr  bool
+	}{
+		{
+			name:     "ip address with default port",
+			data:     map[string]string{"host": "10.0.0.1", "username": "root", "password": "secret"},
+			wantIP:   "10.0.0.1",
+			wantPort: 22,
+		},
+		{
+			name:     "custom port",
+			data:     map[string]string{"host": "10.0.0.1", "port": "2222", "username": "root"},
+			wantIP:   "10.0.0.1",
+			wantPort: 2222,
+		},
+		{
+			name:     "hostname is resolved",
+			data:     map[string]string{"host": "localhost", "username": "root"},
+			wantPort: 22,
+		},
+		{
+			name:    "invalid port",
+			data:    map[string]string{"host": "10.0.0.1", "port": "ssh", "username": "root"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			secret := &corev1.Secret{Data: map[string][]byte{}}
+			for k, v := range tt.data {
+				secret.Data[k] = []byte(v)
+			}
+
+			c, err := NewSSHClient(secret)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Expected an error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if c.IP == nil {
+				t.Fatalf("Expected the host to be resolved")
+			}
+			if tt.wantIP != "" && c.IP.String() != tt.wantIP {
+				t.Errorf("Expected IP %s, got %s", tt.wantIP, c.IP)
+			}
+			if c.Port != tt.wantPort {
+				t.Errorf("Expected port %d, got %d", tt.wantPort, c.Port)
+			}
+			if c.Creds.SSHUser != "root" {
+				t.Errorf("Expected user root, got %s", c.Creds.SSHUser)
+			}
+		})
+	}
+}
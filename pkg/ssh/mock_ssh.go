@@ -76,6 +76,14 @@ func (c *MockSSHClient) WaitForSSH(maxWait time.Duration) error {
 	return ErrNotImplemented
 }
 
+// WaitForDisconnect calls the mocked WaitForDisconnect
+func (c *MockSSHClient) WaitForDisconnect(maxWait time.Duration) error {
+	if c.MockWaitForDisconnect != nil {
+		return c.MockWaitForDisconnect(maxWait)
+	}
+	return ErrNotImplemented
+}
+
 // SetSSHPrivateKey calls the mocked SetSSHPrivateKey
 func (c *MockSSHClient) SetSSHPrivateKey(s string) {
 	if c.MockSetSSHPrivateKey != nil {
@@ -154,6 +154,25 @@ func TestMockSSHClient_WaitForSSH(t *testing.T) {
 		t.Errorf("Expected error %v, got %v", expectedError, err)
 	}
 }
+func TestMockSSHClient_WaitForDisconnect(t *testing.T) {
+	// Test case 1: MockWaitForDisconnect is nil
+	c := &MockSSHClient{}
+	maxWait := time.Second
+	err := c.WaitForDisconnect(maxWait)
+	if err != ErrNotImplemented {
+		t.Errorf("Expected error %v, got %v", ErrNotImplemented, err)
+	}
+
+	// Test case 2: MockWaitForDisconnect is defined
+	expectedError := errors.New("wait for disconnect error")
+	c.MockWaitForDisconnect = func(maxWait time.Duration) error {
+		return expectedError
+	}
+	err = c.WaitForDisconnect(maxWait)
+	if err != expectedError {
+		t.Errorf("Expected error %v, got %v", expectedError, err)
+	}
+}
 func TestMockSSHClient_SetSSHPrivateKey(t *testing.T) {
 	// Test case 1: MockSetSSHPrivateKey is nil
 	c := &MockSSHClient{}
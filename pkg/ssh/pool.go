@@ -0,0 +1,178 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ssh
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// DefaultPoolKeepAlive is the keep alive interval of the pooled connections in seconds.
+const DefaultPoolKeepAlive = 30
+
+// Pool caches SSH connections by key, e.g. one per Build, so that the handshake is not repeated each
+// time a machine is reached. The sessions of the callers are multiplexed over the cached connection.
+// A connection is established again when the version of its credentials Secret changes, or when it
+// does not answer a keep alive request anymore. The zero value is an empty Pool.
+type Pool struct {
+	// KeepAlive is the keep alive interval of the connections in seconds, see Options.
+	// Defaults to DefaultPoolKeepAlive.
+	KeepAlive int
+
+	mu      sync.Mutex
+	clients map[string]*pooledClient
+}
+
+// pooledClient is a cached connection, ready is closed once it is established or failed to be.
+type pooledClient struct {
+	version string
+	ready   chan struct{}
+	client  *SSHClient
+	err     error
+}
+
+// Get returns the connection of key to the machine of the credentials secret, it is established
+// within maxWait if it is not cached. The concurrent callers share a single attempt to connect.
+// The returned client starts the spans of its operations in ctx, its connection must not be closed
+// by the caller, see Evict.
+func (p *Pool) Get(ctx context.Context, key string, secret *corev1.Secret, maxWait time.Duration) (*SSHClient, error) {
+	version := secretVersion(secret)
+	for {
+		p.mu.Lock()
+		cached, ok := p.clients[key]
+		if !ok || cached.version != version {
+			entry := &pooledClient{version: version, ready: make(chan struct{})}
+			if p.clients == nil {
+				p.clients = map[string]*pooledClient{}
+			}
+			p.clients[key] = entry
+			p.mu.Unlock()
+			if ok {
+				closePooled(cached)
+			}
+			return p.connect(ctx, key, entry, secret, maxWait)
+		}
+		p.mu.Unlock()
+
+		select {
+		case <-cached.ready:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if cached.err != nil {
+			return nil, cached.err
+		}
+		if cached.client.Alive() {
+			return cached.client.WithContext(ctx), nil
+		}
+		p.evict(key, cached)
+	}
+}
+
+// connect establishes the connection of the entry, which the other callers wait for.
+func (p *Pool) connect(ctx context.Context, key string, entry *pooledClient, secret *corev1.Secret, maxWait time.Duration) (*SSHClient, error) {
+	client, err := NewSSHClient(secret)
+	if err == nil {
+		client.Options.KeepAlive = p.KeepAlive
+		if client.Options.KeepAlive == 0 {
+			client.Options.KeepAlive = DefaultPoolKeepAlive
+		}
+		// The connection outlives the caller, only its handshake is traced in the context of the caller.
+		client.ctx = ctx
+		err = client.waitForConnect(maxWait)
+		client.ctx = nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err == nil && p.clients[key] != entry {
+		// The entry was evicted while connecting.
+		_ = client.Close()
+		err = fmt.Errorf("ssh: connection %s was evicted while connecting", key)
+	}
+	if err != nil {
+		if p.clients[key] == entry {
+			delete(p.clients, key)
+		}
+		entry.err = err
+		close(entry.ready)
+		return nil, err
+	}
+	entry.client = client
+	close(entry.ready)
+	return client.WithContext(ctx), nil
+}
+
+// Evict closes the connection of key, if any.
+func (p *Pool) Evict(key string) {
+	p.mu.Lock()
+	cached, ok := p.clients[key]
+	p.mu.Unlock()
+	if ok {
+		p.evict(key, cached)
+	}
+}
+
+// evict closes the cached connection of key, unless it was already replaced.
+func (p *Pool) evict(key string, cached *pooledClient) {
+	p.mu.Lock()
+	if p.clients[key] == cached {
+		delete(p.clients, key)
+	}
+	p.mu.Unlock()
+	closePooled(cached)
+}
+
+// closePooled closes an established connection, the ones still connecting are closed once established
+// as they are not cached anymore.
+func closePooled(cached *pooledClient) {
+	select {
+	case <-cached.ready:
+		if cached.client != nil {
+			_ = cached.client.Close()
+		}
+	default:
+	}
+}
+
+// Len returns the number of cached connections.
+func (p *Pool) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.clients)
+}
+
+// Close closes all the cached connections.
+func (p *Pool) Close() {
+	p.mu.Lock()
+	clients := p.clients
+	p.clients = nil
+	p.mu.Unlock()
+	for _, cached := range clients {
+		closePooled(cached)
+	}
+}
+
+// secretVersion returns the version of the credentials of a Secret, which changes when they are updated
+// or when the Secret is created again.
+func secretVersion(secret *corev1.Secret) string {
+	return string(secret.UID) + "/" + secret.ResourceVersion
+}
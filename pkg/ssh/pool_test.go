@@ -0,0 +1,206 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ssh
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/forge-build/forge/pkg/ssh/sshtest"
+)
+
+// waitFor waits for condition, which is checked until timeout.
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Condition not met after 5s")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestPoolReusesConnections(t *testing.T) {
+	srv := sshtest.NewServer(t, sshtest.WithPassword("forge", password), sshtest.WithHandler(echo))
+	secret := srv.Secret("forge")
+	secret.ResourceVersion = "1"
+	pool := &Pool{}
+	t.Cleanup(pool.Close)
+	ctx := context.Background()
+
+	first, err := pool.Get(ctx, "default/ubuntu", secret, time.Second)
+	if err != nil {
+		t.Fatalf("Expected nil error, got %s", err)
+	}
+	second, err := pool.Get(ctx, "default/ubuntu", secret, time.Second)
+	if err != nil {
+		t.Fatalf("Expected nil error, got %s", err)
+	}
+	if first.cryptoClient != second.cryptoClient {
+		t.Errorf("Expected the cached connection to be reused")
+	}
+
+	// The sessions are multiplexed over the connection.
+	for i := 0; i < 3; i++ {
+		if err := second.Run("true", &bytes.Buffer{}, &bytes.Buffer{}); err != nil {
+			t.Fatalf("Expected nil error, got %s", err)
+		}
+	}
+	if got := srv.Connections(); got != 1 {
+		t.Errorf("Expected 1 connection, got %d", got)
+	}
+
+	// Another Build has its own connection.
+	if _, err := pool.Get(ctx, "default/debian", secret, time.Second); err != nil {
+		t.Fatalf("Expected nil error, got %s", err)
+	}
+	if pool.Len() != 2 {
+		t.Errorf("Expected 2 cached connections, got %d", pool.Len())
+	}
+}
+
+func TestPoolConcurrentGets(t *testing.T) {
+	srv := sshtest.NewServer(t, sshtest.WithPassword("forge", password), sshtest.WithHandler(echo))
+	secret := srv.Secret("forge")
+	pool := &Pool{}
+	t.Cleanup(pool.Close)
+
+	type ctxKey struct{}
+	clients := make([]*SSHClient, 10)
+	errs := make([]error, len(clients))
+	var wg sync.WaitGroup
+	for i := range clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx := context.WithValue(context.Background(), ctxKey{}, i)
+			clients[i], errs[i] = pool.Get(ctx, "default/ubuntu", secret, time.Second)
+		}()
+	}
+	wg.Wait()
+
+	// The callers share a single connection, each with its own context.
+	for i, c := range clients {
+		if errs[i] != nil {
+			t.Fatalf("Expected nil error, got %s", errs[i])
+		}
+		if c.cryptoClient != clients[0].cryptoClient {
+			t.Errorf("Expected the callers to share the connection")
+		}
+		if got := c.ctx.Value(ctxKey{}); got != i {
+			t.Errorf("Expected the context of caller %d, got the one of %v", i, got)
+		}
+		if err := c.Run("true", &bytes.Buffer{}, &bytes.Buffer{}); err != nil {
+			t.Errorf("Expected nil error, got %s", err)
+		}
+	}
+	if got := srv.Connections(); got != 1 {
+		t.Errorf("Expected 1 connection, got %d", got)
+	}
+}
+
+func TestPoolReconnects(t *testing.T) {
+	srv := sshtest.NewServer(t, sshtest.WithPassword("forge", password))
+	secret := srv.Secret("forge")
+	secret.ResourceVersion = "1"
+	pool := &Pool{}
+	t.Cleanup(pool.Close)
+	ctx := context.Background()
+
+	first, err := pool.Get(ctx, "default/ubuntu", secret, time.Second)
+	if err != nil {
+		t.Fatalf("Expected nil error, got %s", err)
+	}
+
+	// The credentials changed, the connection is established again and the stale one closed.
+	secret.ResourceVersion = "2"
+	second, err := pool.Get(ctx, "default/ubuntu", secret, time.Second)
+	if err != nil {
+		t.Fatalf("Expected nil error, got %s", err)
+	}
+	if first.cryptoClient == second.cryptoClient {
+		t.Errorf("Expected a new connection after the credentials changed")
+	}
+	waitFor(t, func() bool { return srv.OpenConnections() == 1 })
+
+	// The connection dropped, e.g. the machine rebooted, it fails the health check.
+	srv.Disconnect()
+	waitFor(t, func() bool { return srv.OpenConnections() == 0 })
+	third, err := pool.Get(ctx, "default/ubuntu", secret, time.Second)
+	if err != nil {
+		t.Fatalf("Expected nil error, got %s", err)
+	}
+	if third.cryptoClient == second.cryptoClient {
+		t.Errorf("Expected a new connection after the connection dropped")
+	}
+	// The command runs on the new connection, the server has no handler for it.
+	if code, ok := ExitStatus(third.Run("true", &bytes.Buffer{}, &bytes.Buffer{})); !ok || code != 127 {
+		t.Errorf("ExitStatus() = %d, %v, want 127, true", code, ok)
+	}
+	if got := srv.Connections(); got != 3 {
+		t.Errorf("Expected 3 connections, got %d", got)
+	}
+}
+
+func TestPoolEvict(t *testing.T) {
+	srv := sshtest.NewServer(t, sshtest.WithPassword("forge", password))
+	pool := &Pool{}
+	ctx := context.Background()
+
+	if _, err := pool.Get(ctx, "default/ubuntu", srv.Secret("forge"), time.Second); err != nil {
+		t.Fatalf("Expected nil error, got %s", err)
+	}
+	if _, err := pool.Get(ctx, "default/debian", srv.Secret("forge"), time.Second); err != nil {
+		t.Fatalf("Expected nil error, got %s", err)
+	}
+
+	pool.Evict("default/ubuntu")
+	pool.Evict("default/missing")
+	if pool.Len() != 1 {
+		t.Errorf("Expected 1 cached connection, got %d", pool.Len())
+	}
+	waitFor(t, func() bool { return srv.OpenConnections() == 1 })
+
+	pool.Close()
+	if pool.Len() != 0 {
+		t.Errorf("Expected no cached connection, got %d", pool.Len())
+	}
+	waitFor(t, func() bool { return srv.OpenConnections() == 0 })
+}
+
+func TestPoolConnectionFailure(t *testing.T) {
+	srv := sshtest.NewServer(t, sshtest.WithPassword("forge", password))
+	secret := srv.Secret("forge")
+	secret.Data["password"] = []byte("wrong")
+	pool := &Pool{}
+
+	_, err := pool.Get(context.Background(), "default/ubuntu", secret, 0)
+	if !errors.Is(err, ErrTimeout) {
+		t.Errorf("Expected error %s, got %v", ErrTimeout, err)
+	}
+	if reason := Diagnose(err); reason != AuthenticationFailure {
+		t.Errorf("Diagnose() = %s, want %s", reason, AuthenticationFailure)
+	}
+	if pool.Len() != 0 {
+		t.Errorf("Expected no cached connection, got %d", pool.Len())
+	}
+}
@@ -19,6 +19,7 @@ package ssh
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -29,9 +30,14 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
 	cssh "golang.org/x/crypto/ssh"
+
+	"github.com/forge-build/forge/pkg/tracing"
 )
 
 var (
@@ -53,6 +59,10 @@ var (
 	ErrUnableToWriteFile = errors.New("unable to write file")
 	// ErrNotImplemented is returned when a function is not implemented (typically by the Mock implementation).
 	ErrNotImplemented = errors.New("operation not implemented")
+	// ErrInvalidPrivateKey is returned when the private key cannot be parsed.
+	ErrInvalidPrivateKey = errors.New("invalid private key")
+	// ErrHostKeyMismatch is returned when the host key of the server is not the expected one, see SSHClient.HostKey.
+	ErrHostKeyMismatch = errors.New("host key mismatch")
 	// Setup a mutex for the close channel for thread safety.
 	closeMutex sync.Mutex
 )
@@ -68,6 +78,12 @@ const (
 
 	// Timeout for connecting to an SSH server.
 	Timeout = 60 * time.Second
+
+	// keepAliveRequest is the global request sent to check that a connection is alive.
+	keepAliveRequest = "forge-ssh"
+
+	// aliveTimeout is how long to wait for the reply to a keep alive request, see Alive.
+	aliveTimeout = 5 * time.Second
 )
 
 // Client represents an interface for abstracting common ssh operations.
@@ -79,6 +95,7 @@ type Client interface {
 	Upload(src io.Reader, dst string, mode uint32) error
 	Validate() error
 	WaitForSSH(maxWait time.Duration) error
+	WaitForDisconnect(maxWait time.Duration) error
 
 	SetSSHPrivateKey(string)
 	GetSSHPrivateKey() string
@@ -107,9 +124,12 @@ type SSHClient struct {
 	IP      net.IP
 	Port    int
 	Options Options
+	// HostKey is the expected public key of the server, any key is accepted when nil.
+	HostKey cssh.PublicKey
 
 	cryptoClient *cssh.Client
 	close        chan bool
+	ctx          context.Context
 }
 
 // MockSSHClient represents a Mock Client wrapper.
@@ -122,12 +142,30 @@ type MockSSHClient struct {
 	MockValidate   func() error
 	MockWaitForSSH func(maxWait time.Duration) error
 
+	MockWaitForDisconnect func(maxWait time.Duration) error
+
 	MockSetSSHPrivateKey func(string)
 	MockGetSSHPrivateKey func() string
 	MockSetSSHPassword   func(string)
 	MockGetSSHPassword   func() string
 }
 
+// handshakeTimeout is how long to wait for a server to complete the SSH handshake.
+var handshakeTimeout = Timeout
+
+// handshakeError is returned when the connection to the server is established but the SSH handshake fails.
+type handshakeError struct {
+	err error
+}
+
+func (e *handshakeError) Error() string {
+	return e.err.Error()
+}
+
+func (e *handshakeError) Unwrap() error {
+	return e.err
+}
+
 // dial will attempt to connect to an SSH server.
 var dial = func(network, addr string, config *cssh.ClientConfig) (*cssh.Client, error) {
 	d := net.Dialer{Timeout: Timeout, KeepAlive: 2 * time.Second}
@@ -137,10 +175,14 @@ var dial = func(network, addr string, config *cssh.ClientConfig) (*cssh.Client,
 		return nil, err
 	}
 
+	// Do not wait forever for a server which accepts the connection but never sends its banner.
+	_ = conn.SetDeadline(time.Now().Add(handshakeTimeout))
 	c, chans, reqs, err := cssh.NewClientConn(conn, addr, config)
 	if err != nil {
-		return nil, err
+		_ = conn.Close()
+		return nil, &handshakeError{err: err}
 	}
+	_ = conn.SetDeadline(time.Time{})
 
 	return cssh.NewClient(c, chans, reqs), nil
 }
@@ -164,17 +206,70 @@ var getAuth = func(c *Credentials, authType string) (cssh.AuthMethod, error) {
 	case PasswordAuth:
 		return cssh.Password(c.SSHPassword), nil
 	case KeyAuth:
-		return readPrivateKey(c.SSHPrivateKey)
+		auth, err = readPrivateKey(c.SSHPrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidPrivateKey, err)
+		}
 	}
 	return auth, err
 }
 
-// Connect connects to a machine using SSH.
-func (client *SSHClient) Connect() error {
-	var (
-		auth cssh.AuthMethod
-		err  error
+// SetContext sets the context the spans of the SSH operations are started in.
+func (client *SSHClient) SetContext(ctx context.Context) {
+	client.ctx = ctx
+}
+
+// WithContext returns a client sharing the connection of client, which starts the spans of its
+// operations in ctx. Disconnecting it does not stop the keep alive requests of the connection.
+func (client *SSHClient) WithContext(ctx context.Context) *SSHClient {
+	return &SSHClient{
+		Creds:        client.Creds,
+		IP:           client.IP,
+		Port:         client.Port,
+		Options:      client.Options,
+		HostKey:      client.HostKey,
+		cryptoClient: client.cryptoClient,
+		ctx:          ctx,
+	}
+}
+
+// startSpan starts the span of an SSH operation.
+func (client *SSHClient) startSpan(name string) trace.Span {
+	ctx := client.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	_, span := tracing.Tracer().Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.ServerAddress(client.IP.String()), semconv.ServerPort(client.port())),
 	)
+	return span
+}
+
+// checkHostKey checks the host key of the server against HostKey.
+func (client *SSHClient) checkHostKey(_ string, _ net.Addr, key cssh.PublicKey) error {
+	if client.HostKey == nil {
+		return nil
+	}
+	if !bytes.Equal(key.Marshal(), client.HostKey.Marshal()) {
+		return fmt.Errorf("%w: got %s %s", ErrHostKeyMismatch, key.Type(), cssh.FingerprintSHA256(key))
+	}
+	return nil
+}
+
+func (client *SSHClient) port() int {
+	if client.Port != 0 {
+		return client.Port
+	}
+	return sshPort
+}
+
+// Connect connects to a machine using SSH.
+func (client *SSHClient) Connect() (err error) {
+	span := client.startSpan("ssh.Connect")
+	defer func() { tracing.End(span, err) }()
+
+	var auth cssh.AuthMethod
 
 	if err = client.Validate(); err != nil {
 		return err
@@ -197,15 +292,10 @@ func (client *SSHClient) Connect() error {
 		Auth: []cssh.AuthMethod{
 			auth,
 		},
-		HostKeyCallback: cssh.InsecureIgnoreHostKey(),
+		HostKeyCallback: client.checkHostKey,
 	}
 
-	port := sshPort
-	if client.Port != 0 {
-		port = client.Port
-	}
-
-	c, err := dial("tcp", fmt.Sprintf("%s:%d", client.IP, port), config)
+	c, err := dial("tcp", fmt.Sprintf("%s:%d", client.IP, client.port()), config)
 	if err != nil {
 		return err
 	}
@@ -219,29 +309,57 @@ func (client *SSHClient) Connect() error {
 		client.close = make(chan bool, 1)
 	}
 	if client.Options.KeepAlive > 0 {
-		go client.keepAlive()
+		go client.keepAlive(client.close)
 	}
 	return nil
 }
 
-func (client *SSHClient) keepAlive() {
+// keepAlive sends keep alive requests until Disconnect closes the closed channel.
+func (client *SSHClient) keepAlive(closed <-chan bool) {
 	t := time.NewTicker(time.Duration(client.Options.KeepAlive) * time.Second)
 	defer t.Stop()
 	for {
 		select {
 		case <-t.C:
 			// send a keep alive request on the underlying channel
-			_, _, err := client.cryptoClient.Conn.SendRequest("forge-ssh", true, nil)
+			_, _, err := client.cryptoClient.Conn.SendRequest(keepAliveRequest, true, nil)
 			if err != nil {
 				return
 			}
-		case <-client.close:
+		case <-closed:
 			// client is disconnecting, close it
 			return
 		}
 	}
 }
 
+// Alive returns true if the connection answers a keep alive request.
+func (client *SSHClient) Alive() bool {
+	if client.cryptoClient == nil {
+		return false
+	}
+	replied := make(chan error, 1)
+	go func() {
+		_, _, err := client.cryptoClient.Conn.SendRequest(keepAliveRequest, true, nil)
+		replied <- err
+	}()
+	select {
+	case err := <-replied:
+		return err == nil
+	case <-time.After(aliveTimeout):
+		return false
+	}
+}
+
+// Close disconnects the client and closes its connection.
+func (client *SSHClient) Close() error {
+	client.Disconnect()
+	if client.cryptoClient == nil {
+		return nil
+	}
+	return client.cryptoClient.Close()
+}
+
 // Disconnect should be called when the ssh client is no longer needed, and state can be cleaned up
 func (client *SSHClient) Disconnect() {
 	select {
@@ -258,7 +376,10 @@ func (client *SSHClient) Disconnect() {
 }
 
 // Download downloads a file via SSH (SCP)
-func (client *SSHClient) Download(dst io.WriteCloser, remotePath string) error {
+func (client *SSHClient) Download(dst io.WriteCloser, remotePath string) (err error) {
+	span := client.startSpan("ssh.Download")
+	defer func() { tracing.End(span, err) }()
+
 	defer func() {
 		if err := dst.Close(); err != nil {
 			log.Println(err)
@@ -366,7 +487,10 @@ func (client *SSHClient) Download(dst io.WriteCloser, remotePath string) error {
 }
 
 // Run runs a command via SSH.
-func (client *SSHClient) Run(command string, stdout io.Writer, stderr io.Writer) error {
+func (client *SSHClient) Run(command string, stdout io.Writer, stderr io.Writer) (err error) {
+	span := client.startSpan("ssh.Run")
+	defer func() { tracing.End(span, err) }()
+
 	session, err := client.cryptoClient.NewSession()
 	if err != nil {
 		return err
@@ -397,7 +521,10 @@ func (client *SSHClient) Run(command string, stdout io.Writer, stderr io.Writer)
 }
 
 // Upload uploads a new file via SSH (SCP)
-func (client *SSHClient) Upload(src io.Reader, dst string, mode uint32) error {
+func (client *SSHClient) Upload(src io.Reader, dst string, mode uint32) (err error) {
+	span := client.startSpan("ssh.Upload")
+	defer func() { tracing.End(span, err) }()
+
 	fileContent, err := io.ReadAll(src)
 	if err != nil {
 		return err
@@ -482,12 +609,22 @@ func (client *SSHClient) Validate() error {
 // WaitForSSH will try to connect to an SSH server. If it fails, then it'll
 // sleep for 2 seconds.
 func (client *SSHClient) WaitForSSH(maxWait time.Duration) error {
+	if err := client.waitForConnect(maxWait); err != nil {
+		return ErrTimeout
+	}
+	client.Disconnect()
+	return nil
+}
+
+// waitForConnect tries to connect to an SSH server until maxWait elapsed, the client stays connected.
+// The error wraps ErrTimeout and the error of the last attempt, see Diagnose.
+func (client *SSHClient) waitForConnect(maxWait time.Duration) error {
 	start := time.Now()
 
+	var err error
 	for {
-		err := client.Connect()
+		err = client.Connect()
 		if err == nil {
-			defer client.Disconnect()
 			return nil
 		}
 
@@ -499,7 +636,83 @@ func (client *SSHClient) WaitForSSH(maxWait time.Duration) error {
 		time.Sleep(2 * time.Second)
 	}
 
-	return ErrTimeout
+	return fmt.Errorf("%w: %w", ErrTimeout, err)
+}
+
+// WaitForDisconnect waits for the remote end to drop the current connection,
+// e.g. because the machine is rebooting. The keep alive request is used to
+// detect connections that were not closed cleanly. The connection is closed
+// once maxWait elapsed.
+func (client *SSHClient) WaitForDisconnect(maxWait time.Duration) error {
+	if client.cryptoClient == nil {
+		return nil
+	}
+
+	done := make(chan struct{})
+	go func() {
+		_ = client.cryptoClient.Wait()
+		close(done)
+	}()
+
+	t := time.NewTicker(2 * time.Second)
+	defer t.Stop()
+	timeout := time.After(maxWait)
+	// The keep alive requests are sent in the background, they block until the
+	// remote end replies and a half-open connection never does.
+	replied := make(chan error, 1)
+	probing := false
+	for {
+		select {
+		case <-done:
+			client.Disconnect()
+			return nil
+		case <-t.C:
+			if probing {
+				continue
+			}
+			probing = true
+			go func() {
+				_, _, err := client.cryptoClient.Conn.SendRequest(keepAliveRequest, true, nil)
+				replied <- err
+			}()
+		case err := <-replied:
+			probing = false
+			if err != nil {
+				_ = client.cryptoClient.Close()
+				client.Disconnect()
+				return nil
+			}
+		case <-timeout:
+			// Closing the connection ends the pending keep alive request and Wait.
+			_ = client.cryptoClient.Close()
+			client.Disconnect()
+			return ErrTimeout
+		}
+	}
+}
+
+// IsDisconnectError returns true if the error is caused by the remote end
+// dropping the connection while a command was running.
+func IsDisconnectError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var exitMissing *cssh.ExitMissingError
+	if errors.As(err, &exitMissing) {
+		return true
+	}
+	// Dial errors, e.g. a refused connection, are not disconnects: the command never ran.
+	return errors.Is(err, io.EOF) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE)
+}
+
+// ExitStatus returns the exit status of the remote command which failed with err,
+// it returns false if the command did not exit, e.g. because the connection dropped.
+func ExitStatus(err error) (int, bool) {
+	var exitErr *cssh.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitStatus(), true
+	}
+	return 0, false
 }
 
 // SetSSHPrivateKey sets the private key on the clients credentials.
@@ -0,0 +1,247 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ssh
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	cssh "golang.org/x/crypto/ssh"
+
+	"github.com/forge-build/forge/pkg/ssh/sshtest"
+)
+
+// connectToServer connects a client to srv with the credentials of user.
+func connectToServer(t *testing.T, srv *sshtest.Server, user string) *SSHClient {
+	t.Helper()
+	c, err := NewSSHClient(srv.Secret(user))
+	if err != nil {
+		t.Fatalf("Expected nil error, got %s", err)
+	}
+	if err := c.Connect(); err != nil {
+		t.Fatalf("Expected nil error connecting, got %s", err)
+	}
+	t.Cleanup(c.Disconnect)
+	return c
+}
+
+type bufferCloser struct {
+	bytes.Buffer
+}
+
+func (b *bufferCloser) Close() error {
+	return nil
+}
+
+// echo writes the command it runs to stdout.
+func echo(e *sshtest.Exec) int {
+	fmt.Fprint(e.Stdout, e.Command)
+	return 0
+}
+
+func TestSSHClientPasswordAuth(t *testing.T) {
+	srv := sshtest.NewServer(t, sshtest.WithPassword("forge", password), sshtest.WithHandler(echo))
+	c := connectToServer(t, srv, "forge")
+
+	stdout := &bytes.Buffer{}
+	if err := c.Run("uname -a", stdout, &bytes.Buffer{}); err != nil {
+		t.Fatalf("Expected nil error, got %s", err)
+	}
+	if stdout.String() != "uname -a" {
+		t.Errorf("Unexpected output %q", stdout.String())
+	}
+
+	wrong, err := NewSSHClient(srv.Secret("forge"))
+	if err != nil {
+		t.Fatalf("Expected nil error, got %s", err)
+	}
+	wrong.Creds.SSHPassword = "wrong"
+	if err := wrong.Connect(); err == nil {
+		t.Errorf("Expected an error connecting with a wrong password")
+	}
+}
+
+func TestSSHClientKeyAuth(t *testing.T) {
+	kp, err := NewKeyPair()
+	if err != nil {
+		t.Fatalf("Expected nil error, got %s", err)
+	}
+	key, _, _, _, err := cssh.ParseAuthorizedKey(kp.PublicKey)
+	if err != nil {
+		t.Fatalf("Expected nil error, got %s", err)
+	}
+	srv := sshtest.NewServer(t, sshtest.WithAuthorizedKey("forge", key), sshtest.WithHandler(echo))
+
+	secret := srv.Secret("forge")
+	secret.Data["privateKey"] = kp.PrivateKey
+	c, err := NewSSHClient(secret)
+	if err != nil {
+		t.Fatalf("Expected nil error, got %s", err)
+	}
+	if err := c.Connect(); err != nil {
+		t.Fatalf("Expected nil error connecting, got %s", err)
+	}
+	c.Disconnect()
+
+	other, err := NewKeyPair()
+	if err != nil {
+		t.Fatalf("Expected nil error, got %s", err)
+	}
+	c.Creds.SSHPrivateKey = string(other.PrivateKey)
+	if err := c.Connect(); err == nil {
+		t.Errorf("Expected an error connecting with an unknown key")
+	}
+}
+
+func TestSSHClientRunExitStatus(t *testing.T) {
+	srv := sshtest.NewServer(t, sshtest.WithPassword("forge", password), sshtest.WithHandler(func(e *sshtest.Exec) int {
+		switch e.Command {
+		case "reboot":
+			return sshtest.Disconnect
+		default:
+			fmt.Fprint(e.Stderr, "no space left on device")
+			return 3
+		}
+	}))
+
+	c := connectToServer(t, srv, "forge")
+	stderr := &bytes.Buffer{}
+	err := c.Run("make", &bytes.Buffer{}, stderr)
+	if code, ok := ExitStatus(err); !ok || code != 3 {
+		t.Errorf("ExitStatus() = %d, %v, want 3, true", code, ok)
+	}
+	if IsDisconnectError(err) {
+		t.Errorf("IsDisconnectError() of an exit status = true, want false")
+	}
+	if stderr.String() != "no space left on device" {
+		t.Errorf("Unexpected error output %q", stderr.String())
+	}
+
+	err = c.Run("reboot", &bytes.Buffer{}, &bytes.Buffer{})
+	if !IsDisconnectError(err) {
+		t.Errorf("IsDisconnectError() of a dropped connection = false, want true: %v", err)
+	}
+	if _, ok := ExitStatus(err); ok {
+		t.Errorf("ExitStatus() of a dropped connection = true, want false")
+	}
+}
+
+func TestSSHClientPty(t *testing.T) {
+	t.Setenv("TERM", "xterm")
+	srv := sshtest.NewServer(t, sshtest.WithPassword("forge", password), sshtest.WithHandler(echo))
+
+	c := connectToServer(t, srv, "forge")
+	if err := c.Run("true", &bytes.Buffer{}, &bytes.Buffer{}); err != nil {
+		t.Fatalf("Expected nil error, got %s", err)
+	}
+	c.Options.Pty = true
+	if err := c.Run("sudo true", &bytes.Buffer{}, &bytes.Buffer{}); err != nil {
+		t.Fatalf("Expected nil error, got %s", err)
+	}
+
+	execs := srv.Execs()
+	if len(execs) != 2 {
+		t.Fatalf("Expected 2 commands, got %d", len(execs))
+	}
+	if execs[0].Pty != nil {
+		t.Errorf("Unexpected pty %+v", execs[0].Pty)
+	}
+	if pty := execs[1].Pty; pty == nil || pty.Term != "xterm" || pty.Columns != 40 || pty.Rows != 80 {
+		t.Errorf("Unexpected pty %+v", pty)
+	}
+}
+
+func TestSSHClientUploadDownload(t *testing.T) {
+	srv := sshtest.NewServer(t, sshtest.WithPassword("forge", password))
+	c := connectToServer(t, srv, "forge")
+
+	if err := c.Upload(strings.NewReader("hello forge\n"), "/tmp/forge/hello.txt", 0o640); err != nil {
+		t.Fatalf("Expected nil error uploading, got %s", err)
+	}
+	data, err := srv.ReadFile("/tmp/forge/hello.txt")
+	if err != nil {
+		t.Fatalf("Expected nil error, got %s", err)
+	}
+	if string(data) != "hello forge\n" {
+		t.Errorf("Unexpected uploaded content %q", data)
+	}
+	info, err := os.Stat(srv.Path("/tmp/forge/hello.txt"))
+	if err != nil {
+		t.Fatalf("Expected nil error, got %s", err)
+	}
+	if info.Mode().Perm() != 0o640 {
+		t.Errorf("Unexpected uploaded mode %o", info.Mode().Perm())
+	}
+
+	downloaded := &bufferCloser{}
+	if err := c.Download(downloaded, "/tmp/forge/hello.txt"); err != nil {
+		t.Fatalf("Expected nil error downloading, got %s", err)
+	}
+	if downloaded.String() != "hello forge\n" {
+		t.Errorf("Unexpected downloaded content %q", downloaded.String())
+	}
+
+	if err := c.Download(&bufferCloser{}, "/tmp/forge/missing.txt"); err == nil {
+		t.Errorf("Expected an error downloading a missing file")
+	}
+}
+
+func TestSSHClientKeepAlive(t *testing.T) {
+	srv := sshtest.NewServer(t, sshtest.WithPassword("forge", password))
+	c, err := NewSSHClient(srv.Secret("forge"))
+	if err != nil {
+		t.Fatalf("Expected nil error, got %s", err)
+	}
+	c.Options.KeepAlive = 1
+	if err := c.Connect(); err != nil {
+		t.Fatalf("Expected nil error connecting, got %s", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for srv.Requests("forge-ssh") == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("No keep alive request received")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	// The keep alives stop once the client disconnects.
+	c.Disconnect()
+	time.Sleep(1500 * time.Millisecond)
+	sent := srv.Requests("forge-ssh")
+	time.Sleep(1500 * time.Millisecond)
+	if srv.Requests("forge-ssh") != sent {
+		t.Errorf("Keep alive requests sent after disconnecting")
+	}
+}
+
+func TestSSHClientWaitForDisconnect(t *testing.T) {
+	srv := sshtest.NewServer(t, sshtest.WithPassword("forge", password))
+	c := connectToServer(t, srv, "forge")
+
+	srv.Disconnect()
+	if err := c.WaitForDisconnect(10 * time.Second); err != nil {
+		t.Fatalf("Expected nil error, got %s", err)
+	}
+	if err := c.WaitForSSH(10 * time.Second); err != nil {
+		t.Errorf("Expected nil error reconnecting, got %s", err)
+	}
+}
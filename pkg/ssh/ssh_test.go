@@ -17,8 +17,12 @@ limitations under the License.
 package ssh
 
 import (
+	"errors"
+	"fmt"
 	"io"
 	"net"
+	"os"
+	"syscall"
 	"testing"
 	"time"
 
@@ -27,7 +31,12 @@ import (
 
 const password = "password123"
 
-func requireMockedClient() SSHClient {
+func requireMockedClient(t *testing.T) SSHClient {
+	realDial, realReadPrivateKey := dial, readPrivateKey
+	t.Cleanup(func() {
+		dial, readPrivateKey = realDial, realReadPrivateKey
+	})
+
 	c := SSHClient{}
 	c.Creds = &Credentials{}
 	dial = func(_ string, _ string, _ *cssh.ClientConfig) (*cssh.Client, error) {
@@ -41,7 +50,7 @@ func requireMockedClient() SSHClient {
 
 // TestConnectNoUsername tests that an error is returned if no username is provided.
 func TestConnectNoUsername(t *testing.T) {
-	c := requireMockedClient()
+	c := requireMockedClient(t)
 	err := c.Connect()
 	if err != ErrInvalidUsername {
 		t.Logf("Invalid error type returned %s", err)
@@ -51,7 +60,7 @@ func TestConnectNoUsername(t *testing.T) {
 
 // TestConnectNoPassword tests that an error is returned if no password or key is provided.
 func TestConnectNoPassword(t *testing.T) {
-	c := requireMockedClient()
+	c := requireMockedClient(t)
 	c.Creds.SSHUser = "foo"
 	err := c.Connect()
 	if err != ErrInvalidAuth {
@@ -62,7 +71,7 @@ func TestConnectNoPassword(t *testing.T) {
 
 // TestConnectAuthPrecedence tests that key based auth takes precedence over password based auth
 func TestConnectAuthPrecedence(t *testing.T) {
-	c := requireMockedClient()
+	c := requireMockedClient(t)
 	count := 0
 
 	c.Creds = &Credentials{
@@ -88,7 +97,7 @@ func TestConnectAuthPrecedence(t *testing.T) {
 
 // TestSetSSHPrivateKey tests the SetSSHPrivateKey method of SSHClient.
 func TestSetSSHPrivateKey(t *testing.T) {
-	c := requireMockedClient()
+	c := requireMockedClient(t)
 	privateKey := "/path/to/private/key"
 	c.SetSSHPrivateKey(privateKey)
 
@@ -99,7 +108,7 @@ func TestSetSSHPrivateKey(t *testing.T) {
 
 // TestGetSSHPrivateKey tests the GetSSHPrivateKey method of SSHClient.
 func TestGetSSHPrivateKey(t *testing.T) {
-	c := requireMockedClient()
+	c := requireMockedClient(t)
 	privateKey := "/path/to/private/key"
 	c.Creds.SSHPrivateKey = privateKey
 
@@ -110,7 +119,7 @@ func TestGetSSHPrivateKey(t *testing.T) {
 	}
 } // TestSetSSHPassword tests the SetSSHPassword method of SSHClient.
 func TestSetSSHPassword(t *testing.T) {
-	c := requireMockedClient()
+	c := requireMockedClient(t)
 	c.SetSSHPassword(password)
 
 	if c.Creds.SSHPassword != password {
@@ -120,7 +129,7 @@ func TestSetSSHPassword(t *testing.T) {
 
 // TestGetSSHPassword tests the GetSSHPassword method of SSHClient.
 func TestGetSSHPassword(t *testing.T) {
-	c := requireMockedClient()
+	c := requireMockedClient(t)
 	c.Creds.SSHPassword = password
 
 	result := c.GetSSHPassword()
@@ -134,7 +143,7 @@ func TestGetSSHPassword(t *testing.T) {
 
 // TestValidate tests the Validate method of SSHClient.
 func TestValidate(t *testing.T) {
-	c := requireMockedClient()
+	c := requireMockedClient(t)
 
 	// Test case 1: Empty SSHUser
 	c.Creds.SSHUser = ""
@@ -365,7 +374,7 @@ func TestMockSSHClient(t *testing.T) {
 
 // TestGetAuth tests the getAuth function.
 func TestGetAuth(t *testing.T) {
-	c := requireMockedClient()
+	c := requireMockedClient(t)
 
 	// Test case 1: PasswordAuth
 	c.Creds.SSHPassword = password
@@ -413,7 +422,7 @@ func TestSSHClient_WaitForSSH(t *testing.T) {
 }
 func TestWaitForSSH_Timeout(t *testing.T) {
 	// Create a mocked SSHClient
-	c := requireMockedClient()
+	c := requireMockedClient(t)
 
 	// Set up the Connect function to always return an error
 	_ = func() error {
@@ -429,3 +438,66 @@ func TestWaitForSSH_Timeout(t *testing.T) {
 		t.Errorf("Expected error %s, got %s", ErrTimeout, err)
 	}
 }
+
+// TestWaitForDisconnect_NotConnected tests that a client which never connected is considered disconnected.
+func TestWaitForDisconnect_NotConnected(t *testing.T) {
+	c := requireMockedClient(t)
+	if err := c.WaitForDisconnect(time.Second); err != nil {
+		t.Errorf("Expected nil error, got %s", err)
+	}
+}
+
+func TestIsDisconnectError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "nil error", err: nil, want: false},
+		{name: "exit missing", err: &cssh.ExitMissingError{}, want: true},
+		{name: "EOF", err: io.EOF, want: true},
+		{name: "wrapped EOF", err: fmt.Errorf("run: %w", io.EOF), want: true},
+		{name: "connection reset", err: &net.OpError{Op: "read", Err: os.NewSyscallError("read", syscall.ECONNRESET)}, want: true},
+		{name: "broken pipe", err: &net.OpError{Op: "write", Err: os.NewSyscallError("write", syscall.EPIPE)}, want: true},
+		{name: "connection refused", err: &net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}, want: false},
+		{name: "dial error", err: &net.OpError{Op: "dial", Err: errors.New("no route to host")}, want: false},
+		{name: "exit status", err: &cssh.ExitError{}, want: false},
+		{name: "other error", err: errors.New("boom"), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsDisconnectError(tt.err); got != tt.want {
+				t.Errorf("IsDisconnectError() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExitStatus(t *testing.T) {
+	exitErr := &cssh.ExitError{Waitmsg: cssh.Waitmsg{}}
+	if _, ok := ExitStatus(exitErr); !ok {
+		t.Errorf("ExitStatus() of an exit error = false, want true")
+	}
+	if _, ok := ExitStatus(fmt.Errorf("run: %w", &cssh.ExitMissingError{})); ok {
+		t.Errorf("ExitStatus() of a missing exit = true, want false")
+	}
+	if _, ok := ExitStatus(nil); ok {
+		t.Errorf("ExitStatus() of nil = true, want false")
+	}
+}
+
+func TestGetBootID(t *testing.T) {
+	c := &MockSSHClient{
+		MockRun: func(command string, stdout io.Writer, _ io.Writer) error {
+			_, err := io.WriteString(stdout, "0f3c4a6e-2b6b-4a1e-9a53-3f0d8f0b2c11\n")
+			return err
+		},
+	}
+	bootID, err := GetBootID(c)
+	if err != nil {
+		t.Fatalf("Expected nil error, got %s", err)
+	}
+	if bootID != "0f3c4a6e-2b6b-4a1e-9a53-3f0d8f0b2c11" {
+		t.Errorf("Unexpected boot ID %q", bootID)
+	}
+}
@@ -0,0 +1,535 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package sshtest provides an in-process SSH server to test SSH clients and provisioners end to end,
+// without a machine or a cluster.
+package sshtest
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Disconnect is returned by a Handler to drop the connection without sending an exit status,
+// as a machine which reboots does.
+const Disconnect = -1
+
+// Handler handles a command executed on the server, it returns the exit status of the command.
+type Handler func(e *Exec) int
+
+// Exec is a command executed on the server.
+type Exec struct {
+	// Command is the command requested by the client.
+	Command string
+	// Env are the environment variables requested by the client.
+	Env map[string]string
+	// Pty is the pseudo terminal requested by the client, if any.
+	Pty *Pty
+	// Stdin, Stdout and Stderr are the streams of the session, they are only valid in the Handler.
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// Pty is a pseudo terminal requested by a client.
+type Pty struct {
+	Term    string
+	Columns uint32
+	Rows    uint32
+}
+
+// Option configures a Server.
+type Option func(*Server)
+
+// WithPassword allows user to authenticate with password.
+func WithPassword(user, password string) Option {
+	return func(s *Server) {
+		s.passwords[user] = password
+	}
+}
+
+// WithAuthorizedKey allows user to authenticate with the private key of key.
+func WithAuthorizedKey(user string, key ssh.PublicKey) Option {
+	return func(s *Server) {
+		s.authorizedKeys[user] = append(s.authorizedKeys[user], key)
+	}
+}
+
+// WithHostKey sets the host key of the server, a new ed25519 key is generated by default.
+func WithHostKey(key ssh.Signer) Option {
+	return func(s *Server) {
+		s.hostKey = key
+	}
+}
+
+// WithHandler sets the handler of the commands, other than scp, executed on the server.
+func WithHandler(h Handler) Option {
+	return func(s *Server) {
+		s.handler = h
+	}
+}
+
+// WithShell runs the commands, other than scp, with /bin/sh in the Root directory of the server.
+func WithShell() Option {
+	return func(s *Server) {
+		s.handler = s.shell
+	}
+}
+
+// Server is an in-process SSH server listening on the loopback interface.
+// It serves scp in sink (-t) and source (-f) mode from its Root directory, the other commands
+// are passed to its Handler. Without an authentication option, any client is accepted.
+type Server struct {
+	// Root is the directory the files of the server are stored in, see Path.
+	Root string
+
+	listener       net.Listener
+	hostKey        ssh.Signer
+	handler        Handler
+	passwords      map[string]string
+	authorizedKeys map[string][]ssh.PublicKey
+
+	mu          sync.Mutex
+	conns       map[ssh.Conn]struct{}
+	connections int
+	execs       []Exec
+	requests    map[string]int
+}
+
+// NewServer starts a Server, which is closed when the test finishes.
+func NewServer(t testing.TB, opts ...Option) *Server {
+	t.Helper()
+
+	s := &Server{
+		Root:           t.TempDir(),
+		passwords:      map[string]string{},
+		authorizedKeys: map[string][]ssh.PublicKey{},
+		conns:          map[ssh.Conn]struct{}{},
+		requests:       map[string]int{},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.hostKey == nil {
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatalf("failed to generate the host key: %v", err)
+		}
+		if s.hostKey, err = ssh.NewSignerFromKey(key); err != nil {
+			t.Fatalf("failed to create the host key signer: %v", err)
+		}
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	s.listener = listener
+	t.Cleanup(s.Close)
+
+	go s.serve(s.config())
+	return s
+}
+
+// Addr returns the address the server listens on.
+func (s *Server) Addr() *net.TCPAddr {
+	return s.listener.Addr().(*net.TCPAddr)
+}
+
+// HostKey returns the public host key of the server.
+func (s *Server) HostKey() ssh.PublicKey {
+	return s.hostKey.PublicKey()
+}
+
+// Secret returns the ssh credentials Secret of user, as read by the shell provisioner.
+// The password of user is set if it has one, a private key must be added by the caller.
+// The host key of the server is set, so that the clients check it.
+func (s *Server) Secret(user string) *corev1.Secret {
+	data := map[string][]byte{
+		"host":     []byte(s.Addr().IP.String()),
+		"port":     []byte(strconv.Itoa(s.Addr().Port)),
+		"username": []byte(user),
+		"hostKey":  ssh.MarshalAuthorizedKey(s.HostKey()),
+	}
+	if password, ok := s.passwords[user]; ok {
+		data["password"] = []byte(password)
+	}
+	return &corev1.Secret{Data: data}
+}
+
+// Path returns the local path of a file on the server. Both relative and absolute paths are
+// resolved in Root, which is also the working directory of the commands run by WithShell.
+func (s *Server) Path(name string) string {
+	return filepath.Join(s.Root, filepath.FromSlash(name))
+}
+
+// ReadFile reads a file on the server.
+func (s *Server) ReadFile(name string) ([]byte, error) {
+	return os.ReadFile(s.Path(name))
+}
+
+// WriteFile writes a file on the server, its directory is created if needed.
+func (s *Server) WriteFile(name string, data []byte, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(s.Path(name)), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(s.Path(name), data, perm)
+}
+
+// Execs returns the commands executed on the server, including scp.
+func (s *Server) Execs() []Exec {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Exec(nil), s.execs...)
+}
+
+// Connections returns the number of connections established, including the closed ones.
+func (s *Server) Connections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.connections
+}
+
+// OpenConnections returns the number of connections which are still open.
+func (s *Server) OpenConnections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.conns)
+}
+
+// Requests returns the number of global requests of type typ received, e.g. keep alives.
+func (s *Server) Requests(typ string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[typ]
+}
+
+// Disconnect drops the open connections, the server keeps accepting new ones.
+func (s *Server) Disconnect() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+}
+
+// Close stops the server and drops the open connections.
+func (s *Server) Close() {
+	_ = s.listener.Close()
+	s.Disconnect()
+}
+
+func (s *Server) config() *ssh.ServerConfig {
+	config := &ssh.ServerConfig{
+		NoClientAuth: len(s.passwords) == 0 && len(s.authorizedKeys) == 0,
+	}
+	if len(s.passwords) > 0 {
+		config.PasswordCallback = func(c ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if p, ok := s.passwords[c.User()]; ok && p == string(password) {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("password rejected for %s", c.User())
+		}
+	}
+	if len(s.authorizedKeys) > 0 {
+		config.PublicKeyCallback = func(c ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			for _, k := range s.authorizedKeys[c.User()] {
+				if string(k.Marshal()) == string(key.Marshal()) {
+					return nil, nil
+				}
+			}
+			return nil, fmt.Errorf("unknown public key for %s", c.User())
+		}
+	}
+	config.AddHostKey(s.hostKey)
+	return config
+}
+
+func (s *Server) serve(config *ssh.ServerConfig) {
+	for {
+		nc, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handleConn(nc, config)
+	}
+}
+
+func (s *Server) handleConn(nc net.Conn, config *ssh.ServerConfig) {
+	conn, chans, reqs, err := ssh.NewServerConn(nc, config)
+	if err != nil {
+		_ = nc.Close()
+		return
+	}
+	s.mu.Lock()
+	s.conns[conn] = struct{}{}
+	s.connections++
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		_ = conn.Close()
+	}()
+
+	go s.handleRequests(reqs)
+	for nch := range chans {
+		if nch.ChannelType() != "session" {
+			_ = nch.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+		ch, chReqs, err := nch.Accept()
+		if err != nil {
+			continue
+		}
+		go s.handleSession(conn, ch, chReqs)
+	}
+}
+
+// handleRequests counts the global requests, which are all rejected.
+func (s *Server) handleRequests(reqs <-chan *ssh.Request) {
+	for req := range reqs {
+		s.mu.Lock()
+		s.requests[req.Type]++
+		s.mu.Unlock()
+		if req.WantReply {
+			_ = req.Reply(false, nil)
+		}
+	}
+}
+
+func (s *Server) handleSession(conn ssh.Conn, ch ssh.Channel, reqs <-chan *ssh.Request) {
+	defer ch.Close()
+
+	e := &Exec{Env: map[string]string{}, Stdin: ch, Stdout: ch, Stderr: ch.Stderr()}
+	for req := range reqs {
+		switch req.Type {
+		case "pty-req":
+			var pty struct {
+				Term          string
+				Columns, Rows uint32
+				Width, Height uint32
+				Modes         string
+			}
+			if err := ssh.Unmarshal(req.Payload, &pty); err != nil {
+				_ = req.Reply(false, nil)
+				continue
+			}
+			e.Pty = &Pty{Term: pty.Term, Columns: pty.Columns, Rows: pty.Rows}
+			_ = req.Reply(true, nil)
+		case "env":
+			var env struct{ Name, Value string }
+			if err := ssh.Unmarshal(req.Payload, &env); err != nil {
+				_ = req.Reply(false, nil)
+				continue
+			}
+			e.Env[env.Name] = env.Value
+			_ = req.Reply(true, nil)
+		case "exec":
+			var cmd struct{ Command string }
+			if err := ssh.Unmarshal(req.Payload, &cmd); err != nil {
+				_ = req.Reply(false, nil)
+				continue
+			}
+			e.Command = cmd.Command
+			_ = req.Reply(true, nil)
+			go ssh.DiscardRequests(reqs)
+
+			s.mu.Lock()
+			s.execs = append(s.execs, *e)
+			s.mu.Unlock()
+			status := s.exec(e)
+			if status == Disconnect {
+				_ = conn.Close()
+				return
+			}
+			_, _ = ch.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{uint32(status)}))
+			return
+		default:
+			_ = req.Reply(false, nil)
+		}
+	}
+}
+
+func (s *Server) exec(e *Exec) int {
+	if args := strings.Fields(e.Command); len(args) > 0 && path.Base(args[0]) == "scp" {
+		return s.scp(e, args[1:])
+	}
+	if s.handler == nil {
+		fmt.Fprintf(e.Stderr, "%s: command not found\n", e.Command)
+		return 127
+	}
+	return s.handler(e)
+}
+
+// shell runs a command with /bin/sh in the Root directory, which is also its HOME. The environment
+// of the test is not inherited, as it is not on a remote machine.
+func (s *Server) shell(e *Exec) int {
+	cmd := exec.Command("/bin/sh", "-c", e.Command)
+	cmd.Dir = s.Root
+	cmd.Env = []string{"PATH=" + os.Getenv("PATH"), "HOME=" + s.Root}
+	for name, value := range e.Env {
+		cmd.Env = append(cmd.Env, name+"="+value)
+	}
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = e.Stdin, e.Stdout, e.Stderr
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() > 0 {
+			return exitErr.ExitCode()
+		}
+		fmt.Fprintln(e.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// scp serves a single file copy, in sink mode (-t) to receive it or in source mode (-f) to send it.
+func (s *Server) scp(e *Exec, args []string) int {
+	var (
+		sink, source bool
+		target       string
+	)
+	for _, arg := range args {
+		switch {
+		case arg == "-t":
+			sink = true
+		case arg == "-f":
+			source = true
+		case strings.HasPrefix(arg, "-"):
+		default:
+			target = arg
+		}
+	}
+
+	var err error
+	switch {
+	case sink:
+		err = s.scpSink(e, target)
+	case source:
+		err = s.scpSource(e, target)
+	default:
+		err = errors.New("either -t or -f is required")
+	}
+	if err != nil {
+		fmt.Fprintf(e.Stdout, "\x01scp: %s\n", err)
+		fmt.Fprintf(e.Stderr, "scp: %s\n", err)
+		return 1
+	}
+	return 0
+}
+
+// scpSink receives files in dir, e.g. C0644 5 name followed by the content and a null byte.
+func (s *Server) scpSink(e *Exec, dir string) error {
+	r := bufio.NewReader(e.Stdin)
+	ack(e.Stdout)
+	for {
+		line, err := r.ReadString('\n')
+		if err == io.EOF && line == "" {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		msg := strings.SplitN(strings.TrimSuffix(line, "\n"), " ", 3)
+		if len(msg) != 3 || !strings.HasPrefix(msg[0], "C") || strings.Contains(msg[2], "/") {
+			return fmt.Errorf("unsupported message %q", strings.TrimSpace(line))
+		}
+		mode, err := strconv.ParseUint(msg[0][1:], 8, 32)
+		if err != nil {
+			return fmt.Errorf("invalid mode %q", msg[0][1:])
+		}
+		size, err := strconv.ParseInt(msg[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid size %q", msg[1])
+		}
+		ack(e.Stdout)
+
+		data := make([]byte, size)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return err
+		}
+		if err := readAck(r); err != nil {
+			return err
+		}
+		name := path.Join(dir, msg[2])
+		if err := s.WriteFile(name, data, os.FileMode(mode)); err != nil {
+			return err
+		}
+		if err := os.Chmod(s.Path(name), os.FileMode(mode)); err != nil {
+			return err
+		}
+		ack(e.Stdout)
+	}
+}
+
+// scpSource sends the file name once the client acknowledges each message.
+func (s *Server) scpSource(e *Exec, name string) error {
+	r := bufio.NewReader(e.Stdin)
+	if err := readAck(r); err != nil {
+		return err
+	}
+	info, err := os.Stat(s.Path(name))
+	if os.IsNotExist(err) {
+		return fmt.Errorf("%s: No such file or directory", name)
+	}
+	if err != nil {
+		return err
+	}
+	data, err := s.ReadFile(name)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(e.Stdout, "C%04o %d %s\n", info.Mode().Perm(), len(data), path.Base(name))
+	if err := readAck(r); err != nil {
+		return err
+	}
+	if _, err := e.Stdout.Write(append(data, 0)); err != nil {
+		return err
+	}
+	return readAck(r)
+}
+
+func ack(w io.Writer) {
+	_, _ = w.Write([]byte{0})
+}
+
+func readAck(r *bufio.Reader) error {
+	b, err := r.ReadByte()
+	if err != nil {
+		return err
+	}
+	if b != 0 {
+		return fmt.Errorf("unexpected acknowledgement %d", b)
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package tracing configures the OpenTelemetry tracing of the Forge components and
+// propagates the trace context of the Builds to the provisioner Jobs.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// TracerName is the name of the tracer of the Forge components.
+	TracerName = "github.com/forge-build/forge"
+
+	// TraceParentEnv is the environment variable propagating the trace context to the provisioner Jobs.
+	TraceParentEnv = "TRACEPARENT"
+
+	// EndpointEnv is the environment variable with the OTLP endpoint the provisioner Jobs export their spans to.
+	EndpointEnv = "OTEL_EXPORTER_OTLP_ENDPOINT"
+
+	traceParentHeader = "traceparent"
+)
+
+// endpoint is the OTLP endpoint configured by Setup.
+var endpoint string
+
+// Setup exports the spans of the process to the OTLP gRPC endpoint, e.g. http://localhost:4317
+// for a local collector. Tracing is disabled when the endpoint is empty.
+// The returned function flushes the pending spans, it must be called before the process exits.
+func Setup(ctx context.Context, serviceName, otlpEndpoint string) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	if otlpEndpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracegrpc.New(ctx, otlptracegrpc.WithEndpointURL(otlpEndpoint))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create the OTLP exporter")
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(serviceName)))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create the tracing resource")
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithIDGenerator(IDGenerator{}),
+	)
+	otel.SetTracerProvider(provider)
+	endpoint = otlpEndpoint
+	return provider.Shutdown, nil
+}
+
+// Endpoint returns the OTLP endpoint configured by Setup, it is empty when tracing is disabled.
+func Endpoint() string {
+	return endpoint
+}
+
+// Tracer returns the tracer of the Forge components.
+func Tracer() trace.Tracer {
+	return otel.Tracer(TracerName)
+}
+
+// End ends a span, recording the error of the operation it traces.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// TraceParent returns the W3C traceparent of the span in ctx, it is empty when there is none.
+func TraceParent(ctx context.Context) string {
+	carrier := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(ctx, carrier)
+	return carrier[traceParentHeader]
+}
+
+// ContextWithTraceParent returns a context continuing the trace of a W3C traceparent.
+// The context is returned unchanged if the traceparent is invalid.
+func ContextWithTraceParent(ctx context.Context, traceParent string) context.Context {
+	return propagation.TraceContext{}.Extract(ctx, propagation.MapCarrier{traceParentHeader: traceParent})
+}
+
+// NewTraceParent returns the W3C traceparent of a new sampled span which has not been started.
+func NewTraceParent() string {
+	traceID, spanID := IDGenerator{}.NewIDs(context.Background())
+	return fmt.Sprintf("00-%s-%s-%s", traceID, spanID, trace.FlagsSampled)
+}
+
+type idsKey struct{}
+
+// WithIDs returns a context in which the next root span gets the trace and span IDs of sc.
+// It allows to start a span once its children already reference it, e.g. the span of a
+// Build lifecycle which is only known when the Build ends.
+func WithIDs(ctx context.Context, sc trace.SpanContext) context.Context {
+	return context.WithValue(ctx, idsKey{}, sc)
+}
+
+// IDGenerator generates random trace and span IDs, unless they are set with WithIDs.
+type IDGenerator struct{}
+
+var _ sdktrace.IDGenerator = IDGenerator{}
+
+// NewIDs returns the IDs set with WithIDs, or random ones.
+func (IDGenerator) NewIDs(ctx context.Context) (trace.TraceID, trace.SpanID) {
+	if sc, ok := ctx.Value(idsKey{}).(trace.SpanContext); ok && sc.IsValid() {
+		return sc.TraceID(), sc.SpanID()
+	}
+	traceID := trace.TraceID{}
+	for !traceID.IsValid() {
+		_, _ = rand.Read(traceID[:])
+	}
+	return traceID, IDGenerator{}.NewSpanID(ctx, traceID)
+}
+
+// NewSpanID returns a random span ID, the IDs set with WithIDs are only used for root spans.
+func (IDGenerator) NewSpanID(_ context.Context, _ trace.TraceID) trace.SpanID {
+	spanID := trace.SpanID{}
+	for !spanID.IsValid() {
+		_, _ = rand.Read(spanID[:])
+	}
+	return spanID
+}
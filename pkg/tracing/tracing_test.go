@@ -0,0 +1,93 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func newTestTracer() (trace.Tracer, *tracetest.SpanRecorder) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder), sdktrace.WithIDGenerator(IDGenerator{}))
+	return provider.Tracer(TracerName), recorder
+}
+
+func TestTraceParent(t *testing.T) {
+	g := NewWithT(t)
+
+	traceParent := NewTraceParent()
+	g.Expect(traceParent).To(MatchRegexp(`^00-[0-9a-f]{32}-[0-9a-f]{16}-01$`))
+	g.Expect(NewTraceParent()).NotTo(Equal(traceParent))
+
+	ctx := ContextWithTraceParent(context.Background(), traceParent)
+	g.Expect(trace.SpanContextFromContext(ctx).IsRemote()).To(BeTrue())
+	g.Expect(TraceParent(ctx)).To(Equal(traceParent))
+
+	g.Expect(TraceParent(context.Background())).To(BeEmpty())
+	g.Expect(TraceParent(ContextWithTraceParent(context.Background(), "invalid"))).To(BeEmpty())
+}
+
+func TestWithIDs(t *testing.T) {
+	g := NewWithT(t)
+	tracer, recorder := newTestTracer()
+
+	sc := trace.SpanContextFromContext(ContextWithTraceParent(context.Background(), NewTraceParent()))
+
+	// A child continuing the trace is started first.
+	_, child := tracer.Start(trace.ContextWithRemoteSpanContext(context.Background(), sc), "child")
+	child.End()
+
+	// Then its parent, with the IDs the child references.
+	ctx, parent := tracer.Start(WithIDs(context.Background(), sc), "parent", trace.WithNewRoot())
+	_, sibling := tracer.Start(ctx, "sibling")
+	sibling.End()
+	parent.End()
+
+	spans := recorder.Ended()
+	g.Expect(spans).To(HaveLen(3))
+	g.Expect(spans[0].Parent().SpanID()).To(Equal(sc.SpanID()))
+	g.Expect(spans[2].SpanContext().TraceID()).To(Equal(sc.TraceID()))
+	g.Expect(spans[2].SpanContext().SpanID()).To(Equal(sc.SpanID()))
+	g.Expect(spans[2].Parent().IsValid()).To(BeFalse())
+	// The IDs are only used for the root span.
+	g.Expect(spans[1].SpanContext().TraceID()).To(Equal(sc.TraceID()))
+	g.Expect(spans[1].SpanContext().SpanID()).NotTo(Equal(sc.SpanID()))
+}
+
+func TestEnd(t *testing.T) {
+	g := NewWithT(t)
+	tracer, recorder := newTestTracer()
+
+	_, span := tracer.Start(context.Background(), "succeeded")
+	End(span, nil)
+	_, span = tracer.Start(context.Background(), "failed")
+	End(span, errors.New("connection refused"))
+
+	spans := recorder.Ended()
+	g.Expect(spans).To(HaveLen(2))
+	g.Expect(spans[0].Status().Code).To(Equal(codes.Unset))
+	g.Expect(spans[1].Status()).To(Equal(sdktrace.Status{Code: codes.Error, Description: "connection refused"}))
+	g.Expect(spans[1].Events()).To(HaveLen(1))
+}
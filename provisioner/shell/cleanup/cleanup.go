@@ -0,0 +1,106 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cleanup builds the script generalizing the infrastructure machine before the image is exported.
+package cleanup
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	buildv1 "github.com/forge-build/forge/api/v1alpha1"
+)
+
+// DefaultActions is the curated list of generalization actions run when none are specified.
+var DefaultActions = []buildv1.CleanupAction{
+	buildv1.CleanupActionPackageCache,
+	buildv1.CleanupActionCloudInit,
+	buildv1.CleanupActionSSHHostKeys,
+	buildv1.CleanupActionMachineID,
+	buildv1.CleanupActionTmpFiles,
+	buildv1.CleanupActionLogs,
+	buildv1.CleanupActionShellHistory,
+}
+
+// header runs the commands with sudo when not connected as root, and fails on the first error.
+const header = `set -e
+SUDO=""
+if [ "$(id -u)" -ne 0 ]; then SUDO="sudo -n"; fi`
+
+var commands = map[buildv1.CleanupAction]string{
+	buildv1.CleanupActionMachineID: `$SUDO truncate -s 0 /etc/machine-id
+if [ -e /var/lib/dbus/machine-id ]; then $SUDO rm -f /var/lib/dbus/machine-id && $SUDO ln -s /etc/machine-id /var/lib/dbus/machine-id; fi`,
+	buildv1.CleanupActionSSHHostKeys: `$SUDO rm -f /etc/ssh/ssh_host_*`,
+	buildv1.CleanupActionCloudInit: `if command -v cloud-init >/dev/null 2>&1; then $SUDO cloud-init clean --logs --seed; fi
+$SUDO rm -rf /var/lib/cloud/instances /var/lib/cloud/instance`,
+	buildv1.CleanupActionShellHistory: `$SUDO rm -f /root/.bash_history /root/.zsh_history /home/*/.bash_history /home/*/.zsh_history
+unset HISTFILE`,
+	buildv1.CleanupActionLogs: `$SUDO find /var/log -type f \( -name '*.gz' -o -name '*.[0-9]' -o -name '*.old' \) -delete
+$SUDO find /var/log -type f -exec truncate -s 0 {} +
+if [ -d /var/log/journal ]; then $SUDO find /var/log/journal -type f -name '*.journal*' -delete; fi`,
+	buildv1.CleanupActionTmpFiles: `$SUDO find /tmp /var/tmp -mindepth 1 -delete`,
+	buildv1.CleanupActionPackageCache: `if command -v apt-get >/dev/null 2>&1; then $SUDO apt-get clean; $SUDO rm -rf /var/lib/apt/lists/*;
+elif command -v dnf >/dev/null 2>&1; then $SUDO dnf clean all;
+elif command -v yum >/dev/null 2>&1; then $SUDO yum clean all;
+elif command -v apk >/dev/null 2>&1; then $SUDO rm -rf /var/cache/apk/*;
+fi`,
+}
+
+// Actions returns the generalization actions to run for the cleanup spec.
+func Actions(spec *buildv1.CleanupSpec) []buildv1.CleanupAction {
+	if spec == nil {
+		return DefaultActions
+	}
+	if spec.Disabled {
+		return nil
+	}
+
+	actions := spec.Actions
+	if len(actions) == 0 {
+		actions = DefaultActions
+	}
+	skip := make(map[buildv1.CleanupAction]bool, len(spec.Skip))
+	for _, a := range spec.Skip {
+		skip[a] = true
+	}
+
+	var res []buildv1.CleanupAction
+	for _, a := range actions {
+		if !skip[a] {
+			res = append(res, a)
+		}
+	}
+	return res
+}
+
+// Script returns the script running the generalization actions of the cleanup spec,
+// followed by the additional script if any.
+func Script(spec *buildv1.CleanupSpec) (string, error) {
+	lines := []string{header}
+	for _, a := range Actions(spec) {
+		command, ok := commands[a]
+		if !ok {
+			return "", errors.Errorf("unsupported cleanup action %q", a)
+		}
+		lines = append(lines, fmt.Sprintf("# %s", a), command)
+	}
+	if spec != nil && spec.Run != nil {
+		lines = append(lines, "# Run", *spec.Run)
+	}
+	return strings.Join(lines, "\n") + "\n", nil
+}
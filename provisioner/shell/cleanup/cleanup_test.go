@@ -0,0 +1,91 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cleanup
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"k8s.io/utils/ptr"
+
+	buildv1 "github.com/forge-build/forge/api/v1alpha1"
+)
+
+func TestActions(t *testing.T) {
+	tests := []struct {
+		name string
+		spec *buildv1.CleanupSpec
+		want []buildv1.CleanupAction
+	}{
+		{
+			name: "defaults when not set",
+			spec: nil,
+			want: DefaultActions,
+		},
+		{
+			name: "nothing when disabled",
+			spec: &buildv1.CleanupSpec{Disabled: true},
+			want: nil,
+		},
+		{
+			name: "defaults without the skipped ones",
+			spec: &buildv1.CleanupSpec{Skip: []buildv1.CleanupAction{buildv1.CleanupActionLogs, buildv1.CleanupActionTmpFiles}},
+			want: []buildv1.CleanupAction{
+				buildv1.CleanupActionPackageCache,
+				buildv1.CleanupActionCloudInit,
+				buildv1.CleanupActionSSHHostKeys,
+				buildv1.CleanupActionMachineID,
+				buildv1.CleanupActionShellHistory,
+			},
+		},
+		{
+			name: "overridden actions",
+			spec: &buildv1.CleanupSpec{Actions: []buildv1.CleanupAction{buildv1.CleanupActionMachineID}},
+			want: []buildv1.CleanupAction{buildv1.CleanupActionMachineID},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			g.Expect(Actions(tt.spec)).To(Equal(tt.want))
+		})
+	}
+}
+
+func TestScript(t *testing.T) {
+	g := NewWithT(t)
+
+	script, err := Script(&buildv1.CleanupSpec{
+		Actions: []buildv1.CleanupAction{buildv1.CleanupActionSSHHostKeys},
+		Run:     ptr.To("rm -rf /opt/build"),
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(script).To(HavePrefix("set -e\n"))
+	g.Expect(script).To(ContainSubstring("# SSHHostKeys\n$SUDO rm -f /etc/ssh/ssh_host_*\n"))
+	g.Expect(script).To(HaveSuffix("# Run\nrm -rf /opt/build\n"))
+	g.Expect(script).NotTo(ContainSubstring("machine-id"))
+
+	// Every default action has a command.
+	script, err = Script(nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	for _, a := range DefaultActions {
+		g.Expect(script).To(ContainSubstring("# " + string(a) + "\n"))
+	}
+
+	_, err = Script(&buildv1.CleanupSpec{Actions: []buildv1.CleanupAction{"Unknown"}})
+	g.Expect(err).To(HaveOccurred())
+}
@@ -22,6 +22,10 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/go-logr/logr"
@@ -31,16 +35,18 @@ import (
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/klog/v2"
+	"k8s.io/utils/ptr"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
 
 	"github.com/forge-build/forge/pkg/ssh"
+	"github.com/forge-build/forge/pkg/tracing"
+	"github.com/forge-build/forge/provisioner/shell"
+	"github.com/forge-build/forge/provisioner/shell/runner"
 )
 
 const (
-	CredentialsSecretPath string = "/var/run/secrets/ssh-credentials"
-
 	SSHTimeout = 2 * time.Minute
 )
 
@@ -53,6 +59,24 @@ var (
 	ScriptToRunRef string
 	// SSHCredentialsSecretName is the name of the secret containing the credentials
 	SSHCredentialsSecretName string
+	// SSHCredentialsPath is the directory where the secret containing the credentials is mounted
+	SSHCredentialsPath string
+	// OutputFile is the file where the output of the script is written
+	OutputFile string
+	// ExpectDisconnect allows the script to drop the ssh connection
+	ExpectDisconnect bool
+	// Reboot requires the script to drop the ssh connection by rebooting the machine
+	Reboot bool
+	// VerifyBootID checks that the machine boot ID changed after a disconnect
+	VerifyBootID bool
+	// ReconnectTimeout is how long to wait for the machine to go down and come back
+	ReconnectTimeout time.Duration
+	// RemoveFile is a file to remove instead of running a script
+	RemoveFile string
+	// TerminationMessagePath is the file where the result of the run is written
+	TerminationMessagePath string
+	// OutputsFile is the file on the machine where the script writes its outputs
+	OutputsFile string
 )
 
 func main() {
@@ -63,6 +87,15 @@ func main() {
 	flag.StringVar(&ScriptToRun, "run-script", "", "The script to run")
 	flag.StringVar(&ScriptToRunRef, "run-script-ref", "", "The name of configmap containing the script to run")
 	flag.StringVar(&SSHCredentialsSecretName, "ssh-credentials-secret-name", "", "The name of secret containing the ssh credentials")
+	flag.StringVar(&SSHCredentialsPath, "ssh-credentials-path", "", fmt.Sprintf("The directory where the secret containing the ssh credentials is mounted, e.g. %s", shell.CredentialsSecretPath))
+	flag.StringVar(&OutputFile, "output-file", "", "Write the output of the script to this file instead of logging it")
+	flag.BoolVar(&ExpectDisconnect, "expect-disconnect", false, "Do not fail if the script drops the ssh connection, wait for the machine to come back instead")
+	flag.BoolVar(&Reboot, "reboot", false, "The script reboots the machine, wait for it to go down and come back")
+	flag.BoolVar(&VerifyBootID, "verify-boot-id", false, "Check that the machine boot ID changed after a disconnect")
+	flag.DurationVar(&ReconnectTimeout, "reconnect-timeout", runner.DefaultReconnectTimeout, "How long to wait for the machine to go down and come back after a disconnect")
+	flag.StringVar(&OutputsFile, "outputs-file", "", fmt.Sprintf("The file on the machine where the script writes its outputs as key=value lines, e.g. %s. Its path is in the %s environment variable of the script", shell.OutputsFile, shell.OutputsEnv))
+	flag.StringVar(&TerminationMessagePath, "termination-message-path", shell.TerminationMessagePath, "Write the result of the run, e.g. the exit code of the script, to this file")
+	flag.StringVar(&RemoveFile, "remove-file", "", "Remove this file and exit without connecting to the machine, e.g. to delete an exported image")
 
 	flag.Parse()
 
@@ -72,19 +105,67 @@ func main() {
 
 	logger.Info("Starting shell provisioner")
 
-	k8sClient, err := initClient()
+	if RemoveFile != "" {
+		if err := removeFile(RemoveFile); err != nil {
+			logger.Error(err, "Error removing file")
+			klog.Exit(err)
+		}
+		logger.Info("File removed", "file", RemoveFile)
+		return
+	}
+
+	// Continue the trace of the Build, the spans are flushed before exiting.
+	shutdown, err := tracing.Setup(ctx, shell.ForgeProvisionerShellName, os.Getenv(tracing.EndpointEnv))
 	if err != nil {
-		logger.Error(err, "Error creating Kubernetes client")
+		logger.Error(err, "Error setting up tracing")
 		klog.Exit(err)
 	}
+	ctx, span := tracing.Tracer().Start(tracing.ContextWithTraceParent(ctx, os.Getenv(tracing.TraceParentEnv)), "Provision")
+	start := time.Now()
+	result := &shell.Result{}
+	err = provision(ctx, logger, result)
+	tracing.End(span, err)
+	// The controller reports the result in the status of the provisioner, and reads the exit code
+	// of the script from it to retry transient failures.
+	result.Duration = time.Since(start)
+	setExitCode(result, err)
+	if err := shell.WriteResult(TerminationMessagePath, result); err != nil {
+		logger.Error(err, "Error writing the result")
+	}
+	if err := shutdown(context.Background()); err != nil {
+		logger.Error(err, "Error flushing the spans")
+	}
+	if err != nil {
+		klog.Exit(err)
+	}
+}
+
+// provision fetches the ssh credentials and the script, and runs the script on the machine.
+func provision(ctx context.Context, logger logr.Logger, result *shell.Result) error {
+	var k8sClient client.Client
+	if SSHCredentialsPath == "" || ScriptToRunRef != "" {
+		var err error
+		if k8sClient, err = initClient(); err != nil {
+			logger.Error(err, "Error creating Kubernetes client")
+			return err
+		}
+	}
 
-	logger.Info("Fetching the ssh-credentials secret")
 	// Read the secret
 	secret := &corev1.Secret{}
-	err = k8sClient.Get(ctx, client.ObjectKey{Namespace: Namespace, Name: SSHCredentialsSecretName}, secret)
-	if err != nil {
-		logger.Error(err, "Error getting secret")
-		klog.Exit(err)
+	if SSHCredentialsPath != "" {
+		logger.Info("Reading the mounted ssh-credentials secret")
+		var err error
+		if secret, err = readSecret(SSHCredentialsPath); err != nil {
+			logger.Error(err, "Error reading secret")
+			return err
+		}
+	} else {
+		logger.Info("Fetching the ssh-credentials secret")
+		if err := k8sClient.Get(ctx, client.ObjectKey{Namespace: Namespace, Name: SSHCredentialsSecretName}, secret); err != nil {
+			logger.Error(err, "Error getting secret")
+			return err
+		}
 	}
 
 	// Read scriptToRunRef
@@ -93,25 +174,27 @@ func main() {
 		cm := &corev1.ConfigMap{}
 		if err := k8sClient.Get(ctx, client.ObjectKey{Namespace: Namespace, Name: ScriptToRunRef}, cm); err != nil {
 			logger.Error(err, "Error getting configmap")
-			klog.Exit(err)
+			return err
 		}
 		for _, v := range cm.Data {
 			ScriptToRun = v
 		}
 	}
 
-	err = run(logger, secret)
-	if err != nil {
+	if err := run(ctx, logger, secret, result); err != nil {
 		logger.Error(err, "Error running script")
-		klog.Exit(err)
+		return err
 	}
+	return nil
 }
 
-func run(logger logr.Logger, secret *corev1.Secret) error {
+// run runs the script on the machine, the output of the script is reported in the result.
+func run(ctx context.Context, logger logr.Logger, secret *corev1.Secret, result *shell.Result) error {
 	sshClient, err := ssh.NewSSHClient(secret)
 	if err != nil {
 		return errors.Wrap(err, "Error creating SSH client")
 	}
+	sshClient.SetContext(ctx)
 	logger.Info("Connecting to the machine via ssh")
 	if err := sshClient.WaitForSSH(SSHTimeout); err != nil {
 		return errors.Wrap(err, "failed to connect to the machine via ssh")
@@ -119,28 +202,114 @@ func run(logger logr.Logger, secret *corev1.Secret) error {
 	defer sshClient.Disconnect()
 
 	logger.Info("SSH connection established")
-	script := ScriptToRun
-	if script == "" {
-		return errors.New("script to run is empty")
+	var output io.Writer = &bytes.Buffer{}
+	if OutputFile != "" {
+		if err := os.MkdirAll(filepath.Dir(OutputFile), 0o755); err != nil {
+			return errors.Wrap(err, "failed to create the output directory")
+		}
+		f, err := os.Create(OutputFile)
+		if err != nil {
+			return errors.Wrap(err, "failed to create the output file")
+		}
+		defer f.Close()
+		output = f
 	}
-
-	logger.Info("Running the script")
-	output := &bytes.Buffer{}
 	errOutput := &bytes.Buffer{}
-	err = sshClient.Run(
-		script,
-		output,
-		errOutput,
-	)
+	defer func() {
+		if b, ok := output.(*bytes.Buffer); ok {
+			result.Stdout = b.String()
+		}
+		result.Stderr = errOutput.String()
+	}()
+	err = runner.Run(logger, sshClient, runner.Options{
+		Script:           ScriptToRun,
+		ExpectDisconnect: ExpectDisconnect,
+		Reboot:           Reboot,
+		VerifyBootID:     VerifyBootID,
+		ReconnectTimeout: ReconnectTimeout,
+		Stdout:           output,
+		Stderr:           errOutput,
+		Env:              outputsEnv(os.Environ()),
+		OutputsFile:      OutputsFile,
+	})
 	if err != nil {
-		logger.Error(err, "Failed to run script", "output", output.String(), "error", errOutput.String())
-		return errors.Wrapf(err, "Failed to run script: error: %s, output: %s", errOutput.String(), output.String())
+		logger.Error(err, "Failed to run script", "output", outputString(output), "error", errOutput.String())
+		return errors.Wrapf(err, "Failed to run script: error: %s, output: %s", errOutput.String(), outputString(output))
+	}
+	logger.WithValues("output", outputString(output)).Info("Script executed")
+	if OutputsFile != "" {
+		if result.Outputs, err = runner.ReadOutputs(sshClient, OutputsFile); err != nil {
+			return errors.Wrap(err, "failed to read the outputs of the script")
+		}
+		logger.Info("Read the outputs of the script", "outputs", result.Outputs)
+	}
+	if OutputFile != "" {
+		result.Artifacts = append(result.Artifacts, OutputFile)
 	}
-	logger.WithValues("output", output.String()).Info("Script executed")
+	return nil
+}
 
+// outputsEnv returns the environment variables with the outputs of the previous provisioners, which the
+// provisioner Job sets, to be exported to the script.
+func outputsEnv(environ []string) map[string]string {
+	env := map[string]string{}
+	for _, kv := range environ {
+		if name, value, ok := strings.Cut(kv, "="); ok && strings.HasPrefix(name, shell.OutputEnvPrefix) {
+			env[name] = value
+		}
+	}
+	return env
+}
+
+// setExitCode sets the exit code of the script in the result of the run which failed with err, if not nil.
+func setExitCode(result *shell.Result, err error) {
+	if err == nil {
+		result.ExitCode = ptr.To[int32](0)
+		return
+	}
+	result.Message = err.Error()
+	if code, ok := ssh.ExitStatus(err); ok {
+		result.ExitCode = ptr.To(int32(code))
+	}
+}
+
+// removeFile removes a file, it does not fail if the file is already gone.
+func removeFile(name string) error {
+	if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "failed to remove %s", name)
+	}
 	return nil
 }
 
+// outputString returns the output of the script, unless it is written to a file.
+func outputString(w io.Writer) string {
+	if b, ok := w.(*bytes.Buffer); ok {
+		return b.String()
+	}
+	return fmt.Sprintf("written to %s", OutputFile)
+}
+
+// readSecret reads a secret mounted as a volume in dir, one file per key.
+func readSecret(dir string) (*corev1.Secret, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read the secret directory")
+	}
+	secret := &corev1.Secret{Data: map[string][]byte{}}
+	for _, e := range entries {
+		// Skip the hidden files and directories kubelet uses to update the volume atomically.
+		if e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read the secret key %s", e.Name())
+		}
+		secret.Data[e.Name()] = data
+	}
+	return secret, nil
+}
+
 func initClient() (client.Client, error) {
 	// Load the kubeconfig from default location
 	cfg, err := config.GetConfig()
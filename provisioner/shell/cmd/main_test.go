@@ -0,0 +1,143 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	cssh "golang.org/x/crypto/ssh"
+	"k8s.io/utils/ptr"
+
+	"github.com/forge-build/forge/pkg/ssh/sshtest"
+	"github.com/forge-build/forge/provisioner/shell"
+)
+
+func TestReadSecret(t *testing.T) {
+	g := NewWithT(t)
+
+	// Mimic the layout kubelet uses for secret volumes.
+	dir := t.TempDir()
+	data := filepath.Join(dir, "..2024_01_01")
+	g.Expect(os.Mkdir(data, 0o755)).To(Succeed())
+	g.Expect(os.WriteFile(filepath.Join(data, "host"), []byte("10.0.0.1"), 0o600)).To(Succeed())
+	g.Expect(os.WriteFile(filepath.Join(data, "username"), []byte("root"), 0o600)).To(Succeed())
+	g.Expect(os.Symlink(data, filepath.Join(dir, "..data"))).To(Succeed())
+	g.Expect(os.Symlink(filepath.Join("..data", "host"), filepath.Join(dir, "host"))).To(Succeed())
+	g.Expect(os.Symlink(filepath.Join("..data", "username"), filepath.Join(dir, "username"))).To(Succeed())
+
+	secret, err := readSecret(dir)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(secret.Data).To(HaveLen(2))
+	g.Expect(string(secret.Data["host"])).To(Equal("10.0.0.1"))
+	g.Expect(string(secret.Data["username"])).To(Equal("root"))
+
+	_, err = readSecret(filepath.Join(dir, "missing"))
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestRemoveFile(t *testing.T) {
+	g := NewWithT(t)
+
+	name := filepath.Join(t.TempDir(), "ubuntu.tar.gz")
+	g.Expect(os.WriteFile(name, []byte("image"), 0o600)).To(Succeed())
+
+	g.Expect(removeFile(name)).To(Succeed())
+	g.Expect(name).NotTo(BeAnExistingFile())
+
+	// The file is already gone.
+	g.Expect(removeFile(name)).To(Succeed())
+}
+
+func TestSetExitCode(t *testing.T) {
+	g := NewWithT(t)
+
+	result := &shell.Result{}
+	setExitCode(result, nil)
+	g.Expect(result).To(Equal(&shell.Result{ExitCode: ptr.To[int32](0)}))
+
+	// The script did not exit, e.g. the machine could not be reached.
+	result = &shell.Result{}
+	setExitCode(result, errors.Wrap(cssh.ErrNoAuth, "failed to connect to the machine via ssh"))
+	g.Expect(result.ExitCode).To(BeNil())
+	g.Expect(result.Message).To(ContainSubstring("failed to connect to the machine via ssh"))
+
+	result = &shell.Result{}
+	setExitCode(result, errors.Wrap(&cssh.ExitError{}, "failed to run script"))
+	g.Expect(result.ExitCode).NotTo(BeNil())
+}
+
+func TestOutputsEnv(t *testing.T) {
+	g := NewWithT(t)
+
+	env := outputsEnv([]string{"HOME=/root", "FORGE_OUTPUT_KERNEL=6.8.0-40-generic", "FORGE_OUTPUT_ARGS=a=b", "TRACEPARENT=00-4bf9-00f0-01"})
+	g.Expect(env).To(Equal(map[string]string{"FORGE_OUTPUT_KERNEL": "6.8.0-40-generic", "FORGE_OUTPUT_ARGS": "a=b"}))
+}
+
+func TestRun(t *testing.T) {
+	g := NewWithT(t)
+
+	srv := sshtest.NewServer(t, sshtest.WithPassword("forge", "forge"), sshtest.WithShell())
+	setFlags(t, `echo "kernel $FORGE_OUTPUT_KERNEL"
+echo installing >&2
+echo "image=forge-$FORGE_OUTPUT_KERNEL" >> "$FORGE_OUTPUTS"`)
+	OutputsFile = shell.OutputsFile
+	t.Setenv("FORGE_OUTPUT_KERNEL", "6.8.0")
+
+	result := &shell.Result{}
+	g.Expect(run(context.Background(), logr.Discard(), srv.Secret("forge"), result)).To(Succeed())
+	g.Expect(result.Stdout).To(Equal("kernel 6.8.0\n"))
+	g.Expect(result.Stderr).To(Equal("installing\n"))
+	g.Expect(result.Outputs).To(Equal(map[string]string{"image": "forge-6.8.0"}))
+	g.Expect(srv.Path(shell.OutputsFile)).NotTo(BeAnExistingFile())
+
+	// The output of the script is written to the output file, which is reported as an artifact.
+	setFlags(t, "echo hello")
+	OutputFile = filepath.Join(t.TempDir(), "logs", "output.log")
+	result = &shell.Result{}
+	g.Expect(run(context.Background(), logr.Discard(), srv.Secret("forge"), result)).To(Succeed())
+	g.Expect(result.Artifacts).To(Equal([]string{OutputFile}))
+	g.Expect(os.ReadFile(OutputFile)).To(Equal([]byte("hello\n")))
+}
+
+func TestRunExitCode(t *testing.T) {
+	g := NewWithT(t)
+
+	srv := sshtest.NewServer(t, sshtest.WithPassword("forge", "forge"), sshtest.WithShell())
+	setFlags(t, "echo no space left on device >&2; exit 3")
+
+	result := &shell.Result{}
+	err := run(context.Background(), logr.Discard(), srv.Secret("forge"), result)
+	g.Expect(err).To(HaveOccurred())
+	setExitCode(result, err)
+	g.Expect(result.ExitCode).To(Equal(ptr.To[int32](3)))
+	g.Expect(result.Stderr).To(Equal("no space left on device\n"))
+}
+
+// setFlags sets the script to run, and resets the other flags of the run when the test finishes.
+func setFlags(t *testing.T, script string) {
+	scriptToRun, outputFile, outputsFile := ScriptToRun, OutputFile, OutputsFile
+	t.Cleanup(func() {
+		ScriptToRun, OutputFile, OutputsFile = scriptToRun, outputFile, outputsFile
+	})
+	ScriptToRun = script
+}
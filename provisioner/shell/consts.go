@@ -2,4 +2,20 @@ package shell
 
 const (
 	ForgeProvisionerShellName string = "forge-provisioner-shell"
+
+	// CredentialsSecretPath is the directory where the ssh credentials secret is mounted in the provisioner pods.
+	CredentialsSecretPath string = "/var/run/secrets/ssh-credentials"
+
+	// OutputsFile is the file on the machine, relative to the home directory of the ssh user, where the
+	// scripts write their outputs as key=value lines. It is removed once read, so that it is not in the image.
+	OutputsFile string = ".forge-outputs"
+	// OutputsEnv is the environment variable of the scripts with the path of the outputs file.
+	OutputsEnv string = "FORGE_OUTPUTS"
+	// OutputEnvPrefix is the prefix of the environment variables of the scripts with the outputs
+	// of the previous provisioners, e.g. FORGE_OUTPUT_KERNEL for the kernel output.
+	OutputEnvPrefix string = "FORGE_OUTPUT_"
+
+	// DefaultRebootCommand is the command run by built-in/reboot provisioners
+	// when no command is specified.
+	DefaultRebootCommand string = `if [ "$(id -u)" -eq 0 ]; then shutdown -r now; else sudo -n shutdown -r now; fi`
 )
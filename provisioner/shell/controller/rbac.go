@@ -0,0 +1,79 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	buildv1 "github.com/forge-build/forge/api/v1alpha1"
+	"github.com/forge-build/forge/provisioner/shell"
+)
+
+//+kubebuilder:rbac:groups=core,resources=serviceaccounts,verbs=get;list;watch;create;update;patch
+//+kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=roles;rolebindings,verbs=get;list;watch;create;update;patch
+
+// ensureServiceAccount creates the ServiceAccount of the provisioner Jobs running in the namespace of
+// their Build. The ssh credentials are mounted in the Jobs, the ServiceAccount is only allowed to read
+// the ConfigMaps of the scripts in its namespace.
+func ensureServiceAccount(ctx context.Context, c client.Client, namespace string) error {
+	labels := map[string]string{buildv1.ManagedByLabel: shell.ForgeProvisionerShellName}
+	meta := func() metav1.ObjectMeta {
+		return metav1.ObjectMeta{Name: shell.ForgeProvisionerShellName, Namespace: namespace}
+	}
+
+	sa := &corev1.ServiceAccount{ObjectMeta: meta()}
+	if _, err := controllerutil.CreateOrUpdate(ctx, c, sa, func() error {
+		sa.Labels = labels
+		return nil
+	}); err != nil {
+		return errors.Wrapf(err, "failed to create the service account %s/%s", namespace, sa.Name)
+	}
+
+	role := &rbacv1.Role{ObjectMeta: meta()}
+	if _, err := controllerutil.CreateOrUpdate(ctx, c, role, func() error {
+		role.Labels = labels
+		role.Rules = []rbacv1.PolicyRule{{
+			APIGroups: []string{""},
+			Resources: []string{"configmaps"},
+			Verbs:     []string{"get"},
+		}}
+		return nil
+	}); err != nil {
+		return errors.Wrapf(err, "failed to create the role %s/%s", namespace, role.Name)
+	}
+
+	binding := &rbacv1.RoleBinding{ObjectMeta: meta()}
+	if _, err := controllerutil.CreateOrUpdate(ctx, c, binding, func() error {
+		binding.Labels = labels
+		// The role of a binding is immutable, it is only set on creation.
+		if binding.CreationTimestamp.IsZero() {
+			binding.RoleRef = rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: role.Name}
+		}
+		binding.Subjects = []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: sa.Name, Namespace: namespace}}
+		return nil
+	}); err != nil {
+		return errors.Wrapf(err, "failed to create the role binding %s/%s", namespace, binding.Name)
+	}
+	return nil
+}
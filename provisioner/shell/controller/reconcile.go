@@ -3,15 +3,21 @@ package controller
 import (
 	"context"
 	"fmt"
+	"strconv"
 	"time"
 
 	builderror "github.com/forge-build/forge/pkg/errors"
+	"github.com/forge-build/forge/pkg/tracing"
 
 	"k8s.io/utils/ptr"
 
 	buildv1 "github.com/forge-build/forge/api/v1alpha1"
+	"github.com/forge-build/forge/provisioner/shell"
 	"github.com/forge-build/forge/provisioner/shell/job"
+	forgeutil "github.com/forge-build/forge/util"
 	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
@@ -21,30 +27,97 @@ const (
 	ShellProvisionerRepo = "ghcr.io/forge-build/forge-provisioner-shell"
 	ShellProvisionerTag  = "latest"
 
+	// ForgeCoreNamespace is the namespace of the forge controllers, where the provisioner Jobs of all
+	// the Builds may run.
 	ForgeCoreNamespace = "forge-core"
+
+	// CleanupStep is the step of the provisioner running the cleanup stage.
+	CleanupStep = "cleanup"
 )
 
-func Reconcile(ctx context.Context, client client.Client, build *buildv1.Build, spec *buildv1.ProvisionerSpec) (_ ctrl.Result, err error) {
+// Options configures the provisioner Jobs.
+type Options struct {
+	// Runtime is the default runtime configuration of the Jobs.
+	Runtime *buildv1.ProvisionerRuntimeConfig
+	// Namespace is the namespace where the Jobs of all the Builds run, e.g. ForgeCoreNamespace,
+	// their ServiceAccount reads the ssh credentials Secrets of the Builds via the API.
+	// If empty, the Jobs run in the namespace of their Build with the ssh credentials Secret mounted.
+	Namespace string
+}
+
+// Reconcile runs the shell provisioner in a Job. The runtime configuration of the Job is the
+// default one, overridden by the ones of the Build and of the provisioner. The Build fails when
+// they override the image or the security context of a Job running in the shared namespace.
+func Reconcile(ctx context.Context, client client.Client, build *buildv1.Build, spec *buildv1.ProvisionerSpec, opts Options) (_ ctrl.Result, err error) {
 
 	// Create the Job
 	if spec.UUID == nil {
+		// No provisioner starts on the machine of a failed Build.
+		if build.Status.FailureReason != nil {
+			return ctrl.Result{}, nil
+		}
+
+		// A failed provisioner runs again after its retry backoff.
+		if spec.RetryTime != nil {
+			if wait := time.Until(spec.RetryTime.Time); wait > 0 {
+				return ctrl.Result{RequeueAfter: wait}, nil
+			}
+		}
+
+		// The Jobs in the shared namespace run with a ServiceAccount reading the Secrets of all the
+		// Builds, only the operator chooses their image and security context.
+		if opts.Namespace != "" {
+			for _, config := range []*buildv1.ProvisionerRuntimeConfig{build.Spec.ProvisionerRuntime, spec.Runtime} {
+				if field := privilegedOverride(config); field != "" {
+					build.Status.FailureReason = ptr.To(builderror.ProvisionerFailedError)
+					build.Status.FailureMessage = ptr.To(fmt.Sprintf("Provisioner %s cannot override the %s of its Job, "+
+						"the provisioner Jobs run in the shared namespace %s", provisionerStep(build, spec), field, opts.Namespace))
+					return ctrl.Result{}, nil
+				}
+			}
+		}
+
+		namespace := opts.Namespace
+		if namespace == "" {
+			namespace = build.Namespace
+			if err := ensureServiceAccount(ctx, client, namespace); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+
 		id := uuid.New()
 		builder := job.NewShellJobBuilder().
-			WithNamespace(ForgeCoreNamespace).
+			WithNamespace(namespace).
 			WithBuildNamespace(build.Namespace).
 			WithBuildName(build.Name).
 			WithUUID(id.String()).
-			// TODO get repo and tag from variables
-			WithRepo("medchiheb/forge-shell-provisioner").
-			WithTag("dev").
-			WithBackOffLimit(ptr.Deref(spec.Retries, 1)).
-			WithSSHCredentialsSecretName(build.Spec.Connector.Credentials.Name)
+			WithStep(provisionerStep(build, spec)).
+			WithRepo(ShellProvisionerRepo).
+			WithTag(ShellProvisionerTag).
+			WithRuntimeConfig(opts.Runtime.Merge(build.Spec.ProvisionerRuntime).Merge(spec.Runtime)).
+			// The failed runs are retried by the ShellJobController, according to the exit code of the script.
+			WithBackOffLimit(0).
+			WithSSHCredentialsSecretName(build.Spec.Connector.Credentials.Name).
+			WithMountedSSHCredentials(namespace == build.Namespace).
+			WithExpectDisconnect(spec.ExpectDisconnect).
+			WithVerifyBootID(spec.VerifyBootID).
+			WithOutputsFile(shell.OutputsFile).
+			WithOutputs(forgeutil.GetProvisionerOutputs(build)).
+			WithTraceParent(tracing.TraceParent(ctx)).
+			WithOTLPEndpoint(tracing.Endpoint())
 
+		if spec.ReconnectTimeout != nil {
+			builder.WithReconnectTimeout(spec.ReconnectTimeout.Duration)
+		}
+		if spec.Type == buildv1.ProvisionerTypeReboot {
+			builder.WithReboot(true).
+				WithScriptToRun(shell.DefaultRebootCommand)
+		}
 		if spec.Run != nil {
 			builder.WithScriptToRun(*spec.Run)
 		}
 		if spec.RunConfigMapRef != nil {
-			builder.WithScriptToRun(*spec.Run)
+			builder.WithScriptToRunRef(spec.RunConfigMapRef.Name)
 		}
 
 		desired, err := builder.Build()
@@ -52,21 +125,27 @@ func Reconcile(ctx context.Context, client client.Client, build *buildv1.Build,
 			return ctrl.Result{}, err
 		}
 
-		op, err := controllerutil.CreateOrPatch(ctx, client, desired, func() error {
-			return nil
-		})
-		if err != nil {
-			return ctrl.Result{}, err
+		// The Job is deleted with its Build when they are in the same namespace.
+		if namespace == build.Namespace {
+			if err := controllerutil.SetControllerReference(build, desired, client.Scheme()); err != nil {
+				return ctrl.Result{}, errors.Wrap(err, "failed to set the owner of the provisioner job")
+			}
+		}
+
+		// The Job of each run of a provisioner is new, an existing one belongs to another provisioner.
+		if err := client.Create(ctx, desired); err != nil {
+			if apierrors.IsAlreadyExists(err) {
+				return ctrl.Result{}, errors.Errorf("provisioner job %s/%s already exists", desired.Namespace, desired.Name)
+			}
+			return ctrl.Result{}, errors.Wrapf(err, "failed to create the provisioner job %s/%s", desired.Namespace, desired.Name)
 		}
 
 		spec.UUID = ptr.To(id.String())
 		spec.Status = ptr.To(buildv1.ProvisionerStatusRunning)
-		if op != controllerutil.OperationResultNone {
-			// After job created we RequeueAfter 2 seconds.
-			return ctrl.Result{
-				RequeueAfter: 2 * time.Second,
-			}, nil
-		}
+		// After job created we RequeueAfter 2 seconds.
+		return ctrl.Result{
+			RequeueAfter: 2 * time.Second,
+		}, nil
 	}
 
 	switch *spec.Status {
@@ -86,7 +165,9 @@ func Reconcile(ctx context.Context, client client.Client, build *buildv1.Build,
 		}
 		// Fail the Build if provisioner failed.
 		build.Status.FailureReason = ptr.To(builderror.ProvisionerFailedError)
-		build.Status.FailureMessage = ptr.To(fmt.Sprintf("Provisioner %s failed with Reason %s and Message %s", *spec.UUID, *spec.FailureReason, *spec.FailureMessage))
+		// The failure is unknown when the pod of the Job is gone.
+		build.Status.FailureMessage = ptr.To(fmt.Sprintf("Provisioner %s failed with Reason %s and Message %s",
+			*spec.UUID, ptr.Deref(spec.FailureReason, "Unknown"), ptr.Deref(spec.FailureMessage, "")))
 		return ctrl.Result{}, nil
 	default:
 		return ctrl.Result{}, nil
@@ -94,3 +175,32 @@ func Reconcile(ctx context.Context, client client.Client, build *buildv1.Build,
 
 	return ctrl.Result{}, nil
 }
+
+// privilegedOverride returns the name of the first field of the runtime configuration setting the image
+// or the security context of the Job, or an empty string.
+func privilegedOverride(config *buildv1.ProvisionerRuntimeConfig) string {
+	switch {
+	case config == nil:
+		return ""
+	case config.Image != "":
+		return "image"
+	case config.PodSecurityContext != nil:
+		return "podSecurityContext"
+	case config.SecurityContext != nil:
+		return "securityContext"
+	}
+	return ""
+}
+
+// provisionerStep returns the index of the provisioner in the Build, or cleanup for the cleanup stage.
+func provisionerStep(build *buildv1.Build, spec *buildv1.ProvisionerSpec) string {
+	for i := range build.Spec.Provisioners {
+		if &build.Spec.Provisioners[i] == spec {
+			return strconv.Itoa(i)
+		}
+	}
+	if build.Status.Cleanup == spec {
+		return CleanupStep
+	}
+	return ""
+}
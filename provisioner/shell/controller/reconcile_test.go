@@ -0,0 +1,523 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	buildv1 "github.com/forge-build/forge/api/v1alpha1"
+	builderror "github.com/forge-build/forge/pkg/errors"
+	"github.com/forge-build/forge/provisioner/shell"
+)
+
+func newTestBuild(namespace string, provisioners int) *buildv1.Build {
+	build := &buildv1.Build{
+		ObjectMeta: metav1.ObjectMeta{Name: "ubuntu", Namespace: namespace},
+		Spec: buildv1.BuildSpec{
+			Connector: buildv1.ConnectorSpec{Type: "ssh", Credentials: &corev1.LocalObjectReference{Name: "ubuntu-ssh"}},
+		},
+	}
+	for i := 0; i < provisioners; i++ {
+		build.Spec.Provisioners = append(build.Spec.Provisioners, buildv1.ProvisionerSpec{
+			Type: buildv1.ProvisionerTypeShell,
+			Run:  ptr.To("apt-get upgrade -y"),
+		})
+	}
+	return build
+}
+
+func newTestClient(g *WithT, objs ...client.Object) client.Client {
+	scheme := runtime.NewScheme()
+	g.Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+	g.Expect(buildv1.AddToScheme(scheme)).To(Succeed())
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build()
+}
+
+// reconcileProvisioners runs the provisioners of the Build one after the other, like the Build controller.
+func reconcileProvisioners(ctx context.Context, c client.Client, build *buildv1.Build, opts Options) error {
+	for i := range build.Spec.Provisioners {
+		res, err := Reconcile(ctx, c, build, &build.Spec.Provisioners[i], opts)
+		if err != nil {
+			return err
+		}
+		if res.RequeueAfter > 0 || build.Status.FailureReason != nil {
+			break
+		}
+	}
+	return c.Update(ctx, build)
+}
+
+// completeJob marks the Job complete and processes it like the ShellJobController.
+func completeJob(ctx context.Context, g *WithT, c client.Client, job *batchv1.Job) {
+	finishJob(ctx, g, c, job, batchv1.JobComplete, 0, `{"exitCode":0}`)
+}
+
+// failJob marks the Job failed, with the termination message of its Pod, and processes it like the ShellJobController.
+func failJob(ctx context.Context, g *WithT, c client.Client, job *batchv1.Job, message string) {
+	finishJob(ctx, g, c, job, batchv1.JobFailed, 1, message)
+}
+
+// finishJob sets the condition of the Job, and the exit code and termination message of its Pod,
+// and processes it like the ShellJobController.
+func finishJob(ctx context.Context, g *WithT, c client.Client, job *batchv1.Job, condition batchv1.JobConditionType, exitCode int32, message string) {
+	job.Status.Conditions = []batchv1.JobCondition{{Type: condition, Status: corev1.ConditionTrue}}
+	g.Expect(c.Status().Update(ctx, job)).To(Succeed())
+
+	// The API server sets the selector of the Job and the labels of its Pods.
+	refreshed := job.DeepCopy()
+	refreshed.Spec.Selector = &metav1.LabelSelector{MatchLabels: map[string]string{"batch.kubernetes.io/controller-uid": job.Name}}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      job.Name + "-0",
+			Namespace: job.Namespace,
+			Labels:    refreshed.Spec.Selector.MatchLabels,
+		},
+		Status: corev1.PodStatus{
+			ContainerStatuses: []corev1.ContainerStatus{{
+				Name: "shell-provisioner",
+				State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+					ExitCode: exitCode,
+					Reason:   "Error",
+					Message:  message,
+				}},
+			}},
+		},
+	}
+
+	r := &ShellJobController{Client: c, Clientset: kubefake.NewSimpleClientset(refreshed, pod), Logger: logr.Discard()}
+	_, err := r.reconcileJobs()(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(job)})
+	g.Expect(err).NotTo(HaveOccurred())
+}
+
+// runningJob returns the Job of the running provisioner of the Build.
+func runningJob(ctx context.Context, g *WithT, c client.Client, build *buildv1.Build) *batchv1.Job {
+	jobs := &batchv1.JobList{}
+	g.Expect(c.List(ctx, jobs, client.MatchingLabels{buildv1.BuildNameLabel: build.Name})).To(Succeed())
+	g.Expect(jobs.Items).To(HaveLen(1))
+	return &jobs.Items[0]
+}
+
+func TestReconcileProvisionersInSequence(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	build := newTestBuild("default", 3)
+	c := newTestClient(g, build)
+
+	names := map[string]bool{}
+	for i := range build.Spec.Provisioners {
+		g.Expect(reconcileProvisioners(ctx, c, build, Options{})).To(Succeed())
+
+		// Only the Job of the running provisioner exists.
+		jobs := &batchv1.JobList{}
+		g.Expect(c.List(ctx, jobs)).To(Succeed())
+		g.Expect(jobs.Items).To(HaveLen(1))
+		job := &jobs.Items[0]
+		g.Expect(job.Labels).To(HaveKeyWithValue(buildv1.ProvisionerIDLabel, *build.Spec.Provisioners[i].UUID))
+		g.Expect(job.Labels).To(HaveKeyWithValue(buildv1.ProvisionerStepLabel, []string{"0", "1", "2"}[i]))
+		g.Expect(names).NotTo(HaveKey(job.Name))
+		names[job.Name] = true
+
+		completeJob(ctx, g, c, job)
+		g.Expect(c.Get(ctx, client.ObjectKeyFromObject(build), build)).To(Succeed())
+		g.Expect(build.Spec.Provisioners[i].Status).To(Equal(ptr.To(buildv1.ProvisionerStatusCompleted)))
+	}
+
+	g.Expect(reconcileProvisioners(ctx, c, build, Options{})).To(Succeed())
+	jobs := &batchv1.JobList{}
+	g.Expect(c.List(ctx, jobs)).To(Succeed())
+	g.Expect(jobs.Items).To(BeEmpty())
+	g.Expect(names).To(HaveLen(3))
+}
+
+func TestReconcileProvisionersStopOnFailure(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	build := newTestBuild("default", 2)
+	build.Spec.Provisioners[0].Retries = ptr.To[int32](0)
+	c := newTestClient(g, build)
+
+	g.Expect(reconcileProvisioners(ctx, c, build, Options{})).To(Succeed())
+	failJob(ctx, g, c, runningJob(ctx, g, c, build), `{"exitCode":1,"message":"failed to run script: apt-get failed"}`)
+	g.Expect(c.Get(ctx, client.ObjectKeyFromObject(build), build)).To(Succeed())
+	g.Expect(build.Spec.Provisioners[0].Status).To(Equal(ptr.To(buildv1.ProvisionerStatusFailed)))
+
+	// The failed provisioner fails the Build, the next one does not run.
+	g.Expect(reconcileProvisioners(ctx, c, build, Options{})).To(Succeed())
+	g.Expect(build.Status.FailureReason).To(Equal(ptr.To(builderror.ProvisionerFailedError)))
+	_, err := Reconcile(ctx, c, build, &build.Spec.Provisioners[1], Options{})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(build.Spec.Provisioners[1].UUID).To(BeNil())
+	jobs := &batchv1.JobList{}
+	g.Expect(c.List(ctx, jobs)).To(Succeed())
+	g.Expect(jobs.Items).To(BeEmpty())
+}
+
+func TestReconcileProvisionersAcrossNamespaces(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	// Builds with the same name in different namespaces get their own Jobs.
+	dev, prod := newTestBuild("dev", 1), newTestBuild("prod", 1)
+	c := newTestClient(g, dev, prod)
+	opts := Options{Namespace: ForgeCoreNamespace}
+	g.Expect(reconcileProvisioners(ctx, c, dev, opts)).To(Succeed())
+	g.Expect(reconcileProvisioners(ctx, c, prod, opts)).To(Succeed())
+
+	jobs := &batchv1.JobList{}
+	g.Expect(c.List(ctx, jobs, client.InNamespace(ForgeCoreNamespace))).To(Succeed())
+	g.Expect(jobs.Items).To(HaveLen(2))
+	g.Expect(jobs.Items[0].Name).NotTo(Equal(jobs.Items[1].Name))
+
+	// The Job of a deleted Build is deleted.
+	g.Expect(c.Delete(ctx, dev)).To(Succeed())
+	devJobs := &batchv1.JobList{}
+	g.Expect(c.List(ctx, devJobs, client.MatchingLabels{buildv1.BuildNamespaceLabel: "dev"})).To(Succeed())
+	g.Expect(devJobs.Items).To(HaveLen(1))
+	completeJob(ctx, g, c, &devJobs.Items[0])
+
+	g.Expect(c.List(ctx, jobs, client.InNamespace(ForgeCoreNamespace))).To(Succeed())
+	g.Expect(jobs.Items).To(HaveLen(1))
+	g.Expect(jobs.Items[0].Labels).To(HaveKeyWithValue(buildv1.BuildNamespaceLabel, "prod"))
+}
+
+func TestReconcileSharedNamespaceOverrides(t *testing.T) {
+	tests := []struct {
+		name    string
+		build   *buildv1.ProvisionerRuntimeConfig
+		spec    *buildv1.ProvisionerRuntimeConfig
+		wantErr string
+	}{
+		{
+			name:  "resources",
+			build: &buildv1.ProvisionerRuntimeConfig{NodeSelector: map[string]string{"pool": "builds"}},
+			spec:  &buildv1.ProvisionerRuntimeConfig{PriorityClassName: "low"},
+		},
+		{
+			name:    "image of the Build",
+			build:   &buildv1.ProvisionerRuntimeConfig{Image: "registry.example.com/shell:latest"},
+			wantErr: "Provisioner 0 cannot override the image of its Job",
+		},
+		{
+			name:    "security context of the provisioner",
+			spec:    &buildv1.ProvisionerRuntimeConfig{SecurityContext: &corev1.SecurityContext{Privileged: ptr.To(true)}},
+			wantErr: "Provisioner 0 cannot override the securityContext of its Job",
+		},
+		{
+			name:    "pod security context of the provisioner",
+			spec:    &buildv1.ProvisionerRuntimeConfig{PodSecurityContext: &corev1.PodSecurityContext{RunAsUser: ptr.To[int64](0)}},
+			wantErr: "Provisioner 0 cannot override the podSecurityContext of its Job",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			ctx := context.Background()
+
+			build := newTestBuild("default", 1)
+			build.Spec.ProvisionerRuntime = tt.build
+			build.Spec.Provisioners[0].Runtime = tt.spec
+			c := newTestClient(g, build)
+			g.Expect(reconcileProvisioners(ctx, c, build, Options{Namespace: ForgeCoreNamespace})).To(Succeed())
+
+			jobs := &batchv1.JobList{}
+			g.Expect(c.List(ctx, jobs)).To(Succeed())
+			if tt.wantErr == "" {
+				g.Expect(build.Status.FailureReason).To(BeNil())
+				g.Expect(jobs.Items).To(HaveLen(1))
+				return
+			}
+			g.Expect(build.Status.FailureReason).To(Equal(ptr.To(builderror.ProvisionerFailedError)))
+			g.Expect(*build.Status.FailureMessage).To(HavePrefix(tt.wantErr))
+			g.Expect(build.Spec.Provisioners[0].UUID).To(BeNil())
+			g.Expect(jobs.Items).To(BeEmpty())
+
+			// The overrides are allowed when the Jobs run in the namespace of their Build.
+			build.Status.FailureReason, build.Status.FailureMessage = nil, nil
+			g.Expect(reconcileProvisioners(ctx, c, build, Options{})).To(Succeed())
+			g.Expect(build.Status.FailureReason).To(BeNil())
+			g.Expect(c.List(ctx, jobs)).To(Succeed())
+			g.Expect(jobs.Items).To(HaveLen(1))
+		})
+	}
+}
+
+func TestReconcileStaleJob(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	build := newTestBuild("default", 1)
+	c := newTestClient(g, build)
+	g.Expect(reconcileProvisioners(ctx, c, build, Options{})).To(Succeed())
+	stale := *build.Spec.Provisioners[0].UUID
+
+	// The provisioner runs again, e.g. after a retry, with a new Job.
+	build.Spec.Provisioners[0].UUID = nil
+	build.Spec.Provisioners[0].Status = nil
+	g.Expect(reconcileProvisioners(ctx, c, build, Options{})).To(Succeed())
+
+	jobs := &batchv1.JobList{}
+	g.Expect(c.List(ctx, jobs, client.MatchingLabels{buildv1.ProvisionerIDLabel: stale})).To(Succeed())
+	g.Expect(jobs.Items).To(HaveLen(1))
+	completeJob(ctx, g, c, &jobs.Items[0])
+
+	// The stale Job is deleted without updating the provisioner.
+	g.Expect(c.Get(ctx, client.ObjectKeyFromObject(build), build)).To(Succeed())
+	g.Expect(build.Spec.Provisioners[0].Status).To(Equal(ptr.To(buildv1.ProvisionerStatusRunning)))
+	g.Expect(c.List(ctx, jobs)).To(Succeed())
+	g.Expect(jobs.Items).To(HaveLen(1))
+	g.Expect(jobs.Items[0].Labels).To(HaveKeyWithValue(buildv1.ProvisionerIDLabel, *build.Spec.Provisioners[0].UUID))
+}
+
+func TestReconcileFailedWithoutReason(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	// The pod of the failed Job was gone, the failure is unknown.
+	build := newTestBuild("default", 1)
+	build.Spec.Provisioners[0].UUID = ptr.To("5d2c8b1e")
+	build.Spec.Provisioners[0].Status = ptr.To(buildv1.ProvisionerStatusFailed)
+	c := newTestClient(g, build)
+
+	_, err := Reconcile(ctx, c, build, &build.Spec.Provisioners[0], Options{})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(build.Status.FailureMessage).To(Equal(ptr.To("Provisioner 5d2c8b1e failed with Reason Unknown and Message ")))
+}
+
+func TestReconcileProvisionersInBuildNamespace(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	build := newTestBuild("dev", 2)
+	c := newTestClient(g, build)
+	for i := range build.Spec.Provisioners {
+		g.Expect(reconcileProvisioners(ctx, c, build, Options{})).To(Succeed())
+
+		jobs := &batchv1.JobList{}
+		g.Expect(c.List(ctx, jobs, client.InNamespace("dev"))).To(Succeed())
+		g.Expect(jobs.Items).To(HaveLen(1))
+		job := &jobs.Items[0]
+		g.Expect(job.Labels).To(HaveKeyWithValue(buildv1.ProvisionerIDLabel, *build.Spec.Provisioners[i].UUID))
+		g.Expect(metav1.IsControlledBy(job, build)).To(BeTrue())
+
+		// The ssh credentials are mounted instead of being read via the API.
+		podSpec := job.Spec.Template.Spec
+		g.Expect(podSpec.ServiceAccountName).To(Equal(shell.ForgeProvisionerShellName))
+		g.Expect(podSpec.Volumes).To(HaveLen(1))
+		g.Expect(podSpec.Volumes[0].Secret.SecretName).To(Equal("ubuntu-ssh"))
+		g.Expect(podSpec.Containers[0].Args).To(ContainElements("--ssh-credentials-path", shell.CredentialsSecretPath))
+		g.Expect(podSpec.Containers[0].Args).NotTo(ContainElement("--ssh-credentials-secret-name"))
+
+		completeJob(ctx, g, c, job)
+		g.Expect(c.Get(ctx, client.ObjectKeyFromObject(build), build)).To(Succeed())
+	}
+
+	// The service account of the Jobs is only allowed to read the ConfigMaps of the namespace.
+	key := client.ObjectKey{Namespace: "dev", Name: shell.ForgeProvisionerShellName}
+	g.Expect(c.Get(ctx, key, &corev1.ServiceAccount{})).To(Succeed())
+	role := &rbacv1.Role{}
+	g.Expect(c.Get(ctx, key, role)).To(Succeed())
+	g.Expect(role.Rules).To(Equal([]rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"get"}}}))
+	binding := &rbacv1.RoleBinding{}
+	g.Expect(c.Get(ctx, key, binding)).To(Succeed())
+	g.Expect(binding.RoleRef).To(Equal(rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: shell.ForgeProvisionerShellName}))
+	g.Expect(binding.Subjects).To(Equal([]rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: shell.ForgeProvisionerShellName, Namespace: "dev"}}))
+}
+
+func TestReconcileExitCodePolicy(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	build := newTestBuild("default", 1)
+	p := &build.Spec.Provisioners[0]
+	p.Retries = ptr.To[int32](2)
+	p.RetryBackoff = &metav1.Duration{}
+	p.ExitCodePolicy = &buildv1.ExitCodePolicy{Success: []int32{3}, Retry: []int32{100}, Default: buildv1.ExitCodeActionFail}
+	c := newTestClient(g, build)
+
+	// A transient failure is retried with a new Job.
+	g.Expect(reconcileProvisioners(ctx, c, build, Options{})).To(Succeed())
+	job := runningJob(ctx, g, c, build)
+	g.Expect(job.Spec.BackoffLimit).To(Equal(ptr.To[int32](0)))
+	failJob(ctx, g, c, job, `{"exitCode":100,"message":"failed to run script: apt-get update failed"}`)
+	g.Expect(c.Get(ctx, client.ObjectKeyFromObject(build), build)).To(Succeed())
+	p = &build.Spec.Provisioners[0]
+	g.Expect(p.Status).To(Equal(ptr.To(buildv1.ProvisionerStatusPending)))
+	g.Expect(p.UUID).To(BeNil())
+	g.Expect(p.ExitCode).To(Equal(ptr.To[int32](100)))
+	g.Expect(p.Retried).To(Equal(int32(1)))
+	g.Expect(p.FailureMessage).To(Equal(ptr.To("failed to run script: apt-get update failed")))
+
+	// A run which did not exit is retried.
+	g.Expect(reconcileProvisioners(ctx, c, build, Options{})).To(Succeed())
+	job = runningJob(ctx, g, c, build)
+	failJob(ctx, g, c, job, `{"message":"failed to connect to the machine via ssh"}`)
+	g.Expect(c.Get(ctx, client.ObjectKeyFromObject(build), build)).To(Succeed())
+	p = &build.Spec.Provisioners[0]
+	g.Expect(p.Status).To(Equal(ptr.To(buildv1.ProvisionerStatusPending)))
+	g.Expect(p.ExitCode).To(BeNil())
+	g.Expect(p.Retried).To(Equal(int32(2)))
+
+	// A script error fails the provisioner.
+	g.Expect(reconcileProvisioners(ctx, c, build, Options{})).To(Succeed())
+	job = runningJob(ctx, g, c, build)
+	failJob(ctx, g, c, job, `{"exitCode":1,"message":"failed to run script: syntax error"}`)
+	g.Expect(c.Get(ctx, client.ObjectKeyFromObject(build), build)).To(Succeed())
+	p = &build.Spec.Provisioners[0]
+	g.Expect(p.Status).To(Equal(ptr.To(buildv1.ProvisionerStatusFailed)))
+	g.Expect(p.ExitCode).To(Equal(ptr.To[int32](1)))
+	g.Expect(p.Retried).To(Equal(int32(2)))
+	jobs := &batchv1.JobList{}
+	g.Expect(c.List(ctx, jobs)).To(Succeed())
+	g.Expect(jobs.Items).To(BeEmpty())
+}
+
+func TestReconcileExitCodePolicyFailStopsSequence(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	build := newTestBuild("default", 2)
+	p := &build.Spec.Provisioners[0]
+	p.Retries = ptr.To[int32](2)
+	p.ExitCodePolicy = &buildv1.ExitCodePolicy{Retry: []int32{100}, Default: buildv1.ExitCodeActionFail}
+	c := newTestClient(g, build)
+
+	// A script error fails fast, without retrying the provisioner nor running the next one.
+	g.Expect(reconcileProvisioners(ctx, c, build, Options{})).To(Succeed())
+	failJob(ctx, g, c, runningJob(ctx, g, c, build), `{"exitCode":2,"message":"failed to run script: syntax error"}`)
+	g.Expect(c.Get(ctx, client.ObjectKeyFromObject(build), build)).To(Succeed())
+	g.Expect(build.Spec.Provisioners[0].Status).To(Equal(ptr.To(buildv1.ProvisionerStatusFailed)))
+	g.Expect(build.Spec.Provisioners[0].Retried).To(Equal(int32(0)))
+
+	g.Expect(reconcileProvisioners(ctx, c, build, Options{})).To(Succeed())
+	g.Expect(build.Status.FailureReason).To(Equal(ptr.To(builderror.ProvisionerFailedError)))
+	g.Expect(build.Spec.Provisioners[1].UUID).To(BeNil())
+	jobs := &batchv1.JobList{}
+	g.Expect(c.List(ctx, jobs)).To(Succeed())
+	g.Expect(jobs.Items).To(BeEmpty())
+}
+
+func TestReconcileExitCodePolicySuccess(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	build := newTestBuild("default", 1)
+	build.Spec.Provisioners[0].ExitCodePolicy = &buildv1.ExitCodePolicy{Success: []int32{3}}
+	c := newTestClient(g, build)
+
+	g.Expect(reconcileProvisioners(ctx, c, build, Options{})).To(Succeed())
+	failJob(ctx, g, c, runningJob(ctx, g, c, build), `{"exitCode":3,"message":"failed to run script: exit status 3"}`)
+	g.Expect(c.Get(ctx, client.ObjectKeyFromObject(build), build)).To(Succeed())
+	g.Expect(build.Spec.Provisioners[0].Status).To(Equal(ptr.To(buildv1.ProvisionerStatusCompleted)))
+	g.Expect(build.Spec.Provisioners[0].ExitCode).To(Equal(ptr.To[int32](3)))
+}
+
+func TestReconcileRetryBackoff(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	build := newTestBuild("default", 1)
+	c := newTestClient(g, build)
+
+	// The Job failed before writing its result, the provisioner runs again after the default backoff.
+	g.Expect(reconcileProvisioners(ctx, c, build, Options{})).To(Succeed())
+	failJob(ctx, g, c, runningJob(ctx, g, c, build), "E0101 00:00:00.000000 1 main.go:1] Error getting secret")
+	g.Expect(c.Get(ctx, client.ObjectKeyFromObject(build), build)).To(Succeed())
+	p := &build.Spec.Provisioners[0]
+	g.Expect(p.Status).To(Equal(ptr.To(buildv1.ProvisionerStatusPending)))
+	g.Expect(p.RetryTime).NotTo(BeNil())
+
+	res, err := Reconcile(ctx, c, build, p, Options{})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(res.RequeueAfter).To(BeNumerically(">", 0))
+	g.Expect(res.RequeueAfter).To(BeNumerically("<=", buildv1.DefaultProvisionerRetryBackoff))
+	g.Expect(p.UUID).To(BeNil())
+	jobs := &batchv1.JobList{}
+	g.Expect(c.List(ctx, jobs)).To(Succeed())
+	g.Expect(jobs.Items).To(BeEmpty())
+
+	// The retries are exhausted.
+	p.RetryTime = nil
+	g.Expect(reconcileProvisioners(ctx, c, build, Options{})).To(Succeed())
+	failJob(ctx, g, c, runningJob(ctx, g, c, build), "E0101 00:00:00.000000 1 main.go:1] Error getting secret")
+	g.Expect(c.Get(ctx, client.ObjectKeyFromObject(build), build)).To(Succeed())
+	g.Expect(build.Spec.Provisioners[0].Status).To(Equal(ptr.To(buildv1.ProvisionerStatusFailed)))
+	g.Expect(build.Spec.Provisioners[0].Retried).To(Equal(int32(1)))
+}
+
+func TestReconcileProvisionerResult(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	build := newTestBuild("default", 2)
+	c := newTestClient(g, build)
+
+	g.Expect(reconcileProvisioners(ctx, c, build, Options{})).To(Succeed())
+	finishJob(ctx, g, c, runningJob(ctx, g, c, build), batchv1.JobComplete, 0,
+		`{"exitCode":0,"duration":90000000000,"stdout":"6.8.0-40-generic","outputs":{"kernel":"6.8.0-40-generic"},"artifacts":["/exports/ubuntu.tar.gz"]}`)
+	g.Expect(c.Get(ctx, client.ObjectKeyFromObject(build), build)).To(Succeed())
+	g.Expect(build.Spec.Provisioners[0].Status).To(Equal(ptr.To(buildv1.ProvisionerStatusCompleted)))
+	g.Expect(build.Spec.Provisioners[0].Result).To(Equal(&buildv1.ProvisionerResult{
+		Duration:  &metav1.Duration{Duration: 90 * time.Second},
+		Stdout:    "6.8.0-40-generic",
+		Outputs:   map[string]string{"kernel": "6.8.0-40-generic"},
+		Artifacts: []string{"/exports/ubuntu.tar.gz"},
+	}))
+
+	// The result of a failed run is reported too.
+	g.Expect(reconcileProvisioners(ctx, c, build, Options{})).To(Succeed())
+	failJob(ctx, g, c, runningJob(ctx, g, c, build), `{"exitCode":2,"message":"failed to run script","stderr":"E: Unable to locate package nginx"}`)
+	g.Expect(c.Get(ctx, client.ObjectKeyFromObject(build), build)).To(Succeed())
+	g.Expect(build.Spec.Provisioners[1].Result).To(Equal(&buildv1.ProvisionerResult{Stderr: "E: Unable to locate package nginx"}))
+}
+
+func TestReconcileProvisionerOutputs(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	build := newTestBuild("default", 2)
+	c := newTestClient(g, build)
+
+	g.Expect(reconcileProvisioners(ctx, c, build, Options{})).To(Succeed())
+	job := runningJob(ctx, g, c, build)
+	g.Expect(job.Spec.Template.Spec.Containers[0].Args).To(ContainElements("--outputs-file", shell.OutputsFile))
+	finishJob(ctx, g, c, job, batchv1.JobComplete, 0, `{"exitCode":0,"outputs":{"kernel":"6.8.0-40-generic"}}`)
+
+	// The outputs of the previous provisioners are exported to the script.
+	g.Expect(c.Get(ctx, client.ObjectKeyFromObject(build), build)).To(Succeed())
+	g.Expect(reconcileProvisioners(ctx, c, build, Options{})).To(Succeed())
+	job = runningJob(ctx, g, c, build)
+	g.Expect(job.Labels).To(HaveKeyWithValue(buildv1.ProvisionerStepLabel, "1"))
+	g.Expect(job.Spec.Template.Spec.Containers[0].Env).To(ContainElement(corev1.EnvVar{Name: "FORGE_OUTPUT_KERNEL", Value: "6.8.0-40-generic"}))
+}
@@ -3,6 +3,7 @@ package controller
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/forge-build/forge/util"
 	"k8s.io/utils/ptr"
@@ -10,6 +11,7 @@ import (
 	"sigs.k8s.io/cluster-api/util/patch"
 
 	buildv1 "github.com/forge-build/forge/api/v1alpha1"
+	"github.com/forge-build/forge/provisioner/shell"
 	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -21,6 +23,7 @@ import (
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
@@ -30,21 +33,25 @@ var podControlledByJobNotFoundErr = errors.New("pod for job not found")
 type ShellJobController struct {
 	Logger logr.Logger
 	client.Client
-	Clientset *kubernetes.Clientset
+	Clientset kubernetes.Interface
+	// Namespace is the namespace of the Jobs, the Jobs of all the namespaces are watched if empty.
 	Namespace string
 
 	patchHelper *patch.Helper
 }
 
 func (r *ShellJobController) SetupWithManager(mgr ctrl.Manager) error {
+	predicates := []predicate.Predicate{
+		ManagedByForgeProvisionerShell,
+		JobHasAnyCondition,
+		HasBuildNameLabel,
+		HasProvisionerIDLabel,
+	}
+	if r.Namespace != "" {
+		predicates = append(predicates, InNamespace(r.Namespace))
+	}
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&batchv1.Job{}, builder.WithPredicates(
-			ManagedByForgeProvisionerShell,
-			InNamespace(r.Namespace),
-			JobHasAnyCondition,
-			HasBuildNameLabel,
-			HasProvisionerIDLabel,
-		)).
+		For(&batchv1.Job{}, builder.WithPredicates(predicates...)).
 		Complete(r.reconcileJobs())
 }
 
@@ -75,11 +82,17 @@ func (r *ShellJobController) reconcileJobs() reconcile.Func {
 		err = r.Client.Get(ctx, client.ObjectKey{Namespace: buildNamespace, Name: buildName}, build)
 		if err != nil {
 			if k8sapierror.IsNotFound(err) {
-				r.Logger.Info("Ignoring cached job that must have been deleted")
-				return ctrl.Result{}, nil
+				// The Job may not be owned by a Build from another namespace, it is deleted with it.
+				r.Logger.Info("Deleting the job of a deleted build", "job", job.Name)
+				return ctrl.Result{}, r.deleteJob(ctx, job)
 			}
 			return ctrl.Result{}, fmt.Errorf("getting build from cache: %w", err)
 		}
+		if _, err := util.GetProvisionerByID(build, provisionerID); err != nil {
+			// The provisioner ran again or the Build was reset, the Job is stale.
+			r.Logger.Info("Deleting the job of a provisioner which is not in the build anymore", "job", job.Name, "provisionerID", provisionerID)
+			return ctrl.Result{}, r.deleteJob(ctx, job)
+		}
 		r.patchHelper, err = patch.NewHelper(build, r.Client)
 		if err != nil {
 			return ctrl.Result{}, errors.Wrap(err, "failed to create patch helper")
@@ -106,14 +119,29 @@ func (r *ShellJobController) reconcileJobs() reconcile.Func {
 func (r *ShellJobController) processCompleteScanJob(ctx context.Context, job *batchv1.Job, build *buildv1.Build, provisionerID string) error {
 	r.Logger.Info("Job complete", "build", build.Name, "provisionerID", provisionerID)
 
-	// TODO think about how to handle the output of the shell job (providing logs)
-
 	// Update Build Provisioner Status
 	provisioner, err := util.GetProvisionerByID(build, provisionerID)
 	if err != nil {
 		return errors.Wrapf(err, "unable to find provisioner with id %s in the build %s", provisionerID, build.Name)
 	}
 	provisioner.Status = ptr.To(buildv1.ProvisionerStatusCompleted)
+	provisioner.ExitCode = ptr.To[int32](0)
+
+	// The provisioner reports its result, e.g. its outputs, in its termination message.
+	statuses, err := r.GetTerminatedContainersStatusesByJob(ctx, job)
+	if err != nil {
+		r.Logger.Error(err, "Could not get the result of the job", "job", job.Name)
+	}
+	var result *shell.Result
+	for _, status := range statuses {
+		if result = shell.ParseResult(status.Message); result != nil {
+			break
+		}
+	}
+	setResult(provisioner, result)
+	provisioner.FailureReason = nil
+	provisioner.FailureMessage = nil
+	provisioner.RetryTime = nil
 
 	if err := r.patchHelper.Patch(ctx, build); err != nil {
 		r.Logger.Error(err, "failed to patch build")
@@ -137,6 +165,7 @@ func (r *ShellJobController) processFailedScanJob(ctx context.Context, job *batc
 		return errors.Wrapf(err, "unable to find provisioner with id %s in the build %s", provisionerID, build.Name)
 	}
 
+	var result *shell.Result
 	for container, status := range statuses {
 		if status.ExitCode == 0 {
 			continue
@@ -145,9 +174,31 @@ func (r *ShellJobController) processFailedScanJob(ctx context.Context, job *batc
 		r.Logger.Error(errors.New("shell job failed"), "shell failed with reason", "build", build, "provisionerID", provisionerID, "container", container, "errorMessage", errorMsg)
 		provisioner.FailureReason = ptr.To(status.Reason)
 		provisioner.FailureMessage = ptr.To(status.Message)
+		// The provisioner reports the exit code of the script in its termination message.
+		if result = shell.ParseResult(status.Message); result != nil {
+			provisioner.FailureMessage = ptr.To(result.Message)
+		}
 	}
 
-	provisioner.Status = ptr.To(buildv1.ProvisionerStatusFailed)
+	provisioner.ExitCode = nil
+	if result != nil {
+		provisioner.ExitCode = result.ExitCode
+	}
+	setResult(provisioner, result)
+	switch action := provisioner.ExitCodePolicy.Action(provisioner.ExitCode); {
+	case action == buildv1.ExitCodeActionSucceed:
+		r.Logger.Info("The exit code of the script is a success", "provisionerID", provisionerID, "exitCode", ptr.Deref(provisioner.ExitCode, 0))
+		provisioner.Status = ptr.To(buildv1.ProvisionerStatusCompleted)
+	case action == buildv1.ExitCodeActionRetry && provisioner.Retried < ptr.Deref(provisioner.Retries, 1):
+		// The provisioner runs again with a new Job once the backoff elapsed.
+		r.Logger.Info("Retrying the provisioner", "provisionerID", provisionerID, "retried", provisioner.Retried)
+		provisioner.Retried++
+		provisioner.UUID = nil
+		provisioner.Status = ptr.To(buildv1.ProvisionerStatusPending)
+		provisioner.RetryTime = ptr.To(metav1.NewTime(time.Now().Add(provisioner.GetRetryBackoff())))
+	default:
+		provisioner.Status = ptr.To(buildv1.ProvisionerStatusFailed)
+	}
 
 	if err := r.patchHelper.Patch(ctx, build); err != nil {
 		r.Logger.Error(err, "failed to patch build")
@@ -157,6 +208,23 @@ func (r *ShellJobController) processFailedScanJob(ctx context.Context, job *batc
 	return r.deleteJob(ctx, job)
 }
 
+// setResult reports the result of the run in the status of the provisioner.
+func setResult(provisioner *buildv1.ProvisionerSpec, result *shell.Result) {
+	if result == nil {
+		provisioner.Result = nil
+		return
+	}
+	provisioner.Result = &buildv1.ProvisionerResult{
+		Stdout:    result.Stdout,
+		Stderr:    result.Stderr,
+		Outputs:   result.Outputs,
+		Artifacts: result.Artifacts,
+	}
+	if result.Duration > 0 {
+		provisioner.Result.Duration = &metav1.Duration{Duration: result.Duration}
+	}
+}
+
 func (r *ShellJobController) deleteJob(ctx context.Context, job *batchv1.Job) error {
 	err := r.Client.Delete(ctx, job, client.PropagationPolicy(metav1.DeletePropagationBackground))
 	if err != nil {
@@ -2,9 +2,12 @@ package job
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/forge-build/forge/pkg/kube"
+	"github.com/forge-build/forge/pkg/tracing"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -16,19 +19,34 @@ import (
 
 const (
 	containerName = "shell-provisioner"
+
+	credentialsVolumeName = "ssh-credentials"
 )
 
 type ShellJobBuilder struct {
 	uuid                     string
+	step                     string
 	name                     string
 	namespace                string
 	buildNamespace           string
 	scriptToRun              string
 	scriptToRunRef           string
 	sshCredentialsSecretName string
-
-	repo string
-	tag  string
+	mountSSHCredentials      bool
+	expectDisconnect         bool
+	reboot                   bool
+	verifyBootID             bool
+	reconnectTimeout         time.Duration
+	traceParent              string
+	otlpEndpoint             string
+	outputsFile              string
+	outputs                  map[string]string
+
+	repo             string
+	tag              string
+	image            string
+	imagePullPolicy  corev1.PullPolicy
+	imagePullSecrets []corev1.LocalObjectReference
 
 	ttl                      *time.Duration
 	timeout                  time.Duration
@@ -48,6 +66,12 @@ func (s *ShellJobBuilder) WithUUID(n string) *ShellJobBuilder {
 	return s
 }
 
+// WithStep sets the index of the provisioner in the Build, or cleanup for the cleanup stage.
+func (s *ShellJobBuilder) WithStep(step string) *ShellJobBuilder {
+	s.step = step
+	return s
+}
+
 func (s *ShellJobBuilder) WithBuildName(n string) *ShellJobBuilder {
 	s.name = n
 	return s
@@ -73,6 +97,57 @@ func (s *ShellJobBuilder) WithSSHCredentialsSecretName(name string) *ShellJobBui
 	return s
 }
 
+// WithMountedSSHCredentials mounts the ssh credentials Secret in the Job instead of reading it via the API,
+// the Secret must be in the namespace of the Job.
+func (s *ShellJobBuilder) WithMountedSSHCredentials(m bool) *ShellJobBuilder {
+	s.mountSSHCredentials = m
+	return s
+}
+
+func (s *ShellJobBuilder) WithExpectDisconnect(e bool) *ShellJobBuilder {
+	s.expectDisconnect = e
+	return s
+}
+
+func (s *ShellJobBuilder) WithReboot(r bool) *ShellJobBuilder {
+	s.reboot = r
+	return s
+}
+
+func (s *ShellJobBuilder) WithVerifyBootID(v bool) *ShellJobBuilder {
+	s.verifyBootID = v
+	return s
+}
+
+func (s *ShellJobBuilder) WithReconnectTimeout(timeout time.Duration) *ShellJobBuilder {
+	s.reconnectTimeout = timeout
+	return s
+}
+
+// WithTraceParent sets the trace context the provisioner continues.
+func (s *ShellJobBuilder) WithTraceParent(traceParent string) *ShellJobBuilder {
+	s.traceParent = traceParent
+	return s
+}
+
+// WithOTLPEndpoint sets the OTLP endpoint the provisioner exports its spans to.
+func (s *ShellJobBuilder) WithOTLPEndpoint(endpoint string) *ShellJobBuilder {
+	s.otlpEndpoint = endpoint
+	return s
+}
+
+// WithOutputsFile sets the file on the machine where the script writes its outputs.
+func (s *ShellJobBuilder) WithOutputsFile(path string) *ShellJobBuilder {
+	s.outputsFile = path
+	return s
+}
+
+// WithOutputs sets the outputs of the previous provisioners, exported to the script as environment variables.
+func (s *ShellJobBuilder) WithOutputs(outputs map[string]string) *ShellJobBuilder {
+	s.outputs = outputs
+	return s
+}
+
 func (s *ShellJobBuilder) WithRepo(r string) *ShellJobBuilder {
 	s.repo = r
 	return s
@@ -83,6 +158,41 @@ func (s *ShellJobBuilder) WithTag(t string) *ShellJobBuilder {
 	return s
 }
 
+// WithImage sets the image reference of the Job, it takes precedence over the repo and tag.
+func (s *ShellJobBuilder) WithImage(image string) *ShellJobBuilder {
+	s.image = image
+	return s
+}
+
+func (s *ShellJobBuilder) WithImagePullPolicy(policy corev1.PullPolicy) *ShellJobBuilder {
+	s.imagePullPolicy = policy
+	return s
+}
+
+func (s *ShellJobBuilder) WithImagePullSecrets(secrets []corev1.LocalObjectReference) *ShellJobBuilder {
+	s.imagePullSecrets = secrets
+	return s
+}
+
+// WithRuntimeConfig sets the image, resources, scheduling and security options of the Job.
+func (s *ShellJobBuilder) WithRuntimeConfig(config *buildv1.ProvisionerRuntimeConfig) *ShellJobBuilder {
+	if config == nil {
+		return s
+	}
+	s.WithImage(config.Image).
+		WithImagePullPolicy(config.ImagePullPolicy).
+		WithImagePullSecrets(config.ImagePullSecrets).
+		WithNodeSelector(config.NodeSelector).
+		WithTolerations(config.Tolerations).
+		WithPodPriorityClassName(config.PriorityClassName).
+		WithPodSecurityContext(config.PodSecurityContext).
+		WithSecurityContext(config.SecurityContext)
+	if config.Resources != nil {
+		s.WithResourceRequirements(*config.Resources)
+	}
+	return s
+}
+
 func (s *ShellJobBuilder) WithTimeout(timeout time.Duration) *ShellJobBuilder {
 	s.timeout = timeout
 	return s
@@ -156,10 +266,20 @@ func (s *ShellJobBuilder) Build() (*batchv1.Job, error) {
 		buildv1.ProvisionerIDLabel:  s.uuid,
 		buildv1.BuildNamespaceLabel: s.buildNamespace,
 	}
+	if s.step != "" {
+		jobLabels[buildv1.ProvisionerStepLabel] = s.step
+	}
 	podTemplateLabels := make(map[string]string)
 	for k, v := range jobLabels {
 		podTemplateLabels[k] = v
 	}
+	podTemplateAnnotations := make(map[string]string)
+	for k, v := range s.annotations {
+		podTemplateAnnotations[k] = v
+	}
+	if s.traceParent != "" {
+		podTemplateAnnotations[buildv1.TraceParentAnnotation] = s.traceParent
+	}
 
 	jobSpec := batchv1.JobSpec{
 		BackoffLimit:          ptr.To(s.backoffLimit), // number of retries before marking job as failed.
@@ -168,7 +288,7 @@ func (s *ShellJobBuilder) Build() (*batchv1.Job, error) {
 		Template: corev1.PodTemplateSpec{
 			ObjectMeta: metav1.ObjectMeta{
 				Labels:      podTemplateLabels,
-				Annotations: s.annotations,
+				Annotations: podTemplateAnnotations,
 			},
 			Spec: templateSpec,
 		},
@@ -188,7 +308,7 @@ func (s *ShellJobBuilder) Build() (*batchv1.Job, error) {
 		},
 		Spec: jobSpec,
 	}
-	job.SetName(GetShellJobName(s.name))
+	job.SetName(GetShellJobName(s.buildNamespace, s.name, s.step, s.uuid))
 
 	return job, nil
 }
@@ -208,42 +328,60 @@ func (s *ShellJobBuilder) getPodSpec() corev1.PodSpec {
 		},
 	})
 
+	if s.traceParent != "" {
+		env = append(env, corev1.EnvVar{Name: tracing.TraceParentEnv, Value: s.traceParent})
+	}
+	if s.otlpEndpoint != "" {
+		env = append(env, corev1.EnvVar{Name: tracing.EndpointEnv, Value: s.otlpEndpoint})
+	}
+	keys := make([]string, 0, len(s.outputs))
+	for key := range s.outputs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		env = append(env, corev1.EnvVar{Name: shell.OutputEnvName(key), Value: s.outputs[key]})
+	}
+
 	volumes := make([]corev1.Volume, 0)
 	volumeMounts := make([]corev1.VolumeMount, 0)
-	// TODO add volumes
-	//for _, secret := range s.pullSecrets {
-	//	name := fmt.Sprintf("%s-%s", pullSecretNamePrefix, secret)
-	//	mountPath := fmt.Sprintf("%s/%s", MountPathPrefix, secret)
-	//
-	//	volumes = append(volumes, corev1.Volume{
-	//		Name: name,
-	//		VolumeSource: corev1.VolumeSource{
-	//			Secret: &corev1.SecretVolumeSource{
-	//				SecretName: secret,
-	//			},
-	//		},
-	//	})
-	//
-	//	volumeMounts = append(volumeMounts, corev1.VolumeMount{
-	//		Name:      name,
-	//		ReadOnly:  true,
-	//		MountPath: mountPath,
-	//	})
-	//}
+	if s.mountSSHCredentials {
+		volumes = append(volumes, corev1.Volume{
+			Name: credentialsVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{SecretName: s.sshCredentialsSecretName},
+			},
+		})
+		volumeMounts = append(volumeMounts, corev1.VolumeMount{
+			Name:      credentialsVolumeName,
+			ReadOnly:  true,
+			MountPath: shell.CredentialsSecretPath,
+		})
+	}
 
 	args := s.getArgs()
 
+	imagePullPolicy := s.imagePullPolicy
+	if imagePullPolicy == "" {
+		imagePullPolicy = corev1.PullIfNotPresent
+	}
+	podSecurityContext := s.podSecurityContext
+	if podSecurityContext == nil {
+		podSecurityContext = &corev1.PodSecurityContext{}
+	}
+
 	containers = append(
 		containers,
 		corev1.Container{
 			Name:                     containerName,
 			Image:                    shelljobImageRef,
-			ImagePullPolicy:          corev1.PullIfNotPresent,
+			ImagePullPolicy:          imagePullPolicy,
 			TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
 			Env:                      env,
 			Args:                     args,
 			VolumeMounts:             volumeMounts,
 			Resources:                s.resourceRequirements,
+			SecurityContext:          s.containerSecurityContext,
 		},
 	)
 
@@ -253,7 +391,11 @@ func (s *ShellJobBuilder) getPodSpec() corev1.PodSpec {
 		Affinity:           LinuxNodeAffinity(),
 		RestartPolicy:      corev1.RestartPolicyNever,
 		Containers:         containers,
-		SecurityContext:    &corev1.PodSecurityContext{},
+		SecurityContext:    podSecurityContext,
+		ImagePullSecrets:   s.imagePullSecrets,
+		NodeSelector:       s.nodeSelector,
+		Tolerations:        s.tolerations,
+		PriorityClassName:  s.podPriorityClassName,
 	}
 }
 
@@ -265,30 +407,44 @@ func DurationSecondsPtr(d time.Duration) *int64 {
 }
 
 func (s *ShellJobBuilder) getArgs() []string {
+	args := []string{
+		"--namespace",
+		s.buildNamespace,
+	}
 	if s.scriptToRunRef != "" {
-		args := []string{
-			"--namespace",
-			s.buildNamespace,
-			"--run-script-ref",
-			s.scriptToRunRef,
-			"--ssh-credentials-secret-name",
-			s.sshCredentialsSecretName,
-		}
+		args = append(args, "--run-script-ref", s.scriptToRunRef)
+	} else {
+		args = append(args, "--run-script", s.scriptToRun)
+	}
+	if s.mountSSHCredentials {
+		args = append(args, "--ssh-credentials-path", shell.CredentialsSecretPath)
+	} else {
+		args = append(args, "--ssh-credentials-secret-name", s.sshCredentialsSecretName)
+	}
 
-		return args
+	if s.outputsFile != "" {
+		args = append(args, "--outputs-file", s.outputsFile)
 	}
-	return []string{
-		"--namespace",
-		s.buildNamespace,
-		"--run-script",
-		s.scriptToRun,
-		"--ssh-credentials-secret-name",
-		s.sshCredentialsSecretName,
+	if s.reboot {
+		args = append(args, "--reboot")
 	}
+	if s.expectDisconnect {
+		args = append(args, "--expect-disconnect")
+	}
+	if s.verifyBootID {
+		args = append(args, "--verify-boot-id")
+	}
+	if s.reconnectTimeout > 0 {
+		args = append(args, "--reconnect-timeout", s.reconnectTimeout.String())
+	}
+	return args
 }
 
-func GetShellJobName(buildName string) string {
-	return fmt.Sprintf("forge-provisioner-shell-%s", kube.ComputeHash(buildName))
+// GetShellJobName returns the name of the Job running a provisioner. The Jobs of all the Builds may run in
+// the same namespace, the name is unique across the namespaces of the Builds, the provisioners of a Build
+// and their attempts, since a provisioner gets a new UUID each time it runs.
+func GetShellJobName(buildNamespace, buildName, step, provisionerID string) string {
+	return fmt.Sprintf("forge-provisioner-shell-%s", kube.ComputeHash(strings.Join([]string{buildNamespace, buildName, step, provisionerID}, "/")))
 }
 
 //
@@ -308,8 +464,11 @@ func GetShellJobName(buildName string) string {
 //	return
 //}
 
-// GetImageRef returns upstream Trivy container image reference.
+// GetImageRef returns the image reference of the Job.
 func (s *ShellJobBuilder) GetImageRef() string {
+	if s.image != "" {
+		return s.image
+	}
 	return fmt.Sprintf("%s:%s", s.repo, s.tag)
 }
 
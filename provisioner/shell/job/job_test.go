@@ -0,0 +1,208 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package job
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/utils/ptr"
+
+	buildv1 "github.com/forge-build/forge/api/v1alpha1"
+	"github.com/forge-build/forge/pkg/tracing"
+	"github.com/forge-build/forge/provisioner/shell"
+)
+
+func TestShellJobBuilderArgs(t *testing.T) {
+	g := NewWithT(t)
+
+	job, err := NewShellJobBuilder().
+		WithBuildNamespace("default").
+		WithBuildName("ubuntu").
+		WithScriptToRun("apt-get upgrade -y").
+		WithSSHCredentialsSecretName("ubuntu-ssh-credentials").
+		Build()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(job.Spec.Template.Spec.Containers[0].Args).To(Equal([]string{
+		"--namespace", "default",
+		"--run-script", "apt-get upgrade -y",
+		"--ssh-credentials-secret-name", "ubuntu-ssh-credentials",
+	}))
+}
+
+func TestShellJobBuilderMountedSSHCredentials(t *testing.T) {
+	g := NewWithT(t)
+
+	job, err := NewShellJobBuilder().
+		WithNamespace("default").
+		WithBuildNamespace("default").
+		WithBuildName("ubuntu").
+		WithScriptToRun("apt-get upgrade -y").
+		WithSSHCredentialsSecretName("ubuntu-ssh-credentials").
+		WithMountedSSHCredentials(true).
+		Build()
+	g.Expect(err).NotTo(HaveOccurred())
+	podSpec := job.Spec.Template.Spec
+	g.Expect(podSpec.Containers[0].Args).To(Equal([]string{
+		"--namespace", "default",
+		"--run-script", "apt-get upgrade -y",
+		"--ssh-credentials-path", shell.CredentialsSecretPath,
+	}))
+	g.Expect(podSpec.Volumes).To(ConsistOf(corev1.Volume{
+		Name: credentialsVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{SecretName: "ubuntu-ssh-credentials"},
+		},
+	}))
+	g.Expect(podSpec.Containers[0].VolumeMounts).To(ConsistOf(corev1.VolumeMount{
+		Name:      credentialsVolumeName,
+		ReadOnly:  true,
+		MountPath: shell.CredentialsSecretPath,
+	}))
+}
+
+func TestShellJobBuilderRebootArgs(t *testing.T) {
+	g := NewWithT(t)
+
+	job, err := NewShellJobBuilder().
+		WithBuildNamespace("default").
+		WithBuildName("ubuntu").
+		WithScriptToRun("reboot").
+		WithSSHCredentialsSecretName("ubuntu-ssh-credentials").
+		WithReboot(true).
+		WithVerifyBootID(true).
+		WithReconnectTimeout(5 * time.Minute).
+		Build()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(job.Spec.Template.Spec.Containers[0].Args).To(Equal([]string{
+		"--namespace", "default",
+		"--run-script", "reboot",
+		"--ssh-credentials-secret-name", "ubuntu-ssh-credentials",
+		"--reboot",
+		"--verify-boot-id",
+		"--reconnect-timeout", "5m0s",
+	}))
+}
+
+func TestShellJobBuilderTracing(t *testing.T) {
+	g := NewWithT(t)
+
+	traceParent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	job, err := NewShellJobBuilder().
+		WithBuildNamespace("default").
+		WithBuildName("ubuntu").
+		WithScriptToRun("apt-get upgrade -y").
+		WithTraceParent(traceParent).
+		WithOTLPEndpoint("http://otel-collector:4317").
+		Build()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(job.Spec.Template.Annotations).To(HaveKeyWithValue(buildv1.TraceParentAnnotation, traceParent))
+	g.Expect(job.Spec.Template.Spec.Containers[0].Env).To(ContainElements(
+		corev1.EnvVar{Name: tracing.TraceParentEnv, Value: traceParent},
+		corev1.EnvVar{Name: tracing.EndpointEnv, Value: "http://otel-collector:4317"},
+	))
+
+	// The trace context is only propagated when there is one.
+	job, err = NewShellJobBuilder().WithScriptToRun("apt-get upgrade -y").Build()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(job.Spec.Template.Annotations).To(BeEmpty())
+	g.Expect(job.Spec.Template.Spec.Containers[0].Env).To(HaveLen(1))
+}
+
+func TestShellJobBuilderOutputs(t *testing.T) {
+	g := NewWithT(t)
+
+	job, err := NewShellJobBuilder().
+		WithBuildNamespace("default").
+		WithBuildName("ubuntu").
+		WithScriptToRun("apt-get upgrade -y").
+		WithSSHCredentialsSecretName("ubuntu-ssh-credentials").
+		WithOutputsFile(shell.OutputsFile).
+		WithOutputs(map[string]string{"kernel": "6.8.0-40-generic", "lockfile_sha": "8c1f"}).
+		Build()
+	g.Expect(err).NotTo(HaveOccurred())
+	container := job.Spec.Template.Spec.Containers[0]
+	g.Expect(container.Args).To(Equal([]string{
+		"--namespace", "default",
+		"--run-script", "apt-get upgrade -y",
+		"--ssh-credentials-secret-name", "ubuntu-ssh-credentials",
+		"--outputs-file", shell.OutputsFile,
+	}))
+	g.Expect(container.Env[1:]).To(Equal([]corev1.EnvVar{
+		{Name: "FORGE_OUTPUT_KERNEL", Value: "6.8.0-40-generic"},
+		{Name: "FORGE_OUTPUT_LOCKFILE_SHA", Value: "8c1f"},
+	}))
+}
+
+func TestShellJobBuilderRuntimeConfig(t *testing.T) {
+	g := NewWithT(t)
+
+	defaults := &buildv1.ProvisionerRuntimeConfig{
+		Image:            "registry.example.com/forge/forge-provisioner-shell:v0.1.0",
+		ImagePullSecrets: []corev1.LocalObjectReference{{Name: "registry"}},
+		NodeSelector:     map[string]string{"kubernetes.io/arch": "amd64"},
+	}
+	build := &buildv1.ProvisionerRuntimeConfig{
+		ImagePullPolicy:   corev1.PullAlways,
+		PriorityClassName: "builds",
+		Tolerations:       []corev1.Toleration{{Key: "builds", Operator: corev1.TolerationOpExists}},
+	}
+	provisioner := &buildv1.ProvisionerRuntimeConfig{
+		NodeSelector: map[string]string{"node-role": "builder"},
+		Resources: &corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("256Mi")},
+		},
+		PodSecurityContext: &corev1.PodSecurityContext{RunAsNonRoot: ptr.To(true)},
+		SecurityContext:    &corev1.SecurityContext{AllowPrivilegeEscalation: ptr.To(false)},
+	}
+
+	job, err := NewShellJobBuilder().
+		WithRepo("ghcr.io/forge-build/forge-provisioner-shell").
+		WithTag("latest").
+		WithScriptToRun("apt-get upgrade -y").
+		WithRuntimeConfig(defaults.Merge(build).Merge(provisioner)).
+		Build()
+	g.Expect(err).NotTo(HaveOccurred())
+
+	pod := job.Spec.Template.Spec
+	g.Expect(pod.ImagePullSecrets).To(Equal(defaults.ImagePullSecrets))
+	g.Expect(pod.NodeSelector).To(Equal(provisioner.NodeSelector))
+	g.Expect(pod.Tolerations).To(Equal(build.Tolerations))
+	g.Expect(pod.PriorityClassName).To(Equal("builds"))
+	g.Expect(pod.SecurityContext).To(Equal(provisioner.PodSecurityContext))
+	g.Expect(pod.Containers[0].Image).To(Equal(defaults.Image))
+	g.Expect(pod.Containers[0].ImagePullPolicy).To(Equal(corev1.PullAlways))
+	g.Expect(pod.Containers[0].Resources).To(Equal(*provisioner.Resources))
+	g.Expect(pod.Containers[0].SecurityContext).To(Equal(provisioner.SecurityContext))
+
+	// The overrides do not modify the defaults.
+	g.Expect(defaults.NodeSelector).To(Equal(map[string]string{"kubernetes.io/arch": "amd64"}))
+
+	// The repo and tag are used without a runtime config.
+	job, err = NewShellJobBuilder().
+		WithRepo("ghcr.io/forge-build/forge-provisioner-shell").
+		WithTag("latest").
+		WithScriptToRun("apt-get upgrade -y").
+		WithRuntimeConfig(nil).
+		Build()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(job.Spec.Template.Spec.Containers[0].Image).To(Equal("ghcr.io/forge-build/forge-provisioner-shell:latest"))
+	g.Expect(job.Spec.Template.Spec.Containers[0].ImagePullPolicy).To(Equal(corev1.PullIfNotPresent))
+}
@@ -0,0 +1,57 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package shell
+
+import (
+	"bufio"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var outputKeyRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// OutputEnvName returns the environment variable of the scripts with the output key.
+func OutputEnvName(key string) string {
+	return OutputEnvPrefix + strings.ToUpper(key)
+}
+
+// ParseOutputs parses the key=value lines written by a script to its outputs file.
+// The empty lines and the comments are ignored, a key written again overrides the previous value.
+func ParseOutputs(data string) (map[string]string, error) {
+	outputs := map[string]string{}
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, errors.Errorf("line %d of the outputs is not a key=value pair", n)
+		}
+		if !outputKeyRegexp.MatchString(key) {
+			return nil, errors.Errorf("output key %q on line %d must be made of letters, digits and underscores", key, n)
+		}
+		outputs[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "failed to read the outputs")
+	}
+	return outputs, nil
+}
@@ -0,0 +1,41 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package shell
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestParseOutputs(t *testing.T) {
+	g := NewWithT(t)
+
+	outputs, err := ParseOutputs("# written by the script\nkernel=6.8.0-40-generic\n\nlockfile_sha=8c1f=\nkernel=6.8.0-41-generic\n")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(outputs).To(Equal(map[string]string{"kernel": "6.8.0-41-generic", "lockfile_sha": "8c1f="}))
+
+	_, err = ParseOutputs("kernel")
+	g.Expect(err).To(MatchError(ContainSubstring("line 1")))
+	_, err = ParseOutputs("kernel=6.8.0\nkernel-version=6.8.0")
+	g.Expect(err).To(MatchError(ContainSubstring("line 2")))
+}
+
+func TestOutputEnvName(t *testing.T) {
+	g := NewWithT(t)
+	g.Expect(OutputEnvName("lockfile_sha")).To(Equal("FORGE_OUTPUT_LOCKFILE_SHA"))
+}
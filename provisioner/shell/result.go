@@ -0,0 +1,99 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package shell
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// TerminationMessagePath is the file the provisioner writes its Result to, Kubernetes reports
+	// its content as the termination message of the provisioner container.
+	TerminationMessagePath = "/dev/termination-log"
+
+	// maxTailLength is the length of the end of the output of the script kept in the Result.
+	maxTailLength = 1024
+	// maxMessageLength is the length of the end of the error kept in the Result.
+	maxMessageLength = 1024
+	// maxResultLength is the size limit of the termination messages.
+	maxResultLength = 4096
+)
+
+// Result is the result of a provisioner run, written as JSON to the termination message of its container.
+type Result struct {
+	// ExitCode is the exit code of the script, it is nil if the script did not exit,
+	// e.g. because the machine could not be reached.
+	ExitCode *int32 `json:"exitCode,omitempty"`
+	// Message is the error of a failed run.
+	Message string `json:"message,omitempty"`
+	// Duration is how long the run took.
+	Duration time.Duration `json:"duration,omitempty"`
+	// Stdout and Stderr are the end of the output of the script.
+	Stdout string `json:"stdout,omitempty"`
+	Stderr string `json:"stderr,omitempty"`
+	// Outputs are the key/value pairs emitted by the script.
+	Outputs map[string]string `json:"outputs,omitempty"`
+	// Artifacts are the files written by the provisioner.
+	Artifacts []string `json:"artifacts,omitempty"`
+}
+
+// WriteResult writes the result of the run to path. The end of the message and of the output is kept,
+// the output is dropped if the result does not fit in a termination message.
+func WriteResult(path string, result *Result) error {
+	r := *result
+	r.Message = tail(r.Message, maxMessageLength)
+	r.Stdout = tail(r.Stdout, maxTailLength)
+	r.Stderr = tail(r.Stderr, maxTailLength)
+
+	data, err := json.Marshal(r)
+	for _, drop := range []*string{&r.Stdout, &r.Stderr} {
+		if err != nil || len(data) <= maxResultLength {
+			break
+		}
+		*drop = ""
+		data, err = json.Marshal(r)
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal the result")
+	}
+	if len(data) > maxResultLength {
+		return errors.Errorf("the result is %d bytes, more than the %d bytes of a termination message", len(data), maxResultLength)
+	}
+	return errors.Wrapf(os.WriteFile(path, data, 0o644), "failed to write the result to %s", path)
+}
+
+// ParseResult parses the termination message of a provisioner container. It returns nil if the message
+// is not a Result, e.g. the logs of a provisioner which failed before writing it.
+func ParseResult(message string) *Result {
+	result := &Result{}
+	if err := json.Unmarshal([]byte(message), result); err != nil {
+		return nil
+	}
+	return result
+}
+
+// tail returns the end of s, prefixed with an ellipsis if it is longer than size.
+func tail(s string, size int) string {
+	if len(s) <= size {
+		return s
+	}
+	return "..." + s[len(s)-size:]
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"k8s.io/utils/ptr"
+)
+
+func TestWriteResult(t *testing.T) {
+	g := NewWithT(t)
+
+	path := filepath.Join(t.TempDir(), "termination-log")
+	g.Expect(WriteResult(path, &Result{ExitCode: ptr.To[int32](100), Message: "apt-get failed"})).To(Succeed())
+	data, err := os.ReadFile(path)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ParseResult(string(data))).To(Equal(&Result{ExitCode: ptr.To[int32](100), Message: "apt-get failed"}))
+
+	// The end of a long message is kept to fit in the termination message.
+	message := strings.Repeat("a", 2*maxMessageLength) + "the end"
+	g.Expect(WriteResult(path, &Result{Message: message})).To(Succeed())
+	data, err = os.ReadFile(path)
+	g.Expect(err).NotTo(HaveOccurred())
+	result := ParseResult(string(data))
+	g.Expect(result.Message).To(HaveSuffix("the end"))
+	g.Expect(len(result.Message)).To(BeNumerically("<=", maxMessageLength+3))
+}
+
+func TestParseResult(t *testing.T) {
+	g := NewWithT(t)
+
+	result := ParseResult(`{"exitCode":100,"message":"apt-get failed"}`)
+	g.Expect(result).To(Equal(&Result{ExitCode: ptr.To[int32](100), Message: "apt-get failed"}))
+
+	// The logs of a provisioner which failed before writing its result.
+	g.Expect(ParseResult("E0101 00:00:00.000000 1 main.go:1] Error getting secret")).To(BeNil())
+}
+
+func TestWriteResultOutputs(t *testing.T) {
+	g := NewWithT(t)
+
+	path := filepath.Join(t.TempDir(), "termination-log")
+	result := &Result{
+		ExitCode:  ptr.To[int32](0),
+		Stdout:    strings.Repeat("o", 2*maxTailLength),
+		Stderr:    strings.Repeat("e", maxTailLength),
+		Outputs:   map[string]string{"kernel": "6.8.0-40-generic", "lockfile": strings.Repeat("f", 2000)},
+		Artifacts: []string{"/var/lib/forge/exports/ubuntu.tar.gz"},
+	}
+
+	// The output is dropped to keep the outputs in the termination message.
+	g.Expect(WriteResult(path, result)).To(Succeed())
+	data, err := os.ReadFile(path)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(len(data)).To(BeNumerically("<=", maxResultLength))
+	written := ParseResult(string(data))
+	g.Expect(written.Stdout).To(BeEmpty())
+	g.Expect(written.Stderr).To(Equal(result.Stderr))
+	g.Expect(written.Outputs).To(Equal(result.Outputs))
+	g.Expect(written.Artifacts).To(Equal(result.Artifacts))
+	g.Expect(result.Stdout).To(HaveLen(2 * maxTailLength))
+
+	result.Outputs["lockfile"] = strings.Repeat("f", maxResultLength)
+	g.Expect(WriteResult(path, result)).NotTo(Succeed())
+}
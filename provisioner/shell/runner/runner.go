@@ -0,0 +1,183 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package runner runs the shell provisioner scripts on a machine over SSH.
+// It is shared by the shell provisioner Job and forgectl local runs.
+package runner
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+
+	"github.com/forge-build/forge/internal/validation"
+	"github.com/forge-build/forge/pkg/ssh"
+	"github.com/forge-build/forge/provisioner/shell"
+)
+
+const (
+	// DefaultReconnectTimeout is how long to wait for a machine to go down and come back after a disconnect.
+	DefaultReconnectTimeout = 10 * time.Minute
+)
+
+// Options configures a script run.
+type Options struct {
+	// Script is the script to run.
+	Script string
+	// ExpectDisconnect allows the script to drop the ssh connection.
+	ExpectDisconnect bool
+	// Reboot requires the script to drop the ssh connection by rebooting the machine.
+	Reboot bool
+	// VerifyBootID checks that the machine boot ID changed after a disconnect.
+	VerifyBootID bool
+	// ReconnectTimeout is how long to wait for the machine to go down and come back.
+	ReconnectTimeout time.Duration
+	// Stdout and Stderr receive the output of the script.
+	Stdout io.Writer
+	Stderr io.Writer
+	// Env are the environment variables exported to the script, e.g. the outputs of the previous provisioners.
+	Env map[string]string
+	// OutputsFile is the file on the machine where the script writes its outputs, see ReadOutputs.
+	OutputsFile string
+}
+
+// Run runs a script on a connected machine. When the script is expected to drop the
+// connection, e.g. because it reboots the machine, it waits for the machine to come back.
+func Run(logger logr.Logger, c ssh.Client, opts Options) error {
+	if opts.Script == "" {
+		return errors.New("script to run is empty")
+	}
+	if opts.ReconnectTimeout == 0 {
+		opts.ReconnectTimeout = DefaultReconnectTimeout
+	}
+	if opts.Stdout == nil {
+		opts.Stdout = io.Discard
+	}
+	if opts.Stderr == nil {
+		opts.Stderr = io.Discard
+	}
+
+	var (
+		bootID string
+		err    error
+	)
+	if opts.VerifyBootID {
+		bootID, err = ssh.GetBootID(c)
+		if err != nil {
+			return err
+		}
+		logger.Info("Got the machine boot ID", "bootID", bootID)
+	}
+
+	logger.Info("Running the script")
+	disconnected := false
+	if err := c.Run(script(opts), opts.Stdout, opts.Stderr); err != nil {
+		if !((opts.ExpectDisconnect || opts.Reboot) && ssh.IsDisconnectError(err)) {
+			return errors.Wrap(err, "failed to run script")
+		}
+		logger.Info("The script dropped the ssh connection as expected")
+		disconnected = true
+	}
+
+	if !disconnected && !opts.Reboot {
+		return nil
+	}
+
+	return waitForReconnect(logger, c, opts, bootID, disconnected)
+}
+
+// script returns the script prefixed with the exports of its environment, the outputs file is emptied.
+func script(opts Options) string {
+	if len(opts.Env) == 0 && opts.OutputsFile == "" {
+		return opts.Script
+	}
+	names := make([]string, 0, len(opts.Env))
+	for name := range opts.Env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&sb, "export %s=%s\n", name, validation.Quote(opts.Env[name]))
+	}
+	if opts.OutputsFile != "" {
+		fmt.Fprintf(&sb, "export %s=%s\n", shell.OutputsEnv, validation.Quote(opts.OutputsFile))
+		fmt.Fprintf(&sb, ": > \"$%s\"\n", shell.OutputsEnv)
+	}
+	sb.WriteString(opts.Script)
+	return sb.String()
+}
+
+// ReadOutputs downloads and removes the outputs file written by a script, and parses the outputs.
+func ReadOutputs(c ssh.Client, path string) (map[string]string, error) {
+	data := &bytes.Buffer{}
+	if err := c.Download(nopCloser{data}, path); err != nil {
+		return nil, errors.Wrap(err, "failed to download the outputs")
+	}
+	if err := c.Run("rm -f "+validation.Quote(path), io.Discard, io.Discard); err != nil {
+		return nil, errors.Wrap(err, "failed to remove the outputs file")
+	}
+	return shell.ParseOutputs(data.String())
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+// waitForReconnect waits for the machine to go down, if it is not already, and to come back.
+func waitForReconnect(logger logr.Logger, c ssh.Client, opts Options, bootID string, disconnected bool) error {
+	start := time.Now()
+	if !disconnected {
+		logger.Info("Waiting for the machine to go down")
+		if err := c.WaitForDisconnect(opts.ReconnectTimeout); err != nil {
+			return errors.Wrap(err, "machine did not go down after the reboot")
+		}
+	}
+
+	logger.Info("Waiting for the machine to come back")
+	remaining := opts.ReconnectTimeout - time.Since(start)
+	if remaining <= 0 {
+		return errors.Wrap(ssh.ErrTimeout, "failed to reconnect to the machine via ssh")
+	}
+	if err := c.WaitForSSH(remaining); err != nil {
+		return errors.Wrap(err, "failed to reconnect to the machine via ssh")
+	}
+	logger.Info("SSH connection re-established")
+
+	if opts.VerifyBootID {
+		newBootID, err := ssh.GetBootID(c)
+		if err != nil {
+			return err
+		}
+		if newBootID == bootID {
+			return errors.Errorf("machine boot ID %s did not change, the machine did not reboot", bootID)
+		}
+		logger.Info("The machine rebooted", "bootID", newBootID)
+	}
+
+	return nil
+}
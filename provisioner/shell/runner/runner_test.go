@@ -0,0 +1,206 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runner
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/gomega"
+
+	"github.com/forge-build/forge/pkg/ssh"
+	"github.com/forge-build/forge/pkg/ssh/sshtest"
+)
+
+func TestRun(t *testing.T) {
+	g := NewWithT(t)
+
+	c := &ssh.MockSSHClient{
+		MockRun: func(command string, stdout io.Writer, _ io.Writer) error {
+			_, err := io.WriteString(stdout, command)
+			return err
+		},
+	}
+	out := &bytes.Buffer{}
+	g.Expect(Run(logr.Discard(), c, Options{Script: "echo hello", Stdout: out})).To(Succeed())
+	g.Expect(out.String()).To(Equal("echo hello"))
+
+	g.Expect(Run(logr.Discard(), c, Options{})).ToNot(Succeed())
+
+	c.MockRun = func(string, io.Writer, io.Writer) error {
+		return errors.New("exit status 1")
+	}
+	g.Expect(Run(logr.Discard(), c, Options{Script: "false"})).ToNot(Succeed())
+}
+
+func TestRunExpectDisconnect(t *testing.T) {
+	g := NewWithT(t)
+
+	c := &ssh.MockSSHClient{
+		MockRun: func(string, io.Writer, io.Writer) error {
+			return io.EOF
+		},
+		MockWaitForSSH: func(time.Duration) error {
+			return nil
+		},
+	}
+
+	// A dropped connection fails the script unless it is expected.
+	g.Expect(Run(logr.Discard(), c, Options{Script: "reboot"})).ToNot(Succeed())
+	g.Expect(Run(logr.Discard(), c, Options{Script: "reboot", ExpectDisconnect: true})).To(Succeed())
+
+	c.MockWaitForSSH = func(time.Duration) error {
+		return ssh.ErrTimeout
+	}
+	g.Expect(Run(logr.Discard(), c, Options{Script: "reboot", ExpectDisconnect: true})).ToNot(Succeed())
+}
+
+func TestRunReboot(t *testing.T) {
+	g := NewWithT(t)
+
+	bootIDs := []string{"boot-1", "boot-2"}
+	disconnected := false
+	c := &ssh.MockSSHClient{
+		MockRun: func(command string, stdout io.Writer, _ io.Writer) error {
+			if command == "cat /proc/sys/kernel/random/boot_id" {
+				_, err := io.WriteString(stdout, bootIDs[0])
+				bootIDs = bootIDs[1:]
+				return err
+			}
+			return nil
+		},
+		MockWaitForDisconnect: func(time.Duration) error {
+			disconnected = true
+			return nil
+		},
+		MockWaitForSSH: func(time.Duration) error {
+			return nil
+		},
+	}
+
+	g.Expect(Run(logr.Discard(), c, Options{Script: "shutdown -r now", Reboot: true, VerifyBootID: true})).To(Succeed())
+	g.Expect(disconnected).To(BeTrue())
+
+	// The machine did not reboot if its boot ID did not change.
+	bootIDs = []string{"boot-1", "boot-1"}
+	g.Expect(Run(logr.Discard(), c, Options{Script: "shutdown -r now", Reboot: true, VerifyBootID: true})).ToNot(Succeed())
+}
+
+func TestRunRebootTimeout(t *testing.T) {
+	g := NewWithT(t)
+
+	waitedForSSH := false
+	c := &ssh.MockSSHClient{
+		MockRun: func(string, io.Writer, io.Writer) error {
+			return nil
+		},
+		MockWaitForDisconnect: func(maxWait time.Duration) error {
+			time.Sleep(maxWait)
+			return nil
+		},
+		MockWaitForSSH: func(time.Duration) error {
+			waitedForSSH = true
+			return nil
+		},
+	}
+
+	// No time is left to wait for the machine once it went down.
+	err := Run(logr.Discard(), c, Options{Script: "shutdown -r now", Reboot: true, ReconnectTimeout: 10 * time.Millisecond})
+	g.Expect(err).To(MatchError(ssh.ErrTimeout))
+	g.Expect(waitedForSSH).To(BeFalse())
+}
+
+func TestRunRebootServer(t *testing.T) {
+	g := NewWithT(t)
+
+	// The script drops the connection, and the machine comes back with a new boot ID.
+	boots := 0
+	srv := sshtest.NewServer(t, sshtest.WithPassword("forge", "forge"), sshtest.WithHandler(func(e *sshtest.Exec) int {
+		switch e.Command {
+		case "cat /proc/sys/kernel/random/boot_id":
+			fmt.Fprintf(e.Stdout, "boot-%d\n", boots)
+			return 0
+		default:
+			boots++
+			return sshtest.Disconnect
+		}
+	}))
+	c, err := ssh.NewSSHClient(srv.Secret("forge"))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(c.Connect()).To(Succeed())
+	defer c.Disconnect()
+
+	g.Expect(Run(logr.Discard(), c, Options{Script: "reboot", ExpectDisconnect: true, VerifyBootID: true, ReconnectTimeout: 10 * time.Second})).To(Succeed())
+	g.Expect(boots).To(Equal(1))
+
+	g.Expect(Run(logr.Discard(), c, Options{Script: "reboot"})).ToNot(Succeed())
+}
+
+func TestRunEnv(t *testing.T) {
+	g := NewWithT(t)
+
+	var script string
+	c := &ssh.MockSSHClient{
+		MockRun: func(command string, _ io.Writer, _ io.Writer) error {
+			script = command
+			return nil
+		},
+	}
+	g.Expect(Run(logr.Discard(), c, Options{
+		Script:      "uname -r",
+		Env:         map[string]string{"FORGE_OUTPUT_KERNEL": "6.8.0", "FORGE_OUTPUT_LOCKFILE": "it's"},
+		OutputsFile: ".forge-outputs",
+	})).To(Succeed())
+	g.Expect(script).To(Equal(`export FORGE_OUTPUT_KERNEL='6.8.0'
+export FORGE_OUTPUT_LOCKFILE='it'\''s'
+export FORGE_OUTPUTS='.forge-outputs'
+: > "$FORGE_OUTPUTS"
+uname -r`))
+}
+
+func TestReadOutputs(t *testing.T) {
+	g := NewWithT(t)
+
+	var commands []string
+	c := &ssh.MockSSHClient{
+		MockDownload: func(dst io.WriteCloser, path string) error {
+			g.Expect(path).To(Equal(".forge-outputs"))
+			_, err := io.WriteString(dst, "kernel=6.8.0-40-generic\n")
+			return err
+		},
+		MockRun: func(command string, _ io.Writer, _ io.Writer) error {
+			commands = append(commands, command)
+			return nil
+		},
+	}
+	outputs, err := ReadOutputs(c, ".forge-outputs")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(outputs).To(Equal(map[string]string{"kernel": "6.8.0-40-generic"}))
+	// The outputs file is not left in the image.
+	g.Expect(commands).To(Equal([]string{"rm -f '.forge-outputs'"}))
+
+	c.MockDownload = func(io.WriteCloser, string) error {
+		return errors.New("scp: .forge-outputs: No such file or directory")
+	}
+	_, err = ReadOutputs(c, ".forge-outputs")
+	g.Expect(err).To(HaveOccurred())
+}
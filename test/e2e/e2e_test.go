@@ -118,5 +118,9 @@ var _ = Describe("controller", Ordered, func() {
 			EventuallyWithOffset(1, verifyControllerUp, time.Minute, time.Second).Should(Succeed())
 
 		})
+
+		It("should run a Build on a StaticMachine", func() {
+			runStaticMachineBuild()
+		})
 	})
 })
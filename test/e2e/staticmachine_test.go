@@ -0,0 +1,190 @@
+/*
+Copyright 2024 Forge.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package e2e
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os/exec"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/forge-build/forge/pkg/ssh"
+	"github.com/forge-build/forge/test/utils"
+)
+
+const (
+	staticNamespace = "forge-e2e-static"
+
+	// shellProvisionerImage is the image of the shell provisioner run by the export Jobs.
+	shellProvisionerImage = "ghcr.io/forge-build/forge-provisioner-shell:latest"
+	// legacyShellProvisionerImage is the image of the shell provisioner Jobs, hard-coded in the Build controller.
+	legacyShellProvisionerImage = "medchiheb/forge-shell-provisioner:dev"
+)
+
+// staticMachineManifest is an sshd container standing for an existing machine,
+// with a StaticMachine pointing at it and a Build using the StaticMachine.
+const staticMachineManifest = `
+apiVersion: v1
+kind: Secret
+metadata:
+  name: sshd-credentials
+  namespace: %[1]s
+data:
+  username: %[2]s
+  privateKey: %[3]s
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: sshd
+  namespace: %[1]s
+spec:
+  selector:
+    matchLabels:
+      app: sshd
+  template:
+    metadata:
+      labels:
+        app: sshd
+    spec:
+      containers:
+      - name: sshd
+        image: lscr.io/linuxserver/openssh-server:latest
+        env:
+        - name: USER_NAME
+          value: forge
+        - name: PUBLIC_KEY
+          value: %[4]q
+        - name: SUDO_ACCESS
+          value: "true"
+        ports:
+        - containerPort: 2222
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: sshd
+  namespace: %[1]s
+spec:
+  selector:
+    app: sshd
+  ports:
+  - port: 2222
+---
+apiVersion: v1
+kind: PersistentVolumeClaim
+metadata:
+  name: images
+  namespace: %[1]s
+spec:
+  accessModes:
+  - ReadWriteOnce
+  resources:
+    requests:
+      storage: 2Gi
+---
+apiVersion: infrastructure.forge.build/v1alpha1
+kind: StaticMachine
+metadata:
+  name: sshd
+  namespace: %[1]s
+spec:
+  host: sshd.%[1]s.svc.cluster.local
+  port: 2222
+  credentialsRef:
+    name: sshd-credentials
+  export:
+    persistentVolumeClaim: images
+    excludes:
+    - /config/logs
+---
+apiVersion: forge.build/v1alpha1
+kind: Build
+metadata:
+  name: static
+  namespace: %[1]s
+spec:
+  connector:
+    type: ssh
+  infrastructureRef:
+    apiVersion: infrastructure.forge.build/v1alpha1
+    kind: StaticMachine
+    name: sshd
+  provisioners:
+  - type: built-in/shell
+    run: echo forge > /tmp/forge-e2e
+  cleanup:
+    disabled: true
+`
+
+// runStaticMachineBuild runs a Build against an sshd container through the StaticMachine provider,
+// the controller-manager must be deployed.
+func runStaticMachineBuild() {
+	By("building the shell provisioner image")
+	cmd := exec.Command("make", "docker-build-shell-provisioner", "TAG=latest")
+	_, err := utils.Run(cmd)
+	ExpectWithOffset(1, err).NotTo(HaveOccurred())
+	cmd = exec.Command("docker", "tag", shellProvisionerImage, legacyShellProvisionerImage)
+	_, err = utils.Run(cmd)
+	ExpectWithOffset(1, err).NotTo(HaveOccurred())
+
+	By("loading the shell provisioner image on Kind")
+	for _, image := range []string{shellProvisionerImage, legacyShellProvisionerImage} {
+		ExpectWithOffset(1, utils.LoadImageToKindClusterWithName(image)).To(Succeed())
+	}
+
+	By("creating the sshd container, the StaticMachine and the Build")
+	keyPair, err := ssh.NewKeyPair()
+	ExpectWithOffset(1, err).NotTo(HaveOccurred())
+	cmd = exec.Command("kubectl", "create", "ns", staticNamespace)
+	_, _ = utils.Run(cmd)
+	manifest := fmt.Sprintf(staticMachineManifest, staticNamespace,
+		base64.StdEncoding.EncodeToString([]byte("forge")),
+		base64.StdEncoding.EncodeToString(keyPair.PrivateKey),
+		string(keyPair.PublicKey),
+	)
+	ExpectWithOffset(1, utils.ApplyManifest(manifest)).To(Succeed())
+
+	By("waiting for the Build to be completed")
+	verifyBuildCompleted := func() error {
+		cmd := exec.Command("kubectl", "get", "build", "static", "-n", staticNamespace,
+			"-o", "jsonpath={.status.phase}")
+		phase, err := utils.Run(cmd)
+		if err != nil {
+			return err
+		}
+		if string(phase) != "Completed" {
+			return fmt.Errorf("build in %s phase", phase)
+		}
+		return nil
+	}
+	EventuallyWithOffset(1, verifyBuildCompleted, 10*time.Minute, 5*time.Second).Should(Succeed())
+
+	By("validating that the root filesystem has been exported")
+	cmd = exec.Command("kubectl", "get", "staticmachine", "sshd", "-n", staticNamespace,
+		"-o", "jsonpath={.status.artifact}")
+	artifact, err := utils.Run(cmd)
+	ExpectWithOffset(1, err).NotTo(HaveOccurred())
+	ExpectWithOffset(1, string(artifact)).To(Equal("pvc://images/sshd.tar.gz"))
+
+	By("removing the StaticMachine namespace")
+	cmd = exec.Command("kubectl", "delete", "ns", staticNamespace)
+	_, _ = utils.Run(cmd)
+}
@@ -3,6 +3,7 @@ package util
 import (
 	"context"
 	"fmt"
+	"strconv"
 
 	"sigs.k8s.io/cluster-api/util/record"
 
@@ -18,6 +19,7 @@ import (
 
 type SSHCredentials struct {
 	Host       string
+	Port       int
 	Username   string
 	Password   string
 	PrivateKey string
@@ -59,6 +61,9 @@ func EnsureCredentialsSecret(ctx context.Context, client client.Client, build *b
 		},
 	}
 
+	if creds.Port != 0 {
+		credentials.StringData["port"] = strconv.Itoa(creds.Port)
+	}
 	if creds.Password != "" {
 		credentials.StringData["password"] = creds.Password
 	}
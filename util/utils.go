@@ -154,15 +154,38 @@ func GetBuildByName(ctx context.Context, c client.Client, namespace, name string
 	return build, nil
 }
 
+// GetProvisionerByID returns the provisioner of the Build with the given ID,
+// including the built-in provisioner running the cleanup stage.
 func GetProvisionerByID(build *buildv1.Build, id string) (*buildv1.ProvisionerSpec, error) {
 	for i := range build.Spec.Provisioners {
 		if ptr.Deref(build.Spec.Provisioners[i].UUID, "") == id {
 			return &build.Spec.Provisioners[i], nil
 		}
 	}
+	if build.Status.Cleanup != nil && ptr.Deref(build.Status.Cleanup.UUID, "") == id {
+		return build.Status.Cleanup, nil
+	}
 	return &buildv1.ProvisionerSpec{}, errors.Errorf("provisioner with ID %q not found in Build %q", id, build.Name)
 }
 
+// GetProvisionerOutputs returns the outputs of the completed provisioners of the Build,
+// the outputs of a provisioner override the ones of the previous provisioners with the same key.
+func GetProvisionerOutputs(build *buildv1.Build) map[string]string {
+	var outputs map[string]string
+	for _, p := range build.Spec.Provisioners {
+		if ptr.Deref(p.Status, "") != buildv1.ProvisionerStatusCompleted || p.Result == nil {
+			continue
+		}
+		for key, value := range p.Result.Outputs {
+			if outputs == nil {
+				outputs = map[string]string{}
+			}
+			outputs[key] = value
+		}
+	}
+	return outputs
+}
+
 // GetSecretFromSecretReference returns the secret data from the secret reference.
 func GetSecretFromSecretReference(ctx context.Context, c client.Client, secretRef corev1.SecretReference) (*corev1.Secret, error) {
 	secret := &corev1.Secret{}